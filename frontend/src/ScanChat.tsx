@@ -0,0 +1,157 @@
+import { useState, useEffect, useRef } from 'react';
+import {
+    Box, Button, Textarea, VStack, HStack, Heading, Text, useToast,
+    Card, CardHeader, CardBody, Flex, Badge, IconButton, Tooltip
+} from '@chakra-ui/react';
+import { MessageSquare, Send, Trash2 } from 'lucide-react';
+import ReactMarkdown from 'react-markdown';
+import rehypeHighlight from 'rehype-highlight';
+import { getScanChat, sendScanChatMessage, clearScanChat, type ChatMessage } from './api';
+
+interface ScanChatProps {
+    scanId: string;
+    language: string;
+    sessionId: string;
+}
+
+const SUGGESTIONS = {
+    en: ["Why is the top finding exploitable?", "Show the reachability trace for the SQL injection", "Give me a Go version of this fix"],
+    ko: ["가장 위험한 취약점은 왜 악용 가능한가요?", "SQL Injection의 도달 경로를 보여주세요", "이 수정안의 Go 버전을 주세요"],
+};
+
+export default function ScanChat({ scanId, language, sessionId }: ScanChatProps) {
+    const [messages, setMessages] = useState<ChatMessage[]>([]);
+    const [input, setInput] = useState('');
+    const [sending, setSending] = useState(false);
+    const bottomRef = useRef<HTMLDivElement | null>(null);
+    const toast = useToast();
+
+    // Restore the persisted conversation of this scan
+    useEffect(() => {
+        getScanChat(scanId)
+            .then(setMessages)
+            .catch((e) => console.warn("Failed to load chat:", e));
+    }, [scanId]);
+
+    useEffect(() => {
+        bottomRef.current?.scrollIntoView({ behavior: 'smooth' });
+    }, [messages, sending]);
+
+    const handleSend = async (text: string = input) => {
+        const message = text.trim();
+        if (!message || sending) return;
+
+        setInput('');
+        setSending(true);
+        setMessages((prev) => [...prev, { role: 'user', content: message }]);
+        try {
+            const turn = await sendScanChatMessage(scanId, message, sessionId);
+            // Replace the optimistic user message with the persisted turn
+            setMessages((prev) => [...prev.slice(0, -1), ...turn]);
+        } catch (error) {
+            setMessages((prev) => prev.slice(0, -1));
+            setInput(message);
+            toast({ title: 'Chat Failed', description: String(error), status: 'error' });
+        } finally {
+            setSending(false);
+        }
+    };
+
+    const handleClear = async () => {
+        try {
+            await clearScanChat(scanId, sessionId);
+            setMessages([]);
+        } catch (error) {
+            toast({ title: 'Failed to clear chat', description: String(error), status: 'error' });
+        }
+    };
+
+    return (
+        <Card bg="gray.800" borderTop="4px solid" borderColor="blue.400">
+            <CardHeader>
+                <Flex justify="space-between" align="center">
+                    <Heading size="md" display="flex" alignItems="center" gap={2}>
+                        <MessageSquare className="text-blue-400" />
+                        {language === 'ko' ? '스캔 결과에 질문하기' : 'Ask About This Scan'}
+                    </Heading>
+                    {messages.length > 0 && (
+                        <Tooltip label={language === 'ko' ? '대화 지우기' : 'Clear conversation'}>
+                            <IconButton aria-label="Clear conversation" icon={<Trash2 size={16} />} size="sm" variant="ghost" onClick={handleClear} />
+                        </Tooltip>
+                    )}
+                </Flex>
+            </CardHeader>
+            <CardBody>
+                <VStack spacing={3} align="stretch" maxHeight="500px" overflowY="auto" mb={4}>
+                    {messages.length === 0 && (
+                        <HStack wrap="wrap" spacing={2}>
+                            {SUGGESTIONS[language === 'ko' ? 'ko' : 'en'].map((s) => (
+                                <Button key={s} size="xs" variant="outline" colorScheme="blue" onClick={() => handleSend(s)}>
+                                    {s}
+                                </Button>
+                            ))}
+                        </HStack>
+                    )}
+                    {messages.map((m, i) => (
+                        <Box
+                            key={i}
+                            alignSelf={m.role === 'user' ? 'flex-end' : 'flex-start'}
+                            maxW="90%"
+                            bg={m.role === 'user' ? 'blue.900' : 'gray.900'}
+                            px={4} py={2} borderRadius="md" fontSize="sm"
+                            sx={{
+                                'pre': { bg: 'gray.800', p: 3, borderRadius: 'md', overflowX: 'auto', my: 2 },
+                                'code': { fontFamily: 'monospace' },
+                                'ul, ol': { pl: 5 },
+                            }}
+                        >
+                            {m.role === 'assistant' ? (
+                                <ReactMarkdown rehypePlugins={[rehypeHighlight]}>{m.content}</ReactMarkdown>
+                            ) : (
+                                <Text whiteSpace="pre-wrap">{m.content}</Text>
+                            )}
+                            {m.grounding && (m.grounding.code.length > 0 || m.grounding.rag > 0) && (
+                                <HStack mt={2} spacing={2} wrap="wrap">
+                                    {m.grounding.code.map((c) => <Badge key={c} colorScheme="purple" fontSize="0.65em">{c}</Badge>)}
+                                    {m.grounding.rag > 0 && <Badge colorScheme="green" fontSize="0.65em">{m.grounding.rag} past cases</Badge>}
+                                </HStack>
+                            )}
+                        </Box>
+                    ))}
+                    {sending && (
+                        <Text fontSize="sm" color="gray.400">
+                            {language === 'ko' ? 'RedEye가 답변을 작성 중입니다...' : 'RedEye is thinking...'}
+                        </Text>
+                    )}
+                    <div ref={bottomRef} />
+                </VStack>
+                <Flex gap={2}>
+                    <Textarea
+                        placeholder={language === 'ko' ? '예: 42번째 줄이 왜 취약한가요?' : 'e.g. Why is line 42 vulnerable?'}
+                        value={input}
+                        onChange={(e) => setInput(e.target.value)}
+                        onKeyDown={(e) => {
+                            if (e.key === 'Enter' && !e.shiftKey) {
+                                e.preventDefault();
+                                handleSend();
+                            }
+                        }}
+                        bg="gray.900"
+                        border="none"
+                        rows={2}
+                        resize="none"
+                    />
+                    <IconButton
+                        aria-label="Send"
+                        icon={<Send size={18} />}
+                        colorScheme="blue"
+                        onClick={() => handleSend()}
+                        isLoading={sending}
+                        alignSelf="stretch"
+                        height="auto"
+                    />
+                </Flex>
+            </CardBody>
+        </Card>
+    );
+}
@@ -11,6 +11,8 @@ import ReactMarkdown from 'react-markdown';
 import rehypeHighlight from 'rehype-highlight';
 import 'highlight.js/styles/github-dark.css';
 import { startScan, getScanStatus, getUserRepos, getCurrentUser, logout, type ScanResponse, type GitHubRepo, type GitHubUser } from './api';
+import ScanChat from './ScanChat';
+import SessionsModal from './SessionsModal';
 
 
 
@@ -27,6 +29,7 @@ export default function ScanPage() {
     const [githubUser, setGithubUser] = useState<GitHubUser | null>(null);
     const [repos, setRepos] = useState<GitHubRepo[]>([]);
     const { isOpen, onOpen, onClose } = useDisclosure();
+    const sessionsModal = useDisclosure();
 
     const toast = useToast();
 
@@ -81,13 +84,17 @@ export default function ScanPage() {
         }
     };
 
+    const clearSession = () => {
+        localStorage.removeItem('redeye_session_id');
+        setSessionId(null);
+        setGithubUser(null);
+    };
+
     const handleLogout = async () => {
         if (sessionId) {
             try { await logout(sessionId); } catch (e) { console.warn(e); }
         }
-        localStorage.removeItem('redeye_session_id');
-        setSessionId(null);
-        setGithubUser(null);
+        clearSession();
         toast({ title: 'Logged out', status: 'info' });
     };
 
@@ -110,7 +117,7 @@ export default function ScanPage() {
 
         try {
             // 1. Start Scan (Get ID)
-            const initialRes = await startScan(url, language);
+            const initialRes = await startScan(url, language, sessionId);
             const scanId = initialRes.scan_id;
             console.log("Scan Started:", scanId);
 
@@ -244,6 +251,13 @@ export default function ScanPage() {
                                         >
                                             {githubUser ? `${githubUser.github_user}'s Repos` : 'Select from GitHub'}
                                         </Button>
+                                        <Button
+                                            size="md"
+                                            variant="ghost"
+                                            onClick={sessionsModal.onOpen}
+                                        >
+                                            Sessions
+                                        </Button>
                                         <Button
                                             size="md"
                                             colorScheme="red"
@@ -295,6 +309,19 @@ export default function ScanPage() {
                     </ModalContent>
                 </Modal>
 
+                {/* Login Sessions Modal */}
+                {sessionId && (
+                    <SessionsModal
+                        sessionId={sessionId}
+                        isOpen={sessionsModal.isOpen}
+                        onClose={sessionsModal.onClose}
+                        onSignedOut={() => {
+                            clearSession();
+                            toast({ title: 'Logged out', status: 'info' });
+                        }}
+                    />
+                )}
+
                 {result && (
                     <VStack spacing={6} align="stretch" animation="fadeIn 0.5s">
                         {/* 1. Security Score Dashboard */}
@@ -399,6 +426,9 @@ export default function ScanPage() {
                                 </Box>
                             </CardBody>
                         </Card>
+
+                        {/* 3. Follow-up Q&A grounded in the scan (owner only: the agent's tools run code) */}
+                        {sessionId && <ScanChat scanId={result.scan_id} language={language} sessionId={sessionId} />}
                     </VStack>
                 )}
             </VStack>
@@ -0,0 +1,125 @@
+import { useState, useEffect, useCallback } from 'react';
+import {
+    Button, VStack, HStack, Text, useToast, Flex, Badge, IconButton, Tooltip,
+    Modal, ModalOverlay, ModalContent, ModalHeader, ModalBody, ModalCloseButton, ModalFooter
+} from '@chakra-ui/react';
+import { Monitor, LogOut } from 'lucide-react';
+import { listSessions, revokeSession, revokeAllSessions, type UserSession } from './api';
+
+interface SessionsModalProps {
+    sessionId: string;
+    isOpen: boolean;
+    onClose: () => void;
+    onSignedOut: () => void;   // the current session was revoked
+}
+
+const formatTime = (iso: string) => new Date(iso.endsWith('Z') ? iso : `${iso}Z`).toLocaleString();
+
+export default function SessionsModal({ sessionId, isOpen, onClose, onSignedOut }: SessionsModalProps) {
+    const [sessions, setSessions] = useState<UserSession[]>([]);
+    const [idleHours, setIdleHours] = useState<number | null>(null);
+    const toast = useToast();
+
+    const load = useCallback(() => {
+        listSessions(sessionId)
+            .then((data) => {
+                setSessions(data.sessions);
+                setIdleHours(data.idle_timeout_hours);
+            })
+            .catch((e) => toast({ title: 'Failed to load sessions', description: String(e), status: 'error' }));
+    }, [sessionId, toast]);
+
+    useEffect(() => {
+        if (isOpen) load();
+    }, [isOpen, load]);
+
+    const handleRevoke = async (session: UserSession) => {
+        try {
+            await revokeSession(sessionId, session.id);
+            if (session.current) {
+                onClose();
+                onSignedOut();
+                return;
+            }
+            toast({ title: `Signed out ${session.device}`, status: 'info' });
+            load();
+        } catch (e) {
+            toast({ title: 'Failed to revoke session', description: String(e), status: 'error' });
+        }
+    };
+
+    const handleRevokeOthers = async () => {
+        try {
+            const { revoked } = await revokeAllSessions(sessionId, true);
+            toast({ title: `Signed out ${revoked} other session(s)`, status: 'info' });
+            load();
+        } catch (e) {
+            toast({ title: 'Failed to revoke sessions', description: String(e), status: 'error' });
+        }
+    };
+
+    return (
+        <Modal isOpen={isOpen} onClose={onClose} size="xl">
+            <ModalOverlay />
+            <ModalContent bg="gray.800" color="white">
+                <ModalHeader>Your Sessions</ModalHeader>
+                <ModalCloseButton />
+                <ModalBody maxHeight="400px" overflowY="auto">
+                    <VStack spacing={3} align="stretch">
+                        {sessions.map((s) => (
+                            <Flex
+                                key={s.id}
+                                p={3}
+                                borderRadius="md"
+                                border="1px solid"
+                                borderColor={s.current ? "green.600" : "gray.700"}
+                                align="center"
+                                justify="space-between"
+                            >
+                                <HStack spacing={3}>
+                                    <Monitor size={20} />
+                                    <VStack align="start" spacing={0}>
+                                        <HStack>
+                                            <Text fontWeight="bold">{s.device}</Text>
+                                            {s.current && <Badge colorScheme="green">This device</Badge>}
+                                        </HStack>
+                                        <Text fontSize="sm" color="gray.400">
+                                            {s.ip || 'Unknown IP'} · last active {formatTime(s.last_seen_at)}
+                                        </Text>
+                                        <Text fontSize="xs" color="gray.500">Signed in {formatTime(s.created_at)}</Text>
+                                    </VStack>
+                                </HStack>
+                                <Tooltip label={s.current ? "Log out" : "Sign out this device"}>
+                                    <IconButton
+                                        aria-label="Revoke session"
+                                        icon={<LogOut size={16} />}
+                                        size="sm"
+                                        variant="ghost"
+                                        colorScheme="red"
+                                        onClick={() => handleRevoke(s)}
+                                    />
+                                </Tooltip>
+                            </Flex>
+                        ))}
+                    </VStack>
+                    {idleHours && (
+                        <Text fontSize="xs" color="gray.500" mt={4}>
+                            Sessions sign out automatically after {idleHours} hours without activity.
+                        </Text>
+                    )}
+                </ModalBody>
+                <ModalFooter>
+                    <Button
+                        size="sm"
+                        colorScheme="red"
+                        variant="outline"
+                        isDisabled={sessions.length < 2}
+                        onClick={handleRevokeOthers}
+                    >
+                        Sign out all other sessions
+                    </Button>
+                </ModalFooter>
+            </ModalContent>
+        </Modal>
+    );
+}
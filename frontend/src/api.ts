@@ -18,8 +18,8 @@ export interface ScanResponse {
     agent_response?: string;
 }
 
-export const startScan = async (targetUrl: string, language: string = "en"): Promise<ScanResponse> => {
-    const response = await api.post<ScanResponse>("/scan", { target_url: targetUrl, language });
+export const startScan = async (targetUrl: string, language: string = "en", sessionId?: string | null): Promise<ScanResponse> => {
+    const response = await api.post<ScanResponse>("/scan", { target_url: targetUrl, language, session_id: sessionId || undefined });
     return response.data;
 };
 
@@ -87,8 +87,81 @@ export const logout = async (sessionId: string) => {
     return response.data;
 };
 
+// --- Login Sessions (one per device) ---
+export interface UserSession {
+    id: string;
+    current: boolean;
+    device: string;
+    user_agent?: string;
+    ip?: string;
+    created_at: string;
+    last_seen_at: string;
+    expires_at: string;
+}
+
+export interface UserSessions {
+    idle_timeout_hours: number | null;
+    sessions: UserSession[];
+}
+
+// 로그인된 기기(세션) 목록
+export const listSessions = async (sessionId: string): Promise<UserSessions> => {
+    const response = await api.get<UserSessions>("/auth/sessions", {
+        params: { session_id: sessionId },
+    });
+    return response.data;
+};
+
+// 세션 하나 폐기 (다른 기기 로그아웃)
+export const revokeSession = async (sessionId: string, id: string) => {
+    const response = await api.delete(`/auth/sessions/${id}`, {
+        params: { session_id: sessionId },
+    });
+    return response.data;
+};
+
+// 모든 기기에서 로그아웃 (기본: 현재 세션 유지)
+export const revokeAllSessions = async (sessionId: string, keepCurrent: boolean = true) => {
+    const response = await api.post("/auth/sessions/revoke-all", null, {
+        params: { session_id: sessionId, keep_current: keepCurrent },
+    });
+    return response.data;
+};
+
 export const getScanStatus = async (scanId: string): Promise<ScanResponse> => {
     const response = await api.get<ScanResponse>(`/scan/${scanId}`);
     return response.data;
 };
 
+
+// --- Scan Chat API (follow-up Q&A grounded in the scan) ---
+export interface ChatMessage {
+    role: "user" | "assistant";
+    content: string;
+    created_at?: string;
+    grounding?: {
+        findings: string[];
+        code: string[];
+        rag: number;
+    };
+}
+
+export const getScanChat = async (scanId: string): Promise<ChatMessage[]> => {
+    const response = await api.get<{ messages: ChatMessage[] }>(`/scan/${scanId}/chat`);
+    return response.data.messages;
+};
+
+// 질문/초기화는 스캔을 시작한 계정만 가능 (session_id)
+export const sendScanChatMessage = async (scanId: string, message: string, sessionId: string): Promise<ChatMessage[]> => {
+    const response = await api.post<{ messages: ChatMessage[] }>(`/scan/${scanId}/chat`, { message }, {
+        params: { session_id: sessionId },
+    });
+    return response.data.messages;
+};
+
+export const clearScanChat = async (scanId: string, sessionId: string) => {
+    const response = await api.delete(`/scan/${scanId}/chat`, {
+        params: { session_id: sessionId },
+    });
+    return response.data;
+};
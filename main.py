@@ -1,7 +1,9 @@
 import os
+import json
 import time
+import asyncio
 from typing import List, Optional
-from fastapi import FastAPI, HTTPException, BackgroundTasks
+from fastapi import FastAPI, HTTPException, BackgroundTasks, UploadFile, File, Form
 from pydantic import BaseModel
 from contextlib import asynccontextmanager
 from src.config import settings
@@ -9,11 +11,34 @@ from src.database import db
 from src.rag_engine import rag_service
 from src.expert_model import expert_model
 from src.agent import agent_executor
+from src.services.workspace import workspace_manager
+from src.services.attack_surface import attack_surface_extractor
+from src.services.findings import current_scan_id
+from src.services.fix_verifier import fix_verifier
+from src.services.traffic_replay import traffic_replayer
+from src.services.risk_exceptions import exception_manager
+from src.services.sla import sla_tracker
 
 # 1. Load Config (Handled by settings)
 ZAP_URL = settings.ZAP_URL
 
 # 2. Lifecycle Manager
+async def maintenance_loop():
+    """
+    Hourly: expires risk exceptions past their date (reopening their findings), sends
+    SLA due-soon / overdue / escalation notifications, even when nobody reads the scans,
+    and removes login sessions past their idle timeout.
+    """
+    while True:
+        try:
+            if db.db is not None:
+                await exception_manager.expire_due()
+                await sla_tracker.escalate()
+                await db.delete_idle_sessions()
+        except Exception as e:
+            print(f"⚠️ Maintenance sweep failed: {e}")
+        await asyncio.sleep(3600)
+
 @asynccontextmanager
 async def lifespan(app: FastAPI):
     # Startup
@@ -21,11 +46,16 @@ async def lifespan(app: FastAPI):
         await db.connect()
     except Exception as e:
         print(f"❌ Failed to connect to MongoDB: {e}")
+    if db.db is not None:
+        await db.ensure_retention_indexes()
+        await db.backfill_session_activity()
     
     await rag_service.initialize()
     # expert_model.load_model() # Optional: Preload model on startup
+    maintenance_task = asyncio.create_task(maintenance_loop())
     yield
     # Shutdown
+    maintenance_task.cancel()
     await db.close()
 
 app = FastAPI(title="RedEye: AI Security Agent", version="2.0.0", lifespan=lifespan)
@@ -45,9 +75,23 @@ async def get_model_metrics():
 # Include Routers
 from src.auth.github import router as auth_router
 from src.api.analysis import router as analysis_router
+from src.api.scans import router as scans_router
+from src.api.compliance import router as compliance_router
+from src.api.organizations import router as organizations_router
+from src.api.variants import router as variants_router
+from src.api.imports import router as imports_router
+from src.api.exceptions import router as exceptions_router
+from src.api.data import router as data_router
 
 app.include_router(auth_router)
 app.include_router(analysis_router)
+app.include_router(scans_router)
+app.include_router(compliance_router)
+app.include_router(organizations_router)
+app.include_router(variants_router)
+app.include_router(imports_router)
+app.include_router(exceptions_router)
+app.include_router(data_router)
 
 # Add CORS Middleware
 app.add_middleware(
@@ -66,6 +110,7 @@ import uuid
 class ScanRequest(BaseModel):
     target_url: str
     language: Optional[str] = "en"
+    session_id: Optional[str] = None   # signed-in user: links the scan to the account (deletion / export)
 
 class ScanResponse(BaseModel):
     scan_id: str
@@ -80,15 +125,37 @@ async def background_scan_task(scan_id: str, target_url: str, language: str = "e
     """
     try:
         print(f"🕵️‍♂️ [Worker] Starting scan for {scan_id} ({target_url}) in {language}")
+        current_scan_id.set(scan_id)
         
+        # 0. Attack Surface Inventory (Repository targets only)
+        surface_context = ""
+        if "github.com" in target_url:
+            try:
+                async with workspace_manager.use(target_url, checkout=True) as workspace:
+                    surface = await asyncio.to_thread(attack_surface_extractor.extract, workspace)
+                if db.db is not None:
+                    await db.save_scan_surface(scan_id, surface)
+                surface_context = (
+                    "\n\nAttack surface inventory (focus on unauthenticated routes, uploads and deserialization sites):\n"
+                    + attack_surface_extractor.format_for_agent(surface)
+                )
+                print(f"🗺️ [Worker] Attack surface: {surface['summary']}")
+            except Exception as surface_err:
+                print(f"⚠️ Failed to build attack surface: {surface_err}")
+
         # 1. Run the Agent
         lang_instruction = "IMPORTANT: Please respond in Korean (한국어)." if language == "ko" else "IMPORTANT: Please respond in English."
         
         result = await agent_executor.ainvoke({
-            "input": f"Please perform a full security scan on {target_url}. If you find vulnerabilities, verify them with your tools and suggest fixes based on past solutions.\n\n{lang_instruction}"
+            "input": f"Please perform a full security scan on {target_url}. If you find vulnerabilities, verify them with your tools and suggest fixes based on past solutions.{surface_context}\n\n{lang_instruction}"
         })
         agent_output = result["output"]
 
+        # 1.5 Drop fixes that failed verification (they must never reach PR comments or training data)
+        if db.db is not None:
+            scan_record = await db.get_scan(scan_id)
+            agent_output = fix_verifier.redact_rejected(agent_output, (scan_record or {}).get("fix_verifications") or [])
+
         # 2. Extract Training Data (Simplified approach: Look for code blocks)
         # This is high-level; ideally, use structured output.
         try:
@@ -99,7 +166,8 @@ async def background_scan_task(scan_id: str, target_url: str, language: str = "e
                   await db.save_training_data(
                        vulnerable_code=code_blocks[0],
                        fixed_code=code_blocks[1],
-                       vulnerability_type="agent_generated_fix"
+                       vulnerability_type="agent_generated_fix",
+                       scan_id=scan_id
                   )
         except Exception as data_err:
              print(f"⚠️ Failed to extract training data: {data_err}")
@@ -116,6 +184,15 @@ async def background_scan_task(scan_id: str, target_url: str, language: str = "e
         if db.db is not None:
             await db.update_scan(scan_id, "failed", {"error": str(e)})
 
+async def scan_owner(session_id: Optional[str]) -> Optional[dict]:
+    """Account a new scan belongs to (None for anonymous scans)."""
+    if not session_id:
+        return None
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="Session expired or invalid. Sign in again or omit session_id.")
+    return {"github_id": session["github_id"], "github_user": session["github_user"]}
+
 # --- Endpoints ---
 @app.get("/")
 def health_check():
@@ -130,9 +207,10 @@ async def start_scan(request: ScanRequest, background_tasks: BackgroundTasks):
         raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
 
     scan_id = str(uuid.uuid4())
+    owner = await scan_owner(request.session_id)
     
     # 1. Create Initial Record
-    await db.create_scan(scan_id, request.target_url)
+    await db.create_scan(scan_id, request.target_url, owner)
 
     # 2. Add to Background Queue
     background_tasks.add_task(background_scan_task, scan_id, request.target_url, request.language)
@@ -143,6 +221,45 @@ async def start_scan(request: ScanRequest, background_tasks: BackgroundTasks):
         "target": request.target_url
     }
 
+@app.post("/scan/har", response_model=ScanResponse)
+async def start_har_scan(
+    background_tasks: BackgroundTasks,
+    file: UploadFile = File(...),
+    target_url: Optional[str] = Form(None),
+    language: Optional[str] = Form("en"),
+    headers: Optional[str] = Form(None),
+    session_id: Optional[str] = Form(None)
+):
+    """
+    Starts a DAST scan driven by recorded traffic (HAR export or `redeye proxy` recording).
+    The recorded requests are replayed with their cookies / auth headers and mutated instead
+    of spidering; every finding links back to its HAR entry (`har_entry`).
+    `headers` (JSON object) overrides recorded headers, e.g. a fresh session cookie.
+    """
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+
+    try:
+        traffic = traffic_replayer.parse_har((await file.read()).decode("utf-8", errors="replace"),
+                                             file.filename or "recording.har", target_url)
+        if headers:
+            traffic["extra_headers"] = json.loads(headers)
+    except json.JSONDecodeError:
+        raise HTTPException(status_code=400, detail="headers must be a JSON object.")
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    scan_id = str(uuid.uuid4())
+    await db.create_scan(scan_id, traffic["target"], await scan_owner(session_id))
+    await db.save_scan_traffic(scan_id, traffic)
+    background_tasks.add_task(background_scan_task, scan_id, traffic["target"], language)
+
+    return {
+        "scan_id": scan_id,
+        "status": "pending",
+        "target": traffic["target"]
+    }
+
 @app.get("/scan/{scan_id}", response_model=ScanResponse)
 async def get_scan_status(scan_id: str):
     """
@@ -0,0 +1,50 @@
+"""
+Clone Detection 임베딩 인덱스 생성 스크립트
+
+data/circl_processed/clones.jsonl (scripts/preprocess_circl.py 출력)의 패치 전 코드를
+OpenAI 임베딩으로 변환하여 clone_embeddings.npy로 저장한다 (행 순서 = JSONL 라인 순서).
+토큰 fingerprint 인덱스는 서버 시작 후 첫 스캔에서 메모리에 생성되며, 이 파일이 있으면
+CloneDetector가 토큰 매칭 결과를 임베딩 유사도로 한 번 더 확인한다.
+"""
+
+import json
+import os
+import sys
+import numpy as np
+from langchain_openai import OpenAIEmbeddings
+
+DATA_DIR = "./data/circl_processed"
+CLONES_PATH = os.path.join(DATA_DIR, "clones.jsonl")
+REPAIR_PATH = os.path.join(DATA_DIR, "repair.jsonl")
+OUTPUT_PATH = os.path.join(DATA_DIR, "clone_embeddings.npy")
+BATCH_SIZE = 256
+MAX_CHARS = 6000  # 임베딩 모델 토큰 한도 보호
+
+
+def load_codes() -> list:
+    if os.path.exists(CLONES_PATH):
+        with open(CLONES_PATH, "r", encoding="utf-8") as f:
+            return [json.loads(line)["vulnerable"] for line in f]
+    print(f"⚠️ {CLONES_PATH} 없음 → repair.jsonl 사용 (CVE 인용 불가, preprocess_circl.py 재실행 권장)")
+    with open(REPAIR_PATH, "r", encoding="utf-8") as f:
+        return [json.loads(line)["input"].removeprefix("fix vulnerability: ") for line in f]
+
+
+def main():
+    codes = load_codes()
+    print(f"📥 {len(codes)} hunks 로드됨")
+
+    embeddings = OpenAIEmbeddings()
+    vectors = []
+    for start in range(0, len(codes), BATCH_SIZE):
+        batch = [code[:MAX_CHARS] or " " for code in codes[start:start + BATCH_SIZE]]
+        vectors.extend(embeddings.embed_documents(batch))
+        print(f"  Embedded {min(start + BATCH_SIZE, len(codes))}/{len(codes)}", file=sys.stderr)
+
+    np.save(OUTPUT_PATH, np.asarray(vectors, dtype=np.float32))
+    size_mb = os.path.getsize(OUTPUT_PATH) / (1024 * 1024)
+    print(f"💾 Saved {len(vectors)} vectors → {OUTPUT_PATH} ({size_mb:.1f} MB)")
+
+
+if __name__ == "__main__":
+    main()
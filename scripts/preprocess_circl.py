@@ -4,6 +4,7 @@ CIRCL/vulnerability-cwe-patch 데이터셋 전처리 스크립트
 이 스크립트는 CIRCL 데이터셋의 패치 diff를 파싱하여:
 1. Detection Model용: 취약/안전 코드 스니펫 + 라벨 데이터 생성
 2. Repair Model용: 취약 코드 → 수정 코드 쌍 생성
+3. Clone Detection용: CVE/패치 URL이 붙은 취약 hunk (패치 전 코드 + 패치 후 코드)
 
 v2: 메모리 절약을 위해 JSONL로 스트리밍 저장 + 샘플링
 """
@@ -22,6 +23,7 @@ MIN_CODE_LENGTH = 20     # 너무 짧은 코드 제외
 MAX_CODE_LENGTH = 2000   # 너무 긴 코드 제외 (토큰 초과 방지)
 MAX_DETECTION_SAMPLES = 50000  # Detection 최대 샘플 수 (밸런싱)
 MAX_REPAIR_SAMPLES = 20000    # Repair 최대 샘플 수
+MIN_CLONE_LINES = 4           # Clone 인덱스: 너무 짧은 hunk는 오탐이 많아 제외
 
 # 지원 언어 확장자 매핑
 LANG_EXTENSIONS = {
@@ -167,6 +169,52 @@ def parse_diff_for_repair(diff_text: str, language: str) -> list:
     return pairs
 
 
+def parse_diff_for_clones(diff_text: str, language: str, cve_id: str, cwes: list, patch_url: str) -> list:
+    """
+    diff에서 Clone Detection용 hunk 추출.
+    패치 전 코드(context + - 라인, 원래 순서 유지)와 패치 후 코드(context + + 라인)를 함께 저장하여
+    스캔 시 '취약 버전과 거의 같은 함수'를 찾고 CVE/패치를 인용할 수 있게 한다.
+    """
+    hunks = []
+    current_file = ""
+    before, after, changed = [], [], False
+
+    def flush():
+        if changed and len(before) >= MIN_CLONE_LINES:
+            vuln_code = '\n'.join(before)
+            if MIN_CODE_LENGTH <= len(vuln_code) <= MAX_CODE_LENGTH:
+                hunks.append({
+                    "cve": cve_id,
+                    "cwes": cwes,
+                    "patch_url": patch_url,
+                    "file": current_file,
+                    "language": language,
+                    "vulnerable": vuln_code,
+                    "fixed": '\n'.join(after)
+                })
+
+    for line in diff_text.split('\n'):
+        if line.startswith('--- a/'):
+            flush()
+            before, after, changed = [], [], False
+            current_file = line[6:].strip()
+        elif line.startswith('@@'):
+            flush()
+            before, after, changed = [], [], False
+        elif line.startswith('---') or line.startswith('+++') or line.startswith('\\'):
+            continue
+        elif line.startswith('-'):
+            before.append(line[1:])
+            changed = True
+        elif line.startswith('+'):
+            after.append(line[1:])
+        else:
+            before.append(line[1:] if line.startswith(' ') else line)
+            after.append(line[1:] if line.startswith(' ') else line)
+    flush()
+    return hunks
+
+
 def save_jsonl(data: list, filepath: str):
     """JSONL 형식으로 저장 (메모리 효율적)."""
     with open(filepath, 'w', encoding='utf-8') as f:
@@ -208,6 +256,7 @@ def main():
     
     all_detection = []
     all_repair = []
+    all_clones = []
     lang_counter = Counter()
     skipped = 0
     
@@ -220,6 +269,9 @@ def main():
             skipped += 1
             continue
         
+        cve_id = entry.get("id", "")
+        cwes = entry.get("cwes") or []
+
         for patch in patches:
             patch_b64 = patch.get("patch_text_b64", "")
             patch_url = patch.get("url", "")
@@ -247,6 +299,9 @@ def main():
             # Repair용
             rep_samples = parse_diff_for_repair(diff_text, language)
             all_repair.extend(rep_samples)
+
+            # Clone Detection용 (샘플링 없음: 인덱스는 전체 hunk 사용)
+            all_clones.extend(parse_diff_for_clones(diff_text, language, cve_id, cwes, patch_url))
     
     # 결과 통계 (샘플링 전)
     print(f"\n📊 전처리 결과 (샘플링 전):")
@@ -254,6 +309,7 @@ def main():
     print(f"  - 스킵됨 (패치 없음): {skipped}")
     print(f"  - Detection 샘플: {len(all_detection)}")
     print(f"  - Repair 샘플: {len(all_repair)}")
+    print(f"  - Clone hunk: {len(all_clones)}")
     print(f"\n🌐 언어별 분포:")
     for lang, count in lang_counter.most_common():
         print(f"  {lang}: {count}")
@@ -278,6 +334,9 @@ def main():
     
     rep_path = os.path.join(OUTPUT_DIR, "repair.jsonl")
     save_jsonl(rep_sampled, rep_path)
+
+    clone_path = os.path.join(OUTPUT_DIR, "clones.jsonl")
+    save_jsonl(all_clones, clone_path)
     
     # Label 분포 확인
     det_labels = [s["label"] for s in det_sampled]
@@ -5,6 +5,16 @@ from langchain_core.prompts import ChatPromptTemplate, MessagesPlaceholder
 from src.legacy.zap_scanner import zap_scanner
 from src.expert_model import expert_model
 from src.rag_engine import rag_service
+from src.services.go_fuzzer import go_fuzzer
+from src.services.poc_runner import poc_runner
+from src.services.fix_verifier import fix_verifier
+from src.services.regression_tests import regression_test_generator
+from src.services.workspace import workspace_manager
+from src.services.findings import current_scan_id, normalize_findings, guess_cwe
+from src.services.cvss import cvss_calculator
+from src.services.traffic_replay import traffic_replayer
+from src.database import db
+import asyncio
 import json
 import os
 
@@ -19,27 +29,56 @@ async def run_security_scan(target: str) -> str:
     - If target is a Web URL: Uses OWASP ZAP (DAST) to find runtime vulnerabilities.
     Returns a list of alerts in JSON format.
     """
+    scan_id = current_scan_id.get()
+    scan = await db.get_scan(scan_id) if scan_id and db.db is not None else None
+    traffic = (scan or {}).get("traffic")
+
     if "github.com" in target:
         # SAST Path
         print(f"🔄 Routing to Repo Scanner: {target}")
-        alerts = repo_scanner.scan_repo(target)
+        # The scan's worker already refreshed the workspace (attack surface step): don't fetch twice
+        alerts = await asyncio.to_thread(repo_scanner.scan_repo, target, refresh=scan is None)
+    elif traffic:
+        # DAST Path (recorded traffic): replay + mutate the HAR requests instead of spidering
+        print(f"🔄 Routing to Traffic Replay: {target} ({len(traffic['entries'])} recorded requests)")
+        alerts = await traffic_replayer.replay(traffic, traffic.get("extra_headers"))
+        alerts += traffic_replayer.link(await zap_scanner.scan_recorded(target, traffic), traffic)
     else:
         # DAST Path
         print(f"🔄 Routing to ZAP Scanner: {target}")
         alerts = await zap_scanner.scan(target)
 
+    # Persist structured findings on the running scan (reports, threat model, triage)
+    source = "sast" if "github.com" in target else "dast"
+    surface = (scan or {}).get("surface")
+    if scan:
+        findings = cvss_calculator.score_findings(normalize_findings(alerts, source), surface)
+        await db.save_findings(scan_id, findings)
+
     # Simplify alerts to save context window
     simple_alerts = []
     for a in alerts:
         # Filter: For SAST, include all. For ZAP, only High/Medium unless empty.
         risk = a.get('risk', 'Low')
         if risk in ['High', 'Medium'] or "github.com" in target:
-             simple_alerts.append({
+             simple_alert = {
                 "alert": a.get('alert'),
                 "risk": risk,
                 "description": a.get('description')[:200], 
                 "other": a.get('other', '')[:1000] 
-            })
+            }
+             cvss = cvss_calculator.score({**a, "cwe": guess_cwe(a), "source": source}, surface)
+             simple_alert["cvss"] = f"{cvss['base_score']} base / {cvss['environmental_score']} environmental ({cvss['vector']})"
+             if a.get("har_entry"):
+                simple_alert["har_entry"] = a["har_entry"]["index"]
+             if a.get("file"):
+                simple_alert["file"] = a["file"]
+                simple_alert["line"] = a.get("line")
+             # Reachability (SAST only): is the finding on a live path from an entrypoint?
+             if "reachable" in a:
+                simple_alert["reachable"] = a["reachable"]
+                simple_alert["call_path"] = a.get("call_path", [])
+             simple_alerts.append(simple_alert)
             
     return json.dumps(simple_alerts)
 
@@ -65,6 +104,89 @@ def generate_local_expert_fix(vulnerable_code: str) -> str:
     fix = expert_model.repair(vulnerable_code)
     return fix
 
+@tool
+async def fuzz_go_function(go_source: str, function_name: str) -> str:
+    """
+    Dynamically confirms a Go finding by generating a native Go fuzz test for the flagged
+    function (HTTP handler or single string/[]byte argument) and running `go test -fuzz`
+    in an isolated sandbox for a bounded time.
+    Input: Full Go source file containing the function, and the function name.
+    Output: status (confirmed / not_confirmed / inconclusive / skipped) with evidence.
+    A crash or sink hit means the finding is dynamically confirmed.
+    """
+    result = await asyncio.to_thread(go_fuzzer.fuzz, go_source, function_name)
+    return json.dumps({
+        "status": result["status"],
+        "evidence": result["evidence"],
+        "test_name": result["test_name"]
+    })
+
+@tool
+async def confirm_exploit(vulnerability: str, file: str = "", line: int = 0, source_code: str = "") -> str:
+    """
+    Confirms a web finding by generating a minimal PoC request (e.g. `?file=../../../etc/passwd`,
+    `?expr=...`) and replaying it against a locally started copy of the app in the sandbox.
+    Input: vulnerability type (e.g. "Path Traversal", "Code Injection", "XSS"), the finding's file and line
+    from `run_security_scan`. For a standalone snippet pass the full app source as source_code (file = its file name).
+    Output: status (confirmed / not_confirmed / inconclusive), evidence and the PoC requests (curl).
+    """
+    finding = {"alert": vulnerability, "file": file or None, "line": line or None}
+    scan = None
+    if not source_code:
+        scan_id = current_scan_id.get()
+        scan = await db.get_scan(scan_id) if scan_id and db.db is not None else None
+
+    async with workspace_manager.use(scan["target"] if scan else "") as root_dir:
+        if not source_code and not root_dir:
+            return json.dumps({"status": "inconclusive", "evidence": "No local copy of the app to run. Pass source_code."})
+        result = await asyncio.to_thread(
+            poc_runner.confirm, root_dir=root_dir, content=source_code or None,
+            filename=file or "app.go", finding=finding
+        )
+    return json.dumps({
+        "status": result["status"],
+        "evidence": result["evidence"],
+        "pocs": [{"route": p["route"], "curl": p["curl"], "hit": p.get("hit")} for p in result["pocs"][:5]]
+    })
+
+@tool
+async def verify_fix(file: str, original_code: str, fixed_code: str, vulnerability: str = "") -> str:
+    """
+    Applies a proposed fix to a sandbox copy of the scanned repository and runs the project's own
+    tests (`go test ./...`, `pytest`, `npm test`) before and after the fix. For Go handlers and
+    Python functions it also generates a security regression test that must fail on the vulnerable
+    code and pass on the fixed code.
+    Input: file path (as reported by `run_security_scan`), the exact vulnerable code, the replacement code
+    and the vulnerability type (e.g. "SQL Injection", "Path Traversal").
+    Output: verdict (accepted / rejected / unverified), reason, pass/fail deltas and the regression test.
+    A rejected fix must NOT be included in the report.
+    """
+    scan_id = current_scan_id.get()
+    scan = await db.get_scan(scan_id) if scan_id and db.db is not None else None
+    async with workspace_manager.use(scan["target"] if scan else "") as root_dir:
+        if not root_dir:
+            return json.dumps({"verdict": "unverified", "reason": "No repository workspace for this scan."})
+
+        result = await asyncio.to_thread(fix_verifier.verify, root_dir, file, original_code, fixed_code)
+        if result["verdict"] != "rejected":
+            result["regression_test"] = await asyncio.to_thread(
+                regression_test_generator.generate_for_fix, root_dir, file, original_code, fixed_code, vulnerability
+            )
+    await db.save_fix_verification(scan_id, result)
+
+    regression_test = result.get("regression_test") or {}
+    return json.dumps({
+        "verdict": result["verdict"],
+        "reason": result["reason"],
+        "delta": result["delta"],
+        "tests": [{k: r[k] for k in ("command", "passed", "failed", "build_ok")} for r in result["after_fix"]],
+        "regression_test": {
+            "status": regression_test.get("status"),
+            "test_file": regression_test.get("test_file"),
+            "test_code": regression_test.get("test_code") if regression_test.get("status") == "validated" else None
+        }
+    })
+
 @tool
 async def search_past_solutions(query: str) -> str:
     """
@@ -82,7 +204,7 @@ async def search_past_solutions(query: str) -> str:
         print(f"⚠️ RAG Search Failed (DB Offline?): {e}")
         return "No similar past incidents found. (RAG Search unavailable)"
 
-tools = [run_security_scan, verify_vulnerability, generate_local_expert_fix, search_past_solutions]
+tools = [run_security_scan, verify_vulnerability, generate_local_expert_fix, fuzz_go_function, confirm_exploit, verify_fix, search_past_solutions]
 
 # 2. Setup LLM & Prompt
 llm = ChatOpenAI(model="gpt-4o", temperature=0)
@@ -93,14 +215,24 @@ Your goal is to inspect a target (URL or Repository), find vulnerabilities, veri
 
 Process:
 1. Scan the target using `run_security_scan`.
-2. Analyze found vulnerabilities.
+2. Analyze found vulnerabilities. Findings are pre-sorted by priority:
+   - `reachable: true` findings are on a live call path from an entrypoint (route, main, CLI, consumer). Handle them first and cite the `call_path`.
+   - `reachable: false` findings are in code no entrypoint calls. Report them with lower priority.
 3. VERIFY suspected code using `verify_vulnerability` to reduce false positives.
+   - For Go functions (HTTP handlers, string parsers), also call `fuzz_go_function` for dynamic confirmation.
+     A "confirmed" result is proof of exploitability; mention the evidence in the report.
+   - For web findings (path traversal, code/command injection, XSS in an HTTP handler), call `confirm_exploit`
+     with the finding's file and line. Include the PoC request (curl) and evidence of confirmed findings in the report.
 4. For verified vulnerabilities:
    - First, think of a secure fix yourself using your advanced knowledge.
    - Optionally, call `generate_local_expert_fix` to get a second opinion from a specialized local model.
    - Combine these insights to provide the best possible fix.
+   - For repository targets, call `verify_fix` with the exact vulnerable code and your fix before reporting it.
+     Only report fixes that are "accepted" or "unverified" (say which). Never include a "rejected" fix; revise it and verify again.
+   - If `verify_fix` returns a "validated" regression test, include it with the fix (file path + code) so it ships in the fix PR.
 5. Search for past solutions using `search_past_solutions`.
 6. Compile a final comprehensive report.
+   - For each vulnerability, show its CVSS v3.1 base and environmental score and vector (from `run_security_scan`).
 
 The final report MUST be in Markdown.
 """),
@@ -111,3 +243,30 @@ The final report MUST be in Markdown.
 # 3. Create Agent
 agent = create_openai_tools_agent(llm, tools, prompt)
 agent_executor = AgentExecutor(agent=agent, tools=tools, verbose=True)
+
+# 4. Scan Chat Agent (follow-up Q&A on a completed scan)
+# No run_security_scan: the chat must not overwrite the stored findings it is grounded in.
+chat_tools = [verify_vulnerability, generate_local_expert_fix, fuzz_go_function, confirm_exploit, verify_fix, search_past_solutions]
+
+chat_prompt = ChatPromptTemplate.from_messages([
+    ("system", """You are RedEye, an elite AI Security Engineer. A developer is asking follow-up questions about a completed security scan.
+
+Rules:
+- Ground every answer in the scan context below (findings, code context, reachability traces, PoC evidence, fix verifications, past solutions).
+  Cite findings by file:line and CVSS. If the context does not contain what is asked, say so instead of guessing.
+- Use your tools when they add evidence: `verify_vulnerability` / `fuzz_go_function` / `confirm_exploit` to confirm a finding,
+  `generate_local_expert_fix` for a second opinion on a fix, `search_past_solutions` for similar past cases.
+- Before presenting a fix for a repository file, call `verify_fix` with the exact vulnerable code from the context.
+  Never present a "rejected" fix; revise it and verify again.
+- Answer in the language of the question, in Markdown. Keep answers focused; put code in fenced blocks.
+
+Scan context:
+{scan_context}
+"""),
+    MessagesPlaceholder(variable_name="chat_history"),
+    ("user", "{input}"),
+    MessagesPlaceholder(variable_name="agent_scratchpad"),
+])
+
+chat_agent = create_openai_tools_agent(llm, chat_tools, chat_prompt)
+chat_executor = AgentExecutor(agent=chat_agent, tools=chat_tools, verbose=True)
@@ -4,6 +4,15 @@ from typing import Optional, List, Any
 from src.expert_model import expert_model
 from src.repo_scanner import repo_scanner
 from src.github_diff_scanner import github_diff_scanner
+from src.services.go_fuzzer import go_fuzzer
+from src.services.poc_runner import poc_runner
+from src.services.findings import fingerprint
+from src.services.risk_exceptions import exception_manager
+from src.services.gating import gate_policy
+from src.database import db
+from src.auth.permissions import require_session
+from src.config import settings
+import asyncio
 import logging
 
 router = APIRouter(prefix="/analyze", tags=["Analysis"])
@@ -25,6 +34,18 @@ class PRAnalysisRequest(BaseModel):
     pr_number: int
     max_files: Optional[int] = 50
 
+class GoFuzzRequest(BaseModel):
+    code: str
+    function_name: Optional[str] = None
+    filename: Optional[str] = "target.go"
+    fuzz_time: Optional[int] = None
+
+class PocRequest(BaseModel):
+    code: str
+    filename: str
+    vulnerability_type: Optional[str] = None
+    line: Optional[int] = None
+
 # --- Endpoints ---
 
 @router.post("/code")
@@ -107,6 +128,9 @@ async def analyze_pr(request: PRAnalysisRequest):
     Initial Commit 대응:
     - 파일 수가 max_files를 초과하면 중요한 파일만 필터링
     - 보안 관련 키워드 우선순위 (auth, password, secret, etc.)
+
+    Gate (`gate`): 조직 정책(`PUT /orgs/{org}/gating`) 기준 Checks 결과. 같은 리포지토리에서
+    승인된 risk exception이 있는 finding(동일 fingerprint)은 non-blocking으로 처리됩니다.
     """
     try:
         result = await github_diff_scanner.scan_pr_diff(
@@ -115,9 +139,87 @@ async def analyze_pr(request: PRAnalysisRequest):
             pr_number=request.pr_number,
             max_files=request.max_files
         )
-        
+
+        # Same fingerprints as repository scans, so exceptions approved there apply to the PR
+        for vulnerability in result["vulnerabilities"]:
+            vulnerability["fingerprint"] = fingerprint(vulnerability)
+        await exception_manager.apply_to_scan({
+            "target": f"https://github.com/{request.owner}/{request.repo}", "findings": result["vulnerabilities"]
+        })
+        policy = (await db.get_org_settings(request.owner.lower())).get("gating") if db.db is not None else None
+        result["gate"] = gate_policy.evaluate(result["vulnerabilities"], policy)
+
         return result
         
     except Exception as e:
         logger.error(f"PR scan failed: {e}")
         raise HTTPException(status_code=500, detail=str(e))
+
+@router.post("/fuzz/go")
+async def fuzz_go(request: GoFuzzRequest, session_id: Optional[str] = None):
+    """
+    Generates a native Go fuzz test (`func FuzzX(f *testing.F)`) for a flagged function
+    and runs it with `go test -fuzz` in the sandbox.
+
+    - 제출된 코드를 실행하므로 로그인(session_id) 필요
+    - function_name 생략 시: 파일 내 모든 HTTP 핸들러/문자열 함수를 대상으로 실행
+    - fuzz_time × 대상 함수 수는 GO_FUZZ_MAX_SECONDS 이하
+    - Go 툴체인이 없으면 생성된 테스트 코드만 반환 (status: skipped)
+    - crash 또는 sink hit = 동적 확인 (status: confirmed)
+    """
+    await require_session(session_id)
+
+    targets = [request.function_name] if request.function_name else [
+        t["name"] for t in go_fuzzer.find_targets(request.code)
+    ]
+    if not targets:
+        raise HTTPException(status_code=400, detail="No fuzzable Go functions found in the provided code.")
+
+    fuzz_time = request.fuzz_time or settings.GO_FUZZ_TIME_SECONDS
+    if fuzz_time < 1 or fuzz_time * len(targets) > settings.GO_FUZZ_MAX_SECONDS:
+        raise HTTPException(
+            status_code=400,
+            detail=f"fuzz_time × {len(targets)} target(s) must be between 1 and {settings.GO_FUZZ_MAX_SECONDS} seconds."
+        )
+
+    try:
+        results = []
+        for name in targets:
+            result = await asyncio.to_thread(
+                go_fuzzer.fuzz, request.code, name,
+                filename=request.filename, fuzz_time=fuzz_time
+            )
+            results.append({"function": name, **result})
+
+        return {
+            "results": results,
+            "confirmed": any(r["status"] == "confirmed" for r in results)
+        }
+
+    except Exception as e:
+        logger.error(f"Go fuzzing failed: {e}")
+        raise HTTPException(status_code=500, detail=str(e))
+
+@router.post("/poc")
+async def confirm_with_poc(request: PocRequest, session_id: Optional[str] = None):
+    """
+    Generates a minimal PoC request for a web finding and replays it against a
+    locally started copy of the app (single-file app in `code`) inside the sandbox.
+
+    - 제출된 앱을 실행하므로 로그인(session_id) 필요
+    - vulnerability_type 생략 시: 핸들러 코드에서 sink 종류를 추정 (path traversal, code/command injection, XSS)
+    - status: confirmed (sink marker / crash) | not_confirmed | inconclusive (앱 실행 실패 등)
+    """
+    await require_session(session_id)
+
+    finding = None
+    if request.vulnerability_type or request.line:
+        finding = {"alert": request.vulnerability_type or "", "file": request.filename, "line": request.line}
+
+    try:
+        return await asyncio.to_thread(
+            poc_runner.confirm, content=request.code, filename=request.filename, finding=finding
+        )
+    except Exception as e:
+        logger.error(f"PoC confirmation failed: {e}")
+        raise HTTPException(status_code=500, detail=str(e))
@@ -0,0 +1,80 @@
+from fastapi import APIRouter, HTTPException, Query
+from fastapi.responses import PlainTextResponse, HTMLResponse, Response
+from typing import Optional
+from src.database import db
+from src.repo_scanner import repo_scanner
+from src.services.compliance import compliance_reporter
+from src.services.cvss import cvss_calculator
+from src.services.risk_exceptions import exception_manager
+from src.services.sla import sla_tracker
+import logging
+
+router = APIRouter(prefix="/compliance", tags=["Compliance"])
+logger = logging.getLogger(__name__)
+
+
+# --- Endpoints ---
+
+@router.get("/frameworks")
+async def list_frameworks():
+    """Supported compliance frameworks and their number of controls."""
+    return {"frameworks": compliance_reporter.list_frameworks()}
+
+
+@router.get("/report")
+async def get_compliance_report(
+    target: Optional[str] = Query(None, description="Project (scan target). Defaults to the target of scan_id."),
+    scan_id: Optional[str] = Query(None, description="Report on this scan instead of the latest completed one."),
+    framework: str = Query("owasp_top10_2021", description="owasp_top10_2021 | asvs_4_0_3 | pci_dss_4_0 | iso_27001_2022"),
+    format: str = Query("json", description="json | markdown | html | csv")
+):
+    """
+    Compliance report for a project: every control of the framework with its status
+    (open findings / covered by enabled rules / not covered) and evidence links to scans.
+    """
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+    if not target and not scan_id:
+        raise HTTPException(status_code=400, detail="Either target or scan_id is required.")
+
+    pinned = None
+    if scan_id:
+        pinned = await db.get_scan(scan_id)
+        if not pinned:
+            raise HTTPException(status_code=404, detail="Scan not found")
+        target = target or pinned["target"]
+
+    scans = await db.list_scans(target=target, status="completed")
+    if pinned:
+        scans = [pinned] + [s for s in scans if s["scan_id"] != pinned["scan_id"]]
+    if not scans:
+        raise HTTPException(status_code=404, detail=f"No completed scans for '{target}'.")
+
+    # Scans stored before CVSS scoring existed are scored on read
+    findings = scans[0].get("findings") or []
+    missing = [f for f in findings if "cvss" not in f]
+    if missing:
+        cvss_calculator.score_findings(missing, scans[0].get("surface"))
+    # Findings under an approved risk exception are reported as accepted risks, not open
+    await exception_manager.apply_to_scan(scans[0])
+    await sla_tracker.annotate_scan(scans[0])
+
+    try:
+        report = compliance_reporter.build_report(framework, target, scans, repo_scanner.rule_catalog())
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    filename = f"compliance-{framework}-{report['latest_scan_id'][:8]}"
+    if format == "markdown":
+        return PlainTextResponse(compliance_reporter.to_markdown(report), media_type="text/markdown")
+    if format == "html":
+        return HTMLResponse(compliance_reporter.to_html(report))
+    if format == "csv":
+        return Response(
+            content=compliance_reporter.to_csv(report),
+            media_type="text/csv",
+            headers={"Content-Disposition": f'attachment; filename="{filename}.csv"'}
+        )
+    if format != "json":
+        raise HTTPException(status_code=400, detail="format must be one of: json, markdown, html, csv")
+    return report
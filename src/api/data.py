@@ -0,0 +1,129 @@
+from fastapi import APIRouter, HTTPException, UploadFile, File
+from fastapi.responses import Response
+from bson import json_util
+from datetime import datetime
+from typing import Optional
+from src.config import settings, DEFAULT_RETENTION_DAYS
+from src.database import db, RETENTION_FIELDS, EXPORT_KEYS
+from src.auth.permissions import require_org_admin
+import logging
+
+router = APIRouter(prefix="/data", tags=["Data Management"])
+logger = logging.getLogger(__name__)
+
+EXPORT_FORMAT = "redeye-org-export"
+EXPORT_VERSION = 1
+
+
+def _require_db():
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+
+
+# --- Endpoints ---
+
+@router.get("/retention")
+async def get_retention():
+    """
+    Retention period per collection (days until the TTL index removes a record; null = kept forever)
+    and the TTL indexes currently in place. Configure with RETENTION_DAYS.
+    """
+    _require_db()
+    retention = {**DEFAULT_RETENTION_DAYS, **settings.RETENTION_DAYS}
+    collections = []
+    for collection, field in RETENTION_FIELDS.items():
+        index = (await db.db[collection].index_information()).get(f"retention_{field}")
+        collections.append({
+            "collection": collection,
+            "field": field,
+            "days": "at expires_at" if collection == "sessions" else (retention.get(collection) or None),
+            "ttl_index": index is not None,
+            "expire_after_seconds": index.get("expireAfterSeconds") if index else None,
+        })
+    return {"collections": collections}
+
+
+@router.delete("/account")
+async def delete_account(session_id: str, confirm: str):
+    """
+    Deletes the signed-in account (GDPR Art. 17): sessions, the scans it started (with `session_id`)
+    and their derived data (training pairs, vectors, variant hunts, orphaned explanations).
+    Risk exceptions stay for audit with the user pseudonymized. `confirm` must be the GitHub login.
+    """
+    _require_db()
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="Session expired or invalid.")
+    if confirm.lower() != session["github_user"].lower():
+        raise HTTPException(status_code=400, detail="confirm must equal your GitHub login.")
+
+    result = await db.purge_user_data(session["github_id"], session["github_user"])
+    logger.info(f"Account {session['github_user']} deleted: {result}")
+    return {"account": session["github_user"], **result}
+
+
+@router.get("/orgs/{org}/export")
+async def export_org(org: str, session_id: Optional[str] = None):
+    """
+    Complete JSON export of an organization (backup / migration): settings, scans, risk exceptions,
+    explanations, variant hunts, SLA notifications, training pairs and vectors of its scans.
+    Extended JSON keeps dates and ids intact for `POST /data/orgs/{org}/import`. Sessions are never exported.
+    Organization admins only (the export contains webhook URLs unmasked).
+    """
+    _require_db()
+    await require_org_admin(session_id, org)
+    org = org.lower()
+    collections = await db.export_org_data(org)
+    if not collections["scans"] and not collections["organizations"]:
+        raise HTTPException(status_code=404, detail=f"No data for organization '{org}'.")
+
+    envelope = {
+        "format": EXPORT_FORMAT,
+        "version": EXPORT_VERSION,
+        "org": org,
+        "exported_at": datetime.utcnow(),
+        "counts": {name: len(docs) for name, docs in collections.items()},
+        "collections": collections,
+    }
+    filename = f"redeye-{org}-{datetime.utcnow():%Y%m%d}.json"
+    return Response(
+        content=json_util.dumps(envelope, json_options=json_util.RELAXED_JSON_OPTIONS),
+        media_type="application/json",
+        headers={"Content-Disposition": f'attachment; filename="{filename}"'}
+    )
+
+
+@router.post("/orgs/{org}/import")
+async def import_org(org: str, file: UploadFile = File(...), overwrite: bool = False, session_id: Optional[str] = None):
+    """
+    Restores an organization export (organization admins only). Records are matched by their natural key
+    (scan_id, exception_id, ...); existing ones are kept unless `overwrite=true`.
+    Records that belong to another organization (target, repository, scan_id) reject the whole import.
+    """
+    _require_db()
+    await require_org_admin(session_id, org)
+    try:
+        envelope = json_util.loads((await file.read()).decode("utf-8"))
+    except (ValueError, UnicodeDecodeError) as e:
+        raise HTTPException(status_code=400, detail=f"Not a valid export file: {e}")
+    if not isinstance(envelope, dict) or envelope.get("format") != EXPORT_FORMAT:
+        raise HTTPException(status_code=400, detail=f"Not a RedEye organization export (format must be '{EXPORT_FORMAT}').")
+    if envelope.get("version") != EXPORT_VERSION:
+        raise HTTPException(status_code=400, detail=f"Unsupported export version {envelope.get('version')}.")
+    if envelope.get("org") != org.lower():
+        raise HTTPException(status_code=400, detail=f"Export belongs to '{envelope.get('org')}', not '{org.lower()}'.")
+
+    collections = envelope.get("collections") or {}
+    unknown = [name for name in collections if name not in EXPORT_KEYS]
+    if unknown:
+        raise HTTPException(status_code=400, detail=f"Unknown collection(s) in export: {', '.join(unknown)}.")
+    for name, documents in collections.items():
+        if not isinstance(documents, list) or any(not isinstance(d, dict) for d in documents):
+            raise HTTPException(status_code=400, detail=f"Collection '{name}' must be a list of documents.")
+
+    try:
+        result = await db.import_org_data(org.lower(), collections, overwrite)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    logger.info(f"Imported organization {org.lower()}: {result}")
+    return {"org": org.lower(), "overwrite": overwrite, "collections": result}
@@ -0,0 +1,157 @@
+from fastapi import APIRouter, HTTPException
+from pydantic import BaseModel
+from typing import Optional, List
+from datetime import date, datetime
+from src.database import db
+from src.services.workspace import org_of
+from src.services.risk_exceptions import exception_manager, repository_of
+import logging
+
+router = APIRouter(prefix="/exceptions", tags=["Risk Exceptions"])
+logger = logging.getLogger(__name__)
+
+
+# --- Request Models ---
+class ExceptionRequest(BaseModel):
+    scan_id: str
+    fingerprint: str
+    justification: str
+    compensating_controls: List[str]
+    expires_on: date                      # last day the risk is accepted (UTC)
+
+class DecisionRequest(BaseModel):
+    note: Optional[str] = ""
+
+
+def _require_db():
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+
+
+async def _login_of(session_id: str) -> str:
+    """GitHub login of the signed-in user (the OAuth session from /auth/github/login)."""
+    session = await db.get_user_session(session_id) if session_id else None
+    if not session:
+        raise HTTPException(status_code=401, detail="Sign in with GitHub first (valid session_id required).")
+    return session["github_user"]
+
+
+async def _get_exception_or_404(exception_id: str) -> dict:
+    _require_db()
+    exception = await db.get_exception(exception_id)
+    if not exception:
+        raise HTTPException(status_code=404, detail="Exception not found")
+    return exception
+
+
+async def _security_team(exception: dict) -> list:
+    org_settings = await db.get_org_settings(org_of(exception["target"]))
+    team = org_settings.get("security_team") or []
+    if not team:
+        raise HTTPException(status_code=409, detail="The organization has no security team configured (PUT /orgs/{org}/security-team).")
+    return team
+
+
+# --- Endpoints ---
+
+@router.post("")
+async def request_exception(request: ExceptionRequest, session_id: str):
+    """
+    Requests a risk exception for a finding that ships knowingly.
+    Needs a justification, at least one compensating control and an expiry date
+    (at most EXCEPTION_MAX_DAYS away). The exception stays pending until the security team decides.
+    """
+    _require_db()
+    requested_by = await _login_of(session_id)
+    scan = await db.get_scan(request.scan_id)
+    if not scan:
+        raise HTTPException(status_code=404, detail="Scan not found")
+    finding = next((f for f in scan.get("findings") or [] if f.get("fingerprint") == request.fingerprint), None)
+    if not finding:
+        raise HTTPException(status_code=404, detail="Finding not found")
+
+    await exception_manager.expire_due()
+    existing = await db.list_exceptions(repository=repository_of(scan["target"]), fingerprint=request.fingerprint)
+    open_request = next((e for e in existing if e["status"] in ("pending", "approved")), None)
+    if open_request:
+        raise HTTPException(status_code=409, detail=f"Finding already has a {open_request['status']} exception ({open_request['exception_id']}).")
+
+    try:
+        exception = exception_manager.new_request(
+            scan, finding, request.justification, request.compensating_controls, request.expires_on, requested_by
+        )
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    await db.save_exception(exception)
+    logger.info(f"Exception {exception['exception_id']} requested by {requested_by} for {exception['repository']}")
+    return exception
+
+
+@router.get("")
+async def list_exceptions(target: Optional[str] = None, org: Optional[str] = None, status: Optional[str] = None, limit: int = 100):
+    """Exceptions of a repository (`target`) or of an organization (`org`), newest first."""
+    _require_db()
+    await exception_manager.expire_due()
+    exceptions = await db.list_exceptions(
+        repository=repository_of(target) if target else None, org=org.lower() if org else None, status=status, limit=limit
+    )
+    return {"count": len(exceptions), "exceptions": exceptions}
+
+
+@router.get("/{exception_id}")
+async def get_exception(exception_id: str):
+    await exception_manager.expire_due()
+    return await _get_exception_or_404(exception_id)
+
+
+@router.post("/{exception_id}/approve")
+async def approve_exception(exception_id: str, request: DecisionRequest, session_id: str):
+    """Approves a pending exception. Security team members only; requesters cannot approve their own."""
+    return await _decide(exception_id, True, request, session_id)
+
+
+@router.post("/{exception_id}/reject")
+async def reject_exception(exception_id: str, request: DecisionRequest, session_id: str):
+    """Rejects a pending exception (security team members only)."""
+    return await _decide(exception_id, False, request, session_id)
+
+
+@router.post("/{exception_id}/revoke")
+async def revoke_exception(exception_id: str, request: DecisionRequest, session_id: str):
+    """Ends an approved or pending exception early (requester or security team); the finding reopens."""
+    await exception_manager.expire_due()
+    exception = await _get_exception_or_404(exception_id)
+    login = await _login_of(session_id)
+    team = await _security_team(exception)
+    if login.lower() not in {m.lower() for m in team} and login.lower() != exception["requested_by"].lower():
+        raise HTTPException(status_code=403, detail="Only the requester or the security team can revoke an exception.")
+    if exception["status"] not in ("pending", "approved"):
+        raise HTTPException(status_code=409, detail=f"Exception is already {exception['status']}.")
+
+    now = datetime.utcnow()
+    fields = {
+        "status": "revoked",
+        "revoked_by": login,
+        "revoked_at": now,
+        "history": exception.get("history", []) + [{"action": "revoked", "by": login, "at": now, "note": request.note}],
+    }
+    await db.update_exception(exception_id, fields)
+    return {**exception, **fields}
+
+
+async def _decide(exception_id: str, approve: bool, request: DecisionRequest, session_id: str) -> dict:
+    await exception_manager.expire_due()
+    exception = await _get_exception_or_404(exception_id)
+    approver = await _login_of(session_id)
+    team = await _security_team(exception)
+    try:
+        fields = exception_manager.decide(exception, approve, approver, team, request.note or "")
+    except PermissionError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except ValueError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+
+    await db.update_exception(exception_id, fields)
+    logger.info(f"Exception {exception_id} {fields['status']} by {approver}")
+    return {**exception, **fields}
@@ -0,0 +1,161 @@
+from fastapi import APIRouter, HTTPException, UploadFile, File, Form, BackgroundTasks
+from typing import Optional, List
+from src.database import db
+from src.auth.permissions import require_session, require_scan_owner
+from src.expert_model import expert_model
+from src.services.workspace import workspace_manager
+from src.services.importers import finding_importer, FORMATS
+from src.services.reachability import reachability_analyzer
+from src.services.cvss import cvss_calculator
+from src.services.fix_verifier import fix_verifier
+import asyncio
+import os
+import uuid
+import logging
+
+router = APIRouter(prefix="/imports", tags=["Imports"])
+logger = logging.getLogger(__name__)
+
+MAX_REPORT_BYTES = 20 * 1024 * 1024
+VERIFY_CONTEXT_LINES = 5
+# Each fix verification runs the test suite twice in the sandbox: bound the work one upload can queue
+MAX_FIX_VERIFICATIONS = 5
+
+
+# --- Background Task ---
+async def triage_imported_findings(scan_id: str, fingerprints: List[str], verify_fixes: bool = False):
+    """
+    Runs the RedEye pipeline on imported findings, like on its own SAST results:
+    reachability + CVSS, CodeBERT verification and T5 repair. With `verify_fixes`, the first
+    MAX_FIX_VERIFICATIONS repairs are also verified against the test suite in the sandbox.
+    """
+    try:
+        scan = await db.get_scan(scan_id)
+        findings = [f for f in scan.get("findings") or [] if f.get("fingerprint") in fingerprints]
+        async with workspace_manager.use(scan["target"]) as root_dir:
+            if root_dir:
+                await asyncio.to_thread(reachability_analyzer.annotate, root_dir, findings)
+            cvss_calculator.score_findings(findings, scan.get("surface"))
+
+            verified, fix_runs = 0, 0
+            for finding in findings:
+                fields = {key: finding[key] for key in ("reachable", "call_path", "function", "cvss") if key in finding}
+                snippet = _code_context(root_dir, finding) if finding.get("source") == "sast" else ""
+                if snippet:
+                    verification = await asyncio.to_thread(expert_model.verify, snippet)
+                    fields["ai_verification"] = verification
+                    verified += 1
+                    if verification.get("label") == "VULNERABLE" and finding.get("code"):
+                        repair = await asyncio.to_thread(expert_model.repair, finding["code"])
+                        if repair.get("fixed_code"):
+                            fields["suggested_fix"] = repair["fixed_code"]
+                            if root_dir and verify_fixes and fix_runs < MAX_FIX_VERIFICATIONS:
+                                fix_runs += 1
+                                result = await asyncio.to_thread(
+                                    fix_verifier.verify, root_dir, finding["file"], finding["code"], repair["fixed_code"]
+                                )
+                                await db.save_fix_verification(scan_id, result)
+                                fields["fix_verdict"] = result["verdict"]
+                await db.update_finding(scan_id, finding["fingerprint"], fields)
+        print(f"✅ [Import] Triaged {len(findings)} imported finding(s) of {scan_id} "
+              f"({verified} verified by the AI model, {fix_runs} fix(es) verified in the sandbox)")
+    except Exception as e:
+        print(f"❌ [Import] Triage failed for {scan_id}: {e}")
+
+
+def _code_context(root_dir: Optional[str], finding: dict) -> str:
+    """Source around the finding from the workspace, else the snippet the tool reported."""
+    if root_dir and finding.get("file") and finding.get("line"):
+        path = os.path.realpath(os.path.join(root_dir, finding["file"]))
+        if path.startswith(os.path.realpath(root_dir) + os.sep) and os.path.isfile(path):
+            with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                lines = f.read().split("\n")
+            line = finding["line"]
+            return "\n".join(lines[max(0, line - 1 - VERIFY_CONTEXT_LINES):line + VERIFY_CONTEXT_LINES])
+    return finding.get("code") or ""
+
+
+# --- Endpoints ---
+
+@router.get("/formats")
+async def list_import_formats():
+    """Report formats accepted by POST /imports (auto-detected when `format` is omitted)."""
+    return {"formats": [{"id": key, "description": value} for key, value in FORMATS.items()]}
+
+
+@router.post("")
+async def import_findings(
+    background_tasks: BackgroundTasks,
+    file: UploadFile = File(...),
+    target: Optional[str] = Form(None),
+    scan_id: Optional[str] = Form(None),
+    format: Optional[str] = Form(None),
+    verify: bool = Form(True),
+    verify_fixes: bool = Form(False),
+    session_id: Optional[str] = None
+):
+    """
+    Imports an external scanner report (SARIF, ZAP XML/JSON, gosec, Bandit, Semgrep, npm audit).
+
+    - Findings are attached to `scan_id`, or to the caller's latest scan of `target` (a new scan is created if there is none).
+    - Results already reported by RedEye or another tool at the same location with the same CWE are
+      deduplicated (the tool is added to `reported_by` of the existing finding).
+    - With `verify`, new findings go through the AI verification / repair pipeline in the background.
+    - With `verify_fixes` (opt-in), up to MAX_FIX_VERIFICATIONS repairs are also run against the test suite in the sandbox.
+    - Requires a signed-in session; findings can only be added to scans started by the same account.
+    """
+    session = await require_session(session_id)
+    if not scan_id and not target:
+        raise HTTPException(status_code=400, detail="Provide scan_id or target.")
+
+    content = await file.read()
+    if len(content) > MAX_REPORT_BYTES:
+        raise HTTPException(status_code=413, detail="Report is larger than 20MB.")
+
+    if scan_id:
+        scan = await db.get_scan(scan_id)
+        if not scan:
+            raise HTTPException(status_code=404, detail="Scan not found")
+        await require_scan_owner(session_id, scan)
+    else:
+        latest = await db.list_scans(target=target, limit=1, owner_id=session["github_id"])
+        scan = latest[0] if latest else None
+        if not scan:
+            scan_id = str(uuid.uuid4())
+            await db.create_scan(scan_id, target, {"github_id": session["github_id"], "github_user": session["github_user"]})
+            await db.update_scan(scan_id, "completed", f"Imported from {file.filename}")
+            scan = await db.get_scan(scan_id)
+    scan_id = scan["scan_id"]
+
+    try:
+        async with workspace_manager.use(scan["target"]) as root_dir:
+            imported = finding_importer.parse(content.decode("utf-8", errors="replace"), format, file.filename or "", root_dir)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    existing = scan.get("findings") or []
+    new_findings, duplicates, reported_by = finding_importer.dedupe(imported, existing)
+    await db.save_findings(scan_id, new_findings)
+    for fingerprint, tools in reported_by.items():
+        await db.update_finding(scan_id, fingerprint, {"reported_by": tools})
+
+    tools = sorted({f["tool"] for f in imported})
+    await db.save_import(scan_id, {
+        "filename": file.filename,
+        "tools": tools,
+        "imported": len(new_findings),
+        "duplicates": len(duplicates),
+    })
+    if verify and new_findings:
+        background_tasks.add_task(triage_imported_findings, scan_id, [f["fingerprint"] for f in new_findings], verify_fixes)
+
+    return {
+        "scan_id": scan_id,
+        "target": scan["target"],
+        "tools": tools,
+        "imported": len(new_findings),
+        "duplicates": [{"fingerprint": d["finding"]["fingerprint"], "tool": d["finding"]["tool"],
+                        "duplicate_of": d["duplicate_of"]} for d in duplicates],
+        "verification": "queued" if verify and new_findings else "skipped",
+        "findings": new_findings,
+    }
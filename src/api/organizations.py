@@ -0,0 +1,247 @@
+from fastapi import APIRouter, HTTPException
+from pydantic import BaseModel
+from typing import Optional, List, Dict
+from src.database import db
+from src.auth.permissions import require_org_admin, require_org_member
+from src.services.scan_report import DEFAULT_BRANDING
+from src.services.attribution import insights_report
+from src.services.gating import DEFAULT_GATE_POLICY
+from src.services.sla import sla_tracker
+from src.services.notifications import notifier
+from src.services.scan_report import SEVERITY_ORDER
+from datetime import datetime, timedelta
+import re
+import logging
+
+router = APIRouter(prefix="/orgs", tags=["Organizations"])
+logger = logging.getLogger(__name__)
+
+MAX_LOGO_BYTES = 300 * 1024
+
+
+# --- Request Models ---
+class BrandingRequest(BaseModel):
+    company_name: Optional[str] = None
+    primary_color: Optional[str] = None    # "#RRGGBB"
+    logo_data_uri: Optional[str] = None    # data:image/png|jpeg|svg+xml;base64,... (JPEG also shows in PDF)
+    footer: Optional[str] = None
+    classification: Optional[str] = None   # e.g. "CONFIDENTIAL", "INTERNAL"
+
+
+class InsightsRequest(BaseModel):
+    enabled: bool
+
+
+class SecurityTeamRequest(BaseModel):
+    members: List[str]                     # GitHub logins that approve risk exceptions
+
+class SlaPolicyRequest(BaseModel):
+    days: Optional[Dict[str, int]] = None  # e.g. {"Critical": 7, "High": 30}
+    due_soon_days: Optional[int] = None
+    escalate_after_days: Optional[int] = None
+
+class NotificationChannel(BaseModel):
+    type: str                              # slack | webhook
+    url: str
+    name: Optional[str] = None
+    events: Optional[List[str]] = None     # empty = all events
+
+class NotificationsRequest(BaseModel):
+    channels: List[NotificationChannel]
+
+class GatingRequest(BaseModel):
+    fail_on: Optional[str] = None          # Critical | High | Medium | Low
+    require_reachable: Optional[bool] = None
+
+
+def _require_db():
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+
+
+# --- Endpoints ---
+
+@router.get("/{org}/branding")
+async def get_branding(org: str):
+    """Report branding of an organization (GitHub owner or web host), merged over the defaults."""
+    _require_db()
+    settings = await db.get_org_settings(org.lower())
+    return {"org": org.lower(), "branding": {**DEFAULT_BRANDING, **(settings.get("branding") or {})}}
+
+
+@router.put("/{org}/branding")
+async def set_branding(org: str, request: BrandingRequest, session_id: Optional[str] = None):
+    """
+    Sets the report branding template of an organization (organization admins only).
+    Logos are stored as data URIs so exported reports stay self-contained.
+    """
+    _require_db()
+    await require_org_admin(session_id, org)
+    branding = {k: v for k, v in request.model_dump().items() if v is not None}
+
+    if "primary_color" in branding and not re.fullmatch(r"#[0-9A-Fa-f]{6}|#[0-9A-Fa-f]{3}", branding["primary_color"]):
+        raise HTTPException(status_code=400, detail="primary_color must be a hex color like #c62828.")
+    if "logo_data_uri" in branding:
+        if not re.match(r"data:image/(png|jpeg|jpg|svg\+xml);base64,", branding["logo_data_uri"]):
+            raise HTTPException(status_code=400, detail="logo_data_uri must be a base64 PNG, JPEG or SVG data URI.")
+        if len(branding["logo_data_uri"]) * 3 // 4 > MAX_LOGO_BYTES:
+            raise HTTPException(status_code=400, detail=f"Logo is larger than {MAX_LOGO_BYTES // 1024} KB.")
+
+    await db.save_org_branding(org.lower(), branding)
+    return {"org": org.lower(), "branding": {**DEFAULT_BRANDING, **branding}}
+
+
+@router.put("/{org}/insights")
+async def set_insights(org: str, request: InsightsRequest, session_id: Optional[str] = None):
+    """
+    Opt-in (or out) of team security insights for an organization (organization admins only).
+    Counts are per CODEOWNERS team, never per developer. `enabled_by` is the signed-in login.
+    """
+    _require_db()
+    session = await require_org_admin(session_id, org)
+    insights = {**request.model_dump(), "enabled_by": session["github_user"], "changed_at": datetime.utcnow()}
+    await db.save_org_insights(org.lower(), insights)
+    return {"org": org.lower(), "insights": insights}
+
+
+@router.get("/{org}/insights")
+async def get_insights(org: str, days: int = 90, session_id: Optional[str] = None):
+    """
+    Introduced-vs-fixed findings per team over the last `days`, from completed repository scans.
+
+    - introduced: open findings whose line was committed inside the window (`git blame`)
+    - fixed: findings present in one scan and gone in the next scan of the same repository
+    - Requires opt-in via `PUT /orgs/{org}/insights`, and organization membership to read.
+    """
+    _require_db()
+    await require_org_member(session_id, org)
+    org_settings = await db.get_org_settings(org.lower())
+    if not (org_settings.get("insights") or {}).get("enabled"):
+        raise HTTPException(status_code=403, detail="Team insights are not enabled for this organization (PUT /orgs/{org}/insights).")
+    if days < 1 or days > 730:
+        raise HTTPException(status_code=400, detail="days must be between 1 and 730.")
+
+    scans = await db.list_org_repo_scans(org.lower())
+    report = insights_report(scans, datetime.utcnow() - timedelta(days=days))
+    return {"org": org.lower(), "days": days, **report}
+
+
+@router.get("/{org}/security-team")
+async def get_security_team(org: str):
+    """GitHub logins allowed to approve or reject risk exceptions."""
+    _require_db()
+    settings = await db.get_org_settings(org.lower())
+    return {"org": org.lower(), "members": settings.get("security_team") or []}
+
+
+@router.put("/{org}/security-team")
+async def set_security_team(org: str, request: SecurityTeamRequest, session_id: Optional[str] = None):
+    """
+    Sets the security team (exception approvers). Organization admins only.
+    """
+    _require_db()
+    await require_org_admin(session_id, org)
+    members = sorted({m.strip().lstrip("@") for m in request.members if m.strip()}, key=str.lower)
+    if not members:
+        raise HTTPException(status_code=400, detail="The security team needs at least one member.")
+    await db.save_org_security_team(org.lower(), members)
+    return {"org": org.lower(), "members": members}
+
+
+@router.get("/{org}/gating")
+async def get_gating(org: str):
+    """Security gate policy used by `GET /scan/{scan_id}/gate` and PR scans, merged over the defaults."""
+    _require_db()
+    settings = await db.get_org_settings(org.lower())
+    return {"org": org.lower(), "gating": {**DEFAULT_GATE_POLICY, **(settings.get("gating") or {})}}
+
+
+@router.put("/{org}/gating")
+async def set_gating(org: str, request: GatingRequest, session_id: Optional[str] = None):
+    """Sets the gate policy (organization admins only). Findings under an approved risk exception never block."""
+    _require_db()
+    await require_org_admin(session_id, org)
+    gating = {k: v for k, v in request.model_dump().items() if v is not None}
+    if "fail_on" in gating and gating["fail_on"] not in SEVERITY_ORDER[:-1]:
+        raise HTTPException(status_code=400, detail=f"fail_on must be one of: {', '.join(SEVERITY_ORDER[:-1])}.")
+    await db.save_org_gating(org.lower(), gating)
+    return {"org": org.lower(), "gating": {**DEFAULT_GATE_POLICY, **gating}}
+
+
+@router.get("/{org}/sla")
+async def get_sla_policy(org: str):
+    """Remediation SLA policy (days per severity), merged over the defaults (Critical 7, High 30, ...)."""
+    _require_db()
+    return {"org": org.lower(), "sla": sla_tracker.policy(await db.get_org_settings(org.lower()))}
+
+
+@router.put("/{org}/sla")
+async def set_sla_policy(org: str, request: SlaPolicyRequest, session_id: Optional[str] = None):
+    """Sets the SLA policy (organization admins only). Severities not listed keep the default window."""
+    _require_db()
+    await require_org_admin(session_id, org)
+    sla = {k: v for k, v in request.model_dump().items() if v is not None}
+    for severity, days in (sla.get("days") or {}).items():
+        if severity not in SEVERITY_ORDER[:-1]:
+            raise HTTPException(status_code=400, detail=f"Unknown severity '{severity}'. Use: {', '.join(SEVERITY_ORDER[:-1])}.")
+        if days < 1:
+            raise HTTPException(status_code=400, detail="SLA windows must be at least 1 day.")
+    if sla.get("due_soon_days", 0) < 0 or sla.get("escalate_after_days", 0) < 0:
+        raise HTTPException(status_code=400, detail="due_soon_days and escalate_after_days cannot be negative.")
+
+    await db.save_org_sla(org.lower(), sla)
+    return {"org": org.lower(), "sla": sla_tracker.policy({"sla": sla})}
+
+
+@router.get("/{org}/sla/dashboard")
+async def get_sla_dashboard(org: str, status: Optional[str] = None):
+    """
+    Overdue dashboard over the latest completed scan of every project of the organization:
+    totals by SLA status and severity, per-project breakdown and late findings (most overdue first,
+    with CODEOWNERS owners for routing). `status` filters the late findings (escalated | overdue | due_soon).
+    """
+    _require_db()
+    org_settings = await db.get_org_settings(org.lower())
+    scans = await sla_tracker.latest_scans(org.lower())
+    dashboard = sla_tracker.dashboard(scans, sla_tracker.policy(org_settings))
+    if status:
+        dashboard["late_findings"] = [f for f in dashboard["late_findings"] if f["status"] == status]
+    return {"org": org.lower(), **dashboard}
+
+
+@router.get("/{org}/notifications")
+async def get_notifications(org: str):
+    """Notification channels of the organization (webhook URLs are masked)."""
+    _require_db()
+    channels = ((await db.get_org_settings(org.lower())).get("notifications") or {}).get("channels") or []
+    return {"org": org.lower(), "channels": [{**c, "url": c["url"][:24] + "…"} for c in channels]}
+
+
+@router.put("/{org}/notifications")
+async def set_notifications(org: str, request: NotificationsRequest, session_id: Optional[str] = None):
+    """
+    Sets the notification channels (Slack incoming webhook or generic JSON webhook). Organization admins only.
+    Events: sla.due_soon, sla.overdue, sla.escalated (checked hourly; each finding is notified once per level).
+    Webhook hosts must resolve to public addresses (and match WEBHOOK_ALLOWED_HOSTS when configured).
+    """
+    _require_db()
+    await require_org_admin(session_id, org)
+    try:
+        channels = [await notifier.validate_channel(c.model_dump()) for c in request.channels]
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    await db.save_org_notifications(org.lower(), {"channels": channels})
+    return {"org": org.lower(), "channels": [{**c, "url": c["url"][:24] + "…"} for c in channels]}
+
+
+@router.post("/{org}/notifications/test")
+async def test_notifications(org: str, session_id: Optional[str] = None):
+    """Sends a test message to every channel and returns the delivery result per channel (organization admins only)."""
+    _require_db()
+    await require_org_admin(session_id, org)
+    org_settings = await db.get_org_settings(org.lower())
+    if not (org_settings.get("notifications") or {}).get("channels"):
+        raise HTTPException(status_code=404, detail="No notification channels configured.")
+    results = await notifier.send(org_settings, "test", "RedEye test notification",
+                                  f"Notifications for {org.lower()} are configured correctly.")
+    return {"org": org.lower(), "results": results}
@@ -0,0 +1,447 @@
+from fastapi import APIRouter, HTTPException
+from fastapi.responses import PlainTextResponse, JSONResponse, HTMLResponse, Response
+from pydantic import BaseModel
+from typing import Optional
+from src.database import db
+from src.services.workspace import workspace_manager, org_of
+from src.services.attack_surface import attack_surface_extractor
+from src.services.threat_model import threat_model_generator
+from src.services.poc_runner import poc_runner
+from src.services.fix_verifier import fix_verifier
+from src.services.regression_tests import regression_test_generator
+from src.services.cvss import cvss_calculator
+from src.services.sarif import sarif_exporter
+from src.services.scan_report import scan_report_builder
+from src.services.scan_chat import scan_chat_service
+from src.services.explanations import explanation_generator
+from src.services.attribution import owner_teams
+from src.services.risk_exceptions import exception_manager
+from src.services.gating import gate_policy
+from src.services.sla import sla_tracker
+from src.auth.permissions import require_scan_owner, require_scan_triager
+import asyncio
+import logging
+
+router = APIRouter(prefix="/scan", tags=["Scans"])
+logger = logging.getLogger(__name__)
+
+# --- Request Models ---
+class FixVerificationRequest(BaseModel):
+    file: str
+    original_code: str
+    fixed_code: str
+    vulnerability: Optional[str] = ""
+    cwe: Optional[str] = None
+
+class ChatRequest(BaseModel):
+    message: str
+
+class CvssOverrideRequest(BaseModel):
+    vector: Optional[str] = None          # e.g. "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:N/CR:H"
+    metrics: Optional[dict] = None        # e.g. {"PR": "L", "CR": "H"} (applied on top of vector)
+    reason: str
+
+
+async def _get_scan_or_404(scan_id: str) -> dict:
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+    scan = await db.get_scan(scan_id)
+    if not scan:
+        raise HTTPException(status_code=404, detail="Scan not found")
+    return scan
+
+
+def _with_cvss(scan: dict) -> list:
+    """Findings with CVSS scores (scans stored before scoring existed are scored on read)."""
+    findings = scan.get("findings") or []
+    missing = [f for f in findings if "cvss" not in f]
+    if missing:
+        cvss_calculator.score_findings(missing, scan.get("surface"))
+    return findings
+
+
+def _get_finding_or_404(scan: dict, fingerprint: str) -> dict:
+    finding = next((f for f in scan.get("findings") or [] if f.get("fingerprint") == fingerprint), None)
+    if not finding:
+        raise HTTPException(status_code=404, detail="Finding not found")
+    return finding
+
+# --- Endpoints ---
+
+@router.get("/{scan_id}/surface")
+async def get_scan_surface(scan_id: str):
+    """
+    Attack surface inventory extracted before the scan:
+    routes (method, handler location, auth, params), file uploads,
+    outbound network calls, DB access points and deserialization sites.
+    """
+    scan = await _get_scan_or_404(scan_id)
+
+    if not scan.get("surface"):
+        raise HTTPException(status_code=404, detail="No attack surface for this scan (only repository targets are inventoried).")
+
+    return {
+        "scan_id": scan_id,
+        "target": scan["target"],
+        "surface": scan["surface"]
+    }
+
+
+@router.get("/{scan_id}/traffic")
+async def get_scan_traffic(scan_id: str):
+    """
+    Recorded requests (HAR / recording proxy) that drove the DAST phase.
+    Findings reference them by `har_entry.index`; credential headers are masked.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    traffic = scan.get("traffic")
+    if not traffic:
+        raise HTTPException(status_code=404, detail="This scan was not driven by recorded traffic.")
+
+    sensitive = {"cookie", "authorization", "x-api-key", "x-csrf-token", "x-xsrf-token"}
+    entries = [
+        {**e, "headers": {k: ("***" if k.lower() in sensitive else v) for k, v in e["headers"].items()}}
+        for e in traffic["entries"]
+    ]
+    linked = {}
+    for finding in scan.get("findings") or []:
+        if finding.get("har_entry"):
+            linked.setdefault(finding["har_entry"]["index"], []).append(finding["fingerprint"])
+    for entry in entries:
+        entry["findings"] = linked.get(entry["index"], [])
+
+    return {
+        "scan_id": scan_id,
+        "file": traffic["file"],
+        "host": traffic["host"],
+        "truncated": traffic.get("truncated", False),
+        "entries": entries
+    }
+
+
+@router.get("/{scan_id}/findings")
+async def get_scan_findings(scan_id: str, risk: Optional[str] = None, sla_status: Optional[str] = None):
+    """
+    Structured findings recorded during the scan (SAST/DAST), each with a stable fingerprint and CWE.
+    Findings under a risk exception carry it (`exception`) and `status: risk_accepted` while it is approved.
+    Each finding carries its remediation SLA (`sla`: due date from first detection, status);
+    `sla_status` filters by it (on_track | due_soon | overdue | escalated | exception).
+    """
+    scan = await _get_scan_or_404(scan_id)
+    findings = _with_cvss(scan)
+    await exception_manager.apply_to_scan(scan)
+    await sla_tracker.annotate_scan(scan)
+    if risk:
+        findings = [f for f in findings if f.get("risk") == risk]
+    if sla_status:
+        findings = [f for f in findings if (f.get("sla") or {}).get("status") == sla_status]
+
+    return {
+        "scan_id": scan_id,
+        "target": scan["target"],
+        "count": len(findings),
+        "findings": findings
+    }
+
+
+@router.get("/{scan_id}/owners")
+async def get_findings_by_owner(scan_id: str):
+    """
+    Remediation routing: findings grouped by code owner (CODEOWNERS, last matching rule),
+    each with the commit/author/date that introduced the line (`git blame`).
+    Findings without an owner go to `(unowned)`; a finding with several owners is listed under each.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    routed = {}
+    for finding in _with_cvss(scan):
+        for owner in owner_teams(finding):
+            routed.setdefault(owner, []).append({
+                k: finding.get(k) for k in ("fingerprint", "alert", "risk", "cwe", "file", "line", "attribution")
+            })
+
+    return {
+        "scan_id": scan_id,
+        "target": scan["target"],
+        "owners": [{"owner": owner, "count": len(items), "findings": items}
+                   for owner, items in sorted(routed.items(), key=lambda kv: -len(kv[1]))]
+    }
+
+
+@router.post("/{scan_id}/findings/{fingerprint}/poc")
+async def confirm_finding_with_poc(scan_id: str, fingerprint: str, session_id: Optional[str] = None):
+    """
+    Replays a generated PoC for one finding against a sandboxed copy of the scanned repository
+    and stores the verdict (confirmed / not_confirmed / inconclusive + evidence) on the finding.
+    Runs the repository's app, so only the account that started the scan can do this (session_id).
+    """
+    scan = await _get_scan_or_404(scan_id)
+    await require_scan_owner(session_id, scan)
+    finding = _get_finding_or_404(scan, fingerprint)
+
+    async with workspace_manager.use(scan["target"]) as root_dir:
+        if not root_dir:
+            raise HTTPException(status_code=409, detail="No local workspace for this target. PoC replay needs the repository source.")
+        result = await asyncio.to_thread(poc_runner.confirm, root_dir=root_dir, finding=finding)
+    poc = {
+        "status": result["status"],
+        "evidence": result["evidence"],
+        "requests": [{k: p.get(k) for k in ("route", "method", "url", "data", "param", "payload", "curl", "status_code", "hit")}
+                     for p in result["pocs"]]
+    }
+    # A confirmed exploit raises exploit maturity / report confidence in the CVSS vector
+    cvss = cvss_calculator.score({**finding, "poc": poc}, scan.get("surface"), finding.get("cvss_override"))
+    await db.update_finding(scan_id, fingerprint, {"poc": poc, "cvss": cvss})
+
+    return {"scan_id": scan_id, "fingerprint": fingerprint, "poc": poc, "app_log": result["app_log"]}
+
+
+@router.get("/{scan_id}/findings/{fingerprint}/explanation")
+async def explain_finding(scan_id: str, fingerprint: str, llm: bool = False, refresh: bool = False):
+    """
+    Developer-facing explanation of a finding: what the attacker controls, the source-to-sink
+    data flow in this code (taint path), realistic impact and how the fix blocks it.
+
+    - Templated from the CWE knowledge base by default; `llm=true` rewrites it for this code
+      (falls back to the template if the LLM is unavailable).
+    - Cached per fingerprint; `refresh=true` regenerates.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    finding = _get_finding_or_404(scan, fingerprint)
+
+    cached = None if refresh else await db.get_explanation(fingerprint)
+    if cached and (not llm or cached.get("generator") == "llm"):
+        return {"scan_id": scan_id, "cached": True, "explanation": cached}
+
+    route = cvss_calculator.route_for(finding, scan["surface"]) if scan.get("surface") else None
+    fix = next((f for f in reversed(scan.get("fix_verifications") or [])
+                if f.get("file") == finding.get("file") and f.get("verdict") != "rejected"), None)
+
+    async with workspace_manager.use(scan["target"]) as root_dir:
+        explanation = await asyncio.to_thread(explanation_generator.explain, finding, root_dir, route, fix)
+    if llm:
+        explanation = await explanation_generator.explain_with_llm(explanation, finding)
+
+    await db.save_explanation(explanation)
+    # Stored on the finding too, so SARIF help text and reports pick it up
+    await db.update_finding(scan_id, fingerprint, {"explanation": explanation["markdown"]})
+    return {"scan_id": scan_id, "cached": False, "explanation": explanation}
+
+
+@router.put("/{scan_id}/findings/{fingerprint}/cvss")
+async def override_cvss(scan_id: str, fingerprint: str, request: CvssOverrideRequest, session_id: Optional[str] = None):
+    """
+    Triage override of the derived CVSS v3.1 vector.
+    Base, temporal (E/RL/RC) and environmental (CR/IR/AR, M*) metrics can be set;
+    scores and the v4 vector are recomputed and the override is kept across re-scans.
+    Scan owner or organization security team only; the analyst is the signed-in login.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    session = await require_scan_triager(session_id, scan)
+    finding = _get_finding_or_404(scan, fingerprint)
+    if not request.vector and not request.metrics:
+        raise HTTPException(status_code=400, detail="Provide a CVSS vector or metrics to override.")
+
+    override = {**request.model_dump(), "analyst": session["github_user"]}
+    try:
+        cvss = cvss_calculator.score(finding, scan.get("surface"), override)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+
+    await db.update_finding(scan_id, fingerprint, {"cvss_override": override, "cvss": cvss})
+    return {"scan_id": scan_id, "fingerprint": fingerprint, "cvss": cvss}
+
+
+@router.delete("/{scan_id}/findings/{fingerprint}/cvss")
+async def reset_cvss(scan_id: str, fingerprint: str, session_id: Optional[str] = None):
+    """Removes the triage override and restores the derived CVSS vector (scan owner or security team)."""
+    scan = await _get_scan_or_404(scan_id)
+    await require_scan_triager(session_id, scan)
+    finding = _get_finding_or_404(scan, fingerprint)
+
+    cvss = cvss_calculator.score(finding, scan.get("surface"))
+    await db.update_finding(scan_id, fingerprint, {"cvss_override": None, "cvss": cvss})
+    return {"scan_id": scan_id, "fingerprint": fingerprint, "cvss": cvss}
+
+
+@router.get("/{scan_id}/sarif")
+async def export_sarif(scan_id: str):
+    """
+    SARIF 2.1.0 export of the scan findings (GitHub code scanning upload 등).
+    Each result carries CVSS v3.1 base/environmental scores and the v4 vector.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    _with_cvss(scan)
+    await exception_manager.apply_to_scan(scan)
+    return JSONResponse(sarif_exporter.export(scan), media_type="application/sarif+json")
+
+
+@router.get("/{scan_id}/gate")
+async def evaluate_gate(scan_id: str):
+    """
+    Security gate for CI (GitHub Checks shape: `conclusion` + `output`).
+    Fails on findings at or above the organization's `fail_on` severity (`PUT /orgs/{org}/gating`);
+    findings under an approved, unexpired risk exception are non-blocking.
+    """
+    scan = await _get_scan_or_404(scan_id)
+    if scan["status"] != "completed":
+        raise HTTPException(status_code=409, detail=f"Scan is {scan['status']}; the gate is evaluated once it completes.")
+
+    findings = _with_cvss(scan)
+    await exception_manager.apply_to_scan(scan)
+    org_settings = await db.get_org_settings(org_of(scan["target"]))
+    return {"scan_id": scan_id, "target": scan["target"], **gate_policy.evaluate(findings, org_settings.get("gating"))}
+
+
+@router.get("/{scan_id}/report")
+async def export_report(scan_id: str, format: str = "html"):
+    """
+    Stakeholder report for a completed scan: executive summary, findings by severity with code,
+    verified fixes as diffs, trend against the previous scan and methodology.
+
+    - format: html (self-contained file) | pdf (rendered locally) | json
+    - PDF uses the built-in Latin fonts only; reports containing Korean (or other non-Latin) text return 422, use html.
+    - Branding (logo, color, footer) comes from the target's organization (`PUT /orgs/{org}/branding`).
+    """
+    scan = await _get_scan_or_404(scan_id)
+    if scan["status"] != "completed":
+        raise HTTPException(status_code=409, detail=f"Scan is {scan['status']}; reports are available once it completes.")
+
+    _with_cvss(scan)
+    await exception_manager.apply_to_scan(scan)
+    await sla_tracker.annotate_scan(scan)
+    previous = await db.get_previous_scan(scan["target"], scan["created_at"]) if scan.get("created_at") else None
+    if previous:
+        _with_cvss(previous)
+    org_settings = await db.get_org_settings(org_of(scan["target"]))
+    report = scan_report_builder.build(scan, previous, org_settings.get("branding"))
+
+    filename = f"redeye-report-{report['project']}-{scan_id[:8]}"
+    if format == "html":
+        return HTMLResponse(scan_report_builder.to_html(report))
+    if format == "pdf":
+        unsupported = scan_report_builder.pdf_unsupported_text(report)
+        if unsupported:
+            raise HTTPException(
+                status_code=422,
+                detail=f"PDF export only supports Latin text; the report contains {unsupported[:40]!r}. Use format=html."
+            )
+        pdf = await asyncio.to_thread(scan_report_builder.to_pdf, report)
+        return Response(content=pdf, media_type="application/pdf",
+                        headers={"Content-Disposition": f'attachment; filename="{filename}.pdf"'})
+    if format == "json":
+        return report
+    raise HTTPException(status_code=400, detail="format must be one of: html, pdf, json")
+
+
+@router.post("/{scan_id}/fixes/verify")
+async def verify_fix(scan_id: str, request: FixVerificationRequest, session_id: Optional[str] = None):
+    """
+    Applies a fix to a sandbox copy of the scanned repository and runs the project's tests
+    before/after (go test / pytest / npm test).
+
+    - 제출된 코드를 실행하므로 스캔을 시작한 계정만 가능 (session_id)
+
+    - verdict: accepted (회귀 없음) | rejected (빌드 실패 또는 테스트 회귀) | unverified (테스트 없음/실행 불가)
+    - 결과는 스캔에 저장되며, rejected fix는 리포트(PR 코멘트)에서 제거됩니다.
+    - rejected가 아니면 보안 회귀 테스트를 생성하고 취약/수정 코드 양쪽에서 검증합니다 (`regression_test`).
+    """
+    scan = await _get_scan_or_404(scan_id)
+    await require_scan_owner(session_id, scan)
+    async with workspace_manager.use(scan["target"]) as root_dir:
+        if not root_dir:
+            raise HTTPException(status_code=409, detail="No local workspace for this target. Fix verification needs the repository source.")
+
+        result = await asyncio.to_thread(
+            fix_verifier.verify, root_dir, request.file, request.original_code, request.fixed_code
+        )
+        if result["verdict"] != "rejected":
+            result["regression_test"] = await asyncio.to_thread(
+                regression_test_generator.generate_for_fix, root_dir, request.file,
+                request.original_code, request.fixed_code, request.vulnerability, request.cwe
+            )
+    await db.save_fix_verification(scan_id, result)
+    return result
+
+
+@router.get("/{scan_id}/fixes")
+async def get_fix_verifications(scan_id: str):
+    """Fix verification results recorded for the scan (verdict, test deltas, diff)."""
+    scan = await _get_scan_or_404(scan_id)
+    fixes = scan.get("fix_verifications") or []
+    return {
+        "scan_id": scan_id,
+        "accepted": sum(1 for f in fixes if f["verdict"] == "accepted"),
+        "rejected": sum(1 for f in fixes if f["verdict"] == "rejected"),
+        "fixes": fixes
+    }
+
+
+@router.get("/{scan_id}/threat-model")
+async def get_threat_model(scan_id: str, format: str = "json", llm: bool = False):
+    """
+    STRIDE threat model for the scanned project.
+
+    - format: json (structured model) | markdown | mermaid | threat-dragon
+    - llm=true: optional LLM pass adds project-specific threats on top of the deterministic baseline
+    """
+    scan = await _get_scan_or_404(scan_id)
+    target = scan["target"]
+    project_name = target.rstrip("/").removesuffix(".git").split("/")[-1] or target
+
+    surface = scan.get("surface")
+    async with workspace_manager.use(target) as root_dir:
+        if not surface and root_dir:
+            surface = await asyncio.to_thread(attack_surface_extractor.extract, root_dir)
+
+        model = await asyncio.to_thread(
+            threat_model_generator.generate, project_name, surface, scan.get("findings") or [], root_dir
+        )
+    if llm:
+        model = await threat_model_generator.enrich_with_llm(model)
+
+    if format == "markdown":
+        return PlainTextResponse(model["markdown"], media_type="text/markdown")
+    if format == "mermaid":
+        return PlainTextResponse(model["mermaid"])
+    if format == "threat-dragon":
+        return model["threat_dragon"]
+    return model
+
+
+@router.get("/{scan_id}/chat")
+async def get_scan_chat(scan_id: str):
+    """Persisted follow-up conversation of the scan."""
+    scan = await _get_scan_or_404(scan_id)
+    return {"scan_id": scan_id, "messages": scan.get("chat") or []}
+
+
+@router.post("/{scan_id}/chat")
+async def chat_with_scan(scan_id: str, request: ChatRequest, session_id: Optional[str] = None):
+    """
+    Follow-up Q&A on a completed scan ("why is line 42 vulnerable?", "give me a Go version of this fix").
+    Answers are grounded in the stored findings, source around the referenced lines, reachability traces,
+    PoC evidence and RAG past solutions; the agent can re-verify findings and fixes with its tools.
+    Those tools run code in the sandbox, so only the account that started the scan can chat (session_id).
+    """
+    scan = await _get_scan_or_404(scan_id)
+    await require_scan_owner(session_id, scan)
+    if scan["status"] != "completed":
+        raise HTTPException(status_code=409, detail=f"Scan is {scan['status']}; chat is available once it completes.")
+    if not request.message.strip():
+        raise HTTPException(status_code=400, detail="Message is empty.")
+
+    _with_cvss(scan)
+    try:
+        return await scan_chat_service.ask(scan, request.message.strip())
+    except Exception as e:
+        logger.error(f"Scan chat failed: {e}")
+        raise HTTPException(status_code=500, detail=f"Chat failed: {e}")
+
+
+@router.delete("/{scan_id}/chat")
+async def clear_scan_chat(scan_id: str, session_id: Optional[str] = None):
+    """Clears the conversation memory of the scan (scan owner only)."""
+    scan = await _get_scan_or_404(scan_id)
+    await require_scan_owner(session_id, scan)
+    await db.clear_chat(scan_id)
+    return {"scan_id": scan_id, "cleared": True}
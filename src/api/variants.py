@@ -0,0 +1,116 @@
+from fastapi import APIRouter, HTTPException
+from pydantic import BaseModel
+from typing import Optional
+from src.database import db
+from src.services.workspace import workspace_manager
+from src.services.variant_analysis import variant_analyzer
+import asyncio
+import uuid
+import logging
+
+router = APIRouter(prefix="/variants", tags=["Variant Analysis"])
+logger = logging.getLogger(__name__)
+
+TRIAGE_STATUSES = {"new", "confirmed", "false_positive", "fixed", "wont_fix"}
+
+
+# --- Request Models ---
+class VariantHuntRequest(BaseModel):
+    scan_id: str
+    fingerprint: str
+    use_embeddings: bool = True   # OpenAI embeddings; falls back to local token similarity
+    limit: int = 50
+
+class CandidateTriageRequest(BaseModel):
+    status: str                   # new | confirmed | false_positive | fixed | wont_fix
+    analyst: Optional[str] = None
+    note: Optional[str] = None
+
+
+def _require_db():
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+
+
+def _embedder():
+    """Batch embedding function backed by the RAG embeddings model, or None if unavailable."""
+    try:
+        from src.rag_engine import rag_service
+        return lambda texts: rag_service.embeddings.embed_documents(texts)
+    except Exception as e:
+        logger.warning(f"Embeddings unavailable: {e}")
+        return None
+
+
+# --- Endpoints ---
+
+@router.post("")
+async def start_variant_hunt(request: VariantHuntRequest):
+    """
+    Hunts for variants of a finding across every cached project workspace:
+    the finding is generalized into a structural query (sink call + argument shape,
+    identifiers abstracted), matched in all projects of the same language and ranked
+    by structural + embedding similarity. Candidates start in triage status "new".
+    """
+    _require_db()
+    scan = await db.get_scan(request.scan_id)
+    if not scan:
+        raise HTTPException(status_code=404, detail="Scan not found")
+    finding = next((f for f in scan.get("findings") or [] if f.get("fingerprint") == request.fingerprint), None)
+    if not finding:
+        raise HTTPException(status_code=404, detail="Finding not found")
+
+    async with workspace_manager.use_all() as workspaces:
+        if not workspaces:
+            raise HTTPException(status_code=409, detail="No cached project workspaces to search.")
+        root_dir = workspace_manager.get(scan["target"]) if "github.com" in scan["target"] else None
+
+        try:
+            result = await asyncio.to_thread(
+                variant_analyzer.hunt, finding, workspaces, root_dir,
+                _embedder() if request.use_embeddings else None, request.limit
+            )
+        except ValueError as e:
+            raise HTTPException(status_code=400, detail=str(e))
+
+    hunt = {
+        "hunt_id": str(uuid.uuid4()),
+        "scan_id": request.scan_id,
+        "fingerprint": request.fingerprint,
+        "origin": {k: finding.get(k) for k in ("alert", "cwe", "file", "line")} | {"target": scan["target"]},
+        **result
+    }
+    await db.save_variant_hunt(hunt)
+    hunt.pop("_id", None)
+    return hunt
+
+
+@router.get("")
+async def list_variant_hunts(scan_id: Optional[str] = None):
+    """Previous variant hunts (without candidates), newest first."""
+    _require_db()
+    return {"hunts": await db.list_variant_hunts(scan_id)}
+
+
+@router.get("/{hunt_id}")
+async def get_variant_hunt(hunt_id: str, status: Optional[str] = None):
+    """Candidates of a hunt ranked by score, optionally filtered by triage status."""
+    _require_db()
+    hunt = await db.get_variant_hunt(hunt_id)
+    if not hunt:
+        raise HTTPException(status_code=404, detail="Variant hunt not found")
+    if status:
+        hunt["candidates"] = [c for c in hunt["candidates"] if c.get("status") == status]
+    return hunt
+
+
+@router.put("/{hunt_id}/candidates/{candidate_id}")
+async def triage_candidate(hunt_id: str, candidate_id: str, request: CandidateTriageRequest):
+    """Records the triage decision for a candidate variant."""
+    _require_db()
+    if request.status not in TRIAGE_STATUSES:
+        raise HTTPException(status_code=400, detail=f"status must be one of: {', '.join(sorted(TRIAGE_STATUSES))}")
+    updated = await db.update_variant_candidate(hunt_id, candidate_id, request.model_dump())
+    if not updated:
+        raise HTTPException(status_code=404, detail="Variant hunt or candidate not found")
+    return {"hunt_id": hunt_id, "candidate_id": candidate_id, **request.model_dump()}
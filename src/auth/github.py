@@ -1,11 +1,14 @@
 import os
+import asyncio
+import ipaddress
 import httpx
-from fastapi import APIRouter, HTTPException
+from fastapi import APIRouter, HTTPException, Request, BackgroundTasks
 from fastapi.responses import RedirectResponse, JSONResponse
 from pydantic import BaseModel
 from typing import List, Optional
 from dotenv import load_dotenv
 from src.database import db
+from src.config import settings
 
 load_dotenv()
 
@@ -28,13 +31,80 @@ class Repo(BaseModel):
     language: Optional[str] = None
 
 
+def _is_trusted_proxy(address: str) -> bool:
+    try:
+        ip = ipaddress.ip_address(address.strip())
+    except ValueError:
+        return False
+    return any(ip in ipaddress.ip_network(proxy, strict=False) for proxy in settings.TRUSTED_PROXIES)
+
+
+def client_ip(request: Request) -> Optional[str]:
+    """
+    요청한 클라이언트 IP. X-Forwarded-For는 직접 연결한 상대가 TRUSTED_PROXIES일 때만 사용하고,
+    오른쪽부터 신뢰하지 않는 첫 주소를 택함 (클라이언트가 앞에 붙인 값은 무시).
+    """
+    ip = request.client.host if request.client else None
+    if not ip or not _is_trusted_proxy(ip):
+        return ip
+    for hop in reversed([h.strip() for h in request.headers.get("x-forwarded-for", "").split(",") if h.strip()]):
+        ip = hop
+        if not _is_trusted_proxy(hop):
+            break
+    return ip
+
+
+def describe_device(request: Request) -> dict:
+    """로그인 요청의 기기 정보 (User-Agent, IP, 'Chrome on macOS' 형태의 라벨)."""
+    user_agent = request.headers.get("user-agent", "")
+    ip = client_ip(request)
+
+    ua = user_agent.lower()
+    browser = next((name for key, name in [
+        ("edg/", "Edge"), ("opr/", "Opera"), ("firefox/", "Firefox"), ("chrome/", "Chrome"),
+        ("safari/", "Safari"), ("curl/", "curl"), ("python", "Python")
+    ] if key in ua), "Unknown browser")
+    os_name = next((name for key, name in [
+        ("android", "Android"), ("iphone", "iOS"), ("ipad", "iPadOS"), ("windows", "Windows"),
+        ("mac os", "macOS"), ("linux", "Linux")
+    ] if key in ua), "unknown OS")
+    return {"user_agent": user_agent[:300], "ip": ip, "label": f"{browser} on {os_name}"}
+
+
+async def token_is_valid(client: httpx.AsyncClient, access_token: str) -> bool:
+    """GitHub에 OAuth 토큰이 아직 유효한지 확인 (앱 권한 철회·토큰 재발급 시 False)."""
+    response = await client.post(
+        f"https://api.github.com/applications/{GITHUB_CLIENT_ID}/token",
+        auth=(GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET),
+        json={"access_token": access_token},
+        headers={"Accept": "application/vnd.github+json"}
+    )
+    # 404/422 = 유효하지 않은 토큰. 그 외 오류는 판단 불가 → 유효로 취급
+    return response.status_code not in (404, 422)
+
+
+async def revoke_invalid_sessions(github_id: int, tokens: List[str]):
+    """
+    기존 세션 토큰을 GitHub에 동시에 확인하고, 무효화된 토큰(앱 권한 철회, 재발급, 토큰 수 제한)을 가진
+    세션만 폐기. 로그인 응답 후 백그라운드에서 실행되어 세션 수만큼 로그인이 느려지지 않음.
+    """
+    try:
+        async with httpx.AsyncClient(timeout=10.0) as client:
+            valid = await asyncio.gather(*(token_is_valid(client, t) for t in tokens), return_exceptions=True)
+        invalid = [t for t, ok in zip(tokens, valid) if ok is False]
+        if invalid:
+            await db.revoke_sessions_with_tokens(github_id, invalid)
+    except Exception as e:
+        print(f"⚠️ Session token check failed for user {github_id}: {e}")
+
+
 @router.get("/auth/github/login")
 async def login_with_github():
     """GitHub OAuth 로그인 페이지로 리다이렉트."""
     if not GITHUB_CLIENT_ID:
         raise HTTPException(status_code=500, detail="Server misconfigured: Missing GITHUB_CLIENT_ID")
     
-    scope = "read:user repo"
+    scope = "read:user read:org repo"  # read:org: org admin checks (src/auth/permissions.py)
     github_auth_url = (
         f"https://github.com/login/oauth/authorize"
         f"?client_id={GITHUB_CLIENT_ID}"
@@ -45,13 +115,16 @@ async def login_with_github():
 
 
 @router.get("/auth/github/callback")
-async def github_callback(code: str):
+async def github_callback(code: str, request: Request, background_tasks: BackgroundTasks):
     """
     GitHub OAuth 콜백: 
     1. code → access_token 교환
     2. GitHub 유저 정보 가져오기
-    3. MongoDB에 세션 저장
+    3. MongoDB에 세션 저장 (로그인마다 별도 세션, 기기 정보 포함)
     4. session_id만 프런트엔드에 전달
+
+    기존 세션 중 GitHub에서 토큰이 무효화된 세션(앱 권한 철회, 토큰 재발급)은
+    로그인 후 백그라운드에서 폐기 (유효한 다른 기기의 세션은 유지).
     """
     if not GITHUB_CLIENT_ID or not GITHUB_CLIENT_SECRET:
         raise HTTPException(status_code=500, detail="Server misconfigured: Missing Credentials")
@@ -92,8 +165,16 @@ async def github_callback(code: str):
         
         github_user = user_response.json()
 
+    # 토큰 변경 확인: 무효화된 토큰의 세션만 폐기 (응답 후 실행)
+    previous_tokens = list({
+        s["access_token"] for s in await db.get_session_tokens(github_user.get("id"))
+        if s.get("access_token") and s["access_token"] != access_token
+    })
+    if previous_tokens:
+        background_tasks.add_task(revoke_invalid_sessions, github_user.get("id"), previous_tokens)
+
     # 3. MongoDB에 세션 저장
-    session_id = await db.save_user_session(github_user, access_token)
+    session_id = await db.save_user_session(github_user, access_token, describe_device(request))
 
     # 4. session_id만 프런트엔드에 전달 (access_token 비노출)
     return RedirectResponse(url=f"{FRONTEND_URL}/?session_id={session_id}")
@@ -122,6 +203,58 @@ async def logout(session_id: str):
     return {"message": "로그아웃 완료"}
 
 
+@router.get("/auth/sessions")
+async def list_sessions(session_id: str):
+    """
+    로그인된 모든 기기의 세션 목록 (기기, IP, 로그인·최근 사용 시각).
+    다른 세션의 session_id는 노출하지 않고 `id`(public_id)로 식별.
+    """
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="세션이 만료되었거나 유효하지 않습니다")
+
+    sessions = await db.list_user_sessions(session["github_id"])
+    return {
+        "idle_timeout_hours": settings.SESSION_IDLE_TIMEOUT_HOURS or None,
+        "sessions": [
+            {
+                "id": s["public_id"],
+                "current": s["session_id"] == session_id,
+                "device": (s.get("device") or {}).get("label") or "Unknown device",
+                "user_agent": (s.get("device") or {}).get("user_agent"),
+                "ip": (s.get("device") or {}).get("ip"),
+                "created_at": s.get("created_at"),
+                "last_seen_at": s.get("last_seen_at") or s.get("created_at"),
+                "expires_at": s.get("expires_at"),
+            }
+            for s in sessions
+        ]
+    }
+
+
+@router.delete("/auth/sessions/{public_id}")
+async def revoke_session(public_id: str, session_id: str):
+    """세션 하나 폐기 (다른 기기 로그아웃). 본인 세션만 폐기 가능."""
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="세션이 만료되었거나 유효하지 않습니다")
+
+    if not await db.revoke_user_session(session["github_id"], public_id):
+        raise HTTPException(status_code=404, detail="Session not found")
+    return {"revoked": public_id, "current": public_id == session.get("public_id")}
+
+
+@router.post("/auth/sessions/revoke-all")
+async def revoke_all_sessions(session_id: str, keep_current: bool = True):
+    """모든 기기에서 로그아웃. keep_current=true면 현재 세션은 유지."""
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="세션이 만료되었거나 유효하지 않습니다")
+
+    revoked = await db.revoke_all_user_sessions(session["github_id"], session_id if keep_current else None)
+    return {"revoked": revoked, "kept_current": keep_current}
+
+
 @router.get("/user/repos", response_model=List[Repo])
 async def get_user_repos(session_id: str):
     """
@@ -152,6 +285,8 @@ async def get_user_repos(session_id: str):
         )
         
         if response.status_code == 401:
+            # 이 세션의 토큰이 무효화됨 (앱 권한 철회 등) → 같은 토큰의 세션만 폐기, 다른 기기는 유지
+            await db.revoke_sessions_with_tokens(session["github_id"], [session["access_token"]])
             raise HTTPException(status_code=401, detail="GitHub 토큰이 만료되었습니다. 다시 로그인해주세요.")
         if response.status_code != 200:
             raise HTTPException(status_code=response.status_code, detail="Failed to fetch repos")
@@ -0,0 +1,96 @@
+import re
+import httpx
+from typing import Optional
+from fastapi import HTTPException
+from src.database import db
+from src.config import settings
+from src.services.workspace import org_of
+
+GITHUB_LOGIN = re.compile(r"[a-z0-9](?:[a-z0-9-]{0,38})")
+
+
+async def require_session(session_id: Optional[str]) -> dict:
+    """Signed-in session for endpoints that must not be open to anonymous callers (401 otherwise)."""
+    if not session_id:
+        raise HTTPException(status_code=401, detail="Sign in with GitHub and pass session_id.")
+    if db.db is None:
+        raise HTTPException(status_code=500, detail="Database connection failed. Check MONGO_URI.")
+    session = await db.get_user_session(session_id)
+    if not session:
+        raise HTTPException(status_code=401, detail="Session expired or invalid.")
+    return session
+
+
+async def require_scan_owner(session_id: Optional[str], scan: dict) -> dict:
+    """
+    Session of the account that started the scan (`owner`, set when the scan was started
+    signed in). Anonymous scans have no owner, so nobody can run code against them.
+    """
+    session = await require_session(session_id)
+    owner = scan.get("owner") or {}
+    if owner.get("github_id") != session["github_id"]:
+        raise HTTPException(status_code=403, detail="Only the account that started this scan can do this.")
+    return session
+
+
+async def require_scan_triager(session_id: Optional[str], scan: dict) -> dict:
+    """
+    Session of the scan owner or of a security team member of the scan's organization,
+    for triage changes (CVSS overrides) that feed the gate, SARIF and reports.
+    """
+    session = await require_session(session_id)
+    if (scan.get("owner") or {}).get("github_id") == session["github_id"]:
+        return session
+    team = (await db.get_org_settings(org_of(scan["target"]))).get("security_team") or []
+    if session["github_user"].lower() not in {m.lower() for m in team}:
+        raise HTTPException(status_code=403, detail="Only the scan owner or the organization's security team can triage this scan.")
+    return session
+
+
+async def require_org_admin(session_id: Optional[str], org: str) -> dict:
+    """
+    Session of an administrator of `org`, for settings that change how the whole organization is
+    gated, notified or exported. Verified against GitHub on every call (role changes apply at once):
+
+    - a personal account administers itself
+    - a GitHub organization requires an active admin membership (token scope `read:org`)
+    - web-host orgs (example.com) have no GitHub counterpart: only logins listed in ORG_ADMINS
+    """
+    return await _require_org_role(session_id, org, admin=True)
+
+
+async def require_org_member(session_id: Optional[str], org: str) -> dict:
+    """Session of an active member (any role) of `org`, for organization-internal data. Same rules as require_org_admin."""
+    return await _require_org_role(session_id, org, admin=False)
+
+
+async def _require_org_role(session_id: Optional[str], org: str, admin: bool) -> dict:
+    session = await require_session(session_id)
+    org, login = org.lower(), session["github_user"].lower()
+    role = "admins" if admin else "members"
+    admins = {a.lower() for key, logins in settings.ORG_ADMINS.items() if key.lower() == org for a in logins}
+    if org == login or login in admins:
+        return session
+    if not GITHUB_LOGIN.fullmatch(org):
+        raise HTTPException(status_code=403, detail=f"Only accounts listed in ORG_ADMINS for {org} can do this.")
+
+    async with httpx.AsyncClient() as client:
+        response = await client.get(
+            f"https://api.github.com/user/memberships/orgs/{org}",
+            headers={
+                "Authorization": f"Bearer {session['access_token']}",
+                "Accept": "application/vnd.github.v3+json"
+            }
+        )
+    if response.status_code == 401:
+        raise HTTPException(status_code=401, detail="GitHub token expired. Sign in again.")
+    if response.status_code in (403, 404):
+        # 403 also covers sessions signed in before read:org was requested
+        raise HTTPException(status_code=403, detail=f"Only {role} of the GitHub organization {org} can do this "
+                                                    f"(sign in again if you are one).")
+    if response.status_code != 200:
+        raise HTTPException(status_code=502, detail="Could not verify the organization membership with GitHub.")
+    membership = response.json()
+    if membership.get("state") != "active" or (admin and membership.get("role") != "admin"):
+        raise HTTPException(status_code=403, detail=f"Only {role} of the GitHub organization {org} can do this.")
+    return session
@@ -2,10 +2,21 @@ import os
 from dotenv import load_dotenv
 from pydantic_settings import BaseSettings
 from pydantic import Field
+from typing import Dict, List
 
 # Load .env file
 load_dotenv()
 
+# Default retention in days per collection. Sessions always expire at their own expires_at.
+DEFAULT_RETENTION_DAYS = {
+    "scans": 365,
+    "training_data": 730,
+    "vulnerability_vectors": 365,
+    "explanations": 180,
+    "variant_hunts": 365,
+    "sla_notifications": 400,
+}
+
 class Settings(BaseSettings):
     # API Keys
     OPENAI_API_KEY: str = ""
@@ -30,6 +41,46 @@ class Settings(BaseSettings):
     SCAN_COLLECTION: str = "scans"
     VULN_COLLECTION: str = "vulnerabilities"
 
+    # Cached repository clones (default: <tmp>/redeye-workspaces)
+    WORKSPACE_DIR: str = ""
+    # Commits fetched into a shallow workspace before git blame attribution
+    BLAME_HISTORY_DEPTH: int = 500
+
+    # Login sessions: fixed lifetime, and sign-out after this many hours without use (0 = no idle timeout)
+    SESSION_MAX_DAYS: int = 30
+    SESSION_IDLE_TIMEOUT_HOURS: int = 72
+    # Reverse proxies (IPs or CIDRs) whose X-Forwarded-For is trusted for the session's client IP
+    TRUSTED_PROXIES: List[str] = []
+
+    # Admins of web-host organizations (no GitHub org to check), and extra admins of GitHub orgs,
+    # e.g. ORG_ADMINS='{"example.com": ["alice"]}'
+    ORG_ADMINS: Dict[str, List[str]] = {}
+
+    # Notification webhooks may only target these hosts (and their subdomains) when set,
+    # e.g. WEBHOOK_ALLOWED_HOSTS='["hooks.slack.com"]'. Private/internal addresses are always refused.
+    WEBHOOK_ALLOWED_HOSTS: List[str] = []
+
+    # Risk exceptions: longest allowed acceptance before a renewal is required
+    EXCEPTION_MAX_DAYS: int = 365
+
+    # Data retention overrides in days per collection (TTL indexes, 0 = keep forever),
+    # e.g. RETENTION_DAYS='{"scans": 180, "training_data": 0}'. Merged over DEFAULT_RETENTION_DAYS.
+    RETENTION_DAYS: Dict[str, int] = {}
+
+    # Sandbox (Dynamic Confirmation)
+    # "bwrap": bubblewrap namespaces (no network, only the workspace and toolchains visible).
+    # "none" runs untrusted code directly on the host: trusted local development only.
+    SANDBOX_ISOLATION: str = "bwrap"
+    # Extra read-only paths visible inside the sandbox (toolchains outside /usr, e.g. ~/.pyenv)
+    SANDBOX_READONLY_PATHS: List[str] = []
+    SANDBOX_TIMEOUT_SECONDS: int = 120
+    SANDBOX_MEMORY_MB: int = 2048
+    GO_FUZZ_TIME_SECONDS: int = 30
+    # Upper bound for the fuzzing time of one request (all targets together)
+    GO_FUZZ_MAX_SECONDS: int = 300
+    POC_STARTUP_TIMEOUT_SECONDS: int = 60
+    TEST_TIMEOUT_SECONDS: int = 600
+
     class Config:
         env_file = ".env"
         extra = "ignore"
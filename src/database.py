@@ -1,8 +1,41 @@
 from motor.motor_asyncio import AsyncIOMotorClient
-from .config import settings
+from .config import settings, DEFAULT_RETENTION_DAYS
 from datetime import datetime, timedelta
+import re
 import uuid
 
+# Timestamp each collection's retention TTL index is built on
+RETENTION_FIELDS = {
+    "sessions": "expires_at",
+    "scans": "created_at",
+    "training_data": "created_at",
+    "vulnerability_vectors": "created_at",
+    "explanations": "generated_at",
+    "variant_hunts": "created_at",
+    "sla_notifications": "sent_at",
+}
+
+# Collections in an organization export and the natural key they are re-imported by
+# (sessions hold OAuth tokens and are never exported)
+EXPORT_KEYS = {
+    "organizations": ["org"],
+    "scans": ["scan_id"],
+    "exceptions": ["exception_id"],
+    "explanations": ["fingerprint"],
+    "variant_hunts": ["hunt_id"],
+    "sla_notifications": ["org", "target", "fingerprint", "level"],
+    "training_data": ["_id"],
+    "vulnerability_vectors": ["_id"],
+}
+
+DELETED_USER = "deleted-user"
+
+
+def _org_target_pattern(org: str) -> str:
+    """Scan targets of an organization: its GitHub repositories or its web host (see workspace.org_of)."""
+    return f"github\\.com/{re.escape(org)}/|^https?://{re.escape(org)}(:\\d+)?(/|$)"
+
+
 class Database:
     client: AsyncIOMotorClient = None
     db = None
@@ -20,6 +53,37 @@ class Database:
             cls.db = cls.client[settings.DB_NAME]
             print(f"✅ Connected to MongoDB: {settings.DB_NAME}")
 
+    @classmethod
+    async def ensure_retention_indexes(cls) -> dict:
+        """
+        Creates / updates the TTL index of every collection with a retention period
+        (settings.RETENTION_DAYS over DEFAULT_RETENTION_DAYS; 0 drops it = keep forever).
+        Returns collection → days (sessions: 0 = at expires_at, None = kept forever).
+        """
+        retention = {**DEFAULT_RETENTION_DAYS, **settings.RETENTION_DAYS}
+        applied = {}
+        for collection, field in RETENTION_FIELDS.items():
+            # Sessions carry their own expiry timestamp: remove them exactly then
+            days = 0 if collection == "sessions" else retention.get(collection, 0)
+            name = f"retention_{field}"
+            try:
+                existing = (await cls.db[collection].index_information()).get(name)
+                if collection != "sessions" and not days:
+                    if existing:
+                        await cls.db[collection].drop_index(name)
+                    applied[collection] = None
+                    continue
+                seconds = days * 86400
+                if not existing:
+                    await cls.db[collection].create_index(field, name=name, expireAfterSeconds=seconds)
+                elif existing.get("expireAfterSeconds") != seconds:
+                    await cls.db.command("collMod", collection, index={"name": name, "expireAfterSeconds": seconds})
+                applied[collection] = days
+            except Exception as e:
+                print(f"⚠️ Retention index on {collection}.{field} failed: {e}")
+        print(f"🗓️ Retention TTL indexes: {applied}")
+        return applied
+
     @classmethod
     async def close(cls):
         """Close MongoDB connection."""
@@ -35,14 +99,15 @@ class Database:
 
     # --- Scan Management ---
     @classmethod
-    async def create_scan(cls, scan_id: str, target_url: str):
-        """Create a new scan record."""
+    async def create_scan(cls, scan_id: str, target_url: str, owner: dict = None):
+        """Create a new scan record. `owner` ({github_id, github_user}) links it to an account for deletion."""
         await cls.db["scans"].insert_one({
             "scan_id": scan_id,
             "target": target_url,
             "status": "pending",
             "agent_response": None,
-            "created_at": None
+            "owner": owner,
+            "created_at": datetime.utcnow()
         })
 
     @classmethod
@@ -57,43 +122,337 @@ class Database:
             {"$set": update_data}
         )
 
+    @classmethod
+    async def save_scan_surface(cls, scan_id: str, surface: dict):
+        """Store the attack surface inventory extracted before scanning."""
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$set": {"surface": surface}}
+        )
+
+    @classmethod
+    async def save_scan_traffic(cls, scan_id: str, traffic: dict):
+        """Store recorded traffic (HAR / recording proxy) that drives the DAST phase."""
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$set": {"traffic": traffic}}
+        )
+
+    @classmethod
+    async def save_findings(cls, scan_id: str, findings: list):
+        """
+        Store normalized findings on the scan (merged by fingerprint, so repeated
+        tool calls during one scan do not duplicate them).
+        """
+        scan = await cls.db["scans"].find_one({"scan_id": scan_id}, {"findings": 1, "target": 1, "created_at": 1})
+        merged = {f["fingerprint"]: f for f in (scan or {}).get("findings") or []}
+        first_seen = await cls._first_seen(scan, [f["fingerprint"] for f in findings if f["fingerprint"] not in merged])
+        for finding in findings:
+            previous = merged.get(finding["fingerprint"], {})
+            # Triage results recorded on the previous copy survive a re-scan
+            triage = {key: previous[key] for key in ("poc", "cvss_override") if key in previous}
+            if "cvss_override" in previous:
+                triage["cvss"] = previous["cvss"]
+            triage["first_seen"] = previous.get("first_seen") or first_seen.get(finding["fingerprint"]) or datetime.utcnow()
+            merged[finding["fingerprint"]] = {**finding, **triage}
+
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$set": {"findings": list(merged.values())}}
+        )
+
+    @classmethod
+    async def _first_seen(cls, scan: dict, fingerprints: list) -> dict:
+        """
+        First detection of findings that were already present in the previous scan of the same target
+        (carried forward scan to scan; the SLA clock starts there). Findings that were absent restart it.
+        """
+        if not scan or not fingerprints or not scan.get("created_at"):
+            return {}
+        previous = await cls.db["scans"].find_one(
+            {"target": scan["target"], "created_at": {"$lt": scan["created_at"]}, "status": "completed"},
+            {"_id": 0, "created_at": 1, "findings.fingerprint": 1, "findings.first_seen": 1},
+            sort=[("created_at", -1)]
+        )
+        if not previous:
+            return {}
+        wanted = set(fingerprints)
+        return {
+            f["fingerprint"]: f.get("first_seen") or previous["created_at"]
+            for f in previous.get("findings") or [] if f.get("fingerprint") in wanted
+        }
+
+    @classmethod
+    async def update_finding(cls, scan_id: str, fingerprint: str, fields: dict):
+        """Set fields (e.g. PoC confirmation) on a single stored finding."""
+        result = await cls.db["scans"].update_one(
+            {"scan_id": scan_id, "findings.fingerprint": fingerprint},
+            {"$set": {f"findings.$.{key}": value for key, value in fields.items()}}
+        )
+        return result.modified_count > 0
+
+    @classmethod
+    async def save_fix_verification(cls, scan_id: str, verification: dict):
+        """Append a fix verification result (verdict, test deltas, diff) to the scan."""
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$push": {"fix_verifications": verification}}
+        )
+
+    @classmethod
+    async def save_import(cls, scan_id: str, record: dict):
+        """Append an external scanner import (tool, format, counts) to the scan."""
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$push": {"imports": {**record, "created_at": datetime.utcnow()}}}
+        )
+
+    @classmethod
+    async def append_chat_messages(cls, scan_id: str, messages: list):
+        """Append follow-up chat messages ({role, content, created_at}) to the scan's conversation."""
+        await cls.db["scans"].update_one(
+            {"scan_id": scan_id},
+            {"$push": {"chat": {"$each": messages}}}
+        )
+
+    @classmethod
+    async def clear_chat(cls, scan_id: str):
+        await cls.db["scans"].update_one({"scan_id": scan_id}, {"$unset": {"chat": ""}})
+
     @classmethod
     async def get_scan(cls, scan_id: str):
         """Get scan by ID."""
         return await cls.db["scans"].find_one({"scan_id": scan_id}, {"_id": 0})
 
+    @classmethod
+    async def list_scans(cls, target: str = None, status: str = None, limit: int = 20, owner_id: int = None) -> list:
+        """Scans (newest first), optionally for one target / status / owning account."""
+        query = {}
+        if target:
+            query["target"] = target
+        if status:
+            query["status"] = status
+        if owner_id is not None:
+            query["owner.github_id"] = owner_id
+        cursor = cls.db["scans"].find(query, {"_id": 0}).sort("created_at", -1).limit(limit)
+        return await cursor.to_list(length=limit)
+
+    @classmethod
+    async def get_previous_scan(cls, target: str, before) -> dict:
+        """Latest completed scan of the same target created before `before`."""
+        return await cls.db["scans"].find_one(
+            {"target": target, "status": "completed", "created_at": {"$lt": before}},
+            {"_id": 0},
+            sort=[("created_at", -1)]
+        )
+
+    # --- Finding Explanations ---
+    @classmethod
+    async def get_explanation(cls, fingerprint: str) -> dict:
+        """Cached explanation of a finding (fingerprints are stable across scans)."""
+        return await cls.db["explanations"].find_one({"fingerprint": fingerprint}, {"_id": 0})
+
+    @classmethod
+    async def save_explanation(cls, explanation: dict):
+        await cls.db["explanations"].update_one(
+            {"fingerprint": explanation["fingerprint"]},
+            {"$set": explanation},
+            upsert=True
+        )
+
+    # --- Variant Analysis ---
+    @classmethod
+    async def save_variant_hunt(cls, hunt: dict):
+        await cls.db["variant_hunts"].insert_one({**hunt, "created_at": datetime.utcnow()})
+
+    @classmethod
+    async def get_variant_hunt(cls, hunt_id: str) -> dict:
+        return await cls.db["variant_hunts"].find_one({"hunt_id": hunt_id}, {"_id": 0})
+
+    @classmethod
+    async def list_variant_hunts(cls, scan_id: str = None, limit: int = 20) -> list:
+        query = {"scan_id": scan_id} if scan_id else {}
+        cursor = cls.db["variant_hunts"].find(query, {"_id": 0, "candidates": 0}).sort("created_at", -1).limit(limit)
+        return await cursor.to_list(length=limit)
+
+    @classmethod
+    async def update_variant_candidate(cls, hunt_id: str, candidate_id: str, fields: dict) -> bool:
+        """Triage state of one candidate (status, analyst, note)."""
+        result = await cls.db["variant_hunts"].update_one(
+            {"hunt_id": hunt_id, "candidates.id": candidate_id},
+            {"$set": {f"candidates.$.{key}": value for key, value in fields.items()}}
+        )
+        return result.matched_count > 0
+
+    # --- Risk Exceptions ---
+    @classmethod
+    async def save_exception(cls, exception: dict):
+        await cls.db["exceptions"].insert_one(dict(exception))
+
+    @classmethod
+    async def get_exception(cls, exception_id: str) -> dict:
+        return await cls.db["exceptions"].find_one({"exception_id": exception_id}, {"_id": 0})
+
+    @classmethod
+    async def list_exceptions(cls, repository: str = None, org: str = None, status: str = None,
+                              fingerprint: str = None, limit: int = 1000) -> list:
+        """Exceptions (newest first) of a repository (`owner/repo` or host) or of every repository of an org."""
+        query = {}
+        if repository:
+            query["repository"] = repository
+        elif org:
+            query["repository"] = {"$regex": f"^{re.escape(org)}/"}
+        if status:
+            query["status"] = status
+        if fingerprint:
+            query["fingerprint"] = fingerprint
+        cursor = cls.db["exceptions"].find(query, {"_id": 0}).sort("requested_at", -1).limit(limit)
+        return await cursor.to_list(length=limit)
+
+    @classmethod
+    async def update_exception(cls, exception_id: str, fields: dict):
+        await cls.db["exceptions"].update_one({"exception_id": exception_id}, {"$set": fields})
+
+    @classmethod
+    async def expire_exceptions(cls, now: datetime) -> int:
+        """Pending/approved exceptions past their expiry → expired. Returns how many changed."""
+        result = await cls.db["exceptions"].update_many(
+            {"status": {"$in": ["pending", "approved"]}, "expires_at": {"$lt": now}},
+            {"$set": {"status": "expired", "expired_at": now},
+             "$push": {"history": {"action": "expired", "by": "system", "at": now}}}
+        )
+        return result.modified_count
+
+    # --- Organization Settings ---
+    @classmethod
+    async def get_org_settings(cls, org: str) -> dict:
+        """Per-organization settings (report branding, ...). Organization = GitHub owner / web host."""
+        return await cls.db["organizations"].find_one({"org": org}, {"_id": 0}) or {"org": org}
+
+    @classmethod
+    async def save_org_branding(cls, org: str, branding: dict):
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"branding": branding, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def save_org_insights(cls, org: str, insights: dict):
+        """Opt-in for team security insights (introduced vs fixed)."""
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"insights": insights, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def save_org_security_team(cls, org: str, members: list):
+        """GitHub logins allowed to approve risk exceptions."""
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"security_team": members, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def save_org_gating(cls, org: str, gating: dict):
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"gating": gating, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def save_org_sla(cls, org: str, sla: dict):
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"sla": sla, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def save_org_notifications(cls, org: str, notifications: dict):
+        await cls.db["organizations"].update_one(
+            {"org": org},
+            {"$set": {"notifications": notifications, "updated_at": datetime.utcnow()}},
+            upsert=True
+        )
+
+    @classmethod
+    async def list_orgs_with_notifications(cls) -> list:
+        cursor = cls.db["organizations"].find({"notifications.channels.0": {"$exists": True}}, {"_id": 0})
+        return await cursor.to_list(length=None)
+
+    @classmethod
+    async def list_org_latest_scans(cls, org: str) -> list:
+        """Latest completed scan of every target of an organization (GitHub owner or web host)."""
+        cursor = cls.db["scans"].aggregate([
+            {"$match": {"target": {"$regex": _org_target_pattern(org), "$options": "i"}, "status": "completed"}},
+            {"$sort": {"created_at": -1}},
+            {"$group": {"_id": "$target", "scan": {"$first": "$$ROOT"}}},
+            {"$replaceRoot": {"newRoot": "$scan"}},
+            {"$project": {"_id": 0, "scan_id": 1, "target": 1, "created_at": 1, "findings": 1}},
+        ])
+        return await cursor.to_list(length=None)
+
+    @classmethod
+    async def list_sla_notifications(cls, org: str) -> list:
+        """SLA notifications already sent (one per target + fingerprint + level)."""
+        cursor = cls.db["sla_notifications"].find({"org": org}, {"_id": 0, "target": 1, "fingerprint": 1, "level": 1})
+        return await cursor.to_list(length=None)
+
+    @classmethod
+    async def save_sla_notifications(cls, records: list):
+        if records:
+            await cls.db["sla_notifications"].insert_many(records)
+
+    @classmethod
+    async def list_org_repo_scans(cls, org: str, limit: int = 500) -> list:
+        """Completed repository scans of an organization (GitHub owner), newest first, findings only."""
+        cursor = cls.db["scans"].find(
+            {"target": {"$regex": f"github\\.com/{re.escape(org)}/", "$options": "i"}, "status": "completed"},
+            {"_id": 0, "scan_id": 1, "target": 1, "created_at": 1,
+             "findings.fingerprint": 1, "findings.risk": 1, "findings.owners": 1, "findings.attribution": 1}
+        ).sort("created_at", -1).limit(limit)
+        return await cursor.to_list(length=limit)
+
     # --- GitHub Session Management ---
     @classmethod
-    async def save_user_session(cls, github_user: dict, access_token: str) -> str:
+    async def save_user_session(cls, github_user: dict, access_token: str, device: dict = None) -> str:
         """
         GitHub OAuth 로그인 후 세션을 MongoDB에 저장.
+        로그인마다 별도 세션을 만들어 다른 기기의 세션을 덮어쓰지 않음.
+        device: {user_agent, ip, label} (세션 목록에 표시)
         Returns: session_id (UUID)
         """
         session_id = str(uuid.uuid4())
-        
+        now = datetime.utcnow()
+
         session_data = {
             "session_id": session_id,
+            "public_id": uuid.uuid4().hex[:12],   # 세션 목록/폐기용 식별자 (session_id는 노출하지 않음)
             "github_id": github_user.get("id"),
             "github_user": github_user.get("login"),
             "avatar_url": github_user.get("avatar_url"),
             "access_token": access_token,
-            "created_at": datetime.utcnow(),
-            "expires_at": datetime.utcnow() + timedelta(days=30)
+            "device": device or {},
+            "created_at": now,
+            "last_seen_at": now,
+            "expires_at": now + timedelta(days=settings.SESSION_MAX_DAYS)
         }
-        
-        # 기존 세션이 있으면 업데이트, 없으면 생성
-        await cls.db["sessions"].update_one(
-            {"github_id": github_user.get("id")},
-            {"$set": session_data},
-            upsert=True
-        )
-        
+        await cls.db["sessions"].insert_one(session_data)
+
         print(f"✅ Session saved for {github_user.get('login')} ({session_id[:8]}...)")
         return session_id
 
     @classmethod
     async def get_user_session(cls, session_id: str) -> dict:
-        """session_id로 유저 세션 조회."""
+        """
+        session_id로 유저 세션 조회.
+        고정 만료(expires_at)와 유휴 만료(SESSION_IDLE_TIMEOUT_HOURS 동안 사용 없음)를 모두 확인하고,
+        활동 시각(last_seen_at)을 갱신.
+        """
         session = await cls.db["sessions"].find_one(
             {"session_id": session_id},
             {"_id": 0}
@@ -103,9 +462,20 @@ class Database:
             return None
         
         # 만료 확인
-        if session.get("expires_at") and session["expires_at"] < datetime.utcnow():
+        now = datetime.utcnow()
+        if session.get("expires_at") and session["expires_at"] < now:
+            await cls.delete_user_session(session_id)
+            return None
+
+        last_seen = session.get("last_seen_at") or session.get("created_at")
+        if settings.SESSION_IDLE_TIMEOUT_HOURS and last_seen and last_seen < now - timedelta(hours=settings.SESSION_IDLE_TIMEOUT_HOURS):
             await cls.delete_user_session(session_id)
             return None
+
+        # 요청마다 쓰지 않도록 5분 단위로만 갱신
+        if not last_seen or last_seen < now - timedelta(minutes=5):
+            await cls.db["sessions"].update_one({"session_id": session_id}, {"$set": {"last_seen_at": now}})
+            session["last_seen_at"] = now
         
         return session
 
@@ -115,9 +485,83 @@ class Database:
         await cls.db["sessions"].delete_one({"session_id": session_id})
         print(f"🗑️ Session deleted: {session_id[:8]}...")
 
+    @classmethod
+    async def list_user_sessions(cls, github_id: int) -> list:
+        """유저의 활성 세션 목록 (최근 사용 순, access_token 제외). 유휴 만료된 세션은 정리 전이라도 제외."""
+        now = datetime.utcnow()
+        query = {"github_id": github_id, "expires_at": {"$gt": now}}
+        if settings.SESSION_IDLE_TIMEOUT_HOURS:
+            query["last_seen_at"] = {"$gte": now - timedelta(hours=settings.SESSION_IDLE_TIMEOUT_HOURS)}
+        cursor = cls.db["sessions"].find(query, {"_id": 0, "access_token": 0}).sort("last_seen_at", -1)
+        sessions = await cursor.to_list(length=100)
+
+        # 다중 세션 이전에 만들어진 세션에 public_id 부여
+        for session in sessions:
+            if not session.get("public_id"):
+                session["public_id"] = uuid.uuid4().hex[:12]
+                await cls.db["sessions"].update_one(
+                    {"session_id": session["session_id"]}, {"$set": {"public_id": session["public_id"]}}
+                )
+        return sessions
+
+    @classmethod
+    async def get_session_tokens(cls, github_id: int) -> list:
+        """유저의 기존 세션이 가진 access_token 목록 (로그인 시 토큰 변경 확인용)."""
+        cursor = cls.db["sessions"].find({"github_id": github_id}, {"_id": 0, "access_token": 1})
+        return await cursor.to_list(length=100)
+
+    @classmethod
+    async def revoke_user_session(cls, github_id: int, public_id: str) -> bool:
+        """유저 본인의 세션 하나를 폐기 (다른 기기 로그아웃)."""
+        result = await cls.db["sessions"].delete_one({"github_id": github_id, "public_id": public_id})
+        if result.deleted_count:
+            print(f"🗑️ Session revoked: {public_id} (user {github_id})")
+        return bool(result.deleted_count)
+
+    @classmethod
+    async def revoke_sessions_with_tokens(cls, github_id: int, tokens: list) -> int:
+        """GitHub에서 무효화된 토큰을 가진 세션만 폐기. Returns: 폐기된 세션 수."""
+        result = await cls.db["sessions"].delete_many({"github_id": github_id, "access_token": {"$in": tokens}})
+        print(f"🗑️ Revoked {result.deleted_count} session(s) with invalidated tokens of user {github_id}")
+        return result.deleted_count
+
+    @classmethod
+    async def revoke_all_user_sessions(cls, github_id: int, except_session_id: str = None) -> int:
+        """유저의 모든 세션 폐기 (except_session_id는 유지). Returns: 폐기된 세션 수."""
+        query = {"github_id": github_id}
+        if except_session_id:
+            query["session_id"] = {"$ne": except_session_id}
+        result = await cls.db["sessions"].delete_many(query)
+        print(f"🗑️ Revoked {result.deleted_count} session(s) of user {github_id}")
+        return result.deleted_count
+
+    @classmethod
+    async def backfill_session_activity(cls) -> int:
+        """
+        유휴 만료 도입 전 세션에 last_seen_at 채우기 (시작 시 1회).
+        created_at을 쓰면 72시간 넘은 기존 세션이 배포 직후 모두 만료되므로, 배포 시각부터 유휴 시간을 셈.
+        """
+        result = await cls.db["sessions"].update_many(
+            {"last_seen_at": {"$exists": False}}, {"$set": {"last_seen_at": datetime.utcnow()}}
+        )
+        if result.modified_count:
+            print(f"🕒 Backfilled last_seen_at of {result.modified_count} session(s)")
+        return result.modified_count
+
+    @classmethod
+    async def delete_idle_sessions(cls) -> int:
+        """유휴 만료된 세션 정리 (고정 만료는 TTL 인덱스가 처리)."""
+        if not settings.SESSION_IDLE_TIMEOUT_HOURS:
+            return 0
+        cutoff = datetime.utcnow() - timedelta(hours=settings.SESSION_IDLE_TIMEOUT_HOURS)
+        result = await cls.db["sessions"].delete_many({"last_seen_at": {"$lt": cutoff}})
+        if result.deleted_count:
+            print(f"🧹 Removed {result.deleted_count} idle session(s)")
+        return result.deleted_count
+
     # --- Training Data Collection ---
     @classmethod
-    async def save_training_data(cls, vulnerable_code: str, fixed_code: str, vulnerability_type: str = "general"):
+    async def save_training_data(cls, vulnerable_code: str, fixed_code: str, vulnerability_type: str = "general", scan_id: str = None):
         """
         에이전트가 생성한 (취약 코드, 수정 코드) 쌍을 지속적 학습을 위해 저장.
         """
@@ -128,9 +572,122 @@ class Database:
             "vulnerable_code": vulnerable_code,
             "fixed_code": fixed_code,
             "vulnerability_type": vulnerability_type,
+            "scan_id": scan_id,
             "created_at": datetime.utcnow()
         }
         await cls.db["training_data"].insert_one(data)
         print(f"📥 Saved training data pair for type: {vulnerability_type}")
 
+    # --- Account Deletion ---
+    @classmethod
+    async def purge_user_data(cls, github_id: int, github_user: str) -> dict:
+        """
+        GDPR erasure of an account: its sessions, the scans it started and everything derived from
+        them (training pairs, vectors, variant hunts, explanations no other scan references).
+        Organization records that must stay auditable (risk exceptions, security team) are
+        pseudonymized instead of deleted.
+        """
+        scans = await cls.db["scans"].find({"owner.github_id": github_id}, {"_id": 0, "scan_id": 1, "findings.fingerprint": 1}).to_list(length=None)
+        scan_ids = [s["scan_id"] for s in scans]
+        fingerprints = list({f["fingerprint"] for s in scans for f in s.get("findings") or [] if f.get("fingerprint")})
+
+        deleted = {}
+        by_scan = {"scan_id": {"$in": scan_ids}}
+        for collection in ("training_data", "vulnerability_vectors", "variant_hunts", "scans"):
+            deleted[collection] = (await cls.db[collection].delete_many(by_scan)).deleted_count if scan_ids else 0
+        if fingerprints:
+            still_used = set(await cls.db["scans"].distinct("findings.fingerprint", {"findings.fingerprint": {"$in": fingerprints}}))
+            orphaned = [fp for fp in fingerprints if fp not in still_used]
+            deleted["explanations"] = (await cls.db["explanations"].delete_many({"fingerprint": {"$in": orphaned}})).deleted_count
+        deleted["sessions"] = (await cls.db["sessions"].delete_many({"github_id": github_id})).deleted_count
+
+        pseudonymized = 0
+        for field in ("requested_by", "decided_by", "revoked_by"):
+            pseudonymized += (await cls.db["exceptions"].update_many({field: github_user}, {"$set": {field: DELETED_USER}})).modified_count
+        await cls.db["exceptions"].update_many(
+            {"history.by": github_user}, {"$set": {"history.$[h].by": DELETED_USER}}, array_filters=[{"h.by": github_user}]
+        )
+        await cls.db["organizations"].update_many({"security_team": github_user}, {"$pull": {"security_team": github_user}})
+
+        print(f"🗑️ Purged account {github_user}: {deleted}")
+        return {"deleted": deleted, "pseudonymized_exceptions": pseudonymized}
+
+    # --- Organization Export / Import ---
+    @classmethod
+    async def export_org_data(cls, org: str) -> dict:
+        """Every record of an organization, per collection (see EXPORT_KEYS)."""
+        scans = await cls.db["scans"].find({"target": {"$regex": _org_target_pattern(org), "$options": "i"}}, {"_id": 0}).to_list(length=None)
+        scan_ids = [s["scan_id"] for s in scans]
+        fingerprints = list({f["fingerprint"] for s in scans for f in s.get("findings") or [] if f.get("fingerprint")})
+        # Natural-key collections are exported without Mongo _id; the others keep it as their key
+        queries = {
+            "organizations": ({"org": org}, {"_id": 0}),
+            "exceptions": ({"$or": [{"repository": {"$regex": f"^{re.escape(org)}/"}}, {"repository": org}]}, {"_id": 0}),
+            "explanations": ({"fingerprint": {"$in": fingerprints}}, {"_id": 0}),
+            "variant_hunts": ({"scan_id": {"$in": scan_ids}}, {"_id": 0}),
+            "sla_notifications": ({"org": org}, {"_id": 0}),
+            "training_data": ({"scan_id": {"$in": scan_ids}}, None),
+            "vulnerability_vectors": ({"scan_id": {"$in": scan_ids}}, None),
+        }
+        data = {"scans": scans}
+        for collection, (query, projection) in queries.items():
+            data[collection] = await cls.db[collection].find(query, projection).to_list(length=None)
+        return data
+
+    @classmethod
+    async def import_org_data(cls, org: str, collections: dict, overwrite: bool = False) -> dict:
+        """
+        Upserts exported records by their natural key. Existing records are kept unless `overwrite`.
+        Returns collection → {inserted, updated, skipped}.
+
+        Every record (and any stored record with the same key) must belong to `org`, so an export
+        cannot write into another organization's data; otherwise ValueError and nothing is written.
+        Explanations are a cache shared by all organizations (keyed by fingerprint): they are only
+        inserted, and only for fingerprints no other organization's scan references.
+        """
+        pattern = re.compile(_org_target_pattern(org), re.IGNORECASE)
+        scans = collections.get("scans") or []
+        stored = await cls.db["scans"].find({"target": {"$regex": _org_target_pattern(org), "$options": "i"}},
+                                            {"_id": 0, "scan_id": 1, "findings.fingerprint": 1}).to_list(length=None)
+        scan_ids = {s["scan_id"] for s in stored + scans if s.get("scan_id")}
+        fingerprints = {f.get("fingerprint") for s in stored + scans for f in s.get("findings") or [] if isinstance(f, dict)}
+        shared = set(await cls.db["scans"].distinct("findings.fingerprint", {
+            "findings.fingerprint": {"$in": [e.get("fingerprint") for e in collections.get("explanations") or []]},
+            "target": {"$not": pattern},
+        }))
+        owned = {
+            "organizations": lambda d: d.get("org") == org,
+            "scans": lambda d: isinstance(d.get("target"), str) and bool(pattern.search(d["target"])),
+            "exceptions": lambda d: d.get("repository") == org or str(d.get("repository") or "").startswith(f"{org}/"),
+            "explanations": lambda d: d.get("fingerprint") in fingerprints and d.get("fingerprint") not in shared,
+            "variant_hunts": lambda d: d.get("scan_id") in scan_ids,
+            "sla_notifications": lambda d: d.get("org") == org,
+            "training_data": lambda d: d.get("scan_id") in scan_ids,
+            "vulnerability_vectors": lambda d: d.get("scan_id") in scan_ids,
+        }
+
+        for collection, documents in collections.items():
+            for i, document in enumerate(documents):
+                key = {k: document.get(k) for k in EXPORT_KEYS[collection]}
+                if not owned[collection](document):
+                    raise ValueError(f"{collection}[{i}] does not belong to organization '{org}'.")
+                existing = await cls.db[collection].find_one(key)
+                if existing and collection != "explanations" and not owned[collection](existing):
+                    raise ValueError(f"{collection}[{i}] would replace a record of another organization ({key}).")
+
+        result = {}
+        for collection, documents in collections.items():
+            keys = EXPORT_KEYS[collection]
+            counts = {"inserted": 0, "updated": 0, "skipped": 0}
+            for document in documents:
+                key = {k: document.get(k) for k in keys}
+                if overwrite and collection != "explanations":
+                    outcome = await cls.db[collection].replace_one(key, document, upsert=True)
+                    counts["inserted" if outcome.upserted_id is not None else "updated"] += 1
+                else:
+                    outcome = await cls.db[collection].update_one(key, {"$setOnInsert": {k: v for k, v in document.items() if k != "_id"}}, upsert=True)
+                    counts["inserted" if outcome.upserted_id is not None else "skipped"] += 1
+            result[collection] = counts
+        return result
+
 db = Database()
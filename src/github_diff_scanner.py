@@ -3,6 +3,7 @@ import aiohttp
 from typing import List, Dict, Any
 from src.repo_scanner import RepoScanner
 from src.config import settings
+from src.services.attribution import blame_attributor, CodeOwners, CODEOWNERS_PATHS
 import logging
 
 logger = logging.getLogger(__name__)
@@ -97,12 +98,23 @@ class GitHubDiffScanner:
                     alert['change_type'] = 'added'  # 변경된 코드
                     all_vulnerabilities.append(alert)
             
-            # 6. 결과 반환
+            # 6. PR 작성자 귀속 + CODEOWNERS 라우팅 (실패해도 스캔 결과는 반환)
+            pr_info = {}
+            try:
+                pr_info = await self._get_pr(owner, repo, pr_number)
+                base_ref = (pr_info.get("base") or {}).get("ref")
+                codeowners = CodeOwners(await self._get_codeowners(owner, repo, base_ref))
+                blame_attributor.annotate_pr(all_vulnerabilities, pr_info, codeowners)
+            except Exception as attr_err:
+                logger.warning(f"⚠️ PR attribution skipped: {attr_err}")
+
+            # 7. 결과 반환
             result = {
                 "pr_number": pr_number,
                 "repository": f"{owner}/{repo}",
                 "files_analyzed": len(files),
                 "lines_analyzed": len(changed_lines),
+                "author": (pr_info.get("user") or {}).get("login"),
                 "vulnerabilities": all_vulnerabilities,
                 "summary": f"Found {len(all_vulnerabilities)} potential vulnerabilities in {len(files)} files."
             }
@@ -137,6 +149,42 @@ class GitHubDiffScanner:
                 files = await response.json()
                 return files
     
+    async def _get_pr(self, owner: str, repo: str, pr_number: int) -> Dict[str, Any]:
+        """
+        PR 메타데이터 (작성자, head sha, base ref)
+
+        GET /repos/{owner}/{repo}/pulls/{pr_number}
+        """
+        url = f"https://api.github.com/repos/{owner}/{repo}/pulls/{pr_number}"
+        async with aiohttp.ClientSession() as session:
+            async with session.get(url, headers=self._headers()) as response:
+                if response.status != 200:
+                    raise Exception(f"GitHub API error ({response.status}): {await response.text()}")
+                return await response.json()
+
+    async def _get_codeowners(self, owner: str, repo: str, ref: str = None) -> str:
+        """
+        base 브랜치의 CODEOWNERS 원문 (없으면 빈 문자열)
+
+        GET /repos/{owner}/{repo}/contents/{path}?ref={ref}
+        """
+        headers = self._headers()
+        headers['Accept'] = 'application/vnd.github.raw'
+        params = {'ref': ref} if ref else {}
+        async with aiohttp.ClientSession() as session:
+            for path in CODEOWNERS_PATHS:
+                url = f"https://api.github.com/repos/{owner}/{repo}/contents/{path}"
+                async with session.get(url, headers=headers, params=params) as response:
+                    if response.status == 200:
+                        return await response.text()
+        return ""
+
+    def _headers(self) -> Dict[str, str]:
+        headers = {'Accept': 'application/vnd.github.v3+json'}
+        if self.github_token:
+            headers['Authorization'] = f'token {self.github_token}'
+        return headers
+
     def _parse_diff_patches(self, files: List[Dict]) -> List[Dict[str, Any]]:
         """
         GitHub Diff patch 파싱
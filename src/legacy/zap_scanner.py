@@ -1,5 +1,6 @@
 import os
 import asyncio
+from urllib.parse import urlsplit
 from zapv2 import ZAPv2
 from dotenv import load_dotenv
 
@@ -39,4 +40,29 @@ class ZapScanner:
                 "other": f"Technical Error: {str(e)}"
             }]
 
+    async def scan_recorded(self, target_url: str, traffic: dict):
+        """
+        Seeds ZAP with recorded requests (HAR / recording proxy) instead of spidering,
+        then runs the active scanner on them so authenticated pages are covered.
+        """
+        print(f"🚀 [ZAP] Seeding {len(traffic['entries'])} recorded request(s) for {target_url}")
+        try:
+            self.zap.core.version
+            for entry in traffic["entries"]:
+                # ZAP expects the absolute URL in the request line (proxy form)
+                parts = urlsplit(entry["url"])
+                headers = "".join(f"{k}: {v}\r\n" for k, v in entry["headers"].items())
+                raw = f"{entry['method']} {entry['url']} HTTP/1.1\r\nHost: {parts.netloc}\r\n{headers}\r\n{entry['body']}"
+                self.zap.core.send_request(raw, followredirects=False)
+
+            scan_id = self.zap.ascan.scan(target_url, recurse=True)
+            while int(self.zap.ascan.status(scan_id)) < 100:
+                await asyncio.sleep(5)
+            print("✅ [ZAP] Active scan of recorded traffic complete.")
+            return self.zap.core.alerts(baseurl=target_url)
+
+        except Exception as e:
+            print(f"⚠️ [ZAP] Recorded traffic scan skipped: {e}")
+            return []
+
 zap_scanner = ZapScanner()
@@ -1,7 +1,9 @@
 from langchain_mongodb import MongoDBAtlasVectorSearch
 from langchain_openai import OpenAIEmbeddings
 from pymongo import MongoClient
+from datetime import datetime
 from .config import settings
+from .services.findings import current_scan_id
 
 class RAGService:
     def __init__(self):
@@ -25,7 +27,14 @@ class RAGService:
         print("✅ RAG Service Initialized (Sync PyMongo)")
 
     async def ingest_alert(self, alert_text: str, metadata: dict):
-        """Save a vulnerability alert to the vector store."""
+        """
+        Save a vulnerability alert to the vector store.
+        Tagged with `scan_id` (from metadata, else the scan being processed) so the vector is
+        exported and purged with its scan; `created_at` drives the retention TTL.
+        """
+        scan_id = metadata.get("scan_id") or current_scan_id.get()
+        if scan_id:
+            metadata = {**metadata, "scan_id": scan_id}
         if self.vector_store:
             # add_texts is sync in LangChain standard, but might be async supported?
             # actually asimilarity_search implies async support, but let's stick to standard methods.
@@ -34,7 +43,7 @@ class RAGService:
             # aadd_texts is the async version.
             await self.vector_store.aadd_texts(
                 texts=[alert_text],
-                metadatas=[metadata]
+                metadatas=[{**metadata, "created_at": datetime.utcnow()}]
             )
             print(f"📥 Ingested alert: {alert_text[:50]}...")
 
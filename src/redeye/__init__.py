@@ -1,2 +1,135 @@
+"""
+RedEye CLI
+
+    redeye import gosec.json --target https://github.com/acme/api
+    redeye import zap-report.xml --scan-id <scan_id> --no-verify
+    redeye formats
+    redeye proxy --upstream https://staging.example.com --out qa.har --scan
+    redeye har qa.har --header "Cookie: session=..."
+
+Talks to a running RedEye API (REDEYE_API_URL, default http://localhost:8000).
+"""
+
+import argparse
+import json
+import os
+import sys
+
+import requests
+
+DEFAULT_SERVER = os.getenv("REDEYE_API_URL", "http://localhost:8000")
+
+
+def _import(args) -> int:
+    if not args.target and not args.scan_id:
+        print("❌ --target 또는 --scan-id가 필요합니다.", file=sys.stderr)
+        return 2
+    data = {"verify": str(not args.no_verify).lower()}
+    for key in ("target", "scan_id", "format"):
+        if getattr(args, key):
+            data[key] = getattr(args, key)
+
+    with open(args.report, "rb") as f:
+        response = requests.post(
+            f"{args.server.rstrip('/')}/imports",
+            data=data,
+            files={"file": (os.path.basename(args.report), f)},
+            timeout=300,
+        )
+    if response.status_code != 200:
+        print(f"❌ Import failed ({response.status_code}): {response.text}", file=sys.stderr)
+        return 1
+
+    result = response.json()
+    print(f"📥 {result['imported']} finding(s) imported from {', '.join(result['tools']) or 'report'} "
+          f"→ scan {result['scan_id']} ({result['target']})")
+    if result["duplicates"]:
+        print(f"🔁 {len(result['duplicates'])} duplicate(s) of existing findings merged")
+    print(f"🤖 AI verification: {result['verification']}")
+    for finding in result["findings"]:
+        location = f"{finding['file']}:{finding.get('line')}" if finding.get("file") else finding.get("url", "")
+        print(f"  [{finding.get('risk')}] {finding.get('alert')} ({finding.get('cwe') or 'no CWE'}) {location}")
+    return 0
+
+
+def _upload_har(args, path: str) -> int:
+    data = {"language": args.language}
+    if args.target:
+        data["target_url"] = args.target
+    if args.header:
+        data["headers"] = json.dumps({k.strip(): v.strip() for k, v in (h.split(":", 1) for h in args.header)})
+    with open(path, "rb") as f:
+        response = requests.post(
+            f"{args.server.rstrip('/')}/scan/har",
+            data=data,
+            files={"file": (os.path.basename(path), f)},
+            timeout=120,
+        )
+    if response.status_code != 200:
+        print(f"❌ HAR upload failed ({response.status_code}): {response.text}", file=sys.stderr)
+        return 1
+    result = response.json()
+    print(f"🚀 DAST scan started from recorded traffic: {result['scan_id']} ({result['target']})")
+    print(f"   Poll {args.server.rstrip('/')}/scan/{result['scan_id']}")
+    return 0
+
+
+def _har(args) -> int:
+    return _upload_har(args, args.har)
+
+
+def _proxy(args) -> int:
+    from redeye.proxy import record
+    path = record(args.upstream, args.port, args.out)
+    if args.scan:
+        args.target = args.target or args.upstream
+        return _upload_har(args, path)
+    return 0
+
+
+def _formats(args) -> int:
+    response = requests.get(f"{args.server.rstrip('/')}/imports/formats", timeout=30)
+    response.raise_for_status()
+    for fmt in response.json()["formats"]:
+        print(f"{fmt['id']:<10} {fmt['description']}")
+    return 0
+
+
 def main() -> None:
-    print("Hello from redeye!")
+    parser = argparse.ArgumentParser(prog="redeye", description="RedEye AI Security Agent CLI")
+    parser.add_argument("--server", default=DEFAULT_SERVER, help="RedEye API URL (env: REDEYE_API_URL)")
+    commands = parser.add_subparsers(dest="command", required=True)
+
+    import_cmd = commands.add_parser("import", help="Import an external scanner report (SARIF, ZAP, gosec, Bandit, Semgrep, npm audit)")
+    import_cmd.add_argument("report", help="Report file")
+    import_cmd.add_argument("--target", help="Scanned repository / URL (attaches to its latest scan)")
+    import_cmd.add_argument("--scan-id", help="Attach to this scan")
+    import_cmd.add_argument("--format", help="Report format (auto-detected by default)")
+    import_cmd.add_argument("--no-verify", action="store_true", help="Skip the AI verification / repair pipeline")
+    import_cmd.set_defaults(func=_import)
+
+    har_cmd = commands.add_parser("har", help="Start a DAST scan that replays and mutates a recorded HAR session")
+    har_cmd.add_argument("har", help="HAR file (browser export or `redeye proxy` recording)")
+    har_cmd.set_defaults(func=_har)
+
+    proxy_cmd = commands.add_parser("proxy", help="Record a browser session through a local reverse proxy (HAR)")
+    proxy_cmd.add_argument("--upstream", required=True, help="Application URL to forward to")
+    proxy_cmd.add_argument("--port", type=int, default=8081)
+    proxy_cmd.add_argument("--out", default="redeye-session.har", help="HAR output file")
+    proxy_cmd.add_argument("--scan", action="store_true", help="Start a DAST scan with the recording when stopped")
+    proxy_cmd.set_defaults(func=_proxy)
+
+    for cmd in (har_cmd, proxy_cmd):
+        cmd.add_argument("--target", help="Target URL (default: most recorded host / upstream)")
+        cmd.add_argument("--header", action="append", help="Override a recorded header, e.g. 'Cookie: session=...'")
+        cmd.add_argument("--language", default="en", choices=["en", "ko"])
+
+    formats_cmd = commands.add_parser("formats", help="List supported import formats")
+    formats_cmd.set_defaults(func=_formats)
+
+    args = parser.parse_args()
+    try:
+        sys.exit(args.func(args))
+    except (requests.RequestException, OSError) as e:
+        print(f"❌ {e}", file=sys.stderr)
+        sys.exit(1)
@@ -0,0 +1,133 @@
+"""
+Recording reverse proxy: QA browses http://localhost:<port>, requests are forwarded to the
+upstream application and every exchange is recorded as a HAR 1.2 entry.
+
+A reverse proxy (instead of a forward/MITM proxy) needs no CA certificate: the browser talks
+plain HTTP to localhost, which browsers treat as a secure context, so Secure cookies still work.
+"""
+
+import asyncio
+import json
+import time
+from datetime import datetime, timezone
+from urllib.parse import urlsplit, parse_qsl
+
+from aiohttp import web, ClientSession, ClientTimeout
+
+HOP_HEADERS = {"host", "content-length", "connection", "keep-alive", "transfer-encoding", "upgrade",
+               "proxy-connection", "te", "accept-encoding", "content-encoding"}
+TEXT_TYPES = ("text/", "json", "javascript", "xml", "x-www-form-urlencoded")
+MAX_RECORDED_BODY = 1024 * 1024
+
+
+class RecordingProxy:
+    def __init__(self, upstream: str, port: int = 8081, host: str = "127.0.0.1"):
+        parts = urlsplit(upstream)
+        self.upstream = f"{parts.scheme}://{parts.netloc}"
+        self.local = f"http://{host}:{port}"
+        self.host, self.port = host, port
+        self.entries = []
+        self.session = None
+
+    async def handle(self, request: web.Request) -> web.Response:
+        body = await request.read()
+        url = self.upstream + request.path_qs
+        headers = {k: self._to_upstream(v) for k, v in request.headers.items() if k.lower() not in HOP_HEADERS}
+        started = time.time()
+
+        async with self.session.request(request.method, url, headers=headers, data=body or None, allow_redirects=False) as upstream:
+            content = await upstream.read()
+            status, reason = upstream.status, upstream.reason or ""
+            response_headers = [(k, v) for k, v in upstream.headers.items() if k.lower() not in HOP_HEADERS]
+
+        self.entries.append(self._entry(request, url, headers, body, status, reason, response_headers, content, started))
+        print(f"  ● {request.method} {request.path_qs} -> {status}")
+
+        content_type = upstream.headers.get("Content-Type", "")
+        if any(t in content_type for t in TEXT_TYPES):
+            content = content.replace(self.upstream.encode(), self.local.encode())
+        response = web.Response(status=status, reason=reason, body=content)
+        for key, value in response_headers:
+            if key.lower() == "location":
+                value = value.replace(self.upstream, self.local)
+            elif key.lower() == "set-cookie":
+                # Bind cookies to localhost so the session keeps working through the proxy
+                value = "; ".join(p for p in value.split(";") if not p.strip().lower().startswith("domain="))
+            response.headers.add(key, value)
+        return response
+
+    def har(self) -> dict:
+        return {"log": {
+            "version": "1.2",
+            "creator": {"name": "RedEye recording proxy", "version": "1.0"},
+            "pages": [],
+            "entries": self.entries,
+        }}
+
+    async def run(self):
+        self.session = ClientSession(timeout=ClientTimeout(total=60), auto_decompress=True)
+        app = web.Application(client_max_size=50 * 1024 * 1024)
+        app.router.add_route("*", "/{tail:.*}", self.handle)
+        runner = web.AppRunner(app)
+        await runner.setup()
+        await web.TCPSite(runner, self.host, self.port).start()
+        print(f"🎥 Recording {self.upstream} at {self.local} (Ctrl+C to stop)")
+        try:
+            await asyncio.Event().wait()  # until Ctrl+C cancels the task
+        finally:
+            await runner.cleanup()
+            await self.session.close()
+
+    def _to_upstream(self, value: str) -> str:
+        # Origin / Referer point at the proxy; the application expects its own origin
+        return value.replace(self.local, self.upstream)
+
+    def _entry(self, request, url, headers, body, status, reason, response_headers, content, started) -> dict:
+        mime = request.headers.get("Content-Type", "")
+        response_mime = dict((k.lower(), v) for k, v in response_headers).get("content-type", "")
+        elapsed = int((time.time() - started) * 1000)
+        entry = {
+            "startedDateTime": datetime.fromtimestamp(started, timezone.utc).isoformat(),
+            "time": elapsed,
+            "request": {
+                "method": request.method,
+                "url": url,
+                "httpVersion": "HTTP/1.1",
+                "headers": [{"name": k, "value": v} for k, v in headers.items()],
+                "queryString": [{"name": k, "value": v} for k, v in parse_qsl(urlsplit(url).query, keep_blank_values=True)],
+                "cookies": [],
+                "headersSize": -1,
+                "bodySize": len(body),
+            },
+            "response": {
+                "status": status,
+                "statusText": reason,
+                "httpVersion": "HTTP/1.1",
+                "headers": [{"name": k, "value": v} for k, v in response_headers],
+                "cookies": [],
+                "content": {"size": len(content), "mimeType": response_mime},
+                "redirectURL": dict((k.lower(), v) for k, v in response_headers).get("location", ""),
+                "headersSize": -1,
+                "bodySize": len(content),
+            },
+            "cache": {},
+            "timings": {"send": 0, "wait": elapsed, "receive": 0},
+        }
+        if body:
+            entry["request"]["postData"] = {"mimeType": mime, "text": body.decode("utf-8", errors="replace")}
+        if any(t in response_mime for t in TEXT_TYPES) and len(content) <= MAX_RECORDED_BODY:
+            entry["response"]["content"]["text"] = content.decode("utf-8", errors="replace")
+        return entry
+
+
+def record(upstream: str, port: int, out: str) -> str:
+    """Runs the proxy until Ctrl+C, then writes the HAR file. Returns its path."""
+    proxy = RecordingProxy(upstream, port)
+    try:
+        asyncio.run(proxy.run())
+    except KeyboardInterrupt:
+        pass
+    with open(out, "w", encoding="utf-8") as f:
+        json.dump(proxy.har(), f, ensure_ascii=False, indent=1)
+    print(f"💾 {len(proxy.entries)} request(s) recorded → {out}")
+    return out
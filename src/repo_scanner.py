@@ -1,9 +1,14 @@
 import os
-import shutil
-import tempfile
 import re
-from git import Repo
 from typing import List, Dict, Any
+from src.rules.go_security import go_security_rules
+from src.rules.cicd_security import cicd_security_rules
+from src.rules.pii_privacy import pii_privacy_rules
+from src.rules.llm_security import llm_security_rules
+from src.services.reachability import reachability_analyzer
+from src.services.clone_detection import clone_detector
+from src.services.workspace import workspace_manager
+from src.services.attribution import blame_attributor
 
 class RepoScanner:
     """
@@ -11,6 +16,10 @@ class RepoScanner:
     It can scan:
     1. GitHub Repositories (via `scan_repo`) - Clones and scans all files.
     2. Raw Code Content (via `scan_content`) - Scans a single code snippet (API use).
+
+    Besides the single-line patterns below, file-level rule packs (src/rules/) run on
+    matching file types for checks that need more than one line of context, and repo scans
+    look for copies of known vulnerable code (CVE-patched hunks, see CloneDetector).
     """
     def __init__(self):
         self.vulnerability_patterns = [
@@ -18,6 +27,7 @@ class RepoScanner:
             {
                 "pattern": r"(?i)(aws_access_key_id|aws_secret_access_key|api_key|secret_key)[\s]*=[\s]*['\"][A-Za-z0-9/\+=]{15,}['\"]",
                 "label": "Hardcoded Secret",
+                "cwe": "CWE-798",
                 "risk": "High",
                 "description": "Possible hardcoded API key or secret found. Never commit secrets to version control."
             },
@@ -25,6 +35,7 @@ class RepoScanner:
             {
                 "pattern": r"(?i)(SELECT|INSERT|UPDATE|DELETE).*['\"]\s*\+\s*[a-zA-Z_][a-zA-Z0-9_]*",
                 "label": "SQL Injection",
+                "cwe": "CWE-89",
                 "risk": "High",
                 "description": "Potential SQL Injection via string concatenation detected."
             },
@@ -32,6 +43,7 @@ class RepoScanner:
             {
                 "pattern": r"(?i)pickle\.loads\(",
                 "label": "Unsafe Deserialization",
+                "cwe": "CWE-502",
                 "risk": "High",
                 "description": "Usage of pickle.loads() is insecure if input is untrusted."
             },
@@ -39,6 +51,7 @@ class RepoScanner:
             {
                 "pattern": r"(?i)debug\s*=\s*True",
                 "label": "Debug Mode Enabled",
+                "cwe": "CWE-489",
                 "risk": "Medium",
                 "description": "Debug mode should be disabled in production."
             },
@@ -46,11 +59,34 @@ class RepoScanner:
             {
                 "pattern": r"(?i)#\s*TODO",
                 "label": "TODO Comment",
+                "cwe": "CWE-546",
                 "risk": "Low",
                 "description": "Found TODO comment. Check if it indicates incomplete security features."
             }
         ]
 
+        # File-level rule packs (multi-line checks, CWE mapped)
+        self.rule_packs = [
+            go_security_rules,
+            cicd_security_rules,
+            pii_privacy_rules,
+            llm_security_rules,
+        ]
+
+    def rule_catalog(self) -> List[Dict[str, Any]]:
+        """Every enabled SAST rule with its CWE (line patterns + rule packs)."""
+        catalog = [
+            {"id": vuln["label"], "label": vuln["label"], "cwe": vuln["cwe"], "risk": vuln["risk"], "source": "pattern"}
+            for vuln in self.vulnerability_patterns
+        ]
+        for rule_pack in self.rule_packs:
+            catalog += [
+                {"id": rule["id"], "label": rule["label"], "cwe": rule["cwe"], "risk": rule["risk"], "source": rule_pack.name}
+                for rule in rule_pack.rules.values()
+            ]
+        catalog.append({"id": "VULN-CODE-CLONE", "label": "Vulnerable Code Clone", "cwe": "CWE-1104", "risk": "High", "source": "clone_detection"})
+        return catalog
+
     def scan_content(self, content: str, filename: str = "snippet") -> List[Dict[str, Any]]:
         """
         Scans a single string of code for vulnerabilities.
@@ -72,42 +108,47 @@ class RepoScanner:
                         "alert": vuln["label"],
                         "risk": vuln["risk"],
                         "description": vuln["description"],
-                        "other": f"File: {filename}:{i+1}\nCode:\n{context_snippet}"[:500] 
+                        "other": f"File: {filename}:{i+1}\nCode:\n{context_snippet}"[:500],
+                        "cwe": vuln["cwe"],
+                        "file": filename,
+                        "line": i + 1,
+                        "code": line.strip()
                     })
+
+        for rule_pack in self.rule_packs:
+            if rule_pack.applies_to(filename):
+                alerts.extend(rule_pack.scan(content, filename))
         return alerts
 
-    def scan_repo(self, repo_url: str) -> List[Dict[str, Any]]:
+    def scan_repo(self, repo_url: str, refresh: bool = True) -> List[Dict[str, Any]]:
         """
-        Clones the repo to a temp dir, scans files, and returns alerts.
+        Checks out the repo into its cached workspace, scans files, and returns alerts.
+        The workspace stays read-locked for the whole scan. refresh=False reuses the clone as is
+        (the scan worker already fetched it). Blocking: call from a worker thread.
         Legacy method: In RedEye 3.0, n8n handles cloning. This is kept for backward compatibility.
         """
-        print(f"🔍 [SAST] Cloning {repo_url}...")
-        temp_dir = tempfile.mkdtemp()
+        print(f"🔍 [SAST] Preparing workspace for {repo_url}...")
         alerts = []
 
         try:
-            Repo.clone_from(repo_url, temp_dir, depth=1)
-            
-            # Walk through files
-            for root, dirs, files in os.walk(temp_dir):
-                if ".git" in dirs:
-                    dirs.remove(".git") # Skip .git dir
-                
-                for file in files:
-                    file_path = os.path.join(root, file)
-                    
-                    # Skip binary or non-code files
-                    if not self._is_code_file(file):
-                        continue
-                        
-                    try:
-                        with open(file_path, 'r', encoding='utf-8', errors='ignore') as f:
-                            content = f.read()
-                            # Use the shared scanning logic
-                            file_alerts = self.scan_content(content, filename=file)
-                            alerts.extend(file_alerts)
-                    except Exception as read_err:
-                        print(f"⚠️ Failed to read {file}: {read_err}")
+            with workspace_manager.checked_out(repo_url, refresh) as workspace:
+                alerts = self.scan_directory(workspace)
+
+                # Vendored / copy-pasted code that matches a known CVE hunk
+                try:
+                    alerts.extend(clone_detector.scan_directory(workspace))
+                except Exception as clone_err:
+                    print(f"⚠️ [SAST] Clone detection failed: {clone_err}")
+
+                # Reachability: is the vulnerable function callable from an entrypoint?
+                reachability_analyzer.annotate(workspace, alerts)
+                alerts = reachability_analyzer.rank(alerts)
+
+                # Who introduced it (git blame) and who owns it (CODEOWNERS)
+                try:
+                    blame_attributor.annotate(workspace, alerts)
+                except Exception as blame_err:
+                    print(f"⚠️ [SAST] Blame attribution failed: {blame_err}")
 
         except Exception as e:
             print(f"❌ [SAST] Failed to scan repo: {e}")
@@ -117,10 +158,35 @@ class RepoScanner:
                 "description": f"Failed to clone or scan repository: {str(e)}",
                 "other": ""
             })
-        finally:
-            # Cleanup
-            shutil.rmtree(temp_dir, ignore_errors=True)
-            print(f"🧹 [SAST] Verified & Cleaned up temp dir: {temp_dir}")
+
+        return alerts
+
+    def scan_directory(self, root_dir: str) -> List[Dict[str, Any]]:
+        """
+        Scans every code file under root_dir. Alerts carry paths relative to root_dir.
+        """
+        alerts = []
+        for root, dirs, files in os.walk(root_dir):
+            if ".git" in dirs:
+                dirs.remove(".git") # Skip .git dir
+
+            for file in files:
+                file_path = os.path.join(root, file)
+
+                rel_path = os.path.relpath(file_path, root_dir)
+
+                # Skip binary or non-code files (CI/compose/workflow definitions are claimed by their rule pack)
+                if not self._is_code_file(file) and not any(pack.applies_to(rel_path) for pack in self.rule_packs):
+                    continue
+
+                try:
+                    with open(file_path, 'r', encoding='utf-8', errors='ignore') as f:
+                        content = f.read()
+                        # Use the shared scanning logic
+                        file_alerts = self.scan_content(content, filename=rel_path)
+                        alerts.extend(file_alerts)
+                except Exception as read_err:
+                    print(f"⚠️ Failed to read {file}: {read_err}")
 
         return alerts
 
@@ -0,0 +1,84 @@
+from typing import List, Dict, Any, Tuple
+
+
+class RulePack:
+    """
+    Base class for language/domain-specific rule packs used by RepoScanner.
+
+    Unlike the single-line regex patterns in RepoScanner.vulnerability_patterns,
+    a rule pack sees the whole file, so it can implement multi-line checks
+    (function bodies, check-then-use sequences, declarations vs. usages).
+
+    Every alert keeps the RepoScanner shape (alert, risk, description, other)
+    and adds: rule_id, cwe, explanation, rule_pack, file, line, code.
+    """
+    name: str = "base"
+    extensions: Tuple[str, ...] = ()
+    # Rule metadata by id: {"id", "label", "risk", "cwe", "description", "explanation"}
+    rules: Dict[str, Dict[str, str]] = {}
+
+    def applies_to(self, filename: str) -> bool:
+        return any(filename.endswith(ext) for ext in self.extensions)
+
+    def scan(self, content: str, filename: str) -> List[Dict[str, Any]]:
+        raise NotImplementedError
+
+    def make_alert(self, rule: Dict[str, str], filename: str, lines: List[str], index: int, detail: str = "") -> Dict[str, Any]:
+        """Builds an alert for a rule hit at 0-based line `index` (same context format as RepoScanner)."""
+        start_line = max(0, index - 2)
+        end_line = min(len(lines), index + 3)
+        context_snippet = "\n".join(lines[start_line:end_line])
+        description = rule["description"] + (f" ({detail})" if detail else "")
+
+        return {
+            "alert": rule["label"],
+            "risk": rule["risk"],
+            "description": description,
+            "other": f"File: {filename}:{index+1}\nCode:\n{context_snippet}"[:500],
+            "rule_id": rule["id"],
+            "cwe": rule["cwe"],
+            "explanation": rule["explanation"],
+            "rule_pack": self.name,
+            "file": filename,
+            "line": index + 1,
+            "code": lines[index].strip()
+        }
+
+
+def line_of(content: str, offset: int) -> int:
+    """0-based line index of a character offset."""
+    return content.count("\n", 0, offset)
+
+
+def block_end(content: str, open_brace: int) -> int:
+    """Offset just past the brace that closes the `{` at `open_brace` (brace languages only)."""
+    depth = 0
+    for i in range(open_brace, len(content)):
+        if content[i] == "{":
+            depth += 1
+        elif content[i] == "}":
+            depth -= 1
+            if depth == 0:
+                return i + 1
+    return len(content)
+
+
+def call_arguments(content: str, start: int, limit: int = 600) -> str:
+    """
+    Text of a call's arguments from `start` (just after the opening `(`) to the balancing `)`.
+    Statement-style sinks (`puts x`, `document.cookie = x`) end at the line break instead.
+    """
+    depth = 1
+    statement = content[start - 1] != "("
+    end = min(len(content), start + limit)
+    for i in range(start, end):
+        char = content[i]
+        if char in "([{":
+            depth += 1
+        elif char in ")]}":
+            depth -= 1
+            if depth == 0:
+                return content[start:i]
+        elif char == "\n" and statement and depth == 1:
+            return content[start:i]
+    return content[start:end]
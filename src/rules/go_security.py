@@ -0,0 +1,235 @@
+import re
+from typing import List, Dict, Any
+from src.rules import RulePack, line_of, block_end
+
+
+RULES = {
+    "GO-RACE-SHARED-MAP": {
+        "id": "GO-RACE-SHARED-MAP",
+        "label": "Data Race on Shared Map/Slice in Handler",
+        "risk": "High",
+        "cwe": "CWE-362",
+        "description": "Package-level map or slice is mutated inside an HTTP handler without holding a mutex.",
+        "explanation": (
+            "net/http serves every request on its own goroutine, so handlers run concurrently. "
+            "Writing to a package-level map from two goroutines is a data race; the Go runtime aborts "
+            "the whole process with 'concurrent map writes', which an attacker can trigger with parallel "
+            "requests (DoS), and racy slice appends silently lose or corrupt data. "
+            "Guard the variable with sync.Mutex/RWMutex (Lock before writing) or use sync.Map."
+        )
+    },
+    "GO-TOCTOU-STAT-OPEN": {
+        "id": "GO-TOCTOU-STAT-OPEN",
+        "label": "TOCTOU File Race (Stat then Use)",
+        "risk": "Medium",
+        "cwe": "CWE-367",
+        "description": "File is checked with os.Stat/os.Lstat and then used by path in a separate call.",
+        "explanation": (
+            "Between the check (os.Stat) and the use (os.Open, os.Remove, ...) the file system can change. "
+            "An attacker with write access to the directory can swap the file for a symlink after the check "
+            "and make the program read, overwrite or delete a different file. "
+            "Open the file first and call f.Stat() on the handle, or use O_NOFOLLOW / openat-style APIs."
+        )
+    },
+    "GO-UNSAFE-POINTER": {
+        "id": "GO-UNSAFE-POINTER",
+        "label": "unsafe.Pointer Conversion",
+        "risk": "Medium",
+        "cwe": "CWE-242",
+        "description": "unsafe.Pointer is used to convert between pointer types or to do pointer arithmetic.",
+        "explanation": (
+            "unsafe.Pointer bypasses Go's type and memory safety. Casting between unrelated types or "
+            "doing arithmetic through uintptr can read or write out of bounds, and the garbage collector "
+            "may move or free memory still referenced through a uintptr. "
+            "Prefer encoding/binary, unsafe.Slice/unsafe.String with verified lengths, or safe copies."
+        )
+    },
+    "GO-REFLECT-HEADER": {
+        "id": "GO-REFLECT-HEADER",
+        "label": "reflect.SliceHeader/StringHeader Manipulation",
+        "risk": "Medium",
+        "cwe": "CWE-843",
+        "description": "reflect.SliceHeader or reflect.StringHeader is used to reinterpret memory.",
+        "explanation": (
+            "Building slices or strings from hand-written headers creates values whose Data/Len/Cap "
+            "are not tracked by the runtime. A wrong length exposes adjacent memory (information "
+            "disclosure) and strings built this way can be mutated, breaking immutability assumptions. "
+            "These types are deprecated; use unsafe.Slice/unsafe.String or copy the data."
+        )
+    },
+    "GO-CGO-CSTRING-LEAK": {
+        "id": "GO-CGO-CSTRING-LEAK",
+        "label": "C.CString Without C.free",
+        "risk": "Low",
+        "cwe": "CWE-401",
+        "description": "C.CString allocates C memory that is never released in this function.",
+        "explanation": (
+            "C.CString copies a Go string into memory allocated with malloc, which the Go garbage "
+            "collector does not manage. Calling it per request without C.free leaks memory until the "
+            "process is OOM-killed, an easy remote DoS. Add `defer C.free(unsafe.Pointer(cs))`."
+        )
+    },
+    "GO-CGO-GO-POINTER": {
+        "id": "GO-CGO-GO-POINTER",
+        "label": "Go Memory Passed to C via unsafe.Pointer",
+        "risk": "Medium",
+        "cwe": "CWE-787",
+        "description": "A pointer into Go-managed memory is cast to a C type and passed to C code.",
+        "explanation": (
+            "C code has no bounds information for Go slices or strings. If the C side writes past the "
+            "length, or keeps the pointer after the call, it corrupts Go heap memory (cgo pointer-passing "
+            "rules). Pass explicit lengths, copy into C.malloc'd buffers, or use C.CBytes."
+        )
+    },
+}
+
+TOP_LEVEL_FUNC = re.compile(r"^func\s+(?:\([^)]*\)\s*)?(?P<name>\w+)\s*\((?P<params>[^)]*)\)[^{\n]*\{", re.MULTILINE)
+HANDLER_LITERAL = re.compile(r"func\s*\((?P<params>[^)]*http\.ResponseWriter[^)]*)\)\s*\{")
+HANDLER_PARAMS = re.compile(r"http\.ResponseWriter|\*gin\.Context|echo\.Context|\*fiber\.Ctx")
+PACKAGE_VAR = re.compile(r"^(?P<name>[A-Za-z_]\w*)\s*(?:=\s*(?:make\(\s*)?)?(?:map\[|\[\])")
+STAT_CALL = re.compile(r"os\.(?:L?[Ss]tat)\(\s*(?P<arg>[^)]+?)\s*\)")
+USE_CALL = re.compile(r"(?:os\.(?:Open|OpenFile|ReadFile|WriteFile|Remove|RemoveAll|Create|Chmod|Chown|Rename|Truncate)|ioutil\.(?:ReadFile|WriteFile))\(\s*(?P<arg>[^,)]+)")
+
+
+class GoSecurityRules(RulePack):
+    """
+    Go rule pack: concurrency and unsafe-usage checks that a single-line regex cannot express.
+
+    1. Package-level maps/slices mutated inside HTTP handlers without a mutex (CWE-362)
+    2. Check-then-use file races: os.Stat → os.Open on the same path (CWE-367)
+    3. unsafe.Pointer conversions and reflect header tricks (CWE-242, CWE-843)
+    4. cgo string/pointer passing (CWE-401, CWE-787)
+    """
+    name = "go-security"
+    extensions = (".go",)
+    rules = RULES
+
+    def scan(self, content: str, filename: str) -> List[Dict[str, Any]]:
+        lines = content.split("\n")
+        functions = self._functions(content)
+
+        alerts = []
+        alerts.extend(self._check_shared_state(content, filename, lines, functions))
+        alerts.extend(self._check_toctou(filename, lines, functions))
+        alerts.extend(self._check_unsafe(filename, lines))
+        alerts.extend(self._check_cgo(filename, lines, functions))
+
+        # Handler literals are also part of their enclosing function's body: report each hit once.
+        unique = {}
+        for alert in alerts:
+            unique.setdefault((alert["rule_id"], alert["line"]), alert)
+        return list(unique.values())
+
+    def _functions(self, content: str) -> List[Dict[str, Any]]:
+        """Top-level functions and handler literals with their bodies and line spans."""
+        functions = []
+        for match in TOP_LEVEL_FUNC.finditer(content):
+            end = block_end(content, match.end() - 1)
+            functions.append({
+                "name": match.group("name"),
+                "is_handler": bool(HANDLER_PARAMS.search(match.group("params"))),
+                "start": line_of(content, match.start()),
+                "end": line_of(content, end),
+                "body": content[match.end():end]
+            })
+        for match in HANDLER_LITERAL.finditer(content):
+            end = block_end(content, match.end() - 1)
+            functions.append({
+                "name": "<handler literal>",
+                "is_handler": True,
+                "start": line_of(content, match.start()),
+                "end": line_of(content, end),
+                "body": content[match.end():end]
+            })
+        return functions
+
+    def _package_level_collections(self, lines: List[str]) -> List[str]:
+        """Names of package-level `var` maps/slices (single-line or inside a `var ( ... )` block)."""
+        names = []
+        in_var_block = False
+        for line in lines:
+            stripped = line.strip()
+            if line.startswith("var ("):
+                in_var_block = True
+                continue
+            if in_var_block and stripped == ")":
+                in_var_block = False
+                continue
+            if line.startswith("var "):
+                candidate = stripped[len("var "):]
+            elif in_var_block:
+                candidate = stripped
+            else:
+                continue
+            match = PACKAGE_VAR.match(candidate)
+            if match:
+                names.append(match.group("name"))
+        return names
+
+    def _check_shared_state(self, content, filename, lines, functions):
+        alerts = []
+        shared = self._package_level_collections(lines)
+        if not shared:
+            return alerts
+
+        for fn in functions:
+            if not fn["is_handler"]:
+                continue
+            if re.search(r"\.Lock\(\)", fn["body"]):
+                continue
+            for name in shared:
+                mutation = re.compile(
+                    rf"(?:\b{name}\s*\[[^\]]*\]\s*(?:=(?!=)|\+\+|--|\+=|-=)|delete\(\s*{name}\s*,|\b{name}\s*=\s*append\(\s*{name}\b)"
+                )
+                for offset, body_line in enumerate(lines[fn["start"]:fn["end"] + 1]):
+                    if mutation.search(body_line):
+                        alerts.append(self.make_alert(
+                            RULES["GO-RACE-SHARED-MAP"], filename, lines, fn["start"] + offset,
+                            detail=f"'{name}' in {fn['name']}"
+                        ))
+                        break
+        return alerts
+
+    def _check_toctou(self, filename, lines, functions):
+        alerts = []
+        for fn in functions:
+            checked = {}
+            for i in range(fn["start"], fn["end"] + 1):
+                for match in STAT_CALL.finditer(lines[i]):
+                    checked[match.group("arg").strip()] = i
+                for match in USE_CALL.finditer(lines[i]):
+                    arg = match.group("arg").strip()
+                    if arg in checked and checked[arg] < i:
+                        alerts.append(self.make_alert(
+                            RULES["GO-TOCTOU-STAT-OPEN"], filename, lines, i,
+                            detail=f"'{arg}' checked on line {checked[arg] + 1}"
+                        ))
+                        del checked[arg]
+        return alerts
+
+    def _check_unsafe(self, filename, lines):
+        alerts = []
+        for i, line in enumerate(lines):
+            stripped = line.strip()
+            if stripped.startswith("//"):
+                continue
+            if re.search(r"reflect\.(?:Slice|String)Header", line):
+                alerts.append(self.make_alert(RULES["GO-REFLECT-HEADER"], filename, lines, i))
+            elif re.search(r"\(\*C\.\w+\)\(\s*unsafe\.Pointer\(", line):
+                alerts.append(self.make_alert(RULES["GO-CGO-GO-POINTER"], filename, lines, i))
+            elif re.search(r"\(\*?[\w.\[\]*]+\)\(\s*unsafe\.Pointer\(|uintptr\(\s*unsafe\.Pointer\(|unsafe\.Pointer\(\s*uintptr\(", line):
+                alerts.append(self.make_alert(RULES["GO-UNSAFE-POINTER"], filename, lines, i))
+        return alerts
+
+    def _check_cgo(self, filename, lines, functions):
+        alerts = []
+        for fn in functions:
+            if "C.CString(" not in fn["body"] or "C.free(" in fn["body"]:
+                continue
+            for i in range(fn["start"], fn["end"] + 1):
+                if "C.CString(" in lines[i]:
+                    alerts.append(self.make_alert(RULES["GO-CGO-CSTRING-LEAK"], filename, lines, i))
+        return alerts
+
+
+go_security_rules = GoSecurityRules()
@@ -0,0 +1,255 @@
+import re
+from typing import List, Dict, Any, Optional, Tuple
+from src.rules import RulePack, line_of, call_arguments
+
+
+RULES = {
+    "PII-SECRET-IN-LOG": {
+        "id": "PII-SECRET-IN-LOG",
+        "label": "Secret Written to Logs/Output",
+        "risk": "High",
+        "cwe": "CWE-532",
+        "description": "A password, token, session id or key flows into a log or print statement.",
+        "explanation": (
+            "Logs are shipped to aggregators, kept for months and readable by far more people than the "
+            "credential store. A logged session id or access token can be replayed until it expires; even a prefix "
+            "narrows guessing and links log lines to a user. "
+            "Log a non-secret identifier (user id, request id) or a keyed hash instead of the secret."
+        )
+    },
+    "PII-IN-LOG": {
+        "id": "PII-IN-LOG",
+        "label": "Personal Data Written to Logs/Output",
+        "risk": "Medium",
+        "cwe": "CWE-532",
+        "description": "Personal data (email, phone, national id, card number, ...) flows into a log or print statement.",
+        "explanation": (
+            "Personal data in logs is processing the privacy notice usually does not cover: it escapes retention "
+            "limits and access controls, must be found and erased on a GDPR Art. 17 request, and counts as a "
+            "personal data breach if the log store leaks. "
+            "Log pseudonymous identifiers, or mask the value (j***@example.com) before it reaches the logger."
+        )
+    },
+    "PII-ANALYTICS": {
+        "id": "PII-ANALYTICS",
+        "label": "Personal Data Sent to Analytics/Monitoring",
+        "risk": "Medium",
+        "cwe": "CWE-359",
+        "description": "Personal or secret data is passed to a third-party analytics, tracking or error-monitoring call.",
+        "explanation": (
+            "Analytics and monitoring SaaS (Segment, Mixpanel, Google Analytics, Sentry, ...) are separate processors, "
+            "often outside the EU. Sending them emails, phone numbers or tokens needs a legal basis, a DPA and "
+            "transfer safeguards (GDPR Art. 28, 44), and the vendor's data retention becomes yours. "
+            "Send a pseudonymous user id and keep personal attributes out of event properties."
+        )
+    },
+    "PII-CLEARTEXT-STORAGE": {
+        "id": "PII-CLEARTEXT-STORAGE",
+        "label": "Personal/Secret Data Stored Unencrypted",
+        "risk": "High",
+        "cwe": "CWE-312",
+        "description": "Personal or secret data is written to client storage, a plain file or a database field without hashing/encryption.",
+        "explanation": (
+            "localStorage/sessionStorage are readable by any script on the origin (one XSS exfiltrates them), "
+            "plain files and dumps end up in backups and support bundles, and a password stored without a slow "
+            "hash is exposed in clear on the first database leak. "
+            "Hash passwords (bcrypt/argon2), keep tokens in HttpOnly cookies, and encrypt personal data at rest."
+        )
+    },
+}
+
+# Personal-data categories (identifier names, snake_case) and whether GDPR Art. 9 treats them as special
+PII_CATEGORIES = {
+    # bare `token` is left out on purpose: in lexers and tokenizers it is not a credential
+    "credential": (r"password|passwd|pwd|passphrase|secret|secret_key|client_secret|jwt|"
+                   r"(?:access|refresh|id|auth|bearer|api|reset|session|csrf|oauth|github|hf|personal_access|verification)_token|"
+                   r"api_key|apikey|private_key|session_id|sessionid|sid|cookie|authorization|otp|totp_secret"),
+    "contact": r"email|e_mail|email_address|phone|phone_number|mobile|telephone|street_address|home_address|postal_address",
+    "national_id": (r"ssn|social_security(?:_number)?|national_id|passport(?:_number)?|driver_license|drivers_license|"
+                    r"tax_id|resident_registration_number|rrn|jumin"),
+    "financial": r"card_number|credit_card|cc_number|ccn|cvv|cvc|iban|account_number|bank_account|routing_number",
+    "health": r"diagnosis|medical_record|health_record|blood_type|disability|prescription|medical_history",
+    "personal": r"dob|birth_date|birthdate|date_of_birth|birthday|first_name|last_name|ip_address|client_ip|geolocation",
+}
+SPECIAL_CATEGORIES = {"health"}
+CATEGORY_PATTERNS = {category: re.compile(rf"(?:^|_)(?:{names})$") for category, names in PII_CATEGORIES.items()}
+# Names that describe the data rather than hold it (password_hash, email_verified, token_count, ...)
+NOT_A_VALUE = re.compile(
+    r"(?:^|_)(?:hash|hashed|digest|len|length|count|verified|valid|enabled|required|field|label|prefix|"
+    r"mask|masked|redacted|type|format|regex|pattern|policy|expires?|expiry|ttl|url|path|header|column|key_name)$"
+    r"|^(?:is|has|num|n|max|min|hashed|masked|redacted|validate|valid)_"
+)
+SANITIZER = re.compile(r"(?i)\b(?:mask\w*|redact\w*|anonymi[sz]e\w*|pseudonymi[sz]e\w*|hash\w*|sha\d+|md5|bcrypt\w*|argon2\w*|encrypt\w*|len|bool)\s*\(")
+
+SINKS = [
+    # Logs / output
+    ("log", re.compile(r"\b(?:logging|logger|log|_log|LOG|LOGGER|slog|Rails\.logger|app\.logger|current_app\.logger)\."
+                       r"(?:debug|info|warn|warning|error|exception|critical|fatal|trace|Print\w*|Fatal\w*|Panic\w*|Info\w*|Debug\w*|Warn\w*|Error\w*)\s*\(")),
+    ("log", re.compile(r"\bconsole\.(?:log|info|warn|error|debug|trace)\s*\(|\berror_log\s*\(")),
+    ("print", re.compile(r"(?<![\w.])print\s*\(|\bfmt\.(?:Print|Println|Printf|Fprint|Fprintln|Fprintf)\s*\(|\bSystem\.(?:out|err)\.print\w*\s*\(|(?m:^[ \t]*puts[ \t(])")),
+    # Third-party analytics / monitoring
+    ("analytics", re.compile(r"\b(?:analytics|mixpanel|posthog|amplitude|segment|heap|rudderanalytics|FS)\.(?:track|identify|capture|logEvent|people\.set|setUserProperties|alias|page)\s*\("
+                             r"|\bgtag\s*\(|\bdataLayer\.push\s*\(|\b(?:Sentry|sentry_sdk)\.(?:setUser|set_user|setExtra|set_extra|setContext|set_context|captureMessage|capture_message)\s*\(")),
+    # Unencrypted storage
+    ("storage", re.compile(r"\b(?:localStorage|sessionStorage|AsyncStorage)\.setItem\s*\(|\bdocument\.cookie\s*=|\b(?:fs\.writeFile(?:Sync)?|os\.WriteFile|ioutil\.WriteFile)\s*\("
+                           r"|\b(?:json|pickle|yaml)\.dump\s*\(|\.writerow\s*\(|\.putString\s*\(")),
+]
+DB_WRITE = re.compile(r"\.(?:insert_one|insert_many|insert|update_one|update_many|replace_one|save|create|InsertOne|Create|Save)\s*\(")
+PASSWORD_NAME = re.compile(r"(?:^|_)(?:password|passwd|pwd|passphrase)$")
+
+STRING = re.compile(r"""(?P<q>'''|\"\"\"|`|'|")(?P<body>.*?)(?<!\\)(?P=q)""", re.DOTALL)
+INTERPOLATION = re.compile(r"\$\{([^}]*)\}|(?<!\{)\{([^{}]+)\}(?!\})|#\{([^}]*)\}")
+SUBSCRIPT_KEY = re.compile(r"""(?:\[\s*|\.get\(\s*|[{,]\s*)['"](?P<key>\w+)['"]\s*(?:\]|[,)]|:)""")
+# Values only: `get_token()` names a function, not the data
+IDENTIFIER = re.compile(r"[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*(?![\w$.]|\s*\()")
+FORMAT_CALL = re.compile(r"(?:\.format|\.join|\bstr|\bString|\bfmt\.Sprintf|\bString\.format)\s*\(")
+ASSIGNMENT = re.compile(r"^\s*(?:const\s+|let\s+|var\s+)?(?P<name>[A-Za-z_]\w*)\s*(?::=|=(?!=))\s*(?P<value>.+)$")
+SOURCE_EXTENSIONS = (".py", ".js", ".jsx", ".ts", ".tsx", ".go", ".java", ".rb", ".php", ".kt")
+
+# GDPR controls (ids in compliance_mappings.json "gdpr") each sink touches
+GDPR_ARTICLES = {
+    "log": ["Art. 5(1)(c)", "Art. 5(1)(f)", "Art. 25"],
+    "print": ["Art. 5(1)(c)", "Art. 5(1)(f)", "Art. 25"],
+    "analytics": ["Art. 5(1)(b)", "Art. 28", "Art. 44"],
+    "storage": ["Art. 5(1)(f)", "Art. 25", "Art. 32(1)(a)"],
+}
+
+
+def snake_case(name: str) -> str:
+    return re.sub(r"(?<=[a-z0-9])(?=[A-Z])", "_", name).lower().strip("_$")
+
+
+def classify(name: str) -> Optional[str]:
+    """PII category of an identifier / field name (last attribute of a dotted name), or None."""
+    leaf = snake_case(name.split(".")[-1])
+    if NOT_A_VALUE.search(leaf):
+        return None
+    for category, pattern in CATEGORY_PATTERNS.items():
+        if pattern.search(leaf):
+            return category
+    return None
+
+
+class PiiPrivacyRules(RulePack):
+    """
+    Privacy rule pack: personal data and secret-bearing identifiers flowing into sinks.
+
+    1. Logs and print statements (PII-SECRET-IN-LOG for credentials, PII-IN-LOG for personal data).
+    2. Third-party analytics / monitoring calls (PII-ANALYTICS).
+    3. Unencrypted storage: browser storage, plain files and dumps, passwords written to the DB unhashed
+       (PII-CLEARTEXT-STORAGE).
+
+    Identifiers are classified by name (email, ssn, access_token, sessionId, user["email"], ...).
+    Flows are followed through simple assignments within the file (msg = f"... {email}"; log(msg)).
+    Every alert carries a `privacy` block (data category, sink, GDPR articles) for GDPR reporting.
+    """
+    name = "pii-privacy"
+    extensions = SOURCE_EXTENSIONS
+    rules = RULES
+
+    def scan(self, content: str, filename: str) -> List[Dict[str, Any]]:
+        lines = content.split("\n")
+        derived = self._derived_names(lines)
+        alerts = []
+        seen = set()
+
+        for sink, pattern in SINKS:
+            for match in pattern.finditer(content):
+                index = line_of(content, match.start())
+                if self._is_comment(lines[index]):
+                    continue
+                arguments = call_arguments(content, match.end())
+                if SANITIZER.search(arguments):
+                    continue
+                hit = self._pii_in(arguments, derived)
+                if not hit or (index, sink) in seen:
+                    continue
+                seen.add((index, sink))
+                alerts.append(self._alert(filename, lines, index, sink, *hit))
+
+        for match in DB_WRITE.finditer(content):
+            index = line_of(content, match.start())
+            arguments = call_arguments(content, match.end())
+            if self._is_comment(lines[index]) or SANITIZER.search(arguments):
+                continue
+            for name in self._names(arguments):
+                if PASSWORD_NAME.search(snake_case(name.split(".")[-1])) and not NOT_A_VALUE.search(snake_case(name.split(".")[-1])):
+                    alerts.append(self._alert(filename, lines, index, "storage", "credential", name, None))
+                    break
+        return alerts
+
+    def _alert(self, filename, lines, index, sink, category, name, via):
+        if sink == "storage":
+            rule = RULES["PII-CLEARTEXT-STORAGE"]
+        elif sink == "analytics":
+            rule = RULES["PII-ANALYTICS"]
+        else:
+            rule = RULES["PII-SECRET-IN-LOG"] if category == "credential" else RULES["PII-IN-LOG"]
+        detail = f"{category}: {name}" + (f" via {via}" if via else "") + f" → {sink}"
+        alert = self.make_alert(rule, filename, lines, index, detail=detail)
+        alert["privacy"] = {
+            "data_category": category,
+            "data": name,
+            "sink": sink,
+            "gdpr_special_category": category in SPECIAL_CATEGORIES,
+            "gdpr_articles": GDPR_ARTICLES[sink] + (["Art. 9"] if category in SPECIAL_CATEGORIES else []),
+        }
+        return alert
+
+    def _pii_in(self, arguments: str, derived: Dict[str, Tuple[str, str]]) -> Optional[Tuple[str, str, Optional[str]]]:
+        """(category, identifier, via) of the first personal/secret value in a call's arguments."""
+        for name in self._names(arguments):
+            category = classify(name)
+            if category:
+                return category, name, None
+            root = name.split(".")[0]
+            if root in derived:
+                category, source = derived[root]
+                return category, source, root
+        return None
+
+    def _names(self, text: str) -> List[str]:
+        """Identifiers used as values in an expression: code outside strings, string interpolations, dict keys."""
+        names = [m.group("key") for m in SUBSCRIPT_KEY.finditer(text)]
+        code_parts = []
+        last = 0
+        for string in STRING.finditer(text):
+            code_parts.append(text[last:string.start()])
+            for groups in INTERPOLATION.findall(string.group("body")):
+                code_parts.append(next(g for g in groups if g))
+            last = string.end()
+        code_parts.append(text[last:])
+        code = " ".join(code_parts)
+        # keyword arguments name the parameter, not the value (log(user=email) is still caught via `email`)
+        code = re.sub(r"\b\w+\s*=(?!=)", " ", code)
+        names += IDENTIFIER.findall(code)
+        return names
+
+    def _derived_names(self, lines: List[str]) -> Dict[str, Tuple[str, str]]:
+        """Variables assigned from personal data (msg = f"login {email}") → (category, source identifier)."""
+        derived = {}
+        for line in lines:
+            match = ASSIGNMENT.match(line)
+            if not match or self._is_comment(line) or SANITIZER.search(match.group("value")):
+                continue
+            # Only copies and string building carry the value; the result of other calls does not
+            if "(" in FORMAT_CALL.sub("", STRING.sub('""', match.group("value"))):
+                continue
+            target = match.group("name")
+            if classify(target):
+                continue
+            for name in self._names(match.group("value")):
+                root = name.split(".")[0]
+                category, source = classify(name), name
+                if not category and root in derived:
+                    category, source = derived[root]
+                if category:
+                    derived[target] = (category, source)
+                    break
+        return derived
+
+    def _is_comment(self, line: str) -> bool:
+        return line.strip().startswith(("#", "//", "*", "/*"))
+
+
+pii_privacy_rules = PiiPrivacyRules()
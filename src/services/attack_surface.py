@@ -0,0 +1,362 @@
+import ast
+import os
+import re
+from typing import List, Dict, Any, Optional
+from src.rules import line_of, block_end
+
+
+# --- Line-level sinks (all languages) ---
+SURFACE_PATTERNS = {
+    "outbound_calls": [
+        r"\brequests\.(?:get|post|put|delete|patch|request)\(",
+        r"\bhttpx\.(?:get|post|put|delete|AsyncClient|Client)\b",
+        r"\baiohttp\.ClientSession\(",
+        r"\burllib\.request\.urlopen\(",
+        r"\bhttp\.(?:Get|Post|PostForm|Head|NewRequest(?:WithContext)?)\(",
+        r"\b\w+\.Do\(\s*req\b",
+        r"\bfetch\(\s*[`'\"\w]",
+        r"\baxios(?:\.(?:get|post|put|delete|patch|request))?\(",
+        r"\bhttps?\.request\(",
+        r"\bnew\s+(?:RestTemplate|WebClient)\b|\bHttpClient\.new(?:Builder|HttpClient)\(",
+    ],
+    "db_access": [
+        r"\.execute(?:many)?\(",
+        r"\bsqlite3\.connect\(|\bpsycopg2?\.connect\(|\bpymysql\.connect\(",
+        r"\.(?:find_one|find|insert_one|insert_many|update_one|update_many|delete_one|delete_many|aggregate)\(",
+        r"\bsession\.query\(",
+        r"\b\w+\.(?:Query|QueryRow|QueryContext|Exec|ExecContext)\(",
+        r"\bsql\.Open\(|\bgorm\.Open\(",
+        r"\.(?:findOne|findById|findAll|findByPk)\(|\bmongoose\.connect\(",
+        r"\bJdbcTemplate\b|\.(?:executeQuery|executeUpdate|createQuery|createNativeQuery)\(",
+    ],
+    "deserialization": [
+        r"\bpickle\.loads?\(|\bcPickle\.loads?\(|\bmarshal\.loads?\(|\bjsonpickle\.decode\(",
+        r"\byaml\.load\((?![^)]*SafeLoader)|\byaml\.unsafe_load\(",
+        r"\btorch\.load\(|\bjoblib\.load\(|\bshelve\.open\(",
+        r"\bgob\.NewDecoder\(",
+        r"\bunserialize\(|\bnode-serialize\b|\bserialize\.unserialize\(",
+        r"\bObjectInputStream\b|\.readObject\(|\bXMLDecoder\b|\bXStream\b.*fromXML\(",
+    ],
+    "file_uploads": [
+        r"\bUploadFile\b",
+        r"\brequest\.files\b",
+        r"\.FormFile\(|\.ParseMultipartForm\(",
+        r"\bmulter\(|\bupload\.(?:single|array|fields)\(|\breq\.files?\b",
+        r"\bMultipartFile\b",
+    ],
+}
+
+AUTH_HINTS = re.compile(
+    r"(?i)\b(?:auth\w*|login_required|jwt\w*|requires?_?auth\w*|is_?authenticated|passport\.authenticate|"
+    r"get_current_user|current_user|verify_token|session_required|PreAuthorize|Secured|RolesAllowed|BasicAuth)\b"
+)
+
+SOURCE_EXTENSIONS = (".py", ".go", ".js", ".ts", ".mjs", ".cjs", ".java", ".kt")
+
+
+class AttackSurfaceExtractor:
+    """
+    AttackSurfaceExtractor produces an inventory of a repository's attack surface
+    before scanning, so the agent knows where to focus.
+
+    Frameworks: Go net/http (+ gorilla/gin/echo), Express, FastAPI/Flask, Spring.
+
+    Output:
+        {
+            "routes": [{method, path, framework, handler, file, line, auth, params, file_upload}],
+            "file_uploads": [...], "outbound_calls": [...], "db_access": [...], "deserialization": [...],
+            "summary": {counts}
+        }
+    """
+
+    def extract(self, root_dir: str) -> Dict[str, Any]:
+        surface = {"routes": [], "file_uploads": [], "outbound_calls": [], "db_access": [], "deserialization": []}
+        go_functions: Dict[str, Dict[str, Any]] = {}
+        go_files: List[tuple] = []
+
+        for root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in {".git", "node_modules", "vendor", "__pycache__", "dist", "build"}]
+            for file in files:
+                if not file.endswith(SOURCE_EXTENSIONS):
+                    continue
+                path = os.path.join(root, file)
+                rel_path = os.path.relpath(path, root_dir)
+                try:
+                    with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                        content = f.read()
+                except Exception:
+                    continue
+
+                self._scan_sinks(surface, rel_path, content)
+                if file.endswith(".py"):
+                    surface["routes"].extend(self._python_routes(rel_path, content))
+                elif file.endswith(".go"):
+                    go_files.append((rel_path, content))
+                    go_functions.update(self._go_functions(rel_path, content))
+                elif file.endswith((".js", ".ts", ".mjs", ".cjs")):
+                    surface["routes"].extend(self._express_routes(rel_path, content))
+                elif file.endswith((".java", ".kt")):
+                    surface["routes"].extend(self._spring_routes(rel_path, content))
+
+        # Go handlers can live in a different file from their registration
+        for rel_path, content in go_files:
+            surface["routes"].extend(self._go_routes(rel_path, content, go_functions))
+
+        surface["summary"] = {
+            "routes": len(surface["routes"]),
+            "unauthenticated_routes": sum(1 for r in surface["routes"] if not r["auth"]),
+            **{key: len(surface[key]) for key in SURFACE_PATTERNS}
+        }
+        return surface
+
+    def format_for_agent(self, surface: Dict[str, Any], max_items: int = 40) -> str:
+        """Compact text version of the inventory for the agent's context window."""
+        lines = [f"Attack surface summary: {surface.get('summary', {})}", "Routes:"]
+        for route in surface.get("routes", [])[:max_items]:
+            auth = "auth" if route["auth"] else "NO AUTH"
+            params = ", ".join(route["params"]) or "-"
+            upload = " [FILE UPLOAD]" if route["file_upload"] else ""
+            lines.append(
+                f"- {route['method']} {route['path']} -> {route['handler']} ({route['file']}:{route['line']}) "
+                f"[{auth}] params: {params}{upload}"
+            )
+        for key in ("deserialization", "file_uploads", "outbound_calls", "db_access"):
+            items = surface.get(key, [])
+            if items:
+                lines.append(f"{key}: " + "; ".join(f"{i['file']}:{i['line']}" for i in items[:10]))
+        return "\n".join(lines)
+
+    # --- Sinks ---
+    def _scan_sinks(self, surface, file: str, content: str):
+        for i, line in enumerate(content.split("\n")):
+            stripped = line.strip()
+            if stripped.startswith(("#", "//", "*")):
+                continue
+            for kind, patterns in SURFACE_PATTERNS.items():
+                if any(re.search(p, line) for p in patterns):
+                    surface[kind].append({"file": file, "line": i + 1, "code": stripped[:200]})
+
+    def _route(self, method, path, framework, handler, file, line, auth, params, file_upload) -> Dict[str, Any]:
+        return {
+            "method": method,
+            "path": path,
+            "framework": framework,
+            "handler": handler,
+            "file": file,
+            "line": line,
+            "auth": auth,
+            "params": sorted(set(params)),
+            "file_upload": file_upload
+        }
+
+    # --- FastAPI / Flask ---
+    def _python_routes(self, file: str, content: str) -> List[Dict[str, Any]]:
+        try:
+            tree = ast.parse(content)
+        except SyntaxError:
+            return []
+
+        # APIRouter(prefix="/x") / Blueprint(..., url_prefix="/x")
+        prefixes = {}
+        for node in ast.walk(tree):
+            if isinstance(node, ast.Assign) and isinstance(node.value, ast.Call) and len(node.targets) == 1:
+                target = node.targets[0]
+                if isinstance(target, ast.Name):
+                    for kw in node.value.keywords:
+                        if kw.arg in ("prefix", "url_prefix") and isinstance(kw.value, ast.Constant):
+                            prefixes[target.id] = str(kw.value.value)
+
+        routes = []
+        for node in ast.walk(tree):
+            if not isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+                continue
+            decorators = [d for d in node.decorator_list if isinstance(d, ast.Call) and isinstance(d.func, ast.Attribute)]
+            decorator_names = {ast.unparse(d.func if isinstance(d, ast.Call) else d) for d in node.decorator_list}
+            for decorator in decorators:
+                attr = decorator.func.attr
+                if attr not in {"get", "post", "put", "delete", "patch", "route", "api_route", "websocket"}:
+                    continue
+                owner = decorator.func.value.id if isinstance(decorator.func.value, ast.Name) else ""
+                path = ""
+                if decorator.args and isinstance(decorator.args[0], ast.Constant):
+                    path = str(decorator.args[0].value)
+                methods = [attr.upper()]
+                if attr in {"route", "api_route"}:
+                    methods = ["GET"]
+                    for kw in decorator.keywords:
+                        if kw.arg == "methods" and isinstance(kw.value, (ast.List, ast.Tuple)):
+                            methods = [str(e.value) for e in kw.value.elts if isinstance(e, ast.Constant)]
+
+                body = ast.get_source_segment(content, node) or ""
+                params, upload = self._python_params(node, body, path)
+                auth = bool(AUTH_HINTS.search(" ".join(decorator_names))) or self._python_has_auth(node)
+                framework = "flask" if attr == "route" else "fastapi"
+
+                for method in methods:
+                    routes.append(self._route(
+                        method, prefixes.get(owner, "") + path, framework, node.name,
+                        file, node.lineno, auth, params, upload
+                    ))
+        return routes
+
+    def _python_params(self, node, body: str, path: str):
+        params = re.findall(r"\{(\w+)(?::[^}]*)?\}|<(?:\w+:)?(\w+)>", path)
+        path_params = {p[0] or p[1] for p in params}
+        params = [f"path:{p}" for p in path_params]
+        upload = False
+        for arg in node.args.args + node.args.kwonlyargs:
+            annotation = ast.unparse(arg.annotation) if arg.annotation else ""
+            if arg.arg == "self" or annotation in ("Request", "Response", "BackgroundTasks"):
+                continue
+            if "UploadFile" in annotation:
+                upload = True
+                params.append(f"file:{arg.arg}")
+            elif arg.arg not in path_params:
+                params.append(f"body:{arg.arg}" if annotation and annotation[0].isupper() and annotation not in ("Optional", "List") else f"query:{arg.arg}")
+        # Flask request access
+        params += [f"query:{p}" for p in re.findall(r"request\.args(?:\.get\(|\[)\s*['\"](\w+)['\"]", body)]
+        params += [f"form:{p}" for p in re.findall(r"request\.form(?:\.get\(|\[)\s*['\"](\w+)['\"]", body)]
+        if "request.files" in body:
+            upload = True
+        if "request.get_json" in body or "request.json" in body:
+            params.append("body:json")
+        return params, upload
+
+    def _python_has_auth(self, node) -> bool:
+        for default in node.args.defaults + node.args.kw_defaults:
+            if default is not None and "Depends" in ast.unparse(default) and AUTH_HINTS.search(ast.unparse(default)):
+                return True
+        # Session/token parameters are this codebase family's auth convention (e.g. session_id)
+        return any(re.search(r"(?i)session|token", a.arg) for a in node.args.args)
+
+    # --- Go net/http, gorilla/mux, gin, echo ---
+    def _go_functions(self, file: str, content: str) -> Dict[str, Dict[str, Any]]:
+        functions = {}
+        for match in re.finditer(r"^func\s+(?:\([^)]*\)\s*)?(\w+)\s*\([^{]*\{", content, re.MULTILINE):
+            end = block_end(content, match.end() - 1)
+            functions[match.group(1)] = {
+                "file": file,
+                "line": line_of(content, match.start()) + 1,
+                "body": content[match.end():end]
+            }
+        return functions
+
+    def _go_routes(self, file: str, content: str, functions: Dict[str, Dict[str, Any]]) -> List[Dict[str, Any]]:
+        routes = []
+        pattern = re.compile(
+            r"\.(?P<verb>HandleFunc|Handle|GET|POST|PUT|DELETE|PATCH|Any)\(\s*\"(?P<path>[^\"]*)\"\s*,\s*(?P<handler>[^\n]*)"
+        )
+        for match in pattern.finditer(content):
+            verb, path, handler_expr = match.group("verb"), match.group("path"), match.group("handler")
+            method = verb if verb not in ("HandleFunc", "Handle", "Any") else "ANY"
+            # Go 1.22 patterns: "GET /path"
+            if " " in path:
+                method, path = path.split(" ", 1)
+            methods_call = re.search(r"\.Methods\(\s*\"(\w+)\"", handler_expr)
+            if methods_call:
+                method = methods_call.group(1)
+
+            names = re.findall(r"\b(\w+)\b", handler_expr)
+            handler = next((n for n in reversed(names) if n in functions), None)
+            wrappers = [n for n in names if n in functions and n != handler]
+            definition = functions.get(handler, {})
+            body = definition.get("body", "")
+
+            params = [f"query:{p}" for p in re.findall(r"(?:URL\.Query\(\)\.Get|\.Query|\.DefaultQuery)\(\s*\"([^\"]+)\"", body)]
+            params += [f"form:{p}" for p in re.findall(r"(?:FormValue|PostFormValue|PostForm)\(\s*\"([^\"]+)\"", body)]
+            params += [f"path:{p}" for p in re.findall(r"(?:\.Param\(|mux\.Vars\(\w+\)\[)\s*\"([^\"]+)\"", body)]
+            if re.search(r"json\.NewDecoder\(\s*\w+\.Body\)|\.(?:Bind|ShouldBind)\w*\(", body):
+                params.append("body:json")
+
+            auth = bool(AUTH_HINTS.search(" ".join(wrappers) + " " + handler_expr)) or \
+                bool(re.search(r"Header\.Get\(\s*\"Authorization\"|BasicAuth\(\)", body))
+
+            routes.append(self._route(
+                method, path, "go-net/http", handler or handler_expr.strip().rstrip(")"),
+                definition.get("file", file), definition.get("line", line_of(content, match.start()) + 1),
+                auth, params, bool(re.search(r"FormFile\(|ParseMultipartForm\(", body))
+            ))
+        return routes
+
+    # --- Express ---
+    def _express_routes(self, file: str, content: str) -> List[Dict[str, Any]]:
+        routes = []
+        global_auth = bool(re.search(r"\b(?:app|router)\.use\(\s*[^'\"`\s][^)]*", content) and
+                           any(AUTH_HINTS.search(m) for m in re.findall(r"\b(?:app|router)\.use\(([^)]*)\)", content)))
+        pattern = re.compile(
+            r"\b(?P<owner>app|router|server)\.(?P<method>get|post|put|delete|patch|all)\(\s*['\"`](?P<path>[^'\"`]*)['\"`]\s*,(?P<rest>[^\n]*)"
+        )
+        for match in pattern.finditer(content):
+            rest = match.group("rest")
+            inline = re.search(r"(?:async\s*)?(?:function\s*\([^)]*\)|\([^)]*\)\s*=>|\w+\s*=>)\s*\{", rest)
+            if inline:
+                open_brace = match.start("rest") + inline.end() - 1
+                body = content[open_brace:block_end(content, open_brace)]
+                middleware = rest[:inline.start()]
+                handler = "<inline>"
+            else:
+                names = re.findall(r"\b([A-Za-z_]\w*)\b", rest)
+                handler = names[-1] if names else "<unknown>"
+                middleware = " ".join(names[:-1])
+                definition = re.search(
+                    rf"(?:function\s+{re.escape(handler)}\s*\(|(?:const|let|var)\s+{re.escape(handler)}\s*=)[^{{]*\{{", content
+                )
+                body = content[definition.end() - 1:block_end(content, definition.end() - 1)] if definition else ""
+
+            params = [f"query:{a or b}" for a, b in re.findall(r"req\.query(?:\.(\w+)|\[\s*['\"](\w+)['\"])", body)]
+            params += [f"path:{p}" for p in re.findall(r"req\.params\.(\w+)", body)]
+            params += [f"path:{p}" for p in re.findall(r":(\w+)", match.group("path"))]
+            params += [f"body:{p}" for p in re.findall(r"req\.body\.(\w+)", body)]
+            upload = bool(re.search(r"multer|upload\.(?:single|array|fields)|req\.files?\b", rest + body))
+
+            routes.append(self._route(
+                match.group("method").upper(), match.group("path"), "express", handler,
+                file, line_of(content, match.start()) + 1,
+                global_auth or bool(AUTH_HINTS.search(middleware)), params, upload
+            ))
+        return routes
+
+    # --- Spring ---
+    def _spring_routes(self, file: str, content: str) -> List[Dict[str, Any]]:
+        routes = []
+        class_prefix = ""
+        class_auth = False
+        class_match = re.search(r"@RequestMapping\(\s*(?:value\s*=\s*|path\s*=\s*)?\"([^\"]*)\"[^)]*\)\s*(?:@\w+(?:\([^)]*\))?\s*)*(?:public\s+)?class\b", content)
+        if class_match:
+            class_prefix = class_match.group(1)
+        class_header = content[:content.find(" class ")] if " class " in content else ""
+        if re.search(r"@(?:PreAuthorize|Secured|RolesAllowed)", class_header):
+            class_auth = True
+
+        pattern = re.compile(
+            r"@(?P<verb>Get|Post|Put|Delete|Patch|Request)Mapping(?:\((?P<args>[^)]*)\))?(?P<between>(?:\s*@[\w.]+(?:\([^)]*\))?)*)\s*"
+            r"(?:public|protected|private)?\s*[\w<>\[\],\s?]+?\s+(?P<name>\w+)\s*\((?P<params>[^)]*)\)"
+        )
+        for match in pattern.finditer(content):
+            if class_match and match.start() == class_match.start():
+                continue
+            args = match.group("args") or ""
+            path_match = re.search(r"\"([^\"]*)\"", args)
+            method = match.group("verb").upper()
+            if method == "REQUEST":
+                method_match = re.search(r"RequestMethod\.(\w+)", args)
+                method = method_match.group(1) if method_match else "ANY"
+            signature = match.group("params")
+            # Annotations stacked directly above the mapping (back to the previous member)
+            boundary = max(content.rfind(c, 0, match.start()) for c in ";{}")
+            preceding = content[boundary + 1:match.start()]
+
+            params = [f"query:{p}" for p in re.findall(r"@RequestParam(?:\([^)]*\))?\s+[\w<>]+\s+(\w+)", signature)]
+            params += [f"path:{p}" for p in re.findall(r"@PathVariable(?:\([^)]*\))?\s+[\w<>]+\s+(\w+)", signature)]
+            params += [f"body:{p}" for p in re.findall(r"@RequestBody\s+[\w<>]+\s+(\w+)", signature)]
+
+            routes.append(self._route(
+                method, class_prefix + (path_match.group(1) if path_match else ""), "spring", match.group("name"),
+                file, line_of(content, match.start()) + 1,
+                class_auth or bool(re.search(r"@(?:PreAuthorize|Secured|RolesAllowed)", preceding + match.group("between"))),
+                params, "MultipartFile" in signature
+            ))
+        return routes
+
+
+attack_surface_extractor = AttackSurfaceExtractor()
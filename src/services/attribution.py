@@ -0,0 +1,251 @@
+import os
+import re
+from collections import defaultdict
+from datetime import datetime, timezone
+from typing import List, Dict, Any, Optional, Tuple
+from git import Repo
+from src.config import settings
+
+
+# Where GitHub looks for CODEOWNERS, in order
+CODEOWNERS_PATHS = (".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS")
+UNOWNED = "(unowned)"
+
+
+class CodeOwners:
+    """
+    CODEOWNERS rules (gitignore-style patterns). As on GitHub, the LAST matching rule wins
+    and a rule without owners un-assigns the path.
+    """
+    def __init__(self, content: str = ""):
+        self.rules: List[Tuple[str, re.Pattern, List[str]]] = []
+        for raw in content.splitlines():
+            line = raw.split("#", 1)[0].strip() if not raw.strip().startswith("\\#") else raw.strip()
+            if not line:
+                continue
+            pattern, *owners = line.split()
+            self.rules.append((pattern, self._compile(pattern), owners))
+
+    @classmethod
+    def from_directory(cls, root_dir: str) -> "CodeOwners":
+        for path in CODEOWNERS_PATHS:
+            full = os.path.join(root_dir, path)
+            if os.path.isfile(full):
+                with open(full, "r", encoding="utf-8", errors="ignore") as f:
+                    return cls(f.read())
+        return cls()
+
+    def owners_for(self, path: str) -> List[str]:
+        path = path.replace("\\", "/").lstrip("/")
+        owners: List[str] = []
+        for _, regex, rule_owners in self.rules:
+            if regex.match(path):
+                owners = rule_owners
+        return owners
+
+    def _compile(self, pattern: str) -> re.Pattern:
+        anchored = pattern.startswith("/") or "/" in pattern.strip("/")
+        directory = pattern.endswith("/")
+        body = pattern.strip("/")
+        regex = ""
+        i = 0
+        while i < len(body):
+            if body.startswith("**/", i):
+                regex += "(?:.*/)?"
+                i += 3
+            elif body.startswith("/**", i) and i + 3 == len(body):
+                regex += "/.*"
+                i += 3
+            elif body[i] == "*":
+                regex += "[^/]*"
+                i += 1
+            elif body[i] == "?":
+                regex += "[^/]"
+                i += 1
+            else:
+                regex += re.escape(body[i])
+                i += 1
+        prefix = "^" if anchored else "^(?:.*/)?"
+        suffix = "/.*$" if directory else "(?:/.*)?$"
+        return re.compile(prefix + regex + suffix)
+
+
+class BlameAttributor:
+    """
+    BlameAttributor attributes findings to the change that introduced them, for remediation routing.
+
+    - Repository scans: `git blame` on the cached workspace gives the commit, author and date of
+      the vulnerable line. Workspaces are shallow clones, so history is deepened first
+      (BLAME_HISTORY_DEPTH commits); lines older than that are marked `boundary` (introduced
+      at or before that commit).
+    - PR scans: everything in the diff is attributed to the PR author and head commit.
+
+    Every finding also gets `owners` from CODEOWNERS (last matching rule, GitHub semantics).
+    """
+    def __init__(self, history_depth: Optional[int] = None):
+        self.history_depth = history_depth or settings.BLAME_HISTORY_DEPTH
+
+    def annotate(self, root_dir: str, alerts: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Adds `attribution` {commit, author, author_email, date, summary, boundary} and `owners`."""
+        codeowners = CodeOwners.from_directory(root_dir)
+        by_file: Dict[str, List[Dict[str, Any]]] = defaultdict(list)
+        for alert in alerts:
+            if alert.get("file"):
+                alert["owners"] = codeowners.owners_for(alert["file"])
+                if alert.get("line"):
+                    by_file[alert["file"]].append(alert)
+        if not by_file:
+            return alerts
+
+        try:
+            repo = Repo(root_dir)
+            self._deepen(repo)
+            shallow = os.path.exists(os.path.join(repo.git_dir, "shallow"))
+        except Exception as e:
+            print(f"⚠️ [Blame] Not a usable git checkout, skipping attribution: {e}")
+            return alerts
+
+        for file, file_alerts in by_file.items():
+            try:
+                blame = self.blame(repo, file, sorted({a["line"] for a in file_alerts}), shallow)
+            except Exception as e:
+                print(f"⚠️ [Blame] {file}: {e}")
+                continue
+            for alert in file_alerts:
+                if alert["line"] in blame:
+                    alert["attribution"] = blame[alert["line"]]
+        print(f"👤 [Blame] Attributed findings in {len(by_file)} file(s)")
+        return alerts
+
+    def annotate_pr(self, alerts: List[Dict[str, Any]], pr: Dict[str, Any], codeowners: Optional[CodeOwners] = None) -> List[Dict[str, Any]]:
+        """PR scans: the PR author introduced every finding in the diff (GitHub pulls API object)."""
+        attribution = {
+            "commit": (pr.get("head") or {}).get("sha"),
+            "author": (pr.get("user") or {}).get("login"),
+            "author_email": None,
+            "date": pr.get("created_at"),
+            "summary": pr.get("title"),
+            "boundary": False,
+            "source": "pull_request",
+            "pr_number": pr.get("number"),
+            "pr_url": pr.get("html_url"),
+        }
+        codeowners = codeowners or CodeOwners()
+        for alert in alerts:
+            alert["attribution"] = dict(attribution)
+            file = alert.get("file") or alert.get("filename")
+            if file:
+                alert["owners"] = codeowners.owners_for(file)
+        return alerts
+
+    def blame(self, repo: Repo, file: str, lines: List[int], shallow: bool = False) -> Dict[int, Dict[str, Any]]:
+        """
+        `git blame --porcelain` for the given 1-based lines of one file → line → attribution.
+        In a full clone the boundary commit is the root commit, so only shallow clones mark `boundary`.
+        """
+        args = ["--porcelain"]
+        for line in lines:
+            args += ["-L", f"{line},{line}"]
+        output = repo.git.blame(*args, "HEAD", "--", file)
+
+        commits: Dict[str, Dict[str, Any]] = {}
+        result: Dict[int, Dict[str, Any]] = {}
+        current = None
+        for raw in output.splitlines():
+            header = re.match(r"^([0-9a-f]{40}) \d+ (\d+)", raw)
+            if header:
+                sha, final_line = header.group(1), int(header.group(2))
+                current = commits.setdefault(sha, {"commit": sha, "boundary": False, "source": "blame"})
+                result[final_line] = current
+            elif current is None or raw.startswith("\t"):
+                continue
+            elif raw.startswith("author "):
+                current["author"] = raw[7:]
+            elif raw.startswith("author-mail "):
+                current["author_email"] = raw[12:].strip("<>")
+            elif raw.startswith("author-time "):
+                current["date"] = datetime.fromtimestamp(int(raw[12:]), timezone.utc).isoformat()
+            elif raw.startswith("summary "):
+                current["summary"] = raw[8:]
+            elif raw == "boundary" and shallow:
+                current["boundary"] = True
+        return result
+
+    def _deepen(self, repo: Repo):
+        """Shallow workspace → fetch enough history for blame to name the introducing commit."""
+        if not os.path.exists(os.path.join(repo.git_dir, "shallow")) or self.history_depth <= 1:
+            return
+        try:
+            repo.git.fetch("origin", f"--deepen={self.history_depth}")
+        except Exception as e:
+            print(f"⚠️ [Blame] Could not deepen history (lines will show the clone boundary): {e}")
+
+
+def owner_teams(finding: Dict[str, Any]) -> List[str]:
+    """Owners a finding is routed to (CODEOWNERS), or the unowned bucket."""
+    return finding.get("owners") or [UNOWNED]
+
+
+def insights_report(scans: List[Dict[str, Any]], since: datetime) -> Dict[str, Any]:
+    """
+    Introduced-vs-fixed counts per team (CODEOWNERS owner) for one organization.
+
+    Args:
+        scans: completed repository scans of the organization (any order).
+        since: start of the window. A finding counts as introduced when blame dates it inside the
+            window, and as fixed when it is present in one scan and gone in the next scan of the
+            same target inside the window.
+    """
+    teams: Dict[str, Dict[str, Any]] = defaultdict(lambda: {"introduced": 0, "fixed": 0, "open": 0, "by_risk": defaultdict(int)})
+    by_target: Dict[str, List[Dict[str, Any]]] = defaultdict(list)
+    for scan in scans:
+        by_target[scan["target"]].append(scan)
+
+    for target, target_scans in by_target.items():
+        target_scans.sort(key=lambda s: _as_datetime(s.get("created_at")) or datetime.min)
+        for previous, current in zip(target_scans, target_scans[1:]):
+            scanned_at = _as_datetime(current.get("created_at"))
+            if not scanned_at or scanned_at < since:
+                continue
+            remaining = {f.get("fingerprint") for f in current.get("findings") or []}
+            for finding in previous.get("findings") or []:
+                if finding.get("fingerprint") not in remaining:
+                    for team in owner_teams(finding):
+                        teams[team]["fixed"] += 1
+
+        latest = target_scans[-1]
+        for finding in latest.get("findings") or []:
+            for team in owner_teams(finding):
+                teams[team]["open"] += 1
+                teams[team]["by_risk"][finding.get("risk", "Low")] += 1
+                introduced = _as_datetime((finding.get("attribution") or {}).get("date"))
+                if introduced and introduced >= since and not finding["attribution"].get("boundary"):
+                    teams[team]["introduced"] += 1
+
+    rows = [
+        {"team": team, **{k: v for k, v in counts.items() if k != "by_risk"}, "open_by_risk": dict(counts["by_risk"]),
+         "net": counts["introduced"] - counts["fixed"]}
+        for team, counts in teams.items()
+    ]
+    rows.sort(key=lambda r: (-r["net"], -r["open"], r["team"]))
+    return {
+        "since": since.isoformat(),
+        "targets": sorted(by_target),
+        "teams": rows,
+        "totals": {key: sum(r[key] for r in rows) for key in ("introduced", "fixed", "open")},
+    }
+
+
+def _as_datetime(value: Any) -> Optional[datetime]:
+    if isinstance(value, datetime):
+        return value.replace(tzinfo=None) if value.tzinfo else value
+    if isinstance(value, str) and value:
+        try:
+            parsed = datetime.fromisoformat(value.replace("Z", "+00:00"))
+        except ValueError:
+            return None
+        return parsed.astimezone(timezone.utc).replace(tzinfo=None) if parsed.tzinfo else parsed
+    return None
+
+
+blame_attributor = BlameAttributor()
@@ -0,0 +1,348 @@
+import json
+import math
+import os
+import re
+import time
+import zlib
+from collections import defaultdict
+from typing import List, Dict, Any, Optional
+
+
+DATA_DIR = os.path.join(os.path.dirname(__file__), "..", "..", "data", "circl_processed")
+CLONES_PATH = os.path.join(DATA_DIR, "clones.jsonl")            # scripts/preprocess_circl.py (CVE + patch URL)
+REPAIR_PATH = os.path.join(DATA_DIR, "repair.jsonl")            # fallback: repair pairs without CVE metadata
+EMBEDDINGS_PATH = os.path.join(DATA_DIR, "clone_embeddings.npy")  # scripts/build_clone_index.py
+
+# Language families: a vendored C file can be a copy of C++ code, a .ts file of .js code
+EXTENSIONS = {
+    ".c": "c", ".h": "c", ".cc": "c", ".cpp": "c", ".hpp": "c", ".cxx": "c",
+    ".go": "go", ".py": "python", ".php": "php", ".rb": "ruby", ".rs": "rust", ".java": "java",
+    ".kt": "java", ".cs": "csharp", ".swift": "swift", ".js": "javascript", ".mjs": "javascript", ".ts": "javascript",
+}
+DATASET_LANGUAGES = {"cpp": "c", "typescript": "javascript", "kotlin": "java"}
+HASH_COMMENT_LANGUAGES = {"python", "ruby"}
+
+KGRAM = 8                 # tokens per fingerprint
+WINDOW = 4                # winnowing window
+MIN_TOKENS = 40           # shorter hunks are boilerplate and match everywhere
+MIN_FINGERPRINTS = 12     # distinct fingerprints a hunk needs once abstracted
+MAX_POSTINGS = 50         # fingerprints shared by more hunks than this are boilerplate too
+CLONE_THRESHOLD = 0.8     # share of the hunk's fingerprints found in the file
+SEMANTIC_THRESHOLD = 0.8  # embedding cosine required when embeddings are available
+MAX_FILE_BYTES = 512 * 1024
+SKIP_DIRS = {".git", "node_modules", "__pycache__", ".venv", "venv"}
+# Import blocks look alike in every project once identifiers are abstracted
+IMPORT_LINE = re.compile(r"^\s*(?:import\b|from\s+[\w.]+\s+import\b|#\s*include\b|use\s+[\w:\\]+|require(?:_once)?\b|using\s+[\w.]+;|package\s+\w+|namespace\s+[\w\\]+;)")
+
+KEYWORDS = {
+    "if", "else", "for", "while", "do", "switch", "case", "break", "continue", "return", "goto", "default",
+    "func", "def", "function", "fn", "class", "struct", "enum", "var", "let", "const", "static", "public", "private",
+    "protected", "new", "delete", "try", "catch", "except", "finally", "throw", "raise", "import", "from", "package",
+    "null", "nil", "None", "NULL", "true", "false", "True", "False", "this", "self", "sizeof", "unsafe", "mut",
+    "int", "char", "void", "unsigned", "long", "size_t", "string", "byte", "bool", "float", "double", "err",
+}
+FUNCTION_HEADER = re.compile(
+    r"^\s*(?:(?:pub(?:\(\w+\))?|async|static|public|private|protected|final|export)\s+)*"
+    r"(?:func(?:\s*\([^)]*\))?|def|function|fn|sub)\s+(\w+)"
+    r"|^[\w\s\*&:<>,]*?\b(\w+)\s*\([^;{}]*\)\s*(?:const\s*)?\{?\s*$"
+)
+
+
+class CloneDetector:
+    """
+    CloneDetector flags functions that are near-copies of known vulnerable code
+    (vendored or copy-pasted libraries that never received the upstream fix).
+
+    Index: every pre-patch hunk of the CIRCL vulnerability-cwe-patch dataset
+    (`data/circl_processed/clones.jsonl`) is normalized (identifiers / literals abstracted,
+    comments and whitespace dropped) and fingerprinted with winnowed token k-grams.
+    Optional OpenAI embeddings of the hunks (`clone_embeddings.npy`) confirm the matches semantically.
+
+    Scan: a file region matching most of a hunk's fingerprints - and the lines the patch removed,
+    but not the lines it added - is reported with the CVE and the patch that fixed it.
+    """
+
+    def __init__(self):
+        self.entries: List[Dict[str, Any]] = []
+        self.postings: Dict[str, Dict[int, List[int]]] = {}
+        self.vectors = None
+        self._embeddings = None
+        self._loaded = False
+
+    # --- Index ---
+    def load(self):
+        if self._loaded:
+            return
+        self._loaded = True
+        started = time.time()
+        path, with_metadata = (CLONES_PATH, True) if os.path.exists(CLONES_PATH) else (REPAIR_PATH, False)
+        if not os.path.exists(path):
+            print("⚠️ [Clones] No CIRCL data found, clone detection disabled.")
+            return
+
+        postings: Dict[str, Dict[int, List[int]]] = defaultdict(lambda: defaultdict(list))
+        with open(path, "r", encoding="utf-8") as f:
+            for row, raw in enumerate(f):
+                record = json.loads(raw)
+                entry = self._entry(record, row, with_metadata)
+                if not entry:
+                    continue
+                index = len(self.entries)
+                self.entries.append(entry)
+                for h in entry["hashes"]:
+                    postings[entry["language"]][h].append(index)
+
+        # Boilerplate fingerprints (shared by many unrelated hunks) carry no signal
+        self.postings = {
+            language: {h: ids for h, ids in table.items() if len(ids) <= MAX_POSTINGS}
+            for language, table in postings.items()
+        }
+        self._load_embeddings()
+        print(f"🧬 [Clones] Indexed {len(self.entries)} vulnerable hunks from {os.path.basename(path)} "
+              f"in {time.time() - started:.1f}s (embeddings: {'yes' if self.vectors is not None else 'no'})")
+
+    def _entry(self, record: Dict[str, Any], row: int, with_metadata: bool) -> Optional[Dict[str, Any]]:
+        language = DATASET_LANGUAGES.get(record.get("language"), record.get("language"))
+        if language not in set(EXTENSIONS.values()):
+            return None
+        if with_metadata:
+            vulnerable, fixed = record.get("vulnerable", ""), record.get("fixed", "")
+        else:
+            vulnerable, fixed = record.get("input", "").removeprefix("fix vulnerability: "), record.get("output", "")
+
+        tokens = self.normalize(vulnerable, language)
+        if len(tokens) < MIN_TOKENS:
+            return None
+        hashes = {h for h, _ in self.fingerprints(tokens)}
+        if len(hashes) < MIN_FINGERPRINTS:
+            return None
+        fixed_hashes = {h for h, _ in self.fingerprints(self.normalize(fixed, language))}
+        return {
+            "row": row,
+            "language": language,
+            "hashes": hashes,
+            "lines": vulnerable.strip("\n").count("\n") + 1,
+            # What the patch removed / added: a clone must contain the former and not the latter
+            "removed": hashes - fixed_hashes,
+            "added": fixed_hashes - hashes,
+            "cve": record.get("cve") or None,
+            "cwes": record.get("cwes") or [],
+            "patch_url": record.get("patch_url") or None,
+            "source_file": record.get("file") or None,
+            "fixed": fixed,
+        }
+
+    def _load_embeddings(self):
+        if not os.path.exists(EMBEDDINGS_PATH):
+            return
+        try:
+            import numpy as np
+            vectors = np.load(EMBEDDINGS_PATH)
+            rows = max((e["row"] for e in self.entries), default=-1)
+            if len(vectors) <= rows:
+                print("⚠️ [Clones] clone_embeddings.npy is out of date, rebuild it with scripts/build_clone_index.py")
+                return
+            self.vectors = vectors
+        except Exception as e:
+            print(f"⚠️ [Clones] Failed to load embeddings: {e}")
+
+    # --- Normalization ---
+    def normalize(self, code: str, language: str) -> List[tuple]:
+        """[(token, line)] with comments dropped and identifiers / literals abstracted (calls keep their name)."""
+        code = re.sub(r"/\*[\s\S]*?\*/", lambda m: "\n" * m.group(0).count("\n"), code)
+        comment = r"#.*$" if language in HASH_COMMENT_LANGUAGES else r"//.*$"
+        tokens = []
+        for number, line in enumerate(code.split("\n"), start=1):
+            if IMPORT_LINE.match(line):
+                continue
+            line = re.sub(comment, "", line)
+            raw = re.findall(r"\"(?:[^\"\\]|\\.)*\"|'(?:[^'\\]|\\.)*'|`[^`]*`|\w+|->|::|&&|\|\||[<>=!+\-*/%&|^]=?|[^\s\w]", line)
+            for i, token in enumerate(raw):
+                if token[0] in "\"'`":
+                    tokens.append(("STR", number))
+                elif token[0].isdigit():
+                    tokens.append(("NUM", number))
+                elif re.match(r"\w+$", token):
+                    is_call = i + 1 < len(raw) and raw[i + 1] == "("
+                    tokens.append((token if is_call or token in KEYWORDS else "ID", number))
+                else:
+                    tokens.append((token, number))
+        return tokens
+
+    def fingerprints(self, tokens: List[tuple]) -> List[tuple]:
+        """Winnowed k-gram hashes: [(hash, line of the k-gram's first token)]."""
+        grams = [
+            (zlib.crc32(" ".join(t for t, _ in tokens[i:i + KGRAM]).encode("utf-8")), tokens[i][1])
+            for i in range(len(tokens) - KGRAM + 1)
+        ]
+        if len(grams) <= WINDOW:
+            return grams
+        selected = {}
+        for i in range(len(grams) - WINDOW + 1):
+            window = grams[i:i + WINDOW]
+            h, line = min(window, key=lambda g: g[0])
+            selected.setdefault((h, line), None)
+        return list(selected)
+
+    # --- Scan ---
+    def scan_directory(self, root_dir: str, embed=None) -> List[Dict[str, Any]]:
+        """
+        Clone alerts (RepoScanner shape) for every code file under root_dir.
+        `embed` (callable(List[str]) -> List[List[float]]) overrides the embeddings model
+        used for semantic confirmation (only when clone_embeddings.npy exists).
+        """
+        self.load()
+        if not self.entries:
+            return []
+        alerts = []
+        for current_root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in SKIP_DIRS]
+            for name in files:
+                language = EXTENSIONS.get(os.path.splitext(name)[1].lower())
+                path = os.path.join(current_root, name)
+                if not language or language not in self.postings or os.path.getsize(path) > MAX_FILE_BYTES:
+                    continue
+                try:
+                    with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                        content = f.read()
+                except OSError:
+                    continue
+                alerts.extend(self.scan_content(content, os.path.relpath(path, root_dir), language, embed))
+        if alerts:
+            print(f"🧬 [Clones] {len(alerts)} clone(s) of known vulnerable code in {root_dir}")
+        return alerts
+
+    def scan_content(self, content: str, filename: str, language: str, embed=None) -> List[Dict[str, Any]]:
+        table = self.postings.get(language) or {}
+        lines_by_hash = defaultdict(list)
+        for h, line in self.fingerprints(self.normalize(content, language)):
+            lines_by_hash[h].append(line)
+
+        candidates = defaultdict(set)
+        for h in lines_by_hash:
+            for index in table.get(h, ()):
+                candidates[index].add(h)
+
+        matches = []
+        for index, shared in candidates.items():
+            entry = self.entries[index]
+            if len(shared) / len(entry["hashes"]) < CLONE_THRESHOLD:
+                continue
+            # A copy is contiguous: keep only the fingerprints inside the densest region of the hunk's size
+            shared, start, end = self._densest_region(shared, lines_by_hash, entry["lines"])
+            similarity = len(shared) / len(entry["hashes"])
+            if similarity < CLONE_THRESHOLD:
+                continue
+            # The file must contain what the patch removed, and not what it added (already patched)
+            if entry["removed"] and len(entry["removed"] & shared) / len(entry["removed"]) < CLONE_THRESHOLD:
+                continue
+            if entry["added"] and len(entry["added"] & lines_by_hash.keys()) / len(entry["added"]) >= 0.5:
+                continue
+            removed_lines = sorted(line for h in entry["removed"] & shared for line in lines_by_hash[h] if start <= line <= end)
+            matches.append({
+                "entry": entry,
+                "similarity": similarity,
+                "start": start,
+                "end": end,
+                "line": removed_lines[0] if removed_lines else start,
+            })
+
+        lines = content.split("\n")
+        alerts = []
+        for match in self._best_per_region(matches):
+            region = "\n".join(lines[match["start"] - 1:match["end"]])
+            semantic = self._semantic(match["entry"], region, embed)
+            if semantic is not None and semantic < SEMANTIC_THRESHOLD:
+                continue
+            alerts.append(self._alert(match, semantic, filename, lines))
+        return alerts
+
+    def _densest_region(self, shared: set, lines_by_hash: Dict[int, List[int]], hunk_lines: int):
+        """(fingerprints inside the best window, first line, last line) for a window of ~2x the hunk length."""
+        span = max(2 * hunk_lines, 20)
+        positions = sorted((line, h) for h in shared for line in lines_by_hash[h])
+        counts, best, left = defaultdict(int), (0, 0, 0), 0
+        for right, (line, h) in enumerate(positions):
+            counts[h] += 1
+            while line - positions[left][0] > span:
+                counts[positions[left][1]] -= 1
+                if not counts[positions[left][1]]:
+                    del counts[positions[left][1]]
+                left += 1
+            if len(counts) > best[0]:
+                best = (len(counts), left, right)
+        _, left, right = best
+        return {h for _, h in positions[left:right + 1]}, positions[left][0], positions[right][0]
+
+    def _best_per_region(self, matches: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """One match per overlapping line range: the most similar hunk (CVE-cited hunks win ties)."""
+        best = []
+        for match in sorted(matches, key=lambda m: (-m["similarity"], m["entry"]["cve"] is None)):
+            if any(match["start"] <= b["end"] and b["start"] <= match["end"] for b in best):
+                continue
+            best.append(match)
+        return sorted(best, key=lambda m: m["start"])
+
+    def _semantic(self, entry: Dict[str, Any], region: str, embed) -> Optional[float]:
+        if self.vectors is None:
+            return None
+        try:
+            if embed is None:
+                # Same model as scripts/build_clone_index.py
+                if self._embeddings is None:
+                    from langchain_openai import OpenAIEmbeddings
+                    self._embeddings = OpenAIEmbeddings()
+                embed = self._embeddings.embed_documents
+            query = embed([region])[0]
+            vector = self.vectors[entry["row"]]
+            norm = math.sqrt(sum(x * x for x in query)) * math.sqrt(float((vector * vector).sum()))
+            return float(sum(x * float(y) for x, y in zip(query, vector)) / norm) if norm else 0.0
+        except Exception as e:
+            print(f"⚠️ [Clones] Embedding check failed: {e}")
+            return None
+
+    def _alert(self, match: Dict[str, Any], semantic: Optional[float], filename: str, lines: List[str]) -> Dict[str, Any]:
+        entry = match["entry"]
+        index = match["line"] - 1
+        function = self._enclosing_function(lines, index)
+        reference = entry["cve"] or f"CIRCL sample #{entry['row'] + 1}"
+        where = f"Function `{function}`" if function else f"Lines {match['start']}-{match['end']}"
+        description = (
+            f"{where} is a near-copy ({match['similarity']:.0%} of fingerprints) of code that was patched for "
+            f"{reference}. It still contains the vulnerable lines and not the fix; apply the upstream patch"
+            + (f": {entry['patch_url']}" if entry["patch_url"] else ".")
+        )
+        context_snippet = "\n".join(lines[max(0, index - 2):index + 3])
+        return {
+            "alert": f"Vulnerable Code Clone ({reference})",
+            "risk": "High",
+            "description": description,
+            "other": f"File: {filename}:{index + 1}\nCode:\n{context_snippet}"[:500],
+            "rule_id": "VULN-CODE-CLONE",
+            "cwe": (entry["cwes"] or ["CWE-1104"])[0],
+            "file": filename,
+            "line": index + 1,
+            "code": lines[index].strip() if index < len(lines) else "",
+            "clone": {
+                "cve": entry["cve"],
+                "patch_url": entry["patch_url"],
+                "source_file": entry["source_file"],
+                "similarity": round(match["similarity"], 3),
+                "semantic": round(semantic, 3) if semantic is not None else None,
+                "function": function,
+                "lines": [match["start"], match["end"]],
+                "fixed_code": entry["fixed"][:1500],
+            },
+        }
+
+    def _enclosing_function(self, lines: List[str], index: int) -> Optional[str]:
+        for i in range(min(index, len(lines) - 1), max(-1, index - 200), -1):
+            header = FUNCTION_HEADER.match(lines[i])
+            if header:
+                name = header.group(1) or header.group(2)
+                if name not in KEYWORDS:
+                    return name
+        return None
+
+
+clone_detector = CloneDetector()
@@ -0,0 +1,302 @@
+import csv
+import html
+import io
+import json
+import os
+from datetime import datetime
+from typing import List, Dict, Any, Optional
+
+
+MAPPINGS_PATH = os.path.join(os.path.dirname(os.path.dirname(__file__)), "data", "compliance_mappings.json")
+
+# CWEs the ZAP baseline/active scan rules test for (DAST targets)
+DAST_CWES = {
+    "CWE-22": "ZAP Path Traversal", "CWE-78": "ZAP Remote OS Command Injection", "CWE-79": "ZAP Cross Site Scripting",
+    "CWE-89": "ZAP SQL Injection", "CWE-94": "ZAP Code Injection", "CWE-200": "ZAP Information Disclosure",
+    "CWE-319": "ZAP HTTPS/Mixed Content", "CWE-352": "ZAP Absence of Anti-CSRF Tokens", "CWE-611": "ZAP XXE",
+    "CWE-614": "ZAP Cookie Without Secure Flag", "CWE-693": "ZAP CSP Header Not Set",
+    "CWE-918": "ZAP Server Side Request Forgery", "CWE-1004": "ZAP Cookie No HttpOnly Flag",
+    "CWE-1021": "ZAP Anti-clickjacking Header",
+}
+
+STATUS_LABELS = {"open_findings": "❌ Open findings", "covered": "✅ Covered, no findings", "not_covered": "⚪ Not covered"}
+
+
+class ComplianceReporter:
+    """
+    ComplianceReporter maps findings and rule coverage onto compliance controls
+    (OWASP Top 10, ASVS, PCI DSS, ISO 27001) using the CWE mappings shipped in
+    src/data/compliance_mappings.json.
+
+    Per control:
+    - open_findings: the latest scan of the project has findings with a mapped CWE.
+    - covered: at least one enabled rule tests a mapped CWE and nothing is open.
+    - not_covered: no enabled rule tests it (manual review / other tooling needed).
+
+    Privacy findings (pii-privacy rule pack) name the GDPR articles they concern, so the GDPR
+    report maps them by article instead of CWE and adds a summary by personal-data category.
+    """
+    def __init__(self, mappings_path: str = MAPPINGS_PATH):
+        self.mappings_path = mappings_path
+        self._mappings = None
+
+    @property
+    def mappings(self) -> Dict[str, Any]:
+        if self._mappings is None:
+            with open(self.mappings_path, "r", encoding="utf-8") as f:
+                self._mappings = json.load(f)
+        return self._mappings
+
+    def list_frameworks(self) -> List[Dict[str, Any]]:
+        return [
+            {"id": framework_id, "name": framework["name"], "url": framework.get("url"), "controls": len(framework["controls"])}
+            for framework_id, framework in self.mappings["frameworks"].items()
+        ]
+
+    def controls_for_cwe(self, cwe: str) -> List[Dict[str, str]]:
+        """All controls (any framework) a CWE maps to."""
+        return [
+            {"framework": framework_id, "id": control["id"], "title": control["title"]}
+            for framework_id, framework in self.mappings["frameworks"].items()
+            for control in framework["controls"] if cwe in control["cwes"]
+        ]
+
+    def build_report(
+        self,
+        framework_id: str,
+        project: str,
+        scans: List[Dict[str, Any]],
+        rule_catalog: List[Dict[str, Any]]
+    ) -> Dict[str, Any]:
+        """
+        Args:
+            framework_id: key in compliance_mappings.json (e.g. "owasp_top10_2021").
+            project: scan target (repository URL or web URL).
+            scans: scans of the project, newest first. Findings of the newest one are "open".
+            rule_catalog: enabled SAST rules (RepoScanner.rule_catalog()); DAST targets use the ZAP rule set.
+        """
+        framework = self.mappings["frameworks"].get(framework_id)
+        if framework is None:
+            raise ValueError(f"Unknown framework '{framework_id}'. Available: {', '.join(self.mappings['frameworks'])}")
+
+        latest = scans[0] if scans else None
+        open_findings = [f for f in (latest or {}).get("findings") or [] if f.get("status", "open") == "open"]
+
+        coverage: Dict[str, List[str]] = {}
+        if "github.com" in project:
+            for rule in rule_catalog:
+                coverage.setdefault(rule["cwe"], []).append(rule["id"])
+        else:
+            for cwe, rule in DAST_CWES.items():
+                coverage.setdefault(cwe, []).append(rule)
+
+        evidence_scans = [
+            {"scan_id": s["scan_id"], "date": self._date(s.get("created_at")), "status": s.get("status"),
+             "link": f"/scan/{s['scan_id']}/findings"}
+            for s in scans[:5]
+        ]
+
+        controls, mapped = [], set()
+        for control in framework["controls"]:
+            cwes = set(control["cwes"])
+            findings = [f for f in open_findings if self._maps_to(f, control, framework_id)]
+            mapped.update(f.get("fingerprint") for f in findings)
+            rules = sorted({rule for cwe in cwes for rule in coverage.get(cwe, [])})
+
+            if findings:
+                status = "open_findings"
+            elif rules and latest:
+                status = "covered"
+            else:
+                status = "not_covered"
+
+            controls.append({
+                "id": control["id"],
+                "title": control["title"],
+                "status": status,
+                "cwes": control["cwes"],
+                "rules": rules,
+                "open_findings": [self._finding_summary(f, latest) for f in findings],
+                "evidence": evidence_scans[:1] if status == "open_findings" else evidence_scans if status == "covered" else []
+            })
+
+        tested = [c for c in controls if c["status"] != "not_covered"]
+        return {
+            "project": project,
+            "framework": framework_id,
+            "framework_name": framework["name"],
+            "framework_url": framework.get("url"),
+            "generated_at": datetime.utcnow().isoformat() + "Z",
+            "latest_scan_id": latest["scan_id"] if latest else None,
+            "summary": {
+                "controls": len(controls),
+                "open_findings": sum(1 for c in controls if c["status"] == "open_findings"),
+                "covered": sum(1 for c in controls if c["status"] == "covered"),
+                "not_covered": sum(1 for c in controls if c["status"] == "not_covered"),
+                "coverage_percent": round(100 * len(tested) / len(controls)) if controls else 0
+            },
+            "controls": controls,
+            "unmapped_findings": [self._finding_summary(f, latest) for f in open_findings if f.get("fingerprint") not in mapped],
+            "privacy_summary": self._privacy_summary(open_findings),
+            "accepted_risks": [
+                {**self._finding_summary(f, latest), "exception_id": f["exception"]["exception_id"],
+                 "expires_at": self._date(f["exception"]["expires_at"]), "approved_by": f["exception"]["approved_by"],
+                 "justification": f["exception"]["justification"]}
+                for f in (latest or {}).get("findings") or [] if f.get("status") == "risk_accepted" and f.get("exception")
+            ]
+        }
+
+    # --- Renderers ---
+    def to_markdown(self, report: Dict[str, Any]) -> str:
+        summary = report["summary"]
+        lines = [
+            f"# {report['framework_name']} Compliance Report",
+            "",
+            f"- **Project**: {report['project']}",
+            f"- **Latest scan**: {report['latest_scan_id'] or 'none'}",
+            f"- **Generated**: {report['generated_at']}",
+            f"- **Coverage**: {summary['coverage_percent']}% of controls tested "
+            f"({summary['open_findings']} with open findings, {summary['covered']} passing, {summary['not_covered']} not covered)",
+            "",
+            "| Control | Title | Status | Open findings | Rules | Evidence |",
+            "|---|---|---|---|---|---|",
+        ]
+        for control in report["controls"]:
+            findings = "<br>".join(self._finding_label(f) for f in control["open_findings"]) or "-"
+            rules = ", ".join(control["rules"][:6]) + (" …" if len(control["rules"]) > 6 else "") or "-"
+            evidence = ", ".join(f"[{e['scan_id'][:8]}]({e['link']})" for e in control["evidence"]) or "-"
+            lines.append(
+                f"| {control['id']} | {control['title']} | {STATUS_LABELS[control['status']]} | {findings} | {rules} | {evidence} |"
+            )
+        privacy = report.get("privacy_summary")
+        if privacy:
+            lines += ["", "## Personal data processing", "",
+                      f"{privacy['findings']} finding(s) expose personal or secret data "
+                      f"({privacy['special_category']} involving GDPR Art. 9 special categories).", "",
+                      "| Data category | Findings | Sinks |", "|---|---|---|"]
+            lines += [f"| {c['category']} | {c['findings']} | {', '.join(c['sinks'])} |" for c in privacy["categories"]]
+        if report.get("accepted_risks"):
+            lines += ["", "## Accepted risks", "",
+                      "Findings shipped under an approved risk exception (not counted as open until the exception expires).", "",
+                      "| Finding | Approved by | Expires | Justification |", "|---|---|---|---|"]
+            lines += [f"| {self._finding_label(f)} | {f['approved_by']} | {(f['expires_at'] or '')[:10]} | {f['justification']} |"
+                      for f in report["accepted_risks"]]
+        if report["unmapped_findings"]:
+            lines += ["", "## Findings not mapped to this framework", ""]
+            lines += [f"- {self._finding_label(f)}" for f in report["unmapped_findings"]]
+        return "\n".join(lines) + "\n"
+
+    def to_html(self, report: Dict[str, Any]) -> str:
+        e = html.escape
+        summary = report["summary"]
+        rows = []
+        for control in report["controls"]:
+            findings = "<br>".join(e(self._finding_label(f)) for f in control["open_findings"]) or "-"
+            evidence = ", ".join(f'<a href="{e(ev["link"])}">{e(ev["scan_id"][:8])}</a>' for ev in control["evidence"]) or "-"
+            rows.append(
+                f'<tr class="{control["status"]}"><td>{e(control["id"])}</td><td>{e(control["title"])}</td>'
+                f'<td>{e(STATUS_LABELS[control["status"]])}</td><td>{findings}</td>'
+                f'<td>{e(", ".join(control["rules"])) or "-"}</td><td>{evidence}</td></tr>'
+            )
+        return f"""<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{e(report['framework_name'])} Compliance Report - {e(report['project'])}</title>
+<style>
+body {{ font-family: -apple-system, "Segoe UI", sans-serif; margin: 2rem; color: #1f2933; }}
+table {{ border-collapse: collapse; width: 100%; font-size: 0.9rem; }}
+th, td {{ border: 1px solid #d9e2ec; padding: 6px 8px; text-align: left; vertical-align: top; }}
+th {{ background: #f0f4f8; }}
+tr.open_findings td:nth-child(3) {{ color: #c62828; font-weight: 600; }}
+tr.covered td:nth-child(3) {{ color: #2e7d32; }}
+tr.not_covered td:nth-child(3) {{ color: #7b8794; }}
+.summary {{ display: flex; gap: 1.5rem; margin: 1rem 0; }}
+.summary div {{ background: #f0f4f8; padding: 0.75rem 1rem; border-radius: 6px; }}
+</style>
+</head>
+<body>
+<h1>{e(report['framework_name'])} Compliance Report</h1>
+<p>Project: <strong>{e(report['project'])}</strong> &middot; Latest scan: {e(report['latest_scan_id'] or 'none')} &middot; Generated: {e(report['generated_at'])}</p>
+<div class="summary">
+<div>Coverage<br><strong>{summary['coverage_percent']}%</strong></div>
+<div>Open findings<br><strong>{summary['open_findings']}</strong></div>
+<div>Covered<br><strong>{summary['covered']}</strong></div>
+<div>Not covered<br><strong>{summary['not_covered']}</strong></div>
+<div>Accepted risks<br><strong>{len(report.get('accepted_risks') or [])}</strong></div>
+</div>
+<table>
+<thead><tr><th>Control</th><th>Title</th><th>Status</th><th>Open findings</th><th>Rules</th><th>Evidence</th></tr></thead>
+<tbody>
+{chr(10).join(rows)}
+</tbody>
+</table>
+</body>
+</html>
+"""
+
+    def to_csv(self, report: Dict[str, Any]) -> str:
+        output = io.StringIO()
+        writer = csv.writer(output)
+        writer.writerow(["framework", "control_id", "title", "status", "open_findings", "findings", "cwes", "rules", "evidence"])
+        for control in report["controls"]:
+            writer.writerow([
+                report["framework"], control["id"], control["title"], control["status"],
+                len(control["open_findings"]),
+                "; ".join(self._finding_label(f) for f in control["open_findings"]),
+                " ".join(control["cwes"]),
+                " ".join(control["rules"]),
+                " ".join(ev["link"] for ev in control["evidence"]),
+            ])
+        return output.getvalue()
+
+    # --- Helpers ---
+    def _maps_to(self, finding: Dict[str, Any], control: Dict[str, Any], framework_id: str) -> bool:
+        articles = (finding.get("privacy") or {}).get("gdpr_articles")
+        if framework_id == "gdpr" and articles is not None:
+            return control["id"] in articles
+        return finding.get("cwe") in control["cwes"]
+
+    def _privacy_summary(self, findings: List[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        """Open privacy findings grouped by personal-data category (GDPR records / DPIA input)."""
+        privacy = [f["privacy"] for f in findings if f.get("privacy")]
+        if not privacy:
+            return None
+        categories: Dict[str, Dict[str, Any]] = {}
+        for item in privacy:
+            entry = categories.setdefault(item["data_category"], {"category": item["data_category"], "findings": 0, "sinks": set(), "data": set()})
+            entry["findings"] += 1
+            entry["sinks"].add(item["sink"])
+            entry["data"].add(item["data"])
+        return {
+            "findings": len(privacy),
+            "special_category": sum(1 for item in privacy if item.get("gdpr_special_category")),
+            "categories": [
+                {**entry, "sinks": sorted(entry["sinks"]), "data": sorted(entry["data"])}
+                for entry in sorted(categories.values(), key=lambda e: -e["findings"])
+            ]
+        }
+
+    def _finding_summary(self, finding: Dict[str, Any], scan: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            "fingerprint": finding.get("fingerprint"),
+            "alert": finding.get("alert"),
+            "cwe": finding.get("cwe"),
+            "risk": finding.get("risk"),
+            "location": f"{finding['file']}:{finding.get('line')}" if finding.get("file") else finding.get("url", ""),
+            "cvss": (finding.get("cvss") or {}).get("base_score"),
+            "sla_status": (finding.get("sla") or {}).get("status"),
+            "sla_due": self._date((finding.get("sla") or {}).get("due_at")),
+            "link": f"/scan/{scan['scan_id']}/findings"
+        }
+
+    def _finding_label(self, finding: Dict[str, Any]) -> str:
+        cvss = f", CVSS {finding['cvss']}" if finding.get("cvss") is not None else ""
+        sla = f" [SLA {finding['sla_status'].replace('_', ' ')}]" if finding.get("sla_status") in ("due_soon", "overdue", "escalated") else ""
+        return f"{finding['alert']} ({finding.get('cwe') or 'no CWE'}{cvss}) @ {finding['location']}{sla}"
+
+    def _date(self, value: Optional[Any]) -> Optional[str]:
+        return value.isoformat() if isinstance(value, datetime) else value
+
+
+compliance_reporter = ComplianceReporter()
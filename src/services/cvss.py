@@ -0,0 +1,316 @@
+import math
+import re
+import urllib.parse
+from typing import List, Dict, Any, Optional
+
+
+# Base metric defaults per CWE (CVSS v3.1). Context (auth on the route, reachability,
+# dynamic confirmation) is applied on top of these in CvssCalculator.score().
+CWE_BASE_METRICS = {
+    "CWE-89":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # SQL Injection
+    "CWE-78":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # OS Command Injection
+    "CWE-77":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},
+    "CWE-94":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # Code Injection
+    "CWE-95":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},
+    "CWE-502": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # Unsafe Deserialization
+    "CWE-798": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # Hardcoded Credentials
+    "CWE-22":  {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "N", "A": "N"},   # Path Traversal
+    "CWE-79":  {"AV": "N", "AC": "L", "PR": "N", "UI": "R", "S": "C", "C": "L", "I": "L", "A": "N"},   # XSS
+    "CWE-352": {"AV": "N", "AC": "L", "PR": "N", "UI": "R", "S": "U", "C": "N", "I": "H", "A": "N"},   # CSRF
+    "CWE-918": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "C", "C": "L", "I": "L", "A": "N"},   # SSRF
+    "CWE-611": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "N", "A": "L"},   # XXE
+    "CWE-489": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "L", "I": "N", "A": "N"},   # Debug Mode
+    "CWE-200": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "L", "I": "N", "A": "N"},   # Information Exposure
+    "CWE-693": {"AV": "N", "AC": "L", "PR": "N", "UI": "R", "S": "U", "C": "N", "I": "L", "A": "N"},   # Missing security headers
+    "CWE-1021": {"AV": "N", "AC": "L", "PR": "N", "UI": "R", "S": "U", "C": "N", "I": "L", "A": "N"},  # Clickjacking
+    "CWE-362": {"AV": "N", "AC": "H", "PR": "N", "UI": "N", "S": "U", "C": "L", "I": "L", "A": "H"},   # Race Condition
+    "CWE-367": {"AV": "L", "AC": "H", "PR": "L", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "N"},   # TOCTOU
+    "CWE-242": {"AV": "N", "AC": "H", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # unsafe.Pointer
+    "CWE-843": {"AV": "N", "AC": "H", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # Type Confusion
+    "CWE-787": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "H"},   # Out-of-bounds Write
+    "CWE-401": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "N", "I": "N", "A": "H"},   # Memory Leak (DoS)
+    "CWE-546": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "N", "I": "N", "A": "N"},   # TODO comment (informational)
+}
+
+# Fallback by scanner risk label when the CWE is unknown
+RISK_BASE_METRICS = {
+    "High":   {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "H", "I": "H", "A": "N"},
+    "Medium": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "L", "I": "L", "A": "N"},
+    "Low":    {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "L", "I": "N", "A": "N"},
+    "Informational": {"AV": "N", "AC": "L", "PR": "N", "UI": "N", "S": "U", "C": "N", "I": "N", "A": "N"},
+}
+
+# Attack requirements (v4 AT:P): exploitation depends on winning a race
+RACE_CWES = {"CWE-362", "CWE-367"}
+
+BASE_KEYS = ["AV", "AC", "PR", "UI", "S", "C", "I", "A"]
+TEMPORAL_KEYS = ["E", "RL", "RC"]
+ENVIRONMENTAL_KEYS = ["CR", "IR", "AR", "MAV", "MAC", "MPR", "MUI", "MS", "MC", "MI", "MA"]
+
+# Single-letter values allowed per metric (exact match: "AV:NA" is invalid)
+ALLOWED_VALUES = {key: frozenset(letters) for key, letters in {
+    "AV": "NALP", "AC": "LH", "PR": "NLH", "UI": "NR", "S": "UC", "C": "HLN", "I": "HLN", "A": "HLN",
+    "E": "XUPFH", "RL": "XOTWU", "RC": "XURC",
+    "CR": "XLMH", "IR": "XLMH", "AR": "XLMH",
+    "MAV": "XNALP", "MAC": "XLH", "MPR": "XNLH", "MUI": "XNR", "MS": "XUC", "MC": "XHLN", "MI": "XHLN", "MA": "XHLN",
+}.items()}
+
+WEIGHTS = {
+    "AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+    "AC": {"L": 0.77, "H": 0.44},
+    "UI": {"N": 0.85, "R": 0.62},
+    "CIA": {"H": 0.56, "L": 0.22, "N": 0.0},
+    "E": {"X": 1.0, "H": 1.0, "F": 0.97, "P": 0.94, "U": 0.91},
+    "RL": {"X": 1.0, "U": 1.0, "W": 0.97, "T": 0.96, "O": 0.95},
+    "RC": {"X": 1.0, "C": 1.0, "R": 0.96, "U": 0.92},
+    "REQ": {"X": 1.0, "H": 1.5, "M": 1.0, "L": 0.5},
+}
+
+
+def _pr_weight(value: str, scope: str) -> float:
+    if value == "N":
+        return 0.85
+    if value == "L":
+        return 0.68 if scope == "C" else 0.62
+    return 0.5 if scope == "C" else 0.27
+
+
+def roundup(value: float) -> float:
+    """CVSS v3.1 Roundup (Appendix A): smallest number, to one decimal, >= value."""
+    int_input = round(value * 100000)
+    if int_input % 10000 == 0:
+        return int_input / 100000.0
+    return (math.floor(int_input / 10000) + 1) / 10.0
+
+
+def severity(score: float) -> str:
+    if score == 0:
+        return "None"
+    if score < 4.0:
+        return "Low"
+    if score < 7.0:
+        return "Medium"
+    if score < 9.0:
+        return "High"
+    return "Critical"
+
+
+class CvssCalculator:
+    """
+    CvssCalculator derives a CVSS vector per finding and computes its scores.
+
+    Inputs:
+    - Rule metadata: base metric defaults per CWE (or per scanner risk when the CWE is unknown).
+    - Route authentication (attack surface): authenticated routes need PR:L.
+    - Dynamic confirmation (PoC replay, Go fuzzing, DAST observation): temporal E/RC metrics.
+    - Reachability: findings no entrypoint reaches are scored locally (environmental MAV:L).
+    - Triage overrides: any v3.1 metric (base, temporal or environmental) replaces the derived value.
+
+    Scores are computed with the CVSS v3.1 specification (base, temporal, environmental).
+    A CVSS v4.0 vector is derived from the same metrics for tools that consume v4;
+    v4 scores are not computed here (they require the FIRST macro-vector lookup tables).
+    """
+
+    def score(
+        self,
+        finding: Dict[str, Any],
+        surface: Optional[Dict[str, Any]] = None,
+        override: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        """
+        Returns:
+            {
+                "version": "3.1",
+                "vector": "CVSS:3.1/AV:N/...", "base_score", "base_severity",
+                "temporal_score", "environmental_score", "environmental_severity",
+                "v4_vector": "CVSS:4.0/AV:N/...",
+                "rationale": List[str], "overridden": bool
+            }
+        """
+        metrics, rationale = self.derive_metrics(finding, surface)
+        overridden = False
+        if override:
+            override_metrics = override.get("metrics") or {}
+            if override.get("vector"):
+                override_metrics = {**self.parse_vector(override["vector"]), **override_metrics}
+            for key, value in override_metrics.items():
+                self._check(key, value)
+                metrics[key] = value
+            overridden = bool(override_metrics)
+            rationale.append(f"Triage override by {override.get('analyst') or 'analyst'}: {override.get('reason') or 'no reason given'}")
+
+        scores = self.compute(metrics)
+        return {
+            "version": "3.1",
+            "vector": self.to_vector(metrics),
+            **scores,
+            "v4_vector": self.to_v4_vector(metrics, finding),
+            "rationale": rationale,
+            "overridden": overridden
+        }
+
+    def derive_metrics(self, finding: Dict[str, Any], surface: Optional[Dict[str, Any]] = None):
+        """Metric dict and a list of human-readable reasons for each adjustment."""
+        cwe = finding.get("cwe")
+        if cwe in CWE_BASE_METRICS:
+            metrics = dict(CWE_BASE_METRICS[cwe])
+            rationale = [f"Base metrics: defaults for {cwe}."]
+        else:
+            risk = finding.get("risk") if finding.get("risk") in RISK_BASE_METRICS else "Medium"
+            metrics = dict(RISK_BASE_METRICS[risk])
+            rationale = [f"Base metrics: no CWE defaults, derived from scanner risk '{risk}'."]
+
+        route = self.route_for(finding, surface) if surface else None
+        if route:
+            if route["auth"] and metrics["PR"] == "N":
+                metrics["PR"] = "L"
+                rationale.append(f"PR:L - route {route['method']} {route['path']} requires authentication.")
+            elif not route["auth"]:
+                rationale.append(f"PR:N - route {route['method']} {route['path']} is unauthenticated.")
+
+        # Temporal: exploit maturity / report confidence
+        poc_status = (finding.get("poc") or {}).get("status")
+        fuzz_status = (finding.get("fuzz") or {}).get("status")
+        if poc_status == "confirmed" or fuzz_status == "confirmed":
+            metrics.update({"E": "F", "RC": "C"})
+            rationale.append("E:F/RC:C - exploit confirmed by sandboxed PoC or fuzzing.")
+        elif finding.get("source") == "dast":
+            metrics.update({"E": "X", "RC": "C"})
+            rationale.append("RC:C - observed against the running target (DAST).")
+        else:
+            metrics.update({"E": "U", "RC": "R"})
+            rationale.append("E:U/RC:R - static finding, not dynamically confirmed.")
+
+        # Environmental: reachability from entrypoints
+        if finding.get("reachable") is False:
+            metrics["MAV"] = "L"
+            rationale.append("MAV:L - no entrypoint reaches this code (reachability analysis).")
+        elif finding.get("reachable") is True:
+            rationale.append("Reachable from an entrypoint: " + " -> ".join(finding.get("call_path") or []))
+
+        return metrics, rationale
+
+    def compute(self, metrics: Dict[str, str]) -> Dict[str, Any]:
+        """CVSS v3.1 base, temporal and environmental scores."""
+        m = {key: "X" for key in TEMPORAL_KEYS + ENVIRONMENTAL_KEYS}
+        m.update(metrics)
+
+        # Base
+        iss = 1 - (1 - WEIGHTS["CIA"][m["C"]]) * (1 - WEIGHTS["CIA"][m["I"]]) * (1 - WEIGHTS["CIA"][m["A"]])
+        if m["S"] == "U":
+            impact = 6.42 * iss
+        else:
+            impact = 7.52 * (iss - 0.029) - 3.25 * (iss - 0.02) ** 15
+        exploitability = 8.22 * WEIGHTS["AV"][m["AV"]] * WEIGHTS["AC"][m["AC"]] * _pr_weight(m["PR"], m["S"]) * WEIGHTS["UI"][m["UI"]]
+        if impact <= 0:
+            base = 0.0
+        elif m["S"] == "U":
+            base = roundup(min(impact + exploitability, 10))
+        else:
+            base = roundup(min(1.08 * (impact + exploitability), 10))
+
+        # Temporal
+        temporal_factor = WEIGHTS["E"][m["E"]] * WEIGHTS["RL"][m["RL"]] * WEIGHTS["RC"][m["RC"]]
+        temporal = roundup(base * temporal_factor)
+
+        # Environmental (modified metrics default to the base values)
+        def modified(key):
+            return m[f"M{key}"] if m[f"M{key}"] != "X" else m[key]
+
+        scope = modified("S")
+        miss = min(1 - (1 - WEIGHTS["REQ"][m["CR"]] * WEIGHTS["CIA"][modified("C")])
+                     * (1 - WEIGHTS["REQ"][m["IR"]] * WEIGHTS["CIA"][modified("I")])
+                     * (1 - WEIGHTS["REQ"][m["AR"]] * WEIGHTS["CIA"][modified("A")]), 0.915)
+        if scope == "U":
+            modified_impact = 6.42 * miss
+        else:
+            modified_impact = 7.52 * (miss - 0.029) - 3.25 * (miss * 0.9731 - 0.02) ** 13
+        modified_exploitability = 8.22 * WEIGHTS["AV"][modified("AV")] * WEIGHTS["AC"][modified("AC")] \
+            * _pr_weight(modified("PR"), scope) * WEIGHTS["UI"][modified("UI")]
+        if modified_impact <= 0:
+            environmental = 0.0
+        elif scope == "U":
+            environmental = roundup(roundup(min(modified_impact + modified_exploitability, 10)) * temporal_factor)
+        else:
+            environmental = roundup(roundup(min(1.08 * (modified_impact + modified_exploitability), 10)) * temporal_factor)
+
+        return {
+            "base_score": base,
+            "base_severity": severity(base),
+            "temporal_score": temporal,
+            "environmental_score": environmental,
+            "environmental_severity": severity(environmental)
+        }
+
+    def to_vector(self, metrics: Dict[str, str]) -> str:
+        parts = [f"{key}:{metrics[key]}" for key in BASE_KEYS]
+        parts += [f"{key}:{metrics[key]}" for key in TEMPORAL_KEYS + ENVIRONMENTAL_KEYS
+                  if metrics.get(key, "X") != "X"]
+        return "CVSS:3.1/" + "/".join(parts)
+
+    def parse_vector(self, vector: str) -> Dict[str, str]:
+        """Parses a CVSS:3.1 vector string. Raises ValueError on unknown metrics or values."""
+        parts = vector.strip().split("/")
+        if parts and parts[0].startswith("CVSS:"):
+            if parts[0] not in ("CVSS:3.1", "CVSS:3.0"):
+                raise ValueError(f"Unsupported CVSS version '{parts[0]}' (v3.1 vectors only).")
+            parts = parts[1:]
+        metrics = {}
+        for part in parts:
+            key, _, value = part.partition(":")
+            self._check(key, value)
+            metrics[key] = value
+        return metrics
+
+    def to_v4_vector(self, metrics: Dict[str, str], finding: Dict[str, Any]) -> str:
+        """
+        CVSS v4.0 vector from the v3.1 metrics:
+        UI:R -> UI:P, S:C -> impact on subsequent systems (SC/SI/SA), race CWEs -> AT:P,
+        confirmed exploit -> E:P, reachability/requirements -> environmental MAV/CR/IR/AR.
+        """
+        scope_changed = metrics["S"] == "C"
+        v4 = {
+            "AV": metrics["AV"], "AC": metrics["AC"],
+            "AT": "P" if finding.get("cwe") in RACE_CWES else "N",
+            "PR": metrics["PR"], "UI": "P" if metrics["UI"] == "R" else "N",
+            "VC": metrics["C"], "VI": metrics["I"], "VA": metrics["A"],
+            "SC": metrics["C"] if scope_changed else "N",
+            "SI": metrics["I"] if scope_changed else "N",
+            "SA": metrics["A"] if scope_changed else "N",
+        }
+        parts = [f"{key}:{value}" for key, value in v4.items()]
+        if metrics.get("E") in ("F", "H"):
+            parts.append("E:P" if metrics["E"] == "F" else "E:A")
+        elif metrics.get("E") == "U":
+            parts.append("E:U")
+        for key in ("CR", "IR", "AR", "MAV"):
+            if metrics.get(key, "X") != "X":
+                parts.append(f"{key}:{metrics[key]}")
+        return "CVSS:4.0/" + "/".join(parts)
+
+    def score_findings(self, findings: List[Dict[str, Any]], surface: Optional[Dict[str, Any]] = None) -> List[Dict[str, Any]]:
+        """Adds `cvss` to every finding (keeping triage overrides)."""
+        for finding in findings:
+            finding["cvss"] = self.score(finding, surface, finding.get("cvss_override"))
+        return findings
+
+    def _check(self, key: str, value: str):
+        if key not in ALLOWED_VALUES or not isinstance(value, str) or value not in ALLOWED_VALUES[key]:
+            raise ValueError(f"Invalid CVSS v3.1 metric '{key}:{value}'.")
+
+    def route_for(self, finding: Dict[str, Any], surface: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        routes = surface.get("routes", [])
+        if finding.get("url"):
+            path = urllib.parse.urlparse(finding["url"]).path or "/"
+            for route in routes:
+                pattern = re.sub(r"\\\{[^}]+\\\}|:\w+", "[^/]+", re.escape(route["path"]))
+                if re.fullmatch(pattern, path):
+                    return route
+            return None
+        # SAST: the closest route declared at or above the finding in the same file
+        line = finding.get("line") or 0
+        candidates = [r for r in routes if r["file"] == finding.get("file") and r["line"] <= line]
+        return max(candidates, key=lambda r: r["line"]) if candidates else None
+
+
+cvss_calculator = CvssCalculator()
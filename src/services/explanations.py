@@ -0,0 +1,276 @@
+import json
+import os
+import re
+from datetime import datetime
+from typing import List, Dict, Any, Optional
+
+
+KNOWLEDGE_PATH = os.path.join(os.path.dirname(os.path.dirname(__file__)), "data", "cwe_knowledge.json")
+
+# Where untrusted data enters the code, per language
+SOURCE_PATTERNS = {
+    "go": [
+        (r"\.URL\.Query\(\)", "URL query string"), (r"\.(?:Post)?FormValue\(", "form field"),
+        (r"\.PathValue\(|mux\.Vars\(|\bc\.Param\(", "URL path parameter"), (r"\bc\.Query\(|\bc\.PostForm\(", "request parameter"),
+        (r"\.Header\.Get\(", "request header"), (r"\br\.Body\b|\breq\.Body\b", "request body"),
+        (r"\.Cookie\(", "cookie"), (r"os\.Args", "command-line argument"), (r"os\.Getenv\(", "environment variable"),
+    ],
+    "python": [
+        (r"request\.args", "URL query string"), (r"request\.form", "form field"), (r"request\.(?:get_)?json", "JSON body"),
+        (r"request\.values", "request parameter"), (r"request\.cookies", "cookie"), (r"request\.headers", "request header"),
+        (r"request\.(?:files|data)", "request body"), (r"sys\.argv", "command-line argument"), (r"\binput\(", "user input"),
+        (r"os\.environ", "environment variable"),
+    ],
+    "javascript": [
+        (r"req\.query", "URL query string"), (r"req\.body", "request body"), (r"req\.params", "URL path parameter"),
+        (r"req\.cookies", "cookie"), (r"req\.headers", "request header"), (r"process\.argv", "command-line argument"),
+    ],
+}
+FUNCTION_PATTERNS = {
+    "go": r"^func\s",
+    "python": r"^\s*(?:async\s+)?def\s",
+    "javascript": r"function\b|=>\s*\{",
+}
+LANGUAGES = {".go": "go", ".py": "python", ".js": "javascript", ".ts": "javascript", ".mjs": "javascript"}
+KEYWORDS = {
+    "if", "else", "for", "range", "return", "func", "def", "var", "let", "const", "err", "nil", "None", "True", "False",
+    "true", "false", "null", "await", "async", "in", "not", "and", "or", "string", "int", "byte", "new", "this", "self",
+}
+MAX_FUNCTION_LINES = 120
+
+
+class ExplanationGenerator:
+    """
+    ExplanationGenerator turns a finding into a developer-facing explanation:
+    what the attacker controls, how data flows from source to sink in this code,
+    the realistic impact and how the fix blocks it.
+
+    - Taint path: intra-function backward slice from the sink line to a request source,
+      prefixed by the reachability call path and the route's parameters.
+    - Template: CWE knowledge base (src/data/cwe_knowledge.json), no LLM needed.
+    - LLM (optional): rewrites the template facts for this specific code; falls back to the template.
+    Explanations are cached per fingerprint by the caller (db.explanations).
+    """
+    def __init__(self, knowledge_path: str = KNOWLEDGE_PATH):
+        self.knowledge_path = knowledge_path
+        self._knowledge = None
+
+    @property
+    def knowledge(self) -> Dict[str, Dict[str, str]]:
+        if self._knowledge is None:
+            with open(self.knowledge_path, "r", encoding="utf-8") as f:
+                self._knowledge = json.load(f)["cwes"]
+        return self._knowledge
+
+    def explain(
+        self,
+        finding: Dict[str, Any],
+        root_dir: Optional[str] = None,
+        route: Optional[Dict[str, Any]] = None,
+        fix: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        """Templated (LLM-free) explanation. `fix` is an accepted/unverified fix verification for the finding's file."""
+        entry = self.knowledge.get(finding.get("cwe") or "") or self._generic(finding)
+        steps = self.taint_path(finding, root_dir)
+        sources = [s for s in steps if s["kind"] == "source"]
+
+        if sources:
+            attacker_controls = f"The attacker controls the {sources[0]['note']} read at line {sources[0]['line']}: {entry['attacker_controls']}."
+        elif route and route.get("params"):
+            params = ", ".join(route["params"])
+            attacker_controls = (f"The attacker controls the parameters of {route['method']} {route['path']} ({params}); "
+                                 f"the vulnerable input is {entry['attacker_controls']}.")
+        else:
+            attacker_controls = f"The attacker controls {entry['attacker_controls']}."
+        if route:
+            attacker_controls += " The route requires authentication." if route.get("auth") else " The route is reachable without authentication."
+
+        flow = self._flow_narrative(finding, steps, entry)
+        fix_text = entry["fix"]
+        if fix and fix.get("diff"):
+            added = [l[1:].strip() for l in fix["diff"].split("\n") if l.startswith("+") and not l.startswith("+++") and l[1:].strip()]
+            if added:
+                fix_text += f" The verified fix ({fix['verdict']}) changes the sink to `{added[0][:160]}`, so the input no longer reaches {entry['sink']} unchecked."
+
+        explanation = {
+            "fingerprint": finding.get("fingerprint"),
+            "cwe": finding.get("cwe"),
+            "title": f"{entry['name']} in {self._location(finding)}",
+            "attacker_controls": attacker_controls,
+            "data_flow": flow,
+            "taint_path": steps,
+            "impact": entry["impact"] + self._impact_context(finding),
+            "fix": fix_text,
+            "generator": "template",
+            "generated_at": datetime.utcnow(),
+        }
+        explanation["markdown"] = self.to_markdown(explanation)
+        return explanation
+
+    async def explain_with_llm(self, explanation: Dict[str, Any], finding: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Optional LLM pass: rewrites the templated facts for this specific code.
+        The taint path is kept as computed; failures return the template unchanged.
+        """
+        try:
+            from langchain_openai import ChatOpenAI
+            llm = ChatOpenAI(model="gpt-4o-mini", temperature=0)
+            steps = "\n".join(f"- [{s['kind']}] line {s['line']}: {s['code']}" for s in explanation["taint_path"])
+            prompt = (
+                "You explain security findings to the developer who owns the code. Be concrete about THIS code, "
+                "no generic advice. Respond ONLY with a JSON object with keys attacker_controls, data_flow, impact, fix "
+                "(2-4 sentences each).\n\n"
+                f"Finding: {finding.get('alert')} ({finding.get('cwe')}) at {self._location(finding)}\n"
+                f"Code:\n{(finding.get('code') or '')[:1500]}\n\nTaint path:\n{steps or '(not traced)'}\n\n"
+                f"Template facts:\n{json.dumps({k: explanation[k] for k in ('attacker_controls', 'data_flow', 'impact', 'fix')})}"
+            )
+            response = await llm.ainvoke(prompt)
+            match = re.search(r"\{[\s\S]*\}", response.content)
+            generated = json.loads(match.group(0)) if match else {}
+            if not all(isinstance(generated.get(k), str) and generated[k].strip() for k in ("attacker_controls", "data_flow", "impact", "fix")):
+                raise ValueError("incomplete LLM response")
+            explanation = {**explanation, **{k: generated[k].strip() for k in ("attacker_controls", "data_flow", "impact", "fix")}}
+            explanation["generator"] = "llm"
+            explanation["markdown"] = self.to_markdown(explanation)
+        except Exception as e:
+            print(f"⚠️ [Explain] LLM explanation skipped, using template: {e}")
+        return explanation
+
+    # --- Taint path ---
+    def taint_path(self, finding: Dict[str, Any], root_dir: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Steps from entrypoint to sink: [{"kind": "entrypoint" | "source" | "propagation" | "sink", "line", "code", "note"}].
+        Uses the workspace file when available, otherwise the finding's code snippet.
+        """
+        file = finding.get("file") or ""
+        language = LANGUAGES.get(os.path.splitext(file)[1].lower())
+        lines, sink_index, first_line = self.code_lines(finding, root_dir)
+        steps = [{"kind": "entrypoint", "line": None, "code": "", "note": " -> ".join(finding["call_path"])}] \
+            if finding.get("call_path") else []
+        if not lines or sink_index is None:
+            return steps
+
+        sink_line = lines[sink_index]
+        sink = {"kind": "sink", "line": first_line + sink_index, "code": sink_line.strip(), "note": "vulnerable call"}
+        if not language:
+            return steps + [sink]
+
+        start = sink_index
+        while start > 0 and sink_index - start < MAX_FUNCTION_LINES and not re.search(FUNCTION_PATTERNS[language], lines[start]):
+            start -= 1
+
+        source = self._source_in(sink_line, language)
+        if source:
+            sink["note"] = f"vulnerable call, reads the {source} directly"
+            return steps + [{**sink, "kind": "source", "note": source}, sink]
+
+        tracked = self._identifiers(sink_line)
+        chain = []
+        for i in range(sink_index - 1, start - 1, -1):
+            assignment = re.match(r"\s*(?:var\s+|let\s+|const\s+)?([\w\s,.]+?)\s*(?::=|=|\+=)\s*(.+)$", lines[i])
+            if not assignment or assignment.group(2).startswith("="):
+                continue
+            targets = {t.strip().split(".")[-1] for t in assignment.group(1).split(",")}
+            if not targets & tracked:
+                continue
+            rhs = assignment.group(2)
+            source = self._source_in(rhs, language)
+            step = {"line": first_line + i, "code": lines[i].strip()}
+            if source:
+                chain.append({**step, "kind": "source", "note": source})
+                break
+            chain.append({**step, "kind": "propagation", "note": f"{', '.join(sorted(targets & tracked))} derived from input"})
+            tracked |= self._identifiers(rhs)
+
+        return steps + list(reversed(chain)) + [sink]
+
+    def code_lines(self, finding: Dict[str, Any], root_dir: Optional[str]):
+        """(lines, index of the sink line, line number of lines[0]) from the workspace file, else from the snippet."""
+        file, line = finding.get("file"), finding.get("line")
+        if root_dir and file and line:
+            path = os.path.realpath(os.path.join(root_dir, file))
+            if path.startswith(os.path.realpath(root_dir) + os.sep) and os.path.isfile(path):
+                with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                    lines = f.read().split("\n")
+                if 0 < line <= len(lines):
+                    return lines, line - 1, 1
+        code = finding.get("code") or ""
+        if not code:
+            return [], None, 1
+        lines = code.split("\n")
+        # Scanner snippets carry 2 lines of context before the flagged line (fewer at the top of a file)
+        sink_index = min(2, (line or 1) - 1, len(lines) - 1)
+        return lines, sink_index, (line or 1) - sink_index
+
+    def _source_in(self, code: str, language: str) -> Optional[str]:
+        return next((label for pattern, label in SOURCE_PATTERNS[language] if re.search(pattern, code)), None)
+
+    def _identifiers(self, code: str) -> set:
+        code = re.sub(r"\"(?:[^\"\\]|\\.)*\"|'(?:[^'\\]|\\.)*'|`[^`]*`", "", code)
+        return {i for i in re.findall(r"\b[A-Za-z_]\w*\b", code) if i not in KEYWORDS}
+
+    # --- Text ---
+    def _flow_narrative(self, finding, steps, entry) -> str:
+        parts = []
+        entry_step = next((s for s in steps if s["kind"] == "entrypoint"), None)
+        if entry_step:
+            parts.append(f"The code is reached from an entrypoint via {entry_step['note']}.")
+        elif finding.get("reachable") is False:
+            parts.append("Reachability analysis found no entrypoint that calls this code, which lowers the practical risk.")
+        flow = [s for s in steps if s["kind"] in ("source", "propagation")]
+        if flow:
+            chain = " -> ".join(f"line {s['line']} (`{s['code'][:80]}`)" for s in flow)
+            parts.append(f"Untrusted data enters and flows through {chain}")
+        sink = next((s for s in steps if s["kind"] == "sink"), None)
+        if sink:
+            parts.append(f"and reaches {entry['sink']} at line {sink['line']} (`{sink['code'][:120]}`) without validation."
+                         if flow else f"It reaches {entry['sink']} at line {sink['line']} (`{sink['code'][:120]}`).")
+        if not flow and sink:
+            parts.append("The source of the data could not be traced inside this function; check the callers' arguments.")
+        return " ".join(parts) or f"Input reaches {entry['sink']}."
+
+    def _impact_context(self, finding: Dict[str, Any]) -> str:
+        extra = []
+        if (finding.get("poc") or {}).get("status") == "confirmed" or (finding.get("fuzz") or {}).get("status") == "confirmed":
+            extra.append("This was confirmed exploitable in a sandbox.")
+        cvss = finding.get("cvss") or {}
+        if cvss.get("base_score") is not None:
+            extra.append(f"CVSS {cvss['base_score']} ({cvss.get('base_severity')}).")
+        return (" " + " ".join(extra)) if extra else ""
+
+    def _generic(self, finding: Dict[str, Any]) -> Dict[str, str]:
+        return {
+            "name": finding.get("alert") or "Security issue",
+            "attacker_controls": "input that reaches the flagged code",
+            "sink": "the flagged operation",
+            "impact": (finding.get("description") or "The flagged pattern may let an attacker affect the application's behaviour."),
+            "fix": finding.get("solution") or "Validate or encode the input before it reaches the flagged operation, or use a safe API.",
+        }
+
+    def _location(self, finding: Dict[str, Any]) -> str:
+        return f"{finding['file']}:{finding.get('line')}" if finding.get("file") else (finding.get("url") or "the target")
+
+    def to_markdown(self, explanation: Dict[str, Any]) -> str:
+        lines = [
+            f"### {explanation['title']}",
+            "",
+            f"**What the attacker controls.** {explanation['attacker_controls']}",
+            "",
+            f"**How the data flows.** {explanation['data_flow']}",
+        ]
+        path = [s for s in explanation["taint_path"] if s["kind"] != "entrypoint"]
+        if path:
+            lines += ["", "| Step | Line | Code |", "|---|---|---|"]
+            for step in path:
+                code = step["code"][:100].replace("|", "\\|")
+                lines.append(f"| {step['kind']} | {step['line']} | `{code}` |")
+        lines += [
+            "",
+            f"**Impact.** {explanation['impact']}",
+            "",
+            f"**How the fix blocks it.** {explanation['fix']}",
+        ]
+        return "\n".join(lines) + "\n"
+
+
+explanation_generator = ExplanationGenerator()
@@ -0,0 +1,65 @@
+import hashlib
+import re
+from contextvars import ContextVar
+from typing import List, Dict, Any, Optional
+
+
+# Scan currently being processed by the background worker. Agent tools read it to
+# attach their results (findings) to the right scan record.
+current_scan_id: ContextVar[Optional[str]] = ContextVar("current_scan_id", default=None)
+
+# Default CWE for findings whose rule does not carry one (RepoScanner labels, ZAP alert names)
+LABEL_TO_CWE = {
+    "Hardcoded Secret": "CWE-798",
+    "SQL Injection": "CWE-89",
+    "Unsafe Deserialization": "CWE-502",
+    "Debug Mode Enabled": "CWE-489",
+    "TODO Comment": "CWE-546",
+    "Cross Site Scripting": "CWE-79",
+    "Path Traversal": "CWE-22",
+    "Remote OS Command Injection": "CWE-78",
+    "Code Injection": "CWE-94",
+}
+
+
+def guess_cwe(alert: Dict[str, Any]) -> Optional[str]:
+    """CWE from the alert itself (rule packs, ZAP `cweid`) or from its label."""
+    if alert.get("cwe"):
+        return alert["cwe"]
+    cwe_id = str(alert.get("cweid", "")).strip()
+    if cwe_id and cwe_id not in ("-1", "0"):
+        return f"CWE-{cwe_id}"
+    label = alert.get("alert", "")
+    for known, cwe in LABEL_TO_CWE.items():
+        if known.lower() in label.lower():
+            return cwe
+    return None
+
+
+def fingerprint(alert: Dict[str, Any]) -> str:
+    """
+    Stable identity of a finding across scans.
+    Uses the rule, location (file or URL + param) and the whitespace-normalized code,
+    but NOT the line number, so unrelated edits above the finding keep the fingerprint.
+    """
+    location = alert.get("file") or alert.get("url", "")
+    code = re.sub(r"\s+", " ", alert.get("code") or alert.get("param", "")).strip()
+    raw = "|".join([alert.get("rule_id") or alert.get("alert", ""), location, code])
+    return hashlib.sha1(raw.encode("utf-8")).hexdigest()[:16]
+
+
+def normalize_findings(alerts: List[Dict[str, Any]], source: str) -> List[Dict[str, Any]]:
+    """
+    Converts raw scanner alerts into stored findings: adds source, cwe and fingerprint.
+    Scanner errors (e.g. 'Scan Error', ZAP connection failures) are not findings.
+    """
+    findings = []
+    for alert in alerts:
+        if alert.get("alert") in ("Scan Error", "ZAP Scanner Connection Failed"):
+            continue
+        finding = dict(alert)
+        finding["source"] = finding.get("source") or source
+        finding["cwe"] = guess_cwe(finding)
+        finding["fingerprint"] = fingerprint(finding)
+        findings.append(finding)
+    return findings
@@ -0,0 +1,293 @@
+import difflib
+import json
+import os
+import re
+import threading
+from collections import OrderedDict
+from typing import List, Dict, Any, Optional
+from src.config import settings
+from src.services.sandbox import sandbox
+
+# Baseline runs kept in memory (least recently used are dropped)
+BASELINE_CACHE_SIZE = 32
+
+
+class FixVerifier:
+    """
+    FixVerifier checks that a repair suggestion does not break the project before it is
+    shown to anyone (report, PR comment, training data).
+
+    1. Detect the project's own test command(s): `go test ./...`, `pytest`, `npm test`.
+    2. Baseline: run them on an untouched sandbox copy of the workspace.
+    3. Apply the fix to a second sandbox copy and run them again.
+    4. Compare: build breakage and tests that passed before but fail (or vanish) after
+       reject the fix. Tests that start passing are reported as a bonus.
+
+    Verdicts: accepted / rejected / unverified (no tests, or the suite cannot run here).
+    """
+    def __init__(self):
+        # Baseline runs are expensive; reuse them per (workspace, commit), LRU-bounded.
+        self._baselines: "OrderedDict[tuple, List[Dict[str, Any]]]" = OrderedDict()
+        self._baselines_lock = threading.Lock()
+
+    def detect_test_commands(self, root_dir: str) -> List[Dict[str, Any]]:
+        """
+        Returns [{"framework": "go" | "pytest" | "npm", "command": List[str], "cwd": str (relative)}]
+        """
+        commands = []
+        if os.path.exists(os.path.join(root_dir, "go.mod")):
+            commands.append({"framework": "go", "command": ["go", "test", "-json", "./..."], "cwd": "."})
+
+        package_json = os.path.join(root_dir, "package.json")
+        if os.path.exists(package_json):
+            try:
+                with open(package_json, "r", encoding="utf-8") as f:
+                    test_script = json.load(f).get("scripts", {}).get("test", "")
+            except (OSError, ValueError):
+                test_script = ""
+            # npm init's placeholder always fails; it is not a test suite.
+            if test_script and "no test specified" not in test_script:
+                commands.append({"framework": "npm", "command": ["npm", "test", "--silent"], "cwd": "."})
+
+        if self._has_pytest_suite(root_dir):
+            commands.append({
+                "framework": "pytest",
+                "command": ["python", "-m", "pytest", "-q", "-rA", "-p", "no:cacheprovider"],
+                "cwd": "."
+            })
+        return commands
+
+    def verify(
+        self,
+        root_dir: str,
+        file: str,
+        original_code: str,
+        fixed_code: str
+    ) -> Dict[str, Any]:
+        """
+        Applies `fixed_code` in place of `original_code` in `file` (relative to root_dir)
+        and compares the project's test results before and after.
+
+        Returns:
+            {
+                "verdict": "accepted" | "rejected" | "unverified",
+                "reason": str,
+                "file": str,
+                "diff": str,
+                "baseline": [run summary], "after_fix": [run summary],
+                "delta": {"newly_failing": [...], "newly_passing": [...], "missing": [...], "build_broken": bool}
+            }
+        """
+        result = {
+            "verdict": "unverified", "reason": "", "file": file, "diff": "",
+            "original_code": original_code, "fixed_code": fixed_code,
+            "baseline": [], "after_fix": [],
+            "delta": {"newly_failing": [], "newly_passing": [], "missing": [], "build_broken": False}
+        }
+
+        try:
+            original_content, patched_content = self.apply_fix(root_dir, file, original_code, fixed_code)
+        except ValueError as e:
+            result["verdict"], result["reason"] = "rejected", str(e)
+            return result
+        result["diff"] = "".join(difflib.unified_diff(
+            original_content.splitlines(keepends=True), patched_content.splitlines(keepends=True),
+            fromfile=f"a/{file}", tofile=f"b/{file}"
+        ))
+
+        commands = self.detect_test_commands(root_dir)
+        if not commands:
+            result["reason"] = "No test command detected (go.mod, package.json test script or pytest suite)."
+            return result
+
+        baseline = self._baseline(root_dir, commands)
+        after_fix = self._run_suite(root_dir, commands, patch=(file, patched_content))
+        result["baseline"] = [self._summary(r) for r in baseline]
+        result["after_fix"] = [self._summary(r) for r in after_fix]
+
+        delta = result["delta"]
+        for before, after in zip(baseline, after_fix):
+            delta["newly_failing"] += sorted(after["failed"] - before["failed"])
+            delta["newly_passing"] += sorted(before["failed"] & after["passed"])
+            delta["missing"] += sorted(before["passed"] - after["passed"] - after["failed"])
+            if before["build_ok"] and not after["build_ok"]:
+                delta["build_broken"] = True
+
+        if delta["build_broken"]:
+            result["verdict"], result["reason"] = "rejected", "The fix breaks the build."
+        elif delta["newly_failing"] or delta["missing"]:
+            result["verdict"] = "rejected"
+            result["reason"] = f"{len(delta['newly_failing'])} test(s) fail and {len(delta['missing'])} no longer run after the fix."
+        elif not any(r["build_ok"] and not r["timed_out"] for r in baseline):
+            result["reason"] = "The test suite does not run in the sandbox even without the fix (missing dependencies?)."
+        else:
+            result["verdict"] = "accepted"
+            result["reason"] = "No regressions: all tests that passed before still pass."
+
+        print(f"🧪 [FixVerify] {file}: {result['verdict']} ({result['reason']})")
+        return result
+
+    def redact_rejected(self, report: str, verifications: List[Dict[str, Any]]) -> str:
+        """Removes code blocks containing rejected fixes from a Markdown report."""
+        rejected = [v for v in verifications if v.get("verdict") == "rejected" and v.get("fixed_code", "").strip()]
+        if not rejected:
+            return report
+
+        def replace(match):
+            block = self._normalize(match.group(0))
+            for verification in rejected:
+                if self._normalize(verification["fixed_code"]) in block:
+                    return (f"> ⚠️ Suggested fix for `{verification['file']}` was withheld: "
+                            f"it failed verification ({verification['reason']})")
+            return match.group(0)
+
+        return re.sub(r"```[\w+-]*\n[\s\S]*?\n```", replace, report)
+
+    # --- Patching ---
+    def apply_fix(self, root_dir: str, file: str, original_code: str, fixed_code: str):
+        """Returns (current content, content with the fix applied). Raises ValueError if the fix does not apply."""
+        path = os.path.realpath(os.path.join(root_dir, file))
+        if not path.startswith(os.path.realpath(root_dir) + os.sep) or not os.path.isfile(path):
+            raise ValueError(f"File '{file}' does not exist in the workspace.")
+        with open(path, "r", encoding="utf-8", errors="ignore") as f:
+            content = f.read()
+
+        # An empty snippet would replace the whole file and make the test verdict meaningless
+        if not (original_code or "").strip():
+            raise ValueError("original code is empty")
+        if original_code in content:
+            return content, content.replace(original_code, fixed_code, 1)
+
+        # Snippets quoted by the agent often differ in indentation only: match line by line, stripped.
+        lines = content.split("\n")
+        wanted = [l.strip() for l in original_code.strip("\n").split("\n")]
+        for i in range(len(lines) - len(wanted) + 1):
+            if [l.strip() for l in lines[i:i + len(wanted)]] == wanted:
+                indent = lines[i][:len(lines[i]) - len(lines[i].lstrip())]
+                fixed_lines = [indent + l if l.strip() else l for l in self._dedent(fixed_code).split("\n")]
+                return content, "\n".join(lines[:i] + fixed_lines + lines[i + len(wanted):])
+        raise ValueError(f"The fix does not apply: original code not found in '{file}'.")
+
+    def _dedent(self, code: str) -> str:
+        lines = code.strip("\n").split("\n")
+        indents = [len(l) - len(l.lstrip()) for l in lines if l.strip()]
+        cut = min(indents) if indents else 0
+        return "\n".join(l[cut:] for l in lines)
+
+    def _normalize(self, code: str) -> str:
+        return re.sub(r"\s+", " ", code).strip()
+
+    # --- Running ---
+    def _baseline(self, root_dir: str, commands) -> List[Dict[str, Any]]:
+        key = (root_dir, self._commit_of(root_dir))
+        with self._baselines_lock:
+            if key[1] and key in self._baselines:
+                self._baselines.move_to_end(key)
+                return self._baselines[key]
+        baseline = self._run_suite(root_dir, commands)
+        if key[1]:
+            with self._baselines_lock:
+                self._baselines[key] = baseline
+                while len(self._baselines) > BASELINE_CACHE_SIZE:
+                    self._baselines.popitem(last=False)
+        return baseline
+
+    def _commit_of(self, root_dir: str) -> Optional[str]:
+        try:
+            from git import Repo
+            return Repo(root_dir).head.commit.hexsha
+        except Exception:
+            return None
+
+    def _run_suite(self, root_dir: str, commands, patch: Optional[tuple] = None) -> List[Dict[str, Any]]:
+        workspace = sandbox.create_workspace(root_dir)
+        try:
+            sandbox.link_dependencies(root_dir, workspace)
+            if patch:
+                with open(os.path.join(workspace, patch[0]), "w", encoding="utf-8") as f:
+                    f.write(patch[1])
+
+            results = []
+            for spec in commands:
+                run = sandbox.run(spec["command"], cwd=os.path.join(workspace, spec["cwd"]),
+                                  timeout=settings.TEST_TIMEOUT_SECONDS)
+                results.append({**self._parse(spec["framework"], run), "framework": spec["framework"],
+                                "command": run["command"], "exit_code": run["exit_code"], "timed_out": run["timed_out"],
+                                "output": (run["stdout"] + run["stderr"])[-2000:]})
+            return results
+        finally:
+            sandbox.cleanup(workspace)
+
+    def _parse(self, framework: str, run: Dict[str, Any]) -> Dict[str, Any]:
+        """Per-test outcomes: {"passed": set, "failed": set, "build_ok": bool}"""
+        output = run["stdout"] + "\n" + run["stderr"]
+        passed, failed = set(), set()
+        build_ok = run["exit_code"] is not None
+
+        if framework == "go":
+            for line in run["stdout"].splitlines():
+                try:
+                    event = json.loads(line)
+                except ValueError:
+                    continue
+                name = f"{event.get('Package')}::{event['Test']}" if event.get("Test") else None
+                if event.get("Action") == "pass" and name:
+                    passed.add(name)
+                elif event.get("Action") == "fail" and name:
+                    failed.add(name)
+                elif event.get("FailedBuild"):
+                    build_ok = False
+            if "[build failed]" in output or "[setup failed]" in output:
+                build_ok = False
+
+        elif framework == "pytest":
+            for status, name in re.findall(r"^(PASSED|FAILED|ERROR)\s+(\S+)", output, re.MULTILINE):
+                (passed if status == "PASSED" else failed).add(name)
+            # Collection errors (SyntaxError, ImportError) mean the code no longer loads.
+            if run["exit_code"] in (2, 3, 4) or "ERROR collecting" in output:
+                build_ok = False
+
+        else:
+            # npm test: runner-agnostic. Jest/Mocha style lines for names, exit code for the verdict.
+            passed |= {m.strip() for m in re.findall(r"^\s*(?:✓|√|PASS)\s+(.+)$", output, re.MULTILINE)}
+            failed |= {m.strip() for m in re.findall(r"^\s*(?:✕|×|FAIL)\s+(.+)$", output, re.MULTILINE)}
+            if run["exit_code"] not in (0, None) and not failed:
+                failed.add("npm test")
+            elif run["exit_code"] == 0:
+                passed.add("npm test")
+            if re.search(r"SyntaxError|Cannot find module", output):
+                build_ok = False
+
+        return {"passed": passed, "failed": failed, "build_ok": build_ok and not run["timed_out"]}
+
+    def _summary(self, result: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            "framework": result["framework"],
+            "command": result["command"],
+            "exit_code": result["exit_code"],
+            "timed_out": result["timed_out"],
+            "build_ok": result["build_ok"],
+            "passed": len(result["passed"]),
+            "failed": len(result["failed"]),
+            "failed_tests": sorted(result["failed"])[:50],
+            "output": result["output"] if result["failed"] or not result["build_ok"] else ""
+        }
+
+    def _has_pytest_suite(self, root_dir: str) -> bool:
+        for marker in ("pytest.ini", "conftest.py"):
+            if os.path.exists(os.path.join(root_dir, marker)):
+                return True
+        for config in ("pyproject.toml", "setup.cfg", "tox.ini"):
+            path = os.path.join(root_dir, config)
+            if os.path.exists(path):
+                with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                    if "pytest" in f.read():
+                        return True
+        for current_root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in (".git", "node_modules", "venv", ".venv", "__pycache__")]
+            if any(re.match(r"test_.*\.py$|.*_test\.py$", f) for f in files):
+                return True
+        return False
+
+
+fix_verifier = FixVerifier()
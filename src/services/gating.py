@@ -0,0 +1,65 @@
+from typing import List, Dict, Any, Optional
+from src.services.scan_report import SEVERITY_ORDER, severity_of
+
+
+DEFAULT_GATE_POLICY = {
+    "fail_on": "High",            # lowest severity that blocks (Critical | High | Medium | Low)
+    "require_reachable": False,   # only block findings not proven unreachable
+}
+
+
+class GatePolicy:
+    """
+    GatePolicy decides whether a scan or PR passes the organization's security gate and
+    returns the result in the shape of a GitHub Checks run (`conclusion` + `output`).
+
+    A finding blocks when its severity is at or above `fail_on`, unless an approved, unexpired
+    risk exception covers it (`status: risk_accepted`); those are listed as non-blocking.
+    """
+    def evaluate(self, findings: List[Dict[str, Any]], policy: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        policy = {**DEFAULT_GATE_POLICY, **(policy or {})}
+        threshold = SEVERITY_ORDER.index(policy["fail_on"])
+
+        blocking, excepted, below = [], [], 0
+        for finding in findings:
+            severity = severity_of(finding)
+            if SEVERITY_ORDER.index(severity) > threshold:
+                below += 1
+            elif policy["require_reachable"] and finding.get("reachable") is False:
+                below += 1
+            elif finding.get("status") == "risk_accepted":
+                excepted.append(self._summary(finding, severity))
+            else:
+                blocking.append(self._summary(finding, severity))
+
+        conclusion = "failure" if blocking else "success"
+        title = (f"{len(blocking)} blocking finding(s) at or above {policy['fail_on']}" if blocking
+                 else f"No blocking findings at or above {policy['fail_on']}")
+        lines = [f"- **{f['severity']}** {f['title']} `{f['location']}`" for f in blocking[:50]]
+        if excepted:
+            lines += ["", f"{len(excepted)} finding(s) are non-blocking under an approved risk exception:"]
+            lines += [f"- {f['title']} `{f['location']}` (until {f['exception_expires']})" for f in excepted[:50]]
+        return {
+            "conclusion": conclusion,
+            "policy": policy,
+            "blocking": blocking,
+            "excepted": excepted,
+            "below_threshold": below,
+            "output": {"title": title, "summary": "\n".join(lines) or title},
+        }
+
+    def _summary(self, finding: Dict[str, Any], severity: str) -> Dict[str, Any]:
+        file = finding.get("file") or finding.get("filename")
+        exception = finding.get("exception") or {}
+        expires = exception.get("expires_at")
+        return {
+            "fingerprint": finding.get("fingerprint"),
+            "title": finding.get("alert", "Finding"),
+            "severity": severity,
+            "location": f"{file}:{finding.get('line')}" if file else finding.get("url", ""),
+            "exception_id": exception.get("exception_id") if finding.get("status") == "risk_accepted" else None,
+            "exception_expires": expires.date().isoformat() if hasattr(expires, "date") else expires,
+        }
+
+
+gate_policy = GatePolicy()
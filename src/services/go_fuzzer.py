@@ -0,0 +1,324 @@
+import os
+import re
+import shutil
+from typing import List, Dict, Any, Optional
+from src.config import settings
+from src.services.sandbox import sandbox
+
+
+# Markers whose presence in a handler response means the fuzz input reached a sensitive sink.
+SINK_MARKERS = [
+    "root:x:0:0",          # /etc/passwd (Path Traversal / LFI)
+    "[boot loader]",       # win.ini (Path Traversal on Windows hosts)
+]
+
+# Reflected unescaped in the response body = XSS sink.
+REFLECTION_PROBE = "<script>redeye"
+
+# Seed corpus: known-bad payloads for common sink classes. The fuzzer mutates from here.
+SEED_INPUTS = [
+    "index.html",
+    "../../../../../../etc/passwd",
+    "..%2f..%2f..%2f..%2fetc%2fpasswd",
+    "/etc/passwd",
+    "<script>redeye</script>",
+    "' OR 1=1 --",
+    "",
+]
+
+HANDLER_SIGNATURE = re.compile(
+    r"^func\s+(?P<name>[A-Za-z_]\w*)\s*\(\s*(?P<w>\w+)\s+http\.ResponseWriter\s*,\s*(?P<r>\w+)\s+\*http\.Request\s*\)",
+    re.MULTILINE
+)
+STRING_FUNC_SIGNATURE = re.compile(
+    r"^func\s+(?P<name>[A-Za-z_]\w*)\s*\(\s*\w+\s+(?P<type>string|\[\]byte)\s*\)",
+    re.MULTILINE
+)
+PACKAGE_CLAUSE = re.compile(r"^package\s+(\w+)", re.MULTILINE)
+
+
+class GoFuzzer:
+    """
+    GoFuzzer generates native Go fuzz tests (`func FuzzX(f *testing.F)`) for flagged
+    functions and runs them with `go test -fuzz` inside the Sandbox.
+
+    Supported targets:
+    1. HTTP handlers `func(w http.ResponseWriter, r *http.Request)` - driven via httptest,
+       with fuzz input injected into every query/form parameter the handler reads.
+    2. Plain functions taking a single `string` or `[]byte` argument.
+
+    A panic (crash) or a sink marker in the handler response is recorded as
+    dynamic confirmation of the static finding.
+    """
+
+    def is_available(self) -> bool:
+        return shutil.which("go") is not None
+
+    def find_targets(self, content: str) -> List[Dict[str, Any]]:
+        """Lists fuzzable functions in a Go source file."""
+        targets = []
+        for match in HANDLER_SIGNATURE.finditer(content):
+            targets.append({
+                "name": match.group("name"),
+                "kind": "handler",
+                "line": content[:match.start()].count("\n") + 1
+            })
+        for match in STRING_FUNC_SIGNATURE.finditer(content):
+            targets.append({
+                "name": match.group("name"),
+                "kind": match.group("type"),
+                "line": content[:match.start()].count("\n") + 1
+            })
+        return targets
+
+    def generate_fuzz_test(self, content: str, function_name: str) -> Dict[str, Any]:
+        """
+        Generates the Go fuzz test source for `function_name`.
+
+        Returns:
+            {
+                "package": str,
+                "test_name": str,
+                "kind": "handler" | "string" | "[]byte",
+                "params": List[str],
+                "code": str
+            }
+        """
+        target = next((t for t in self.find_targets(content) if t["name"] == function_name), None)
+        if not target:
+            raise ValueError(f"'{function_name}' is not a fuzzable Go function (handler or single string/[]byte argument).")
+
+        package_match = PACKAGE_CLAUSE.search(content)
+        package = package_match.group(1) if package_match else "main"
+        test_name = "Fuzz" + function_name[0].upper() + function_name[1:]
+
+        if target["kind"] == "handler":
+            params = self.extract_request_params(content, function_name)
+            code = self._handler_template(package, test_name, function_name, params)
+        else:
+            params = []
+            code = self._function_template(package, test_name, function_name, target["kind"])
+
+        return {
+            "package": package,
+            "test_name": test_name,
+            "kind": target["kind"],
+            "params": params,
+            "code": code
+        }
+
+    def fuzz(
+        self,
+        content: str,
+        function_name: str,
+        source_dir: Optional[str] = None,
+        filename: str = "target.go",
+        fuzz_time: Optional[int] = None
+    ) -> Dict[str, Any]:
+        """
+        Generates and runs a fuzz test for `function_name`.
+
+        Args:
+            content: Source of the Go file containing the function.
+            function_name: Function to fuzz.
+            source_dir: Package directory in a cloned workspace. If omitted, `content`
+                        is fuzzed as a standalone single-file package.
+            filename: File name used for `content` in standalone mode.
+            fuzz_time: Fuzzing budget in seconds (default: GO_FUZZ_TIME_SECONDS).
+
+        Returns:
+            {
+                "status": "confirmed" | "not_confirmed" | "inconclusive" | "skipped",
+                "evidence": str,
+                "test_name": str,
+                "test_code": str,
+                "output": str
+            }
+        """
+        fuzz_time = min(fuzz_time or settings.GO_FUZZ_TIME_SECONDS, settings.GO_FUZZ_MAX_SECONDS)
+
+        try:
+            generated = self.generate_fuzz_test(content, function_name)
+        except ValueError as e:
+            return {"status": "skipped", "evidence": str(e), "test_name": None, "test_code": "", "output": ""}
+
+        if not self.is_available():
+            return {
+                "status": "skipped",
+                "evidence": "Go toolchain not found on this host. Generated test is attached for manual runs.",
+                "test_name": generated["test_name"],
+                "test_code": generated["code"],
+                "output": ""
+            }
+
+        workspace, package_dir = self._prepare_workspace(content, filename, source_dir)
+        try:
+            with open(os.path.join(package_dir, "redeye_fuzz_test.go"), "w", encoding="utf-8") as f:
+                f.write(generated["code"])
+
+            run = sandbox.run(
+                ["go", "test", "-run=^$", f"-fuzz=^{generated['test_name']}$", f"-fuzztime={fuzz_time}s", "."],
+                cwd=package_dir,
+                timeout=fuzz_time + settings.SANDBOX_TIMEOUT_SECONDS
+            )
+            status, evidence = self._classify(run)
+            print(f"🐛 [GoFuzz] {generated['test_name']}: {status}")
+
+            return {
+                "status": status,
+                "evidence": evidence,
+                "test_name": generated["test_name"],
+                "test_code": generated["code"],
+                "output": (run["stdout"] + run["stderr"])[-4000:]
+            }
+        finally:
+            sandbox.cleanup(workspace)
+
+    def _prepare_workspace(self, content: str, filename: str, source_dir: Optional[str]):
+        """Copies the module (or a standalone file) into a sandbox workspace. Returns (workspace, package_dir)."""
+        if source_dir:
+            module_root = self._find_module_root(source_dir)
+            if module_root:
+                workspace = sandbox.create_workspace(module_root)
+                package_dir = os.path.join(workspace, os.path.relpath(source_dir, module_root))
+                return workspace, package_dir
+
+            # No go.mod: copy only the package's own (non-test) sources.
+            workspace = sandbox.create_workspace()
+            for name in os.listdir(source_dir):
+                if name.endswith(".go") and not name.endswith("_test.go"):
+                    shutil.copy(os.path.join(source_dir, name), workspace)
+        else:
+            workspace = sandbox.create_workspace()
+            with open(os.path.join(workspace, os.path.basename(filename)), "w", encoding="utf-8") as f:
+                f.write(content)
+
+        with open(os.path.join(workspace, "go.mod"), "w", encoding="utf-8") as f:
+            f.write("module redeye/fuzztarget\n\ngo 1.18\n")
+        return workspace, workspace
+
+    def _find_module_root(self, directory: str) -> Optional[str]:
+        current = os.path.abspath(directory)
+        while True:
+            if os.path.exists(os.path.join(current, "go.mod")):
+                return current
+            parent = os.path.dirname(current)
+            if parent == current:
+                return None
+            current = parent
+
+    def _classify(self, run: Dict[str, Any]):
+        output = run["stdout"] + run["stderr"]
+
+        if run["exit_code"] is None and not run["timed_out"]:
+            return "inconclusive", f"Fuzz test could not be started: {run['stderr']}"
+        if "REDEYE_SINK_HIT" in output:
+            line = next((l.strip() for l in output.splitlines() if "REDEYE_SINK_HIT" in l), "")
+            return "confirmed", f"Sink reached by fuzz input: {line}"
+        if "panic:" in output and "--- FAIL" in output:
+            line = next((l.strip() for l in output.splitlines() if l.strip().startswith("panic:")), "panic")
+            return "confirmed", f"Crash found by fuzzer: {line}"
+        if run["exit_code"] == 0 and not run["timed_out"]:
+            return "not_confirmed", "Fuzzing budget exhausted without crashes or sink hits."
+        if run["timed_out"]:
+            return "inconclusive", "Fuzzing did not finish within the sandbox time limit."
+        if "[build failed]" in output or "cannot find package" in output or "no required module" in output:
+            return "inconclusive", "Fuzz target failed to build in the sandbox (missing dependencies?)."
+        return "inconclusive", f"go test exited with code {run['exit_code']}."
+
+    def extract_request_params(self, content: str, function_name: str) -> List[str]:
+        """Collects query/form parameter names the handler reads."""
+        body = self._function_body(content, function_name)
+        params = re.findall(
+            r"(?:URL\.Query\(\)\.Get|FormValue|PostFormValue|Query\(\)\.Has)\(\s*\"([^\"]+)\"\s*\)", body
+        )
+        params += re.findall(r"URL\.Query\(\)\[\s*\"([^\"]+)\"\s*\]", body)
+        # Keep order, drop duplicates
+        unique = list(dict.fromkeys(params))
+        return unique or ["q", "id", "file", "name"]
+
+    def _function_body(self, content: str, function_name: str) -> str:
+        match = re.search(rf"^func\s+{re.escape(function_name)}\s*\(", content, re.MULTILINE)
+        if not match:
+            return ""
+        start = content.find("{", match.end())
+        depth = 0
+        for i in range(start, len(content)):
+            if content[i] == "{":
+                depth += 1
+            elif content[i] == "}":
+                depth -= 1
+                if depth == 0:
+                    return content[start:i + 1]
+        return content[start:]
+
+    def _go_string_list(self, values: List[str]) -> str:
+        return ", ".join('"' + v.replace("\\", "\\\\").replace('"', '\\"') + '"' for v in values)
+
+    def _handler_template(self, package: str, test_name: str, function_name: str, params: List[str]) -> str:
+        return f"""// Code generated by RedEye. DO NOT EDIT.
+
+package {package}
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func {test_name}(f *testing.F) {{
+	for _, seed := range []string{{{self._go_string_list(SEED_INPUTS)}}} {{
+		f.Add(seed)
+	}}
+
+	params := []string{{{self._go_string_list(params)}}}
+	markers := []string{{{self._go_string_list(SINK_MARKERS)}}}
+	probe := "{REFLECTION_PROBE}"
+
+	f.Fuzz(func(t *testing.T, input string) {{
+		values := url.Values{{}}
+		for _, p := range params {{
+			values.Set(p, input)
+		}}
+		req := httptest.NewRequest(http.MethodGet, "/?"+values.Encode(), nil)
+		rec := httptest.NewRecorder()
+
+		{function_name}(rec, req)
+
+		body := rec.Body.String()
+		for _, marker := range markers {{
+			if strings.Contains(body, marker) && !strings.Contains(input, marker) {{
+				t.Fatalf("REDEYE_SINK_HIT marker=%q input=%q", marker, input)
+			}}
+		}}
+		if strings.Contains(input, probe) && strings.Contains(body, probe) {{
+			t.Fatalf("REDEYE_SINK_HIT reflected=%q", input)
+		}}
+	}})
+}}
+"""
+
+    def _function_template(self, package: str, test_name: str, function_name: str, arg_type: str) -> str:
+        call = f"{function_name}(input)" if arg_type == "string" else f"{function_name}([]byte(input))"
+        return f"""// Code generated by RedEye. DO NOT EDIT.
+
+package {package}
+
+import "testing"
+
+func {test_name}(f *testing.F) {{
+	for _, seed := range []string{{{self._go_string_list(SEED_INPUTS)}}} {{
+		f.Add(seed)
+	}}
+
+	f.Fuzz(func(t *testing.T, input string) {{
+		// Any panic here is reported by the Go fuzzing engine as a crash.
+		{call}
+	}})
+}}
+"""
+
+
+go_fuzzer = GoFuzzer()
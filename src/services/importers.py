@@ -0,0 +1,370 @@
+import json
+import os
+import re
+import defusedxml.ElementTree as ET
+from defusedxml import DefusedXmlException
+from typing import List, Dict, Any, Optional, Tuple
+from src.services.findings import normalize_findings
+
+
+FORMATS = {
+    "sarif": "SARIF 2.1.0 (CodeQL, ESLint, Semgrep, gosec -fmt sarif, ...)",
+    "zap_xml": "OWASP ZAP traditional XML report",
+    "zap_json": "OWASP ZAP traditional JSON report",
+    "gosec": "gosec -fmt json",
+    "bandit": "bandit -f json",
+    "semgrep": "semgrep --json",
+    "npm_audit": "npm audit --json (v1 advisories and v2 vulnerabilities)",
+}
+# Checkout directories of common CI runners (GitHub Actions, GitLab CI, Jenkins, Docker images)
+CI_ROOTS = re.compile(r"^/(?:home/runner/work/[^/]+/[^/]+|builds/[^/]+/[^/]+|github/workspace|var/jenkins_home/workspace/[^/]+|workspace|src|app|code)/")
+LINE_TOLERANCE = 2  # tools disagree on which line of a multi-line statement they report
+ZAP_RISKS = {"3": "High", "2": "Medium", "1": "Low", "0": "Informational"}
+SEVERITY_RISKS = {
+    "critical": "High", "high": "High", "error": "High",
+    "moderate": "Medium", "medium": "Medium", "warning": "Medium",
+    "low": "Low", "info": "Low", "note": "Low", "none": "Informational",
+}
+
+
+class FindingImporter:
+    """
+    FindingImporter ingests results of external scanners (SARIF, ZAP XML/JSON, gosec, Bandit,
+    Semgrep, npm audit) as RedEye findings.
+
+    - parse(): native report -> alerts in the RepoScanner/ZAP shape (+ `tool`), normalized
+      into findings (source, CWE, fingerprint).
+    - dedupe(): drops imported findings that RedEye (or another imported tool) already reported
+      at the same location with the same CWE; the tool is recorded in `reported_by` instead.
+    """
+
+    def detect_format(self, content: str, filename: str = "") -> str:
+        stripped = content.lstrip()
+        if stripped.startswith("<"):
+            if "OWASPZAPReport" in stripped[:500]:
+                return "zap_xml"
+            raise ValueError("Unsupported XML report (only the OWASP ZAP XML report is supported).")
+        try:
+            data = json.loads(content)
+        except json.JSONDecodeError:
+            raise ValueError("Report is neither JSON nor XML.")
+        if not isinstance(data, dict):
+            raise ValueError("Unsupported JSON report.")
+        if "runs" in data and str(data.get("version", "")).startswith("2.1"):
+            return "sarif"
+        if "Issues" in data and ("Golang errors" in data or "Stats" in data):
+            return "gosec"
+        if "results" in data and ("generated_at" in data or "metrics" in data):
+            return "bandit"
+        if "results" in data and isinstance(data["results"], list) and (
+                not data["results"] or "check_id" in data["results"][0]):
+            return "semgrep"
+        if "site" in data and ("@programName" in data or "@version" in data):
+            return "zap_json"
+        if "auditReportVersion" in data or "advisories" in data:
+            return "npm_audit"
+        raise ValueError(f"Could not detect the report format of {filename or 'the upload'}.")
+
+    def parse(self, content: str, format: Optional[str] = None, filename: str = "", root_dir: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Findings from a native report. `root_dir` makes absolute tool paths relative to the workspace."""
+        format = format or self.detect_format(content, filename)
+        if format not in FORMATS:
+            raise ValueError(f"format must be one of: {', '.join(FORMATS)}")
+        if format == "zap_xml":
+            alerts = self._zap_xml(content)
+        else:
+            try:
+                data = json.loads(content)
+            except json.JSONDecodeError as e:
+                raise ValueError(f"Invalid {format} report: {e}")
+            alerts = getattr(self, f"_{format}")(data)
+
+        self._relativize(alerts, root_dir)
+        findings = []
+        for source in ("sast", "dast", "sca"):
+            findings += normalize_findings([a for a in alerts if a["source"] == source], source)
+        print(f"📥 [Import] {len(findings)} finding(s) parsed from {format} report")
+        return findings
+
+    def dedupe(
+        self, imported: List[Dict[str, Any]], existing: List[Dict[str, Any]]
+    ) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]], Dict[str, List[str]]]:
+        """
+        Returns (new findings, duplicates, reported_by). A duplicate is {"finding", "duplicate_of"}: the fingerprint
+        of the already-stored (or earlier imported) finding at the same location with the same CWE.
+        `reported_by` maps stored findings that gained a tool to their new tool list; the caller persists it.
+        Neither input list is modified.
+        """
+        kept, duplicates, reported_by = [], [], {}
+        for finding in imported:
+            match = next((f for f in existing + kept if self.same_issue(f, finding)), None)
+            if not match:
+                kept.append({**finding, "reported_by": [finding["tool"]]})
+                continue
+            duplicates.append({"finding": finding, "duplicate_of": match["fingerprint"]})
+            if any(match is k for k in kept):
+                tools = match["reported_by"]
+            else:
+                tools = reported_by.setdefault(
+                    match["fingerprint"], list(match.get("reported_by") or [match.get("tool") or "redeye"])
+                )
+            if finding["tool"] not in tools:
+                tools.append(finding["tool"])
+        return kept, duplicates, reported_by
+
+    def same_issue(self, a: Dict[str, Any], b: Dict[str, Any]) -> bool:
+        """Same location (file/line, URL/param or package) and same CWE."""
+        if a.get("cwe") and b.get("cwe") and a["cwe"] != b["cwe"]:
+            return False
+        if (a.get("source") == "sca") != (b.get("source") == "sca"):
+            return False
+        if a.get("source") == "sca":
+            return a.get("package") == b.get("package") and (a.get("cwe") == b.get("cwe") or a.get("advisory") == b.get("advisory"))
+        if a.get("file") and b.get("file"):
+            if not self._same_path(a["file"], b["file"]):
+                return False
+            tolerance = LINE_TOLERANCE if a.get("cwe") and b.get("cwe") else 0
+            return abs(int(a.get("line") or 0) - int(b.get("line") or 0)) <= tolerance
+        if a.get("url") and b.get("url"):
+            return self._url_path(a["url"]) == self._url_path(b["url"]) and (a.get("param") or "") == (b.get("param") or "") \
+                and (a.get("cwe") or a.get("alert")) == (b.get("cwe") or b.get("alert"))
+        return False
+
+    # --- Formats ---
+    def _sarif(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        alerts = []
+        for run in data.get("runs") or []:
+            driver = (run.get("tool") or {}).get("driver") or {}
+            tool = (driver.get("name") or "sarif").lower()
+            rules = {r.get("id"): r for r in driver.get("rules") or []}
+            for result in run.get("results") or []:
+                rule = rules.get(result.get("ruleId")) or {}
+                if "ruleIndex" in result and not rule and result["ruleIndex"] < len(driver.get("rules") or []):
+                    rule = driver["rules"][result["ruleIndex"]]
+                location = ((result.get("locations") or [{}])[0]).get("physicalLocation") or {}
+                region = location.get("region") or {}
+                uri = (location.get("artifactLocation") or {}).get("uri", "")
+                message = (result.get("message") or {}).get("text") or (rule.get("shortDescription") or {}).get("text", "")
+                properties = rule.get("properties") or {}
+                alert = {
+                    "alert": (rule.get("shortDescription") or {}).get("text") or rule.get("name") or result.get("ruleId") or "Imported finding",
+                    "risk": self._sarif_risk(result, properties),
+                    "description": message,
+                    "rule_id": f"{tool}:{result.get('ruleId') or rule.get('id')}",
+                    "cwe": self._cwe(properties.get("tags") or [], properties.get("cwe")),
+                    "tool": tool,
+                }
+                if uri.startswith(("http://", "https://")):
+                    alert.update({"source": "dast", "url": uri, "other": message[:500]})
+                else:
+                    snippet = (region.get("snippet") or {}).get("text", "")
+                    alert.update(self._code_location(re.sub(r"^file://", "", uri), region.get("startLine"), snippet))
+                alerts.append(alert)
+        return alerts
+
+    def _zap_xml(self, content: str) -> List[Dict[str, Any]]:
+        try:
+            # defusedxml: uploads are untrusted, so entity expansion and external entities are rejected
+            root = ET.fromstring(content)
+        except ET.ParseError as e:
+            raise ValueError(f"Invalid ZAP XML report: {e}")
+        except DefusedXmlException as e:
+            raise ValueError(f"ZAP XML report uses forbidden DTD/entity constructs: {e}")
+        alerts = []
+        for site in root.iter("site"):
+            for item in site.iter("alertitem"):
+                field = lambda name: (item.findtext(name) or "").strip()
+                instances = [{k: (i.findtext(k) or "").strip() for k in ("uri", "method", "param", "attack", "evidence")}
+                             for i in item.iter("instance")]
+                alerts += self._zap_alerts({
+                    "alert": field("alert") or field("name"), "riskcode": field("riskcode"), "desc": field("desc"),
+                    "solution": field("solution"), "cweid": field("cweid"), "pluginid": field("pluginid"),
+                    "instances": instances or [{"uri": field("uri"), "param": field("param"), "attack": field("attack"),
+                                                "evidence": field("evidence"), "method": ""}],
+                }, site.get("name", ""))
+        return alerts
+
+    def _zap_json(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        sites = data.get("site") or []
+        alerts = []
+        for site in sites if isinstance(sites, list) else [sites]:
+            for item in site.get("alerts") or []:
+                alerts += self._zap_alerts(item, site.get("@name", ""))
+        return alerts
+
+    def _zap_alerts(self, item: Dict[str, Any], site: str) -> List[Dict[str, Any]]:
+        description = self._strip_html(item.get("desc", ""))
+        cwe_id = str(item.get("cweid", "")).strip()
+        alerts = []
+        for instance in item.get("instances") or [{}]:
+            alerts.append({
+                "alert": item.get("alert") or item.get("name") or "ZAP alert",
+                "risk": ZAP_RISKS.get(str(item.get("riskcode", "")), "Low"),
+                "description": description,
+                "solution": self._strip_html(item.get("solution", "")),
+                "url": instance.get("uri") or site,
+                "method": instance.get("method", ""),
+                "param": instance.get("param", ""),
+                "attack": instance.get("attack", ""),
+                "evidence": instance.get("evidence", ""),
+                "other": f"Attack: {instance.get('attack', '')}\nEvidence: {instance.get('evidence', '')}"[:500],
+                "rule_id": f"zap:{item.get('pluginid', '')}",
+                "cwe": f"CWE-{cwe_id}" if cwe_id and cwe_id not in ("-1", "0") else None,
+                "source": "dast",
+                "tool": "zap",
+            })
+        return alerts
+
+    def _gosec(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        alerts = []
+        for issue in data.get("Issues") or []:
+            cwe = issue.get("cwe") or {}
+            line = str(issue.get("line", "")).split("-")[0]
+            code_lines = (issue.get("code") or "").split("\n")
+            code = next((l.split(":", 1)[1] for l in code_lines if l.startswith(f"{line}:")), code_lines[0])
+            alerts.append({
+                "alert": issue.get("details") or issue.get("rule_id"),
+                "risk": SEVERITY_RISKS.get(str(issue.get("severity", "")).lower(), "Medium"),
+                "description": f"{issue.get('details', '')} (gosec {issue.get('rule_id')}, confidence {issue.get('confidence', '').lower()})",
+                "rule_id": f"gosec:{issue.get('rule_id')}",
+                "cwe": f"CWE-{cwe['id']}" if cwe.get("id") else None,
+                "tool": "gosec",
+                **self._code_location(issue.get("file", ""), line, code),
+            })
+        return alerts
+
+    def _bandit(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        alerts = []
+        for result in data.get("results") or []:
+            cwe = result.get("issue_cwe") or {}
+            code_lines = (result.get("code") or "").split("\n")
+            line = result.get("line_number")
+            code = next((re.sub(r"^\d+\s", "", l) for l in code_lines if l.startswith(f"{line} ")), "")
+            alerts.append({
+                "alert": result.get("issue_text") or result.get("test_name"),
+                "risk": SEVERITY_RISKS.get(str(result.get("issue_severity", "")).lower(), "Medium"),
+                "description": f"{result.get('issue_text', '')} (bandit {result.get('test_id')} {result.get('test_name', '')}, "
+                               f"confidence {str(result.get('issue_confidence', '')).lower()})",
+                "rule_id": f"bandit:{result.get('test_id')}",
+                "cwe": f"CWE-{cwe['id']}" if cwe.get("id") else None,
+                "tool": "bandit",
+                **self._code_location(result.get("filename", ""), line, code),
+            })
+        return alerts
+
+    def _semgrep(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        alerts = []
+        for result in data.get("results") or []:
+            extra = result.get("extra") or {}
+            metadata = extra.get("metadata") or {}
+            cwes = metadata.get("cwe") or []
+            lines = extra.get("lines") or ""
+            alerts.append({
+                "alert": result.get("check_id", "").split(".")[-1].replace("-", " ").capitalize() or "Semgrep finding",
+                "risk": SEVERITY_RISKS.get(str(extra.get("severity", "")).lower(), "Medium"),
+                "description": extra.get("message", ""),
+                "rule_id": f"semgrep:{result.get('check_id')}",
+                "cwe": self._cwe(cwes if isinstance(cwes, list) else [cwes]),
+                "tool": "semgrep",
+                **self._code_location(result.get("path", ""), (result.get("start") or {}).get("line"),
+                                      "" if lines == "requires login" else lines.split("\n")[0]),
+            })
+        return alerts
+
+    def _npm_audit(self, data: Dict[str, Any]) -> List[Dict[str, Any]]:
+        alerts = []
+        if "advisories" in data:
+            for advisory in (data.get("advisories") or {}).values():
+                alerts.append(self._npm_alert(
+                    advisory.get("module_name"), advisory.get("title"), advisory.get("severity"), advisory.get("cwe"),
+                    advisory.get("url"), advisory.get("vulnerable_versions"), advisory.get("recommendation", "")
+                ))
+            return alerts
+
+        for name, vulnerability in (data.get("vulnerabilities") or {}).items():
+            fix = vulnerability.get("fixAvailable")
+            recommendation = (f"Upgrade to {fix['name']}@{fix['version']}" + (" (major)" if fix.get("isSemVerMajor") else "")
+                              if isinstance(fix, dict) else "Run `npm audit fix`" if fix else "No fix available")
+            # `via` lists advisories; strings point at the vulnerable dependency that causes this one
+            for via in vulnerability.get("via") or []:
+                if isinstance(via, dict):
+                    alerts.append(self._npm_alert(
+                        name, via.get("title"), via.get("severity") or vulnerability.get("severity"), via.get("cwe"),
+                        via.get("url"), via.get("range") or vulnerability.get("range"), recommendation
+                    ))
+        return alerts
+
+    def _npm_alert(self, package, title, severity, cwe, url, versions, recommendation) -> Dict[str, Any]:
+        return {
+            "alert": f"Vulnerable dependency: {package} ({title})",
+            "risk": SEVERITY_RISKS.get(str(severity).lower(), "Medium"),
+            "description": f"{package} {versions or ''} is affected by {title}. {recommendation}".strip(),
+            "other": url or "",
+            "rule_id": f"npm-audit:{url.rstrip('/').split('/')[-1] if url else title}",
+            "cwe": self._cwe(cwe if isinstance(cwe, list) else [cwe] if cwe else []),
+            "file": "package.json",
+            "code": f"{package}@{versions or '*'}",
+            "package": package,
+            "advisory": url,
+            "source": "sca",
+            "tool": "npm-audit",
+        }
+
+    # --- Helpers ---
+    def _code_location(self, path: str, line, code: str) -> Dict[str, Any]:
+        line = int(line) if str(line or "").isdigit() else None
+        return {
+            "file": path,
+            "line": line,
+            "code": (code or "").strip(),
+            "other": f"File: {path}:{line}\nCode:\n{code}"[:500],
+            "source": "sast",
+        }
+
+    def _relativize(self, alerts: List[Dict[str, Any]], root_dir: Optional[str]):
+        """CI tools report absolute paths (/home/runner/work/app/app/main.go): make them workspace-relative."""
+        absolute = [a for a in alerts if a.get("source") == "sast" and os.path.isabs(a.get("file") or "")]
+        if not absolute:
+            for alert in alerts:
+                if alert.get("source") == "sast":
+                    alert["file"] = alert["file"].removeprefix("./")
+            return
+        directories = {os.path.dirname(a["file"]) for a in absolute}
+        prefix = os.path.commonpath(list(directories)) if len(directories) > 1 else None
+        for alert in absolute:
+            path = alert["file"]
+            # Longest suffix of the path that exists in the workspace, else strip the CI checkout directory
+            parts = path.strip("/").split("/")
+            relative = next((os.path.join(*parts[i:]) for i in range(len(parts))
+                             if root_dir and os.path.isfile(os.path.join(root_dir, *parts[i:]))), None)
+            if not relative and CI_ROOTS.match(path):
+                relative = CI_ROOTS.sub("", path)
+            alert["file"] = relative or (os.path.relpath(path, prefix) if prefix else path.lstrip("/"))
+            alert["other"] = alert["other"].replace(path, alert["file"])
+
+    def _sarif_risk(self, result: Dict[str, Any], properties: Dict[str, Any]) -> str:
+        severity = properties.get("security-severity")
+        if severity:
+            score = float(severity)
+            return "High" if score >= 7.0 else "Medium" if score >= 4.0 else "Low"
+        return SEVERITY_RISKS.get(result.get("level", "warning"), "Medium")
+
+    def _cwe(self, tags: List[Any], extra: Any = None) -> Optional[str]:
+        for tag in list(tags) + ([extra] if extra else []):
+            match = re.search(r"cwe[-/](\d+)", str(tag), re.IGNORECASE)
+            if match:
+                return f"CWE-{int(match.group(1))}"
+        return None
+
+    def _same_path(self, a: str, b: str) -> bool:
+        a, b = a.replace("\\", "/").removeprefix("./"), b.replace("\\", "/").removeprefix("./")
+        return a == b or a.endswith("/" + b) or b.endswith("/" + a)
+
+    def _url_path(self, url: str) -> str:
+        return url.split("?")[0].split("#")[0].rstrip("/")
+
+    def _strip_html(self, text: str) -> str:
+        return re.sub(r"\s+", " ", re.sub(r"<[^>]+>", " ", text or "")).strip()
+
+
+finding_importer = FindingImporter()
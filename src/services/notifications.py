@@ -0,0 +1,117 @@
+import asyncio
+import ipaddress
+import socket
+import urllib.parse
+import httpx
+from datetime import datetime
+from typing import List, Dict, Any, Optional, Tuple
+from src.config import settings
+
+
+CHANNEL_TYPES = ("slack", "webhook")
+# Events a channel can subscribe to (empty `events` = all)
+EVENTS = ("sla.due_soon", "sla.overdue", "sla.escalated", "test")
+
+
+class Notifier:
+    """
+    Notifier delivers organization events to the channels configured under `notifications.channels`
+    of the org settings (`PUT /orgs/{org}/notifications`):
+
+    - slack: Slack incoming webhook (`text` message with a mrkdwn body)
+    - webhook: generic JSON POST {event, org, title, text, data, sent_at} (n8n, Teams/Jira bridges, ...)
+
+    Delivery failures are reported per channel and never raised, so one broken channel cannot stop
+    the others (or the caller's sweep).
+
+    Webhook hosts must be public (private, loopback, link-local and reserved addresses are refused)
+    and, when WEBHOOK_ALLOWED_HOSTS is set, on that list. The check is repeated at send time and the
+    request goes to the address that was checked, so a DNS change cannot redirect it to an internal host.
+    """
+    def __init__(self, timeout: float = 10.0):
+        self.timeout = timeout
+
+    async def validate_channel(self, channel: Dict[str, Any]) -> Dict[str, Any]:
+        """Normalized channel config (raises ValueError)."""
+        if channel.get("type") not in CHANNEL_TYPES:
+            raise ValueError(f"Channel type must be one of: {', '.join(CHANNEL_TYPES)}.")
+        url = (channel.get("url") or "").strip()
+        if not url.startswith("https://"):
+            raise ValueError("Channel URL must be an https:// webhook URL.")
+        await self.resolve_destination(url)
+        events = [e for e in channel.get("events") or []]
+        unknown = [e for e in events if e not in EVENTS]
+        if unknown:
+            raise ValueError(f"Unknown event(s): {', '.join(unknown)}. Available: {', '.join(EVENTS)}.")
+        return {"type": channel["type"], "url": url, "events": events, "name": channel.get("name") or channel["type"]}
+
+    async def resolve_destination(self, url: str) -> Tuple[str, str]:
+        """(host, public IP the webhook resolves to). Raises ValueError for disallowed hosts."""
+        parsed = urllib.parse.urlsplit(url)
+        host = (parsed.hostname or "").lower()
+        if parsed.scheme != "https" or not host or parsed.username or parsed.password:
+            raise ValueError("Channel URL must be an https:// webhook URL without credentials.")
+        allowed = [h.lower().lstrip(".") for h in settings.WEBHOOK_ALLOWED_HOSTS]
+        if allowed and not any(host == h or host.endswith("." + h) for h in allowed):
+            raise ValueError(f"Webhook host {host} is not allowed (WEBHOOK_ALLOWED_HOSTS: {', '.join(allowed)}).")
+        try:
+            infos = await asyncio.get_running_loop().getaddrinfo(host, parsed.port or 443, type=socket.SOCK_STREAM)
+        except socket.gaierror:
+            raise ValueError(f"Webhook host {host} does not resolve.")
+        addresses = {ipaddress.ip_address(info[4][0].split("%")[0]) for info in infos}
+        blocked = [str(a) for a in addresses if not a.is_global or a.is_multicast]
+        if not addresses or blocked:
+            raise ValueError(f"Webhook host {host} resolves to a non-public address ({', '.join(blocked)}).")
+        return host, str(sorted(addresses, key=lambda a: a.version)[0])
+
+    def channels_for(self, org_settings: Dict[str, Any], event: str) -> List[Dict[str, Any]]:
+        channels = (org_settings.get("notifications") or {}).get("channels") or []
+        return [c for c in channels if not c.get("events") or event in c["events"]]
+
+    async def send(
+        self,
+        org_settings: Dict[str, Any],
+        event: str,
+        title: str,
+        text: str,
+        data: Optional[Dict[str, Any]] = None
+    ) -> List[Dict[str, Any]]:
+        """Sends one event to every subscribed channel. Returns the delivery result per channel."""
+        results = []
+        channels = self.channels_for(org_settings, event)
+        if not channels:
+            return results
+
+        async with httpx.AsyncClient(timeout=self.timeout) as client:
+            for channel in channels:
+                if channel["type"] == "slack":
+                    body = {"text": f"*{title}*\n{text}"}
+                else:
+                    body = {"event": event, "org": org_settings.get("org"), "title": title, "text": text,
+                            "data": data or {}, "sent_at": datetime.utcnow().isoformat() + "Z"}
+                try:
+                    response = await self._post(client, channel["url"], body)
+                    ok = response.status_code < 300
+                    results.append({"channel": channel["name"], "ok": ok, "status_code": response.status_code})
+                except (httpx.HTTPError, ValueError) as e:
+                    ok = False
+                    results.append({"channel": channel["name"], "ok": False, "error": str(e)})
+                if not ok:
+                    print(f"⚠️ [Notify] {event} → {channel['name']} failed: {results[-1]}")
+        print(f"📣 [Notify] {event} for {org_settings.get('org')}: {sum(r['ok'] for r in results)}/{len(results)} channel(s)")
+        return results
+
+
+    async def _post(self, client: httpx.AsyncClient, url: str, body: Dict[str, Any]) -> httpx.Response:
+        """POST to the checked address; Host and TLS SNI (certificate check) keep the original host name."""
+        host, address = await self.resolve_destination(url)
+        parsed = urllib.parse.urlsplit(url)
+        netloc = f"[{address}]" if ":" in address else address
+        if parsed.port:
+            netloc += f":{parsed.port}"
+        pinned = urllib.parse.urlunsplit(parsed._replace(netloc=netloc))
+        return await client.post(pinned, json=body, headers={"Host": parsed.netloc},
+                                 extensions={"sni_hostname": host})
+
+
+notifier = Notifier()
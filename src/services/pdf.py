@@ -0,0 +1,256 @@
+import zlib
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+
+PAGE_WIDTH, PAGE_HEIGHT = 595.0, 842.0  # A4 in points
+MARGIN = 50.0
+
+# Helvetica glyph widths (1/1000 em) for ASCII 32..126, from the standard AFM metrics
+HELVETICA_WIDTHS = [
+    278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+    556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+    1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+    667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+    333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+    556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+]
+
+FONTS = {"regular": ("F1", "Helvetica"), "bold": ("F2", "Helvetica-Bold"), "mono": ("F3", "Courier")}
+
+
+def hex_to_rgb(color: str) -> Tuple[float, float, float]:
+    color = color.lstrip("#")
+    if len(color) == 3:
+        color = "".join(c * 2 for c in color)
+    return tuple(int(color[i:i + 2], 16) / 255 for i in (0, 2, 4))
+
+
+def can_render(value: str) -> bool:
+    """True if every character has a WinAnsi glyph in the standard fonts."""
+    try:
+        str(value).encode("cp1252")
+        return True
+    except UnicodeEncodeError:
+        return False
+
+
+class PdfDocument:
+    """
+    Minimal PDF 1.4 writer (standard Type1 fonts, text, filled rectangles, JPEG images).
+    No external dependencies, so reports render locally without a browser or service.
+
+    Text uses WinAnsiEncoding and no fonts are embedded, so characters outside cp1252
+    (e.g. Hangul) cannot be drawn. Check content with can_render() before building a document.
+    """
+    def __init__(self, title: str = "", author: str = ""):
+        self.title = title
+        self.author = author
+        self.pages: List[List[str]] = []
+        self.images: List[dict] = []
+
+    def add_page(self):
+        self.pages.append([])
+
+    # --- Drawing (origin bottom-left, points) ---
+    def text(self, x: float, y: float, value: str, font: str = "regular", size: float = 10,
+             color: str = "#1f2933"):
+        r, g, b = hex_to_rgb(color)
+        self.pages[-1].append(
+            f"BT /{FONTS[font][0]} {size:.1f} Tf {r:.3f} {g:.3f} {b:.3f} rg {x:.2f} {y:.2f} Td ({self._escape(value)}) Tj ET"
+        )
+
+    def rect(self, x: float, y: float, w: float, h: float, color: str):
+        r, g, b = hex_to_rgb(color)
+        self.pages[-1].append(f"{r:.3f} {g:.3f} {b:.3f} rg {x:.2f} {y:.2f} {w:.2f} {h:.2f} re f")
+
+    def image(self, x: float, y: float, w: float, h: float, jpeg: bytes) -> bool:
+        """Draws a baseline JPEG (embedded as-is with DCTDecode). Returns False for unsupported data."""
+        size = self._jpeg_size(jpeg)
+        if not size:
+            return False
+        name = f"Im{len(self.images) + 1}"
+        self.images.append({"name": name, "data": jpeg, "width": size[0], "height": size[1], "components": size[2]})
+        self.pages[-1].append(f"q {w:.2f} 0 0 {h:.2f} {x:.2f} {y:.2f} cm /{name} Do Q")
+        return True
+
+    def text_width(self, value: str, font: str = "regular", size: float = 10) -> float:
+        if font == "mono":
+            return len(value) * 0.6 * size
+        units = sum(HELVETICA_WIDTHS[ord(c) - 32] if 32 <= ord(c) <= 126 else 556 for c in value)
+        return units * size / 1000 * (1.05 if font == "bold" else 1.0)
+
+    # --- Serialization ---
+    def render(self) -> bytes:
+        objects: List[bytes] = []
+
+        def add(body: bytes) -> int:
+            objects.append(body)
+            return len(objects)
+
+        catalog_id = add(b"")  # filled in once the page tree id is known
+        pages_id = add(b"")
+        font_ids = {
+            key: add(f"<< /Type /Font /Subtype /Type1 /BaseFont /{base} /Encoding /WinAnsiEncoding >>".encode())
+            for key, (_, base) in FONTS.items()
+        }
+        image_ids = {}
+        for image in self.images:
+            colorspace = "/DeviceGray" if image["components"] == 1 else "/DeviceCMYK" if image["components"] == 4 else "/DeviceRGB"
+            image_ids[image["name"]] = add(
+                f"<< /Type /XObject /Subtype /Image /Width {image['width']} /Height {image['height']} "
+                f"/ColorSpace {colorspace} /BitsPerComponent 8 /Filter /DCTDecode /Length {len(image['data'])} >>\n"
+                f"stream\n".encode() + image["data"] + b"\nendstream"
+            )
+
+        fonts = " ".join(f"/{FONTS[key][0]} {obj} 0 R" for key, obj in font_ids.items())
+        xobjects = " ".join(f"/{name} {obj} 0 R" for name, obj in image_ids.items())
+        resources = f"<< /Font << {fonts} >>" + (f" /XObject << {xobjects} >>" if xobjects else "") + " >>"
+
+        page_ids = []
+        for operations in self.pages:
+            stream = zlib.compress("\n".join(operations).encode("latin-1"))
+            content_id = add(f"<< /Length {len(stream)} /Filter /FlateDecode >>\nstream\n".encode() + stream + b"\nendstream")
+            page_ids.append(add(
+                f"<< /Type /Page /Parent {pages_id} 0 R /MediaBox [0 0 {PAGE_WIDTH:.0f} {PAGE_HEIGHT:.0f}] "
+                f"/Resources {resources} /Contents {content_id} 0 R >>".encode()
+            ))
+
+        objects[catalog_id - 1] = f"<< /Type /Catalog /Pages {pages_id} 0 R >>".encode()
+        objects[pages_id - 1] = (
+            f"<< /Type /Pages /Kids [{' '.join(f'{p} 0 R' for p in page_ids)}] /Count {len(page_ids)} >>".encode()
+        )
+        info_id = add(
+            f"<< /Title ({self._escape(self.title)}) /Author ({self._escape(self.author)}) /Producer (RedEye) "
+            f"/CreationDate (D:{datetime.utcnow().strftime('%Y%m%d%H%M%S')}Z) >>".encode("latin-1")
+        )
+
+        output = bytearray(b"%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+        offsets = []
+        for i, body in enumerate(objects, start=1):
+            offsets.append(len(output))
+            output += f"{i} 0 obj\n".encode() + body + b"\nendobj\n"
+        xref = len(output)
+        output += f"xref\n0 {len(objects) + 1}\n0000000000 65535 f \n".encode()
+        output += "".join(f"{offset:010d} 00000 n \n" for offset in offsets).encode()
+        output += (f"trailer\n<< /Size {len(objects) + 1} /Root {catalog_id} 0 R /Info {info_id} 0 R >>\n"
+                   f"startxref\n{xref}\n%%EOF\n").encode()
+        return bytes(output)
+
+    def _escape(self, value: str) -> str:
+        encoded = value.replace("\t", "    ").encode("cp1252", errors="replace").decode("latin-1")
+        encoded = "".join(c if c >= " " else " " for c in encoded)
+        return encoded.replace("\\", "\\\\").replace("(", "\\(").replace(")", "\\)")
+
+    def _jpeg_size(self, data: bytes) -> Optional[Tuple[int, int, int]]:
+        """(width, height, components) from the SOF marker, or None if not a JPEG."""
+        if data[:2] != b"\xff\xd8":
+            return None
+        i = 2
+        while i + 9 < len(data):
+            if data[i] != 0xFF:
+                return None
+            marker, length = data[i + 1], int.from_bytes(data[i + 2:i + 4], "big")
+            if marker in (0xC0, 0xC1, 0xC2):
+                height = int.from_bytes(data[i + 5:i + 7], "big")
+                width = int.from_bytes(data[i + 7:i + 9], "big")
+                return width, height, data[i + 9]
+            i += 2 + length
+        return None
+
+
+class PdfFlow:
+    """
+    Top-to-bottom layout on top of PdfDocument: wraps text, breaks pages and
+    draws the running header/footer on every page.
+    """
+    def __init__(self, document: PdfDocument, header: str = "", footer: str = "", accent: str = "#c62828"):
+        self.doc = document
+        self.header = header
+        self.footer = footer
+        self.accent = accent
+        self.width = PAGE_WIDTH - 2 * MARGIN
+        self.y = 0.0
+        self.new_page()
+
+    def new_page(self):
+        self.doc.add_page()
+        page = len(self.doc.pages)
+        self.doc.rect(0, PAGE_HEIGHT - 6, PAGE_WIDTH, 6, self.accent)
+        if self.header:
+            self.doc.text(MARGIN, PAGE_HEIGHT - 30, self.header, "bold", 8, "#7b8794")
+        footer = f"{self.footer}    Page {page}" if self.footer else f"Page {page}"
+        self.doc.text(MARGIN, 25, footer, "regular", 8, "#7b8794")
+        self.y = PAGE_HEIGHT - 60
+
+    def ensure(self, height: float):
+        if self.y - height < MARGIN:
+            self.new_page()
+
+    def space(self, height: float = 8):
+        self.y -= height
+
+    def heading(self, value: str, size: float = 16, color: Optional[str] = None):
+        self.ensure(size * 2.5)
+        self.y -= size * 0.6
+        self.doc.text(MARGIN, self.y - size, value, "bold", size, color or "#1f2933")
+        self.y -= size * 1.6
+
+    def paragraph(self, value: str, size: float = 10, font: str = "regular", color: str = "#1f2933", indent: float = 0):
+        for line in self.wrap(value, font, size, self.width - indent):
+            self.ensure(size * 1.4)
+            self.doc.text(MARGIN + indent, self.y - size, line, font, size, color)
+            self.y -= size * 1.4
+
+    def bullet(self, value: str, size: float = 10):
+        lines = self.wrap(value, "regular", size, self.width - 14)
+        for i, line in enumerate(lines):
+            self.ensure(size * 1.4)
+            if i == 0:
+                self.doc.text(MARGIN + 2, self.y - size, "-", "bold", size, self.accent)
+            self.doc.text(MARGIN + 14, self.y - size, line, "regular", size, "#1f2933")
+            self.y -= size * 1.4
+
+    def code(self, value: str, size: float = 8, line_colors: Optional[dict] = None):
+        """Monospace block on a grey background. line_colors maps a line prefix ('+', '-') to a text color."""
+        max_chars = int((self.width - 12) / (0.6 * size))
+        lines = []
+        for raw in value.rstrip("\n").split("\n"):
+            raw = raw.replace("\t", "    ")
+            lines += [raw[i:i + max_chars] for i in range(0, len(raw), max_chars)] or [""]
+        line_height = size * 1.3
+        for line in lines:
+            if self.y - line_height < MARGIN:
+                self.new_page()
+            self.doc.rect(MARGIN, self.y - line_height, self.width, line_height, "#f0f4f8")
+            color = next((c for prefix, c in (line_colors or {}).items() if line.startswith(prefix)), "#243b53")
+            self.doc.text(MARGIN + 6, self.y - size - 1, line, "mono", size, color)
+            self.y -= line_height
+        self.y -= 6
+
+    def badge(self, value: str, color: str, size: float = 8) -> float:
+        """Colored label at the current line; returns its width so text can follow it."""
+        width = self.doc.text_width(value, "bold", size) + 10
+        self.doc.rect(MARGIN, self.y - size - 5, width, size + 6, color)
+        self.doc.text(MARGIN + 5, self.y - size - 1, value, "bold", size, "#ffffff")
+        return width
+
+    def wrap(self, value: str, font: str, size: float, width: float) -> List[str]:
+        lines = []
+        for paragraph in str(value).split("\n"):
+            current = ""
+            for word in paragraph.split(" "):
+                candidate = f"{current} {word}" if current else word
+                if self.doc.text_width(candidate, font, size) <= width:
+                    current = candidate
+                    continue
+                if current:
+                    lines.append(current)
+                # Hard-break words longer than a line (URLs, hashes)
+                while self.doc.text_width(word, font, size) > width:
+                    cut = max(1, int(len(word) * width / self.doc.text_width(word, font, size)) - 1)
+                    lines.append(word[:cut])
+                    word = word[cut:]
+                current = word
+            lines.append(current)
+        return lines
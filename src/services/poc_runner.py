@@ -0,0 +1,410 @@
+import os
+import re
+import socket
+import time
+import urllib.parse
+from typing import List, Dict, Any, Optional, Tuple
+from src.config import settings
+from src.rules import block_end
+from src.services.sandbox import sandbox, SandboxUnavailable
+from src.services.attack_surface import attack_surface_extractor
+
+
+# Payload + the marker that proves the sink was reached. Markers are built so they do not
+# appear verbatim in the payload itself (the app has to evaluate / read something to produce them).
+POC_PAYLOADS = {
+    "path_traversal": [
+        {"payload": "../../../../../../../../etc/passwd", "markers": ["root:x:0:0"]},
+        {"payload": "..%2f..%2f..%2f..%2f..%2f..%2fetc%2fpasswd", "markers": ["root:x:0:0"]},
+    ],
+    "code_injection": [
+        {"payload": "'redeye'+'-poc'", "markers": ["redeye-poc"]},
+        # Last resort: a payload that terminates the interpreter. Confirmed if the target dies.
+        {"payload": "process.exit()", "markers": [], "expect_crash": True, "languages": ["javascript"]},
+    ],
+    "command_injection": [
+        {"payload": ";echo redeye-$((6*7))", "markers": ["redeye-42"]},
+        {"payload": "|echo redeye-$((6*7))", "markers": ["redeye-42"]},
+    ],
+    "xss": [
+        {"payload": "<script>redeye-poc</script>", "markers": ["<script>redeye-poc</script>"]},
+    ],
+}
+
+# Handler body patterns used when the finding does not say which class it is.
+SINK_CLASSES = {
+    "path_traversal": [r"ReadFile\(", r"os\.Open\(", r"readFileSync\(|readFile\(", r"sendFile\(|send_file\(", r"\bopen\("],
+    "command_injection": [r"exec\.Command\(", r"os\.system\(", r"subprocess\.", r"child_process|execSync\(|\bexec\("],
+    "code_injection": [r"\beval\(", r"new Function\(", r"vm\.runIn"],
+    "xss": [r"res\.send\(\s*`[^`]*\$\{", r"fmt\.Fprintf\(\s*\w+\s*,", r"w\.Write\(", r"return\s+f['\"]<"],
+}
+
+CWE_TO_CLASS = {
+    "CWE-22": "path_traversal", "CWE-23": "path_traversal", "CWE-73": "path_traversal",
+    "CWE-78": "command_injection", "CWE-77": "command_injection",
+    "CWE-94": "code_injection", "CWE-95": "code_injection",
+    "CWE-79": "xss",
+}
+
+LABEL_TO_CLASS = {
+    "traversal": "path_traversal", "lfi": "path_traversal", "file inclusion": "path_traversal",
+    "command": "command_injection", "code injection": "code_injection", "eval": "code_injection",
+    "xss": "xss", "cross site scripting": "xss", "cross-site scripting": "xss",
+}
+
+LANGUAGE_BY_EXT = {".go": "go", ".js": "javascript", ".ts": "javascript", ".py": "python"}
+
+
+class PocRunner:
+    """
+    PocRunner turns a web finding into a minimal proof-of-concept request and replays it
+    against a locally started copy of the target app.
+
+    Flow:
+    1. Pick the routes the finding belongs to (attack surface inventory).
+    2. Build PoC requests for the sink class (path traversal, code/command injection, XSS).
+    3. Copy the project into a Sandbox workspace, rewrite the listen port to a free
+       loopback port and start the app as a process group with resource limits.
+    4. Send a baseline request, then the PoCs, and look for sink markers (or a crash).
+
+    Result status: confirmed / not_confirmed / inconclusive, with evidence and the raw exchanges.
+    """
+
+    def generate_pocs(self, surface: Dict[str, Any], root_dir: str, finding: Optional[Dict[str, Any]] = None) -> List[Dict[str, Any]]:
+        """
+        Builds PoC requests for the routes matching `finding` (all routes if no finding).
+
+        Returns:
+            [{"route": str, "method": str, "url": str, "data": dict | None, "param": str,
+              "vuln_class": str, "payload": str, "markers": List[str], "expect_crash": bool, "curl": str}]
+        """
+        pocs = []
+        for route in self._select_routes(surface, root_dir, finding):
+            vuln_class = self._vuln_class(finding, route, root_dir)
+            if not vuln_class:
+                continue
+            language = LANGUAGE_BY_EXT.get(os.path.splitext(route["file"])[1])
+            params = route["params"] or ["query:q"]
+
+            for template in POC_PAYLOADS[vuln_class]:
+                if template.get("languages") and language not in template["languages"]:
+                    continue
+                for param in params:
+                    location, _, name = param.partition(":")
+                    if location not in ("query", "form", "body", "path"):
+                        continue
+                    pocs.append(self._build_request(route, location, name, vuln_class, template))
+        return pocs
+
+    def confirm(
+        self,
+        root_dir: Optional[str] = None,
+        content: Optional[str] = None,
+        filename: str = "app.go",
+        finding: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        """
+        Generates PoCs for `finding` and replays them against the running app.
+
+        Args:
+            root_dir: Project workspace (e.g. a cached clone). Never modified; a sandbox copy is used.
+            content: Single-file app source, used when there is no workspace.
+            filename: File name for `content`.
+            finding: Finding dict (file/line/url/param/alert/cwe). If omitted, every route is probed.
+
+        Returns:
+            {
+                "status": "confirmed" | "not_confirmed" | "inconclusive",
+                "evidence": str,
+                "pocs": [{..., "status_code": int, "response": str, "hit": bool}],
+                "app_log": str
+            }
+        """
+        workspace = sandbox.create_workspace(root_dir)
+        if content is not None:
+            with open(os.path.join(workspace, os.path.basename(filename)), "w", encoding="utf-8") as f:
+                f.write(content)
+            if finding is not None:
+                finding = {**finding, "file": os.path.basename(filename)}
+
+        process = None
+        try:
+            surface = attack_surface_extractor.extract(workspace)
+            pocs = self.generate_pocs(surface, workspace, finding)
+            if not pocs:
+                return self._result("inconclusive", "No HTTP route with a known sink class matches this finding.", [])
+
+            entry = self._find_entrypoint(workspace, pocs[0]["file"])
+            if not entry:
+                return self._result("inconclusive", "Could not find how to start the app (no server entrypoint).", pocs)
+
+            port = self._free_port()
+            cmd, cwd = self._launch_command(workspace, entry, port)
+            if root_dir:
+                sandbox.link_dependencies(root_dir, workspace)
+
+            try:
+                process = sandbox.start(cmd, cwd, env={"PORT": str(port), "HOST": "127.0.0.1"}, port=port)
+            except SandboxUnavailable as e:
+                return self._result("inconclusive", str(e), pocs)
+            if not self._wait_until_ready(process):
+                log = sandbox.stop(process)
+                process = None
+                return self._result("inconclusive", "Target app did not start in the sandbox (missing dependencies?).", pocs, log)
+
+            self._send(process, {**pocs[0], "url": pocs[0]["baseline_url"], "data": pocs[0]["baseline_data"]})
+
+            status, evidence = "not_confirmed", "All PoC requests were replayed; no sink marker or crash was observed."
+            for poc in pocs:
+                status_code, body = self._send(process, poc)
+                poc["status_code"] = status_code
+                poc["response"] = body[:1000]
+                poc["hit"] = any(marker in body for marker in poc["markers"])
+
+                if poc["expect_crash"]:
+                    time.sleep(0.5)
+                    poc["hit"] = process.poll() is not None
+
+                if poc["hit"]:
+                    status = "confirmed"
+                    evidence = self._describe_hit(poc, body)
+                    break
+                if process.poll() is not None:
+                    status, evidence = "inconclusive", f"Target app exited unexpectedly after {poc['method']} {poc['url']}."
+                    break
+
+            log = sandbox.stop(process)
+            process = None
+            print(f"💥 [PoC] {status}: {evidence}")
+            return self._result(status, evidence, pocs, log)
+        finally:
+            if process is not None:
+                sandbox.stop(process)
+            sandbox.cleanup(workspace)
+
+    # --- Route / class selection ---
+    def _select_routes(self, surface, root_dir, finding) -> List[Dict[str, Any]]:
+        routes = surface.get("routes", [])
+        if not finding:
+            return routes
+
+        # DAST finding: match on URL path
+        if finding.get("url"):
+            path = urllib.parse.urlparse(finding["url"]).path or "/"
+            by_path = [r for r in routes if self._path_regex(r["path"]).fullmatch(path)]
+            if by_path:
+                param = finding.get("param")
+                if param:
+                    by_path = [{**r, "params": [p for p in r["params"] if p.endswith(f":{param}")] or r["params"]} for r in by_path]
+                return by_path
+
+        # SAST finding: route whose handler contains the flagged line
+        file = finding.get("file")
+        if file:
+            in_file = [r for r in routes if r["file"] == file]
+            line = finding.get("line")
+            if line:
+                containing = [r for r in in_file if self._handler_span(root_dir, r)[0] <= line <= self._handler_span(root_dir, r)[1]]
+                if containing:
+                    return containing
+            return in_file
+        return routes
+
+    def _handler_span(self, root_dir: str, route: Dict[str, Any]) -> Tuple[int, int]:
+        """(first line, last line) of the route handler (best effort for brace languages)."""
+        content = self._read(os.path.join(root_dir, route["file"]))
+        lines = content.split("\n")
+        start = route["line"]
+        if route["file"].endswith(".py"):
+            indent = len(lines[start - 1]) - len(lines[start - 1].lstrip()) if start <= len(lines) else 0
+            end = start
+            for i in range(start, len(lines)):
+                if lines[i].strip() and len(lines[i]) - len(lines[i].lstrip()) <= indent and not lines[i].lstrip().startswith(("@", ")")):
+                    break
+                end = i + 1
+            return start, end
+        offset = sum(len(l) + 1 for l in lines[:start - 1])
+        brace = content.find("{", offset)
+        if brace == -1:
+            return start, start
+        return start, content[:block_end(content, brace)].count("\n") + 1
+
+    def _vuln_class(self, finding, route, root_dir) -> Optional[str]:
+        if finding:
+            if finding.get("cwe") in CWE_TO_CLASS:
+                return CWE_TO_CLASS[finding["cwe"]]
+            label = (finding.get("alert") or "").lower()
+            for keyword, vuln_class in LABEL_TO_CLASS.items():
+                if keyword in label:
+                    return vuln_class
+
+        start, end = self._handler_span(root_dir, route)
+        body = "\n".join(self._read(os.path.join(root_dir, route["file"])).split("\n")[start - 1:end])
+        for vuln_class, patterns in SINK_CLASSES.items():
+            if any(re.search(p, body) for p in patterns):
+                return vuln_class
+        return None
+
+    def _path_regex(self, route_path: str):
+        pattern = re.sub(r"\\\{[^}]+\\\}|:\w+", "[^/]+", re.escape(route_path))
+        return re.compile(pattern)
+
+    # --- Request building ---
+    def _build_request(self, route, location, name, vuln_class, template) -> Dict[str, Any]:
+        method = route["method"] if route["method"] not in ("ANY", "ALL", "USE") else "GET"
+        payload = template["payload"]
+
+        def render(value):
+            path = re.sub(r"\{[^}]+\}|:\w+", "1", route["path"]) or "/"
+            query, data = {}, None
+            if location == "path":
+                path = re.sub(r"\{" + re.escape(name) + r"(?::[^}]*)?\}|:" + re.escape(name) + r"\b", urllib.parse.quote(value, safe=""), route["path"])
+            elif location == "query" or method == "GET":
+                query[name] = value
+            else:
+                data = {name: value}
+            url = path + ("?" + urllib.parse.urlencode(query, safe="%") if query else "")
+            return url, data
+
+        url, data = render(payload)
+        baseline_url, baseline_data = render("redeye")
+        if data is not None and method == "GET":
+            method = "POST"
+
+        curl = f"curl -s -X {method} 'http://<target>{url}'"
+        if data:
+            curl += f" --data '{urllib.parse.urlencode(data)}'"
+
+        return {
+            "route": f"{route['method']} {route['path']}",
+            "file": route["file"],
+            "line": route["line"],
+            "method": method,
+            "url": url,
+            "data": data,
+            "baseline_url": baseline_url,
+            "baseline_data": baseline_data,
+            "param": f"{location}:{name}",
+            "vuln_class": vuln_class,
+            "payload": payload,
+            "markers": template["markers"],
+            "expect_crash": template.get("expect_crash", False),
+            "curl": curl,
+        }
+
+    def _send(self, process, poc: Dict[str, Any]) -> Tuple[Optional[int], str]:
+        data = urllib.parse.urlencode(poc["data"]).encode() if poc.get("data") else None
+        headers = {"Content-Type": "application/x-www-form-urlencoded"} if data else {}
+        try:
+            return sandbox.request(process, poc["method"], poc["url"], body=data, headers=headers)
+        except Exception as e:
+            return None, f"<request failed: {e}>"
+
+    def _describe_hit(self, poc: Dict[str, Any], body: str) -> str:
+        if poc["expect_crash"]:
+            return f"Target process terminated by payload {poc['payload']!r} sent to {poc['method']} {poc['url']} ({poc['param']})."
+        marker = next(m for m in poc["markers"] if m in body)
+        index = body.find(marker)
+        snippet = body[max(0, index - 40):index + len(marker) + 40].replace("\n", "\\n")
+        return f"{poc['method']} {poc['url']} returned sink marker {marker!r}: ...{snippet}..."
+
+    # --- App startup ---
+    def _find_entrypoint(self, workspace: str, route_file: str) -> Optional[str]:
+        """File that starts the HTTP server: the route file itself, or another server file of the same language."""
+        language = LANGUAGE_BY_EXT.get(os.path.splitext(route_file)[1])
+        if self._starts_server(self._read(os.path.join(workspace, route_file)), language):
+            return route_file
+        for current_root, dirs, files in os.walk(workspace):
+            dirs[:] = [d for d in dirs if d not in ("node_modules", ".git", "vendor", "__pycache__")]
+            for file in sorted(files):
+                rel_path = os.path.relpath(os.path.join(current_root, file), workspace)
+                if LANGUAGE_BY_EXT.get(os.path.splitext(file)[1]) == language and \
+                        self._starts_server(self._read(os.path.join(current_root, file)), language):
+                    return rel_path
+        return None
+
+    def _starts_server(self, content: str, language: Optional[str]) -> bool:
+        if language == "go":
+            return "package main" in content and bool(re.search(r"ListenAndServe|\.Run\(|\.Start\(", content))
+        if language == "javascript":
+            return bool(re.search(r"\.listen\(", content))
+        if language == "python":
+            return bool(re.search(r"\bapp\.run\(|uvicorn\.run\(|=\s*FastAPI\(", content))
+        return False
+
+    def _launch_command(self, workspace: str, entry: str, port: int) -> Tuple[List[str], str]:
+        """Rewrites the listen port in the sandbox copy and returns (command, cwd)."""
+        path = os.path.join(workspace, entry)
+        content = self._read(path)
+        language = LANGUAGE_BY_EXT.get(os.path.splitext(entry)[1])
+
+        if language == "go":
+            content = re.sub(r'(ListenAndServe(?:TLS)?\(\s*)"[^"]*"', rf'\1"127.0.0.1:{port}"', content)
+            content = re.sub(r'(\.Run\(\s*)"[^"]*"', rf'\1"127.0.0.1:{port}"', content)
+            self._write(path, content)
+            package_dir = os.path.dirname(path)
+            if not self._has_go_module(package_dir, workspace):
+                self._write(os.path.join(package_dir, "go.mod"), "module redeye/poctarget\n\ngo 1.18\n")
+            # Only the main package's own files: fixtures often mix languages in one directory.
+            return ["go", "run", "."], package_dir
+
+        if language == "javascript":
+            content = re.sub(r"(\.listen\(\s*)(?:process\.env\.PORT\s*\|\|\s*)?\d+", rf"\g<1>{port}", content)
+            self._write(path, content)
+            return ["node", os.path.basename(entry)], os.path.dirname(path)
+
+        # Python: Flask app.run / uvicorn.run in __main__, or a bare FastAPI app object
+        if re.search(r"\bapp\.run\(|uvicorn\.run\(", content):
+            content = re.sub(r"(\b(?:app|uvicorn)\.run\([^)]*?)port\s*=\s*\d+", rf"\1port={port}", content)
+            if f"port={port}" not in content:
+                content = re.sub(r"\b(app|uvicorn)\.run\(", rf"\1.run(port={port}, ", content, count=1)
+            self._write(path, content)
+            return ["python", os.path.basename(entry)], os.path.dirname(path)
+
+        module = os.path.splitext(entry)[0].replace(os.sep, ".")
+        app_name = re.search(r"(\w+)\s*=\s*FastAPI\(", content).group(1)
+        return ["python", "-m", "uvicorn", f"{module}:{app_name}", "--host", "127.0.0.1", "--port", str(port)], workspace
+
+    def _has_go_module(self, directory: str, workspace: str) -> bool:
+        current = directory
+        while current.startswith(workspace):
+            if os.path.exists(os.path.join(current, "go.mod")):
+                return True
+            current = os.path.dirname(current)
+        return False
+
+    def _wait_until_ready(self, process) -> bool:
+        deadline = time.time() + settings.POC_STARTUP_TIMEOUT_SECONDS
+        while time.time() < deadline:
+            if process.poll() is not None:
+                return False
+            if sandbox.is_listening(process):
+                return True
+            time.sleep(0.3)
+        return False
+
+    def _free_port(self) -> int:
+        with socket.socket(socket.AF_INET, socket.SOCK_STREAM) as s:
+            s.bind(("127.0.0.1", 0))
+            return s.getsockname()[1]
+
+    # --- Helpers ---
+    def _result(self, status: str, evidence: str, pocs: List[Dict[str, Any]], app_log: str = "") -> Dict[str, Any]:
+        for poc in pocs:
+            poc.pop("baseline_url", None)
+            poc.pop("baseline_data", None)
+        return {"status": status, "evidence": evidence, "pocs": pocs, "app_log": app_log[-4000:]}
+
+    def _read(self, path: str) -> str:
+        try:
+            with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                return f.read()
+        except OSError:
+            return ""
+
+    def _write(self, path: str, content: str):
+        with open(path, "w", encoding="utf-8") as f:
+            f.write(content)
+
+
+poc_runner = PocRunner()
@@ -0,0 +1,320 @@
+import ast
+import os
+import re
+from collections import deque
+from typing import List, Dict, Any, Optional
+from src.rules import line_of, block_end
+
+
+RISK_WEIGHT = {"High": 3, "Medium": 2, "Low": 1}
+
+# Calls that are language keywords/builtins rather than first-party functions.
+IGNORED_CALLS = {
+    "if", "for", "while", "switch", "return", "func", "function", "catch", "typeof",
+    "print", "len", "make", "append", "new", "require", "super", "int", "str",
+}
+
+# --- Entrypoint patterns ---
+GO_ROUTE = re.compile(
+    r"\.(?P<verb>HandleFunc|Handle|GET|POST|PUT|DELETE|PATCH|Any)\(\s*\"(?P<path>[^\"]*)\"\s*,\s*(?:http\.HandlerFunc\()?(?P<handler>[\w.]+)"
+)
+GO_CLI = re.compile(r"\b(?:RunE?|PreRunE?)\s*:\s*(?P<handler>[\w.]+)")
+GO_FUNC = re.compile(r"^func\s+(?:\([^)]*\)\s*)?(?P<name>\w+)\s*\([^{]*\{", re.MULTILINE)
+
+JS_FUNC = re.compile(
+    r"(?:^|\n)\s*(?:export\s+)?(?:async\s+)?function\s+(?P<name>\w+)\s*\([^)]*\)\s*\{"
+    r"|(?:^|\n)\s*(?:export\s+)?(?:const|let|var)\s+(?P<arrow>\w+)\s*=\s*(?:async\s*)?(?:function\s*)?\([^)]*\)\s*(?:=>)?\s*\{"
+)
+JS_ROUTE = re.compile(
+    r"\b(?:app|router|server)\.(?P<method>get|post|put|delete|patch|all|use)\(\s*['\"`](?P<path>[^'\"`]*)['\"`]\s*,(?P<rest>[^\n]*)"
+)
+JS_CONSUMER = re.compile(r"\.(?:on|subscribe|consume|action)\(\s*(?:['\"`](?P<event>[^'\"`]*)['\"`]\s*,\s*)?(?P<handler>\w+)?")
+
+PY_ROUTE_DECORATORS = {"get", "post", "put", "delete", "patch", "route", "websocket", "api_route"}
+PY_CLI_DECORATORS = {"command", "group", "callback"}
+PY_CONSUMER_DECORATORS = {"task", "consumer", "subscriber", "agent", "shared_task", "on_event"}
+
+
+class ReachabilityAnalyzer:
+    """
+    ReachabilityAnalyzer builds a per-language call graph of a cloned repository and
+    checks whether each finding's enclosing function can be reached from an entrypoint.
+
+    Entrypoints:
+    - HTTP route registrations (Go net/http & gin, Express, FastAPI/Flask decorators)
+    - `main` functions / `if __name__ == "__main__":` blocks
+    - CLI commands (click/typer, cobra, commander `.action()`)
+    - Message consumers (Celery tasks, `.on()/.subscribe()` handlers)
+
+    The graph is name-based (calls are resolved by function name across files), which
+    over-approximates reachability rather than missing live paths.
+    """
+
+    def build_graph(self, root_dir: str) -> Dict[str, Any]:
+        """
+        Returns:
+            {
+                "functions": {node_id: {"name", "file", "start", "end", "calls": set}},
+                "entrypoints": {node_id: label}
+            }
+        """
+        graph = {"functions": {}, "entrypoints": {}}
+
+        for root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in {".git", "node_modules", "vendor", "__pycache__"}]
+            for file in files:
+                path = os.path.join(root, file)
+                rel_path = os.path.relpath(path, root_dir)
+                try:
+                    with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                        content = f.read()
+                except Exception:
+                    continue
+
+                if file.endswith(".py"):
+                    self._index_python(graph, rel_path, content)
+                elif file.endswith(".go"):
+                    self._index_go(graph, rel_path, content)
+                elif file.endswith((".js", ".ts", ".mjs", ".cjs")):
+                    self._index_js(graph, rel_path, content)
+
+        return graph
+
+    def annotate(self, root_dir: str, alerts: List[Dict[str, Any]], graph: Optional[Dict[str, Any]] = None) -> List[Dict[str, Any]]:
+        """
+        Adds reachability fields to each alert that has `file` and `line`:
+            reachable: True | False | None (None = module-level code / unknown)
+            call_path: ["GET /view", "fileHandler (main.go:10)"]
+            function:  enclosing function name
+        """
+        graph = graph or self.build_graph(root_dir)
+        parents = self._shortest_paths(graph)
+
+        for alert in alerts:
+            file, line = alert.get("file"), alert.get("line")
+            if not file or not line:
+                continue
+
+            node_id = self._enclosing_function(graph, file, line)
+            if node_id is None:
+                alert["reachable"] = None
+                alert["call_path"] = []
+                continue
+
+            alert["function"] = graph["functions"][node_id]["name"]
+            if node_id in parents:
+                alert["reachable"] = True
+                alert["call_path"] = self._path_labels(graph, parents, node_id)
+            else:
+                alert["reachable"] = False
+                alert["call_path"] = []
+
+        return alerts
+
+    def rank(self, alerts: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """
+        Orders findings by severity adjusted for reachability (reachable first, dead code last).
+        Adds `priority_score` so the agent and reports can sort consistently.
+        """
+        for alert in alerts:
+            score = RISK_WEIGHT.get(alert.get("risk"), 1) * 10
+            if alert.get("reachable") is True:
+                score += 5 - min(len(alert.get("call_path", [])), 5)
+            elif alert.get("reachable") is False:
+                score -= 8
+            alert["priority_score"] = score
+        return sorted(alerts, key=lambda a: a["priority_score"], reverse=True)
+
+    # --- Graph search ---
+    def _shortest_paths(self, graph: Dict[str, Any]) -> Dict[str, Optional[str]]:
+        """Multi-source BFS from all entrypoints. Returns {node_id: parent_node_id}."""
+        by_name: Dict[str, List[str]] = {}
+        for node_id, fn in graph["functions"].items():
+            by_name.setdefault(fn["name"], []).append(node_id)
+
+        parents: Dict[str, Optional[str]] = {}
+        queue = deque()
+        for node_id in graph["entrypoints"]:
+            parents[node_id] = None
+            queue.append(node_id)
+
+        while queue:
+            current = queue.popleft()
+            for called in graph["functions"].get(current, {}).get("calls", ()):
+                for target in by_name.get(called, []):
+                    if target not in parents:
+                        parents[target] = current
+                        queue.append(target)
+        return parents
+
+    def _path_labels(self, graph, parents, node_id) -> List[str]:
+        path = []
+        current = node_id
+        while current is not None:
+            fn = graph["functions"][current]
+            path.append(f"{fn['name']} ({fn['file']}:{fn['start']})")
+            if parents[current] is None:
+                path.append(graph["entrypoints"][current])
+            current = parents[current]
+        return list(reversed(path))
+
+    def _enclosing_function(self, graph, file: str, line: int) -> Optional[str]:
+        """Innermost function whose span contains the line."""
+        best, best_size = None, None
+        for node_id, fn in graph["functions"].items():
+            if fn["file"] == file and fn["start"] <= line <= fn["end"]:
+                size = fn["end"] - fn["start"]
+                if best is None or size < best_size:
+                    best, best_size = node_id, size
+        return best
+
+    def _add_function(self, graph, file, name, start, end, calls) -> str:
+        node_id = f"{file}::{name}::{start}"
+        graph["functions"][node_id] = {
+            "name": name,
+            "file": file,
+            "start": start,
+            "end": end,
+            "calls": {c for c in calls if c not in IGNORED_CALLS and c != name}
+        }
+        return node_id
+
+    def _calls_in(self, code: str) -> set:
+        return set(re.findall(r"\b([A-Za-z_]\w*)\s*\(", code))
+
+    # --- Python (ast) ---
+    def _index_python(self, graph, file: str, content: str):
+        try:
+            tree = ast.parse(content)
+        except SyntaxError:
+            return
+
+        for node in ast.walk(tree):
+            if not isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+                continue
+            calls = set()
+            for child in ast.walk(node):
+                if isinstance(child, ast.Call):
+                    if isinstance(child.func, ast.Name):
+                        calls.add(child.func.id)
+                    elif isinstance(child.func, ast.Attribute):
+                        calls.add(child.func.attr)
+            node_id = self._add_function(graph, file, node.name, node.lineno, node.end_lineno or node.lineno, calls)
+
+            label = self._python_entry_label(node, file)
+            if label:
+                graph["entrypoints"][node_id] = label
+
+        # `if __name__ == "__main__":` block acts as a synthetic `main`
+        for node in tree.body:
+            if isinstance(node, ast.If) and "__main__" in ast.dump(node.test):
+                calls = set()
+                for child in ast.walk(node):
+                    if isinstance(child, ast.Call) and isinstance(child.func, (ast.Name, ast.Attribute)):
+                        calls.add(child.func.id if isinstance(child.func, ast.Name) else child.func.attr)
+                node_id = self._add_function(graph, file, "__main__", node.lineno, node.end_lineno or node.lineno, calls)
+                graph["entrypoints"][node_id] = f"main ({file})"
+
+    def _python_entry_label(self, node, file: str) -> Optional[str]:
+        if node.name == "main":
+            return f"main ({file})"
+        for decorator in node.decorator_list:
+            target = decorator.func if isinstance(decorator, ast.Call) else decorator
+            attr = target.attr if isinstance(target, ast.Attribute) else getattr(target, "id", "")
+            if attr in PY_ROUTE_DECORATORS:
+                path = ""
+                if isinstance(decorator, ast.Call) and decorator.args and isinstance(decorator.args[0], ast.Constant):
+                    path = str(decorator.args[0].value)
+                method = attr.upper()
+                if attr in {"route", "api_route"}:
+                    # Flask / FastAPI default to GET unless methods=[...] is given
+                    methods = next((kw.value for kw in getattr(decorator, "keywords", []) if kw.arg == "methods"), None)
+                    names = [str(e.value).upper() for e in getattr(methods, "elts", []) if isinstance(e, ast.Constant)]
+                    method = ",".join(names) or "GET"
+                return f"{method} {path}".strip()
+            if attr in PY_CLI_DECORATORS:
+                return f"CLI command {node.name}"
+            if attr in PY_CONSUMER_DECORATORS:
+                return f"Consumer {node.name}"
+        return None
+
+    # --- Go (regex + brace matching) ---
+    def _index_go(self, graph, file: str, content: str):
+        name_to_node = {}
+        for match in GO_FUNC.finditer(content):
+            end = block_end(content, match.end() - 1)
+            body = content[match.end():end]
+            node_id = self._add_function(
+                graph, file, match.group("name"),
+                line_of(content, match.start()) + 1, line_of(content, end) + 1,
+                self._calls_in(body) | self._go_handler_refs(body)
+            )
+            name_to_node[match.group("name")] = node_id
+            if match.group("name") == "main":
+                graph["entrypoints"][node_id] = f"main ({file})"
+
+        for match in GO_ROUTE.finditer(content):
+            handler = match.group("handler").split(".")[-1]
+            if handler in name_to_node:
+                graph["entrypoints"][name_to_node[handler]] = self._go_route_label(match, content)
+        for match in GO_CLI.finditer(content):
+            handler = match.group("handler").split(".")[-1]
+            if handler in name_to_node:
+                graph["entrypoints"][name_to_node[handler]] = f"CLI command {handler}"
+
+    def _go_route_label(self, match, content: str) -> str:
+        """"GET /view": gin-style verb, Go 1.22 "GET /path" pattern or a chained .Methods("GET") (mux)."""
+        verb, path = match.group("verb"), match.group("path")
+        method = verb if verb not in ("HandleFunc", "Handle", "Any") else "ANY"
+        if " " in path:
+            method, path = path.split(" ", 1)
+        line_end = content.find("\n", match.end())
+        methods_call = re.search(r"\.Methods\(\s*\"(\w+)\"", content[match.end():line_end if line_end != -1 else None])
+        if methods_call:
+            method = methods_call.group(1)
+        return f"{method} {path}"
+
+    def _go_handler_refs(self, body: str) -> set:
+        """Functions passed by reference (e.g. `http.HandleFunc("/x", handler)`) count as calls."""
+        return {m.group("handler").split(".")[-1] for m in GO_ROUTE.finditer(body)}
+
+    # --- JavaScript / TypeScript ---
+    def _index_js(self, graph, file: str, content: str):
+        name_to_node = {}
+        for match in JS_FUNC.finditer(content):
+            name = match.group("name") or match.group("arrow")
+            open_brace = match.end() - 1
+            end = block_end(content, open_brace)
+            node_id = self._add_function(
+                graph, file, name,
+                line_of(content, match.start() + 1) + 1, line_of(content, end) + 1,
+                self._calls_in(content[match.end():end])
+            )
+            name_to_node[name] = node_id
+
+        for match in JS_ROUTE.finditer(content):
+            label = f"{match.group('method').upper()} {match.group('path')}"
+            rest = match.group("rest")
+            inline = re.search(r"(?:function\s*\([^)]*\)|\([^)]*\)\s*=>|\w+\s*=>)\s*\{", rest)
+            if inline:
+                # Inline callback: the callback body itself is the entry node
+                open_brace = match.start("rest") + inline.end() - 1
+                end = block_end(content, open_brace)
+                node_id = self._add_function(
+                    graph, file, f"<{label}>",
+                    line_of(content, match.start()) + 1, line_of(content, end) + 1,
+                    self._calls_in(content[open_brace:end])
+                )
+                graph["entrypoints"][node_id] = label
+            else:
+                for handler in re.findall(r"\b(\w+)\b", rest):
+                    if handler in name_to_node:
+                        graph["entrypoints"][name_to_node[handler]] = label
+
+        for match in JS_CONSUMER.finditer(content):
+            handler = match.group("handler")
+            if handler and handler in name_to_node:
+                graph["entrypoints"][name_to_node[handler]] = f"Consumer {match.group('event') or handler}"
+
+
+reachability_analyzer = ReachabilityAnalyzer()
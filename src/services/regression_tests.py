@@ -0,0 +1,328 @@
+import os
+import re
+from typing import List, Dict, Any, Optional
+from src.config import settings
+from src.services.sandbox import sandbox
+from src.services.go_fuzzer import go_fuzzer
+from src.services.fix_verifier import fix_verifier
+from src.services.poc_runner import CWE_TO_CLASS, LABEL_TO_CLASS
+
+
+# Regression tests also cover SQL injection (no running app needed, the DB driver is mocked).
+TEST_CLASSES = {**CWE_TO_CLASS, "CWE-89": "sql_injection"}
+TEST_LABELS = {**LABEL_TO_CLASS, "sql": "sql_injection"}
+
+TRAVERSAL_CASES = [("dot_dot", "../../../../../../../../etc/passwd"), ("nested", "a/../../../../../../../etc/passwd"),
+                   ("absolute", "/etc/passwd")]
+SQL_CASES = [("or_true", "' OR 1=1 --"), ("or_string", "' OR '1'='1"), ("stacked", "1; DROP TABLE users --")]
+COMMAND_CASES = [("semicolon", "x; touch redeye_pwned"), ("and", "x && touch redeye_pwned"),
+                 ("subshell", "$(touch redeye_pwned)")]
+XSS_CASES = [("script_tag", "<script>redeye</script>"), ("img_onerror", "<img src=x onerror=redeye>")]
+
+
+class RegressionTestGenerator:
+    """
+    RegressionTestGenerator writes a security unit test for each fix: it must FAIL on the
+    vulnerable code and PASS on the fixed code, so the vulnerability cannot silently return.
+
+    Supported:
+    - Go HTTP handlers: table test via httptest (path traversal, XSS, command injection).
+    - Python functions: pytest cases (SQL injection with a mocked DB driver, path traversal,
+      command injection).
+
+    The test is validated in the Sandbox against both versions before it is proposed.
+    """
+
+    def generate(
+        self,
+        root_dir: str,
+        file: str,
+        function_name: str,
+        vulnerability: str = "",
+        cwe: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """
+        Returns:
+            {"language": "go" | "python", "function": str, "vuln_class": str,
+             "test_file": str (relative), "test_name": str, "test_code": str, "command": List[str], "cwd": str}
+        Raises ValueError when the function/class combination is not supported.
+        """
+        vuln_class = self._vuln_class(vulnerability, cwe)
+        if not vuln_class:
+            raise ValueError(f"No regression test template for '{vulnerability or cwe}'.")
+
+        with open(os.path.join(root_dir, file), "r", encoding="utf-8", errors="ignore") as f:
+            content = f.read()
+
+        if file.endswith(".go"):
+            return self._go_test(root_dir, file, content, function_name, vuln_class)
+        if file.endswith(".py"):
+            return self._python_test(root_dir, file, content, function_name, vuln_class)
+        raise ValueError("Regression tests are generated for Go and Python only.")
+
+    def generate_for_fix(
+        self,
+        root_dir: str,
+        file: str,
+        original_code: str,
+        fixed_code: str,
+        vulnerability: str = "",
+        cwe: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """
+        Generates and validates the regression test for a fix. The target function is the one
+        enclosing `original_code`.
+
+        Returns the generated test plus:
+            {"status": "validated" | "passes_on_vulnerable" | "fails_on_fixed" | "error" | "unsupported",
+             "reason": str, "vulnerable_run": {...}, "fixed_run": {...}}
+        """
+        try:
+            original_content, patched_content = fix_verifier.apply_fix(root_dir, file, original_code, fixed_code)
+            function_name = self.enclosing_function(original_content, original_code, file)
+            if not function_name:
+                raise ValueError("Could not find the function containing the vulnerable code.")
+            test = self.generate(root_dir, file, function_name, vulnerability, cwe)
+        except ValueError as e:
+            return {"status": "unsupported", "reason": str(e)}
+
+        return {**test, **self.validate(root_dir, test, file, patched_content)}
+
+    def validate(self, root_dir: str, test: Dict[str, Any], file: str, patched_content: str) -> Dict[str, Any]:
+        """Runs the test on the vulnerable and on the fixed code in separate sandbox copies."""
+        vulnerable_run = self._run_test(root_dir, test)
+        fixed_run = self._run_test(root_dir, test, patch=(file, patched_content))
+
+        if vulnerable_run["error"] or fixed_run["error"]:
+            status, reason = "error", vulnerable_run["error"] or fixed_run["error"]
+        elif vulnerable_run["passed"]:
+            status, reason = "passes_on_vulnerable", "The test does not detect the vulnerability (it passes on the vulnerable code)."
+        elif not fixed_run["passed"]:
+            status, reason = "fails_on_fixed", "The test still fails after the fix."
+        else:
+            status, reason = "validated", "Fails on the vulnerable code and passes on the fixed code."
+
+        print(f"🧷 [RegressionTest] {test['test_name']}: {status}")
+        return {"status": status, "reason": reason, "vulnerable_run": vulnerable_run, "fixed_run": fixed_run}
+
+    def enclosing_function(self, content: str, snippet: str, file: str) -> Optional[str]:
+        """Name of the Go/Python function whose body contains `snippet`."""
+        first_line = next((l.strip() for l in snippet.split("\n") if l.strip()), "")
+        index = content.find(first_line)
+        if index == -1:
+            return None
+        pattern = r"^func\s+(?:\([^)]*\)\s*)?(\w+)\s*\(" if file.endswith(".go") else r"^\s*(?:async\s+)?def\s+(\w+)\s*\("
+        names = re.findall(pattern, content[:index], re.MULTILINE)
+        return names[-1] if names else None
+
+    # --- Templates ---
+    def _go_test(self, root_dir, file, content, function_name, vuln_class) -> Dict[str, Any]:
+        target = next((t for t in go_fuzzer.find_targets(content) if t["name"] == function_name), None)
+        if not target or target["kind"] != "handler":
+            raise ValueError(f"'{function_name}' is not an HTTP handler; Go regression tests target handlers.")
+        if vuln_class == "sql_injection" or vuln_class == "code_injection":
+            raise ValueError(f"No Go regression test template for {vuln_class}.")
+
+        package = re.search(r"^package\s+(\w+)", content, re.MULTILINE).group(1)
+        params = go_fuzzer.extract_request_params(content, function_name)
+        test_name = f"Test{function_name[0].upper()}{function_name[1:]}Rejects{self._camel(vuln_class)}"
+
+        cases, check = {
+            "path_traversal": (TRAVERSAL_CASES, 'strings.Contains(rec.Body.String(), "root:x:0:0")'),
+            "xss": (XSS_CASES, "strings.Contains(rec.Body.String(), tc.input)"),
+            "command_injection": (COMMAND_CASES, 'fileExists("redeye_pwned")'),
+        }[vuln_class]
+        rows = "\n".join(f'\t\t{{"{name}", {self._go_quote(value)}}},' for name, value in cases)
+        chdir = (
+            '\t\t\tcwd, _ := os.Getwd()\n\t\t\tos.Chdir(t.TempDir())\n\t\t\tdefer os.Chdir(cwd)\n\n'
+            if vuln_class == "command_injection" else ""
+        )
+        helper = (
+            '\nfunc fileExists(name string) bool {\n\t_, err := os.Stat(name)\n\treturn err == nil\n}\n'
+            if vuln_class == "command_injection" else ""
+        )
+        imports = ["net/http", "net/http/httptest", "net/url"] + (["os"] if helper else []) + \
+            (["strings"] if "strings." in check else []) + ["testing"]
+
+        code = f"""// Security regression test generated by RedEye: fails while {function_name} is vulnerable to {vuln_class.replace("_", " ")}.
+
+package {package}
+
+import (
+{chr(10).join(f'	"{i}"' for i in imports)}
+)
+
+func {test_name}(t *testing.T) {{
+	cases := []struct {{
+		name  string
+		input string
+	}}{{
+{rows}
+	}}
+	params := []string{{{", ".join(self._go_quote(p) for p in params)}}}
+
+	for _, tc := range cases {{
+		t.Run(tc.name, func(t *testing.T) {{
+{chdir}			values := url.Values{{}}
+			for _, p := range params {{
+				values.Set(p, tc.input)
+			}}
+			req := httptest.NewRequest(http.MethodGet, "/?"+values.Encode(), nil)
+			rec := httptest.NewRecorder()
+
+			{function_name}(rec, req)
+
+			if {check} {{
+				t.Fatalf("{function_name} is vulnerable to {vuln_class.replace("_", " ")} with input %q", tc.input)
+			}}
+		}})
+	}}
+}}
+{helper}"""
+        directory = os.path.dirname(file)
+        test_file = os.path.join(directory, f"redeye_{self._snake(function_name)}_security_test.go")
+        return {
+            "language": "go", "function": function_name, "vuln_class": vuln_class,
+            "test_file": test_file, "test_name": test_name, "test_code": code,
+            "command": ["go", "test", "-count=1", f"-run=^{test_name}$", "."], "cwd": directory or "."
+        }
+
+    def _python_test(self, root_dir, file, content, function_name, vuln_class) -> Dict[str, Any]:
+        if not re.search(rf"^def\s+{re.escape(function_name)}\s*\(\s*\w+", content, re.MULTILINE):
+            raise ValueError(f"'{function_name}' is not a module-level function with an input argument.")
+        if vuln_class in ("xss", "code_injection"):
+            raise ValueError(f"No Python regression test template for {vuln_class}.")
+
+        test_dir = "tests" if os.path.isdir(os.path.join(root_dir, "tests")) else os.path.dirname(file)
+        test_file = os.path.join(test_dir, f"test_redeye_{self._snake(function_name)}_security.py")
+        test_name = f"test_{self._snake(function_name)}_rejects_{vuln_class}"
+        depth = len([p for p in test_dir.split(os.sep) if p])
+        cases = {"sql_injection": SQL_CASES, "path_traversal": TRAVERSAL_CASES, "command_injection": COMMAND_CASES}[vuln_class]
+        params = ",\n".join(f"    pytest.param({value!r}, id={name!r})" for name, value in cases)
+
+        body = {
+            "sql_injection": f"""    module = _load_target()
+    drivers = [getattr(module, name) for name in ("sqlite3", "pymysql", "psycopg2", "MySQLdb") if hasattr(module, name)]
+    assert drivers, "no DB driver module found in {file}"
+
+    with contextlib.ExitStack() as stack:
+        connects = [stack.enter_context(mock.patch.object(driver, "connect")) for driver in drivers]
+        module.{function_name}(payload)
+
+    executed = []
+    for connect in connects:
+        connection = connect.return_value
+        executed += connection.execute.call_args_list + connection.cursor.return_value.execute.call_args_list
+    assert executed, "{function_name} did not run any SQL"
+    for call in executed:
+        # Parameterized queries keep the payload out of the SQL text.
+        assert payload not in str(call.args[0]), f"input concatenated into SQL: {{call.args[0]}}\"""",
+            "path_traversal": f"""    module = _load_target()
+    try:
+        result = module.{function_name}(payload)
+    except (ValueError, PermissionError, FileNotFoundError, IsADirectoryError):
+        return  # rejecting the path is the expected secure behaviour
+    assert "root:x:0:0" not in str(result)""",
+            "command_injection": f"""    module = _load_target()
+    monkeypatch.chdir(tmp_path)
+    try:
+        module.{function_name}(payload)
+    except (ValueError, OSError):
+        pass  # rejecting the input is the expected secure behaviour
+    assert not (tmp_path / "redeye_pwned").exists(), "shell metacharacters in input were executed\"""",
+        }[vuln_class]
+        fixtures = ", monkeypatch, tmp_path" if vuln_class == "command_injection" else ""
+        imports = "import contextlib\n" if vuln_class == "sql_injection" else ""
+
+        code = f"""# Security regression test generated by RedEye: fails while {function_name} is vulnerable to {vuln_class.replace("_", " ")}.
+{imports}import importlib.util
+import pathlib
+from unittest import mock
+
+import pytest
+
+TARGET = pathlib.Path(__file__).resolve().parents[{depth}] / {file!r}
+
+
+def _load_target():
+    spec = importlib.util.spec_from_file_location("redeye_regression_target", TARGET)
+    module = importlib.util.module_from_spec(spec)
+    spec.loader.exec_module(module)
+    return module
+
+
+@pytest.mark.parametrize("payload", [
+{params},
+])
+def {test_name}(payload{fixtures}):
+{body}
+"""
+        if vuln_class != "sql_injection":
+            code = code.replace("from unittest import mock\n", "")
+        return {
+            "language": "python", "function": function_name, "vuln_class": vuln_class,
+            "test_file": test_file, "test_name": test_name, "test_code": code,
+            "command": ["python", "-m", "pytest", "-q", "-p", "no:cacheprovider", test_file], "cwd": "."
+        }
+
+    # --- Running ---
+    def _run_test(self, root_dir: str, test: Dict[str, Any], patch: Optional[tuple] = None) -> Dict[str, Any]:
+        workspace = sandbox.create_workspace(root_dir)
+        try:
+            if patch:
+                with open(os.path.join(workspace, patch[0]), "w", encoding="utf-8") as f:
+                    f.write(patch[1])
+            test_path = os.path.join(workspace, test["test_file"])
+            os.makedirs(os.path.dirname(test_path), exist_ok=True)
+            with open(test_path, "w", encoding="utf-8") as f:
+                f.write(test["test_code"])
+
+            cwd = os.path.join(workspace, test["cwd"])
+            if test["language"] == "go" and not self._in_go_module(cwd, workspace):
+                with open(os.path.join(cwd, "go.mod"), "w", encoding="utf-8") as f:
+                    f.write("module redeye/regression\n\ngo 1.18\n")
+
+            run = sandbox.run(test["command"], cwd=cwd, timeout=settings.TEST_TIMEOUT_SECONDS)
+            output = (run["stdout"] + run["stderr"])[-3000:]
+            return {"passed": run["exit_code"] == 0, "exit_code": run["exit_code"],
+                    "error": self._run_error(test["language"], run, output), "output": output}
+        finally:
+            sandbox.cleanup(workspace)
+
+    def _run_error(self, language: str, run: Dict[str, Any], output: str) -> str:
+        if run["timed_out"]:
+            return "Test run timed out."
+        if run["exit_code"] is None:
+            return run["stderr"]
+        if language == "go" and ("[build failed]" in output or "[setup failed]" in output):
+            return "Test package does not build in the sandbox."
+        if language == "python" and ("No module named pytest" in output or "ERROR collecting" in output):
+            return "pytest is not available or the target module cannot be imported in the sandbox."
+        return ""
+
+    def _in_go_module(self, directory: str, workspace: str) -> bool:
+        current = directory
+        while current.startswith(workspace):
+            if os.path.exists(os.path.join(current, "go.mod")):
+                return True
+            current = os.path.dirname(current)
+        return False
+
+    # --- Helpers ---
+    def _vuln_class(self, vulnerability: str, cwe: Optional[str]) -> Optional[str]:
+        if cwe in TEST_CLASSES:
+            return TEST_CLASSES[cwe]
+        label = (vulnerability or "").lower()
+        return next((c for keyword, c in TEST_LABELS.items() if keyword in label), None)
+
+    def _go_quote(self, value: str) -> str:
+        return '"' + value.replace("\\", "\\\\").replace('"', '\\"') + '"'
+
+    def _camel(self, value: str) -> str:
+        return "".join(part.capitalize() for part in value.split("_"))
+
+    def _snake(self, value: str) -> str:
+        return re.sub(r"(?<!^)(?=[A-Z])", "_", value).lower()
+
+
+regression_test_generator = RegressionTestGenerator()
@@ -0,0 +1,154 @@
+import urllib.parse
+import uuid
+from datetime import datetime, date, time, timedelta
+from typing import List, Dict, Any, Optional
+from src.config import settings
+from src.database import db
+
+
+MIN_JUSTIFICATION_CHARS = 20
+# Finding fields copied onto the exception so it stays readable after the scan is gone
+FINDING_SNAPSHOT = ("alert", "rule_id", "risk", "cwe", "file", "line", "url", "param", "code")
+
+
+def repository_of(target: str) -> str:
+    """Scope of an exception: `owner/repo` for GitHub targets, the host for web targets."""
+    parsed = urllib.parse.urlparse(target if "://" in target else f"https://{target}")
+    if parsed.netloc.endswith("github.com"):
+        parts = [p for p in parsed.path.split("/") if p]
+        if len(parts) >= 2:
+            return f"{parts[0]}/{parts[1].removesuffix('.git')}".lower()
+    return parsed.netloc.lower() or target
+
+
+class ExceptionManager:
+    """
+    ExceptionManager implements the risk acceptance workflow: a finding that ships knowingly gets an
+    exception with a justification, compensating controls and an expiry date.
+
+    - pending → approved | rejected (by a member of the organization's security team, not the requester)
+    - approved → revoked (manually) | expired (on expiry date; the finding reopens)
+
+    Exceptions are keyed by repository + fingerprint (fingerprints are stable across scans), so one
+    approval covers every later scan and PR of the same repository until it expires. They are applied
+    to findings on read: an active exception sets `status: risk_accepted`, which reports show as an
+    accepted risk and the gate treats as non-blocking.
+    """
+    def __init__(self, max_days: Optional[int] = None):
+        self.max_days = max_days or settings.EXCEPTION_MAX_DAYS
+
+    def new_request(
+        self,
+        scan: Dict[str, Any],
+        finding: Dict[str, Any],
+        justification: str,
+        compensating_controls: List[str],
+        expires_on: date,
+        requested_by: str
+    ) -> Dict[str, Any]:
+        """Validated exception request for one finding of a scan (raises ValueError)."""
+        justification = (justification or "").strip()
+        if len(justification) < MIN_JUSTIFICATION_CHARS:
+            raise ValueError(f"Justification must explain why the risk is acceptable (at least {MIN_JUSTIFICATION_CHARS} characters).")
+        controls = [c.strip() for c in compensating_controls or [] if c and c.strip()]
+        if not controls:
+            raise ValueError("At least one compensating control is required.")
+        today = datetime.utcnow().date()
+        if expires_on <= today:
+            raise ValueError("Expiry date must be in the future.")
+        if expires_on > today + timedelta(days=self.max_days):
+            raise ValueError(f"Exceptions can last at most {self.max_days} days; request a renewal instead.")
+
+        now = datetime.utcnow()
+        return {
+            "exception_id": str(uuid.uuid4()),
+            "repository": repository_of(scan["target"]),
+            "target": scan["target"],
+            "fingerprint": finding["fingerprint"],
+            "scan_id": scan["scan_id"],
+            "finding": {k: finding.get(k) for k in FINDING_SNAPSHOT if finding.get(k) is not None},
+            "justification": justification,
+            "compensating_controls": controls,
+            # Valid through the whole expiry day (UTC)
+            "expires_at": datetime.combine(expires_on, time.max),
+            "status": "pending",
+            "requested_by": requested_by,
+            "requested_at": now,
+            "history": [{"action": "requested", "by": requested_by, "at": now}],
+        }
+
+    def decide(self, exception: Dict[str, Any], approve: bool, approver: str, security_team: List[str], note: str = "") -> Dict[str, Any]:
+        """Fields to update for an approval / rejection (raises PermissionError or ValueError)."""
+        if approver.lower() not in {m.lower() for m in security_team}:
+            raise PermissionError("Only members of the organization's security team can decide on exceptions.")
+        if approver.lower() == (exception.get("requested_by") or "").lower():
+            raise PermissionError("The requester cannot approve their own exception.")
+        if exception["status"] != "pending":
+            raise ValueError(f"Exception is {exception['status']}; only pending exceptions can be decided.")
+        if approve and exception["expires_at"] < datetime.utcnow():
+            raise ValueError("Exception has already expired; request a new one.")
+
+        now = datetime.utcnow()
+        action = "approved" if approve else "rejected"
+        return {
+            "status": action,
+            "decided_by": approver,
+            "decided_at": now,
+            "decision_note": note,
+            "history": exception.get("history", []) + [{"action": action, "by": approver, "at": now, "note": note}],
+        }
+
+    def is_active(self, exception: Dict[str, Any], now: Optional[datetime] = None) -> bool:
+        return exception.get("status") == "approved" and exception["expires_at"] >= (now or datetime.utcnow())
+
+    def apply(self, findings: List[Dict[str, Any]], exceptions: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """
+        Annotates findings with their exception (`exception`: id, status, expiry, approver).
+        Active approval → status `risk_accepted`; an expired one leaves the finding `open` with `reopened`.
+        """
+        now = datetime.utcnow()
+        latest: Dict[str, Dict[str, Any]] = {}
+        for exception in sorted(exceptions, key=lambda e: e["requested_at"]):
+            current = latest.get(exception["fingerprint"])
+            # An active approval is never hidden by a later pending/rejected request
+            if current is None or not self.is_active(current, now):
+                latest[exception["fingerprint"]] = exception
+
+        for finding in findings:
+            exception = latest.get(finding.get("fingerprint"))
+            if not exception:
+                continue
+            status = "expired" if exception["status"] == "approved" and not self.is_active(exception, now) else exception["status"]
+            finding["exception"] = {
+                "exception_id": exception["exception_id"],
+                "status": status,
+                "expires_at": exception["expires_at"],
+                "justification": exception["justification"],
+                "compensating_controls": exception["compensating_controls"],
+                "approved_by": exception.get("decided_by") if exception["status"] in ("approved", "expired", "revoked") else None,
+            }
+            if status == "approved":
+                finding["status"] = "risk_accepted"
+            elif status in ("expired", "revoked") and exception.get("decided_by"):
+                # Was accepted before: back to open (blocking again)
+                finding["status"] = "open"
+                finding["reopened"] = True
+        return findings
+
+    async def apply_to_scan(self, scan: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Expires due exceptions, then applies the repository's exceptions to the scan findings."""
+        findings = scan.get("findings") or []
+        if findings and db.db is not None:
+            await self.expire_due()
+            self.apply(findings, await db.list_exceptions(repository=repository_of(scan["target"])))
+        return findings
+
+    async def expire_due(self) -> int:
+        """Marks exceptions past their expiry date as expired (their findings reopen)."""
+        expired = await db.expire_exceptions(datetime.utcnow())
+        if expired:
+            print(f"⏰ [Exceptions] {expired} exception(s) expired; findings reopened")
+        return expired
+
+
+exception_manager = ExceptionManager()
@@ -0,0 +1,397 @@
+import http.client
+import os
+import shutil
+import signal
+import socket
+import subprocess
+import tempfile
+import time
+from typing import Dict, List, Optional, Any, Tuple
+from src.config import settings
+
+
+WORKSPACE_PREFIX = "redeye-sandbox-"
+# System paths mounted read-only inside the sandbox (toolchains, CA certificates, user database)
+SYSTEM_PATHS = [
+    "/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc/alternatives",
+    "/etc/ssl", "/etc/ca-certificates", "/etc/passwd", "/etc/group",
+]
+# Network access is only granted to `go mod download`; these make DNS/TLS work there
+NETWORK_PATHS = ["/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf"]
+
+# Forwards a unix socket in the workspace to the app's loopback port, so the host can
+# reach a server that runs in its own network namespace. Listens only once the app does.
+RELAY_SCRIPT = """
+import socket, sys, threading, time
+path, port = sys.argv[1], int(sys.argv[2])
+while True:
+    try:
+        socket.create_connection(("127.0.0.1", port), 1).close()
+        break
+    except OSError:
+        time.sleep(0.2)
+
+def pipe(source, target):
+    try:
+        while True:
+            chunk = source.recv(65536)
+            if not chunk:
+                break
+            target.sendall(chunk)
+    except OSError:
+        pass
+    try:
+        target.shutdown(socket.SHUT_WR)
+    except OSError:
+        pass
+
+def handle(client):
+    try:
+        upstream = socket.create_connection(("127.0.0.1", port), 10)
+    except OSError:
+        client.close()
+        return
+    threading.Thread(target=pipe, args=(client, upstream), daemon=True).start()
+    pipe(upstream, client)
+    client.close()
+    upstream.close()
+
+server = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+server.bind(path)
+server.listen(16)
+while True:
+    client, _ = server.accept()
+    threading.Thread(target=handle, args=(client,), daemon=True).start()
+"""
+
+
+class SandboxUnavailable(RuntimeError):
+    """The configured isolation backend is missing on this host."""
+
+
+class _UnixHTTPConnection(http.client.HTTPConnection):
+    def __init__(self, socket_path: str, timeout: float):
+        super().__init__("localhost", timeout=timeout)
+        self.socket_path = socket_path
+
+    def connect(self):
+        self.sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        self.sock.settimeout(self.timeout)
+        self.sock.connect(self.socket_path)
+
+
+class Sandbox:
+    """
+    Sandbox runs untrusted project code (fuzz tests, test suites, PoC targets)
+    inside a throwaway workspace directory with time and resource limits.
+
+    Isolation:
+    - Each run gets a fresh temp copy of the source (the original clone is never touched).
+    - Only a minimal environment is passed through (no API keys, no DB URIs).
+    - CPU time, address space and wall-clock time are limited per process group.
+    - With SANDBOX_ISOLATION=bwrap every process runs in its own bubblewrap namespaces:
+      no network, no view of the host filesystem besides read-only toolchains and the
+      workspace itself, no capabilities. Go modules are downloaded beforehand in a separate
+      step (`go mod download` executes no project code) and are read-only for the project.
+      Servers are reached through a unix socket relay in the workspace (see request()).
+    """
+    def __init__(self, timeout: int = None, memory_mb: int = None, isolation: str = None):
+        self.timeout = timeout or settings.SANDBOX_TIMEOUT_SECONDS
+        self.memory_mb = memory_mb or settings.SANDBOX_MEMORY_MB
+        self.isolation = isolation or settings.SANDBOX_ISOLATION
+
+    def create_workspace(self, source_dir: Optional[str] = None) -> str:
+        """
+        Creates an isolated workspace. If source_dir is given, its contents
+        (excluding .git) are copied into the workspace.
+        """
+        workspace = tempfile.mkdtemp(prefix=WORKSPACE_PREFIX)
+        if source_dir:
+            shutil.copytree(
+                source_dir, workspace,
+                ignore=shutil.ignore_patterns(".git", "node_modules", "__pycache__"),
+                dirs_exist_ok=True
+            )
+        return workspace
+
+    def link_dependencies(self, source_dir: str, workspace: str):
+        """
+        Workspaces skip node_modules; link the already installed dependencies
+        instead of copying them (or installing from the network).
+        """
+        source = os.path.join(source_dir, "node_modules")
+        target = os.path.join(workspace, "node_modules")
+        if os.path.isdir(source) and not os.path.exists(target):
+            os.symlink(source, target)
+
+    def cleanup(self, workspace: str):
+        shutil.rmtree(workspace, ignore_errors=True)
+
+    @property
+    def isolated(self) -> bool:
+        return self.isolation != "none"
+
+    def _gomodcache(self) -> str:
+        return os.environ.get("GOMODCACHE", os.path.join(tempfile.gettempdir(), "redeye-gomodcache"))
+
+    def _minimal_env(self, workspace: str, extra: Optional[Dict[str, str]] = None) -> Dict[str, str]:
+        # Secrets from settings/.env must never leak into project code.
+        env = {
+            "PATH": os.environ.get("PATH", "/usr/local/bin:/usr/bin:/bin"),
+            "HOME": workspace,
+            "LANG": "C.UTF-8",
+            # The module cache is shared (read-only when isolated); the build cache is per
+            # workspace so one project can never plant build outputs for another.
+            "GOCACHE": os.path.join(self._workspace_root(workspace), ".redeye-gocache"),
+            "GOMODCACHE": self._gomodcache(),
+            "GOTOOLCHAIN": "local",
+            "GOFLAGS": "-mod=mod",
+            "CI": "true",
+        }
+        if self.isolated:
+            # Offline: dependencies come from the module cache filled by _download_go_modules
+            env.update({"GOPROXY": "off", "GOSUMDB": "off"})
+        if extra:
+            env.update(extra)
+        return env
+
+    def _workspace_root(self, cwd: str) -> str:
+        """The sandbox workspace containing cwd (cwd itself outside of sandbox workspaces)."""
+        current = os.path.abspath(cwd)
+        while current != os.path.dirname(current):
+            if os.path.basename(current).startswith(WORKSPACE_PREFIX):
+                return current
+            current = os.path.dirname(current)
+        return os.path.abspath(cwd)
+
+    def _wrap(self, cmd: List[str], cwd: str, network: bool = False) -> List[str]:
+        """Prefixes cmd with the bubblewrap invocation for the workspace of cwd."""
+        if not self.isolated:
+            return cmd
+        bwrap = shutil.which("bwrap")
+        if self.isolation != "bwrap" or not bwrap:
+            raise SandboxUnavailable(
+                f"Sandbox isolation '{self.isolation}' is not available (bubblewrap/bwrap not installed?). "
+                "Set SANDBOX_ISOLATION=none only for trusted local development."
+            )
+
+        root = self._workspace_root(cwd)
+        args = [bwrap, "--unshare-all", "--die-with-parent", "--new-session", "--cap-drop", "ALL",
+                "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp"]
+        readonly = SYSTEM_PATHS + os.environ.get("PATH", "").split(os.pathsep) + list(settings.SANDBOX_READONLY_PATHS)
+        if network:
+            args.append("--share-net")
+            readonly += NETWORK_PATHS
+        mounted: List[str] = []
+        for path in readonly:
+            if not os.path.isabs(path) or not os.path.exists(path) or \
+                    any(path == m or path.startswith(m.rstrip("/") + "/") for m in mounted):
+                continue
+            mounted.append(path)
+            args += ["--ro-bind", path, path]
+
+        modcache = self._gomodcache()
+        os.makedirs(modcache, exist_ok=True)
+        args += ["--bind" if network else "--ro-bind", modcache, modcache, "--bind", root, root]
+        # Dependencies linked into the workspace (link_dependencies) stay read-only
+        for name in os.listdir(root):
+            target = os.path.join(root, name)
+            if os.path.islink(target):
+                real = os.path.realpath(target)
+                args += ["--ro-bind", real, real]
+        return args + ["--chdir", os.path.abspath(cwd), "--"] + cmd
+
+    def _download_go_modules(self, cwd: str, env: Optional[Dict[str, str]] = None):
+        """
+        Fills the module cache for the module around cwd. Runs with network access, but
+        `go mod download` only fetches and verifies modules; no project code is executed.
+        """
+        if not self.isolated:
+            return
+        download_env = {**self._minimal_env(cwd, env), "GOPROXY": "https://proxy.golang.org,direct", "GOSUMDB": "sum.golang.org"}
+        try:
+            subprocess.run(
+                self._wrap(["go", "mod", "download"], cwd, network=True),
+                cwd=cwd, env=download_env, capture_output=True, timeout=self.timeout,
+                preexec_fn=self._limit_resources if os.name == "posix" else None
+            )
+        except subprocess.TimeoutExpired:
+            print(f"⚠️ [Sandbox] go mod download timed out in {cwd}")
+
+    def _limit_resources(self):
+        """preexec_fn: applied in the child process before exec."""
+        import resource
+        memory_bytes = self.memory_mb * 1024 * 1024
+        resource.setrlimit(resource.RLIMIT_AS, (memory_bytes, memory_bytes))
+        resource.setrlimit(resource.RLIMIT_CPU, (self.timeout * 4, self.timeout * 4))
+        resource.setrlimit(resource.RLIMIT_CORE, (0, 0))
+
+    def run(
+        self,
+        cmd: List[str],
+        cwd: str,
+        timeout: Optional[int] = None,
+        env: Optional[Dict[str, str]] = None
+    ) -> Dict[str, Any]:
+        """
+        Runs a command inside the workspace and waits for it to finish.
+
+        Returns:
+            {
+                "command": str,
+                "exit_code": int | None,
+                "stdout": str,
+                "stderr": str,
+                "timed_out": bool,
+                "duration": float (seconds)
+            }
+        """
+        timeout = timeout or self.timeout
+        started = time.time()
+        print(f"🧪 [Sandbox] Running: {' '.join(cmd)} (timeout={timeout}s)")
+
+        try:
+            if cmd[0] == "go":
+                self._download_go_modules(cwd, env)
+            process = subprocess.Popen(
+                self._wrap(cmd, cwd),
+                cwd=cwd,
+                env=self._minimal_env(cwd, env),
+                stdout=subprocess.PIPE,
+                stderr=subprocess.PIPE,
+                text=True,
+                errors="replace",
+                start_new_session=True,
+                preexec_fn=self._limit_resources if os.name == "posix" else None
+            )
+        except FileNotFoundError as e:
+            return {
+                "command": " ".join(cmd),
+                "exit_code": None,
+                "stdout": "",
+                "stderr": f"Command not found: {e}",
+                "timed_out": False,
+                "duration": 0.0
+            }
+        except SandboxUnavailable as e:
+            return {
+                "command": " ".join(cmd),
+                "exit_code": None,
+                "stdout": "",
+                "stderr": str(e),
+                "timed_out": False,
+                "duration": 0.0
+            }
+
+        timed_out = False
+        try:
+            stdout, stderr = process.communicate(timeout=timeout)
+        except subprocess.TimeoutExpired:
+            timed_out = True
+            self._kill_group(process)
+            stdout, stderr = process.communicate()
+
+        return {
+            "command": " ".join(cmd),
+            "exit_code": process.returncode,
+            "stdout": stdout[-20000:],
+            "stderr": stderr[-20000:],
+            "timed_out": timed_out,
+            "duration": round(time.time() - started, 2)
+        }
+
+    def start(self, cmd: List[str], cwd: str, env: Optional[Dict[str, str]] = None, port: Optional[int] = None) -> subprocess.Popen:
+        """
+        Starts a long-running process (e.g. a web app under test). Caller must call stop().
+        `port` is the loopback port the server listens on; reach it with is_listening()/request().
+        Raises SandboxUnavailable when the isolation backend is missing.
+        """
+        print(f"🧪 [Sandbox] Starting: {' '.join(cmd)}")
+        root = self._workspace_root(cwd)
+        socket_path = None
+        if self.isolated and port:
+            # The server's loopback is private to its namespace: relay it through a unix socket
+            socket_path = os.path.join(root, ".redeye-http.sock")
+            relay_path = os.path.join(root, ".redeye-relay.py")
+            with open(relay_path, "w", encoding="utf-8") as f:
+                f.write(RELAY_SCRIPT)
+            env = {**(env or {}), "REDEYE_RELAY": relay_path, "REDEYE_SOCKET": socket_path, "REDEYE_PORT": str(port)}
+            cmd = ["sh", "-c", 'python3 "$REDEYE_RELAY" "$REDEYE_SOCKET" "$REDEYE_PORT" & exec "$@"', "sh", *cmd]
+        if cmd[0] == "go":
+            self._download_go_modules(cwd, env)
+
+        wrapped = self._wrap(cmd, cwd)
+        # Output goes to a file so a chatty server can never block on a full pipe.
+        log_file = open(os.path.join(root, ".redeye-process.log"), "w+", encoding="utf-8", errors="replace")
+        process = subprocess.Popen(
+            wrapped,
+            cwd=cwd,
+            env=self._minimal_env(cwd, env),
+            stdout=log_file,
+            stderr=subprocess.STDOUT,
+            start_new_session=True,
+            preexec_fn=self._limit_resources if os.name == "posix" else None
+        )
+        process.redeye_log = log_file
+        process.redeye_port = port
+        process.redeye_socket = socket_path
+        return process
+
+    def is_listening(self, process: subprocess.Popen) -> bool:
+        """True once the server started with start(port=...) accepts connections."""
+        try:
+            if process.redeye_socket:
+                with socket.socket(socket.AF_UNIX, socket.SOCK_STREAM) as s:
+                    s.settimeout(1)
+                    s.connect(process.redeye_socket)
+            else:
+                socket.create_connection(("127.0.0.1", process.redeye_port), timeout=1).close()
+            return True
+        except OSError:
+            return False
+
+    def request(
+        self,
+        process: subprocess.Popen,
+        method: str,
+        path: str,
+        body: Optional[bytes] = None,
+        headers: Optional[Dict[str, str]] = None,
+        timeout: float = 10
+    ) -> Tuple[int, str]:
+        """Sends one HTTP request to a server started with start(port=...). Returns (status, body)."""
+        if process.redeye_socket:
+            connection = _UnixHTTPConnection(process.redeye_socket, timeout)
+        else:
+            connection = http.client.HTTPConnection("127.0.0.1", process.redeye_port, timeout=timeout)
+        try:
+            connection.request(method, path, body=body, headers=headers or {})
+            response = connection.getresponse()
+            return response.status, response.read(200000).decode("utf-8", errors="replace")
+        finally:
+            connection.close()
+
+    def stop(self, process: subprocess.Popen) -> str:
+        """Stops a process started with start() and returns its captured output."""
+        if process.poll() is None:
+            self._kill_group(process)
+        try:
+            process.wait(timeout=5)
+        except subprocess.TimeoutExpired:
+            pass
+        log_file = getattr(process, "redeye_log", None)
+        if log_file is None:
+            return ""
+        log_file.seek(0)
+        output = log_file.read()
+        log_file.close()
+        return output[-20000:]
+
+    def _kill_group(self, process: subprocess.Popen):
+        try:
+            os.killpg(os.getpgid(process.pid), signal.SIGKILL)
+        except (ProcessLookupError, PermissionError):
+            process.kill()
+
+
+sandbox = Sandbox()
@@ -0,0 +1,121 @@
+import re
+from typing import Dict, Any
+
+
+TOOL_NAME = "RedEye"
+TOOL_VERSION = "2.0.0"
+
+
+class SarifExporter:
+    """
+    SarifExporter converts stored scan findings into SARIF 2.1.0 (GitHub code scanning,
+    IDE viewers, DefectDojo).
+
+    - One rule per rule_id (or alert label), tagged with its CWE.
+    - `security-severity` on the rule is the highest CVSS base score of its results
+      (GitHub maps it to Critical/High/Medium/Low).
+    - Every result carries the CVSS v3.1 vector, base/environmental scores and the v4 vector.
+    - Findings under an approved risk exception carry an accepted external suppression.
+    """
+
+    def export(self, scan: Dict[str, Any]) -> Dict[str, Any]:
+        findings = scan.get("findings") or []
+        rules: Dict[str, Dict[str, Any]] = {}
+        results = []
+
+        for finding in findings:
+            rule_id = finding.get("rule_id") or self._slug(finding.get("alert", "finding"))
+            cvss = finding.get("cvss") or {}
+            base_score = cvss.get("base_score", 0.0)
+
+            rule = rules.get(rule_id)
+            if rule is None:
+                rule = {
+                    "id": rule_id,
+                    "name": finding.get("alert", rule_id),
+                    "shortDescription": {"text": finding.get("alert", rule_id)},
+                    "fullDescription": {"text": finding.get("description") or finding.get("alert", rule_id)},
+                    "help": {"text": finding.get("explanation") or finding.get("description") or ""},
+                    "properties": {"tags": ["security"] + ([finding["cwe"]] if finding.get("cwe") else []),
+                                   "security-severity": "0.0"}
+                }
+                rules[rule_id] = rule
+            if base_score > float(rule["properties"]["security-severity"]):
+                rule["properties"]["security-severity"] = f"{base_score:.1f}"
+
+            result = {
+                "ruleId": rule_id,
+                "level": self._level(cvss.get("environmental_score", base_score), finding.get("risk")),
+                "message": {"text": self._message(finding)},
+                "locations": [self._location(finding)],
+                "partialFingerprints": {"redeyeFingerprint/v1": finding.get("fingerprint", "")},
+                "properties": {
+                    "risk": finding.get("risk"),
+                    "source": finding.get("source"),
+                    "cvssV3_1": cvss.get("vector"),
+                    "cvssV3_1BaseScore": cvss.get("base_score"),
+                    "cvssV3_1EnvironmentalScore": cvss.get("environmental_score"),
+                    "cvssV4_0": cvss.get("v4_vector"),
+                    "reachable": finding.get("reachable"),
+                    "confirmed": (finding.get("poc") or {}).get("status") == "confirmed"
+                }
+            }
+            if finding.get("status") == "risk_accepted" and finding.get("exception"):
+                exception = finding["exception"]
+                expires = exception["expires_at"]
+                result["suppressions"] = [{
+                    "kind": "external",
+                    "status": "accepted",
+                    "guid": exception["exception_id"],
+                    "justification": exception["justification"],
+                    "properties": {"approvedBy": exception.get("approved_by"),
+                                   "expiresAt": expires.date().isoformat() if hasattr(expires, "date") else expires}
+                }]
+            results.append(result)
+
+        rule_list = list(rules.values())
+        rule_index = {rule["id"]: i for i, rule in enumerate(rule_list)}
+        for result in results:
+            result["ruleIndex"] = rule_index[result["ruleId"]]
+
+        return {
+            "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+            "version": "2.1.0",
+            "runs": [{
+                "tool": {"driver": {"name": TOOL_NAME, "version": TOOL_VERSION, "rules": rule_list}},
+                "automationDetails": {"id": f"redeye/{scan.get('scan_id', '')}"},
+                "properties": {"target": scan.get("target")},
+                "results": results
+            }]
+        }
+
+    def _location(self, finding: Dict[str, Any]) -> Dict[str, Any]:
+        if finding.get("file"):
+            location = {"physicalLocation": {"artifactLocation": {"uri": finding["file"].replace("\\", "/")}}}
+            if finding.get("line"):
+                location["physicalLocation"]["region"] = {"startLine": int(finding["line"])}
+                if finding.get("code"):
+                    location["physicalLocation"]["region"]["snippet"] = {"text": finding["code"]}
+            return location
+        # DAST findings have no source location: point at the tested URL
+        return {"physicalLocation": {"artifactLocation": {"uri": finding.get("url") or "unknown"}}}
+
+    def _message(self, finding: Dict[str, Any]) -> str:
+        message = finding.get("description") or finding.get("alert", "")
+        cvss = finding.get("cvss")
+        if cvss:
+            message += f" (CVSS {cvss['base_score']} base / {cvss['environmental_score']} environmental)"
+        if finding.get("param"):
+            message += f" Parameter: {finding['param']}."
+        return message
+
+    def _level(self, score: float, risk: str) -> str:
+        if score:
+            return "error" if score >= 7.0 else "warning" if score >= 4.0 else "note"
+        return {"High": "error", "Medium": "warning"}.get(risk, "note")
+
+    def _slug(self, label: str) -> str:
+        return re.sub(r"[^a-z0-9]+", "-", label.lower()).strip("-") or "finding"
+
+
+sarif_exporter = SarifExporter()
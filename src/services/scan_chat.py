@@ -0,0 +1,187 @@
+import json
+import os
+import re
+from datetime import datetime
+from typing import List, Dict, Any, Optional
+from langchain_core.messages import HumanMessage, AIMessage
+from src.database import db
+from src.rag_engine import rag_service
+from src.services.findings import current_scan_id
+from src.services.workspace import workspace_manager
+
+
+MAX_HISTORY_MESSAGES = 20
+MAX_CONTEXT_FINDINGS = 40
+CODE_CONTEXT_LINES = 15
+
+
+class ScanChatService:
+    """
+    ScanChatService answers follow-up questions about a completed scan
+    ("why is line 42 vulnerable?", "give me a Go version of this fix").
+
+    Each turn grounds the chat agent in the stored scan: findings (CVSS, reachability
+    trace, PoC/fuzz evidence), source around the lines the question refers to,
+    fix verification verdicts, the agent report and RAG past solutions.
+    The conversation is persisted on the scan (`chat`) and replayed as memory.
+    """
+
+    async def ask(self, scan: Dict[str, Any], message: str) -> Dict[str, Any]:
+        from src.agent import chat_executor
+
+        async with workspace_manager.use(scan["target"]) as root_dir:
+            context, grounding = self.build_context(scan, message, root_dir)
+        past_solutions = await self._past_solutions(message, grounding["findings"], scan)
+        if past_solutions:
+            context += "\n\n## Similar past cases (RAG)\n" + "\n---\n".join(past_solutions)
+        grounding["rag"] = len(past_solutions)
+
+        # Agent tools (confirm_exploit, verify_fix) resolve the workspace through the current scan
+        token = current_scan_id.set(scan["scan_id"])
+        try:
+            result = await chat_executor.ainvoke({
+                "input": message,
+                "chat_history": self.history(scan),
+                "scan_context": context
+            })
+        finally:
+            current_scan_id.reset(token)
+
+        now = datetime.utcnow()
+        messages = [
+            {"role": "user", "content": message, "created_at": now},
+            {"role": "assistant", "content": result["output"], "created_at": now, "grounding": grounding},
+        ]
+        await db.append_chat_messages(scan["scan_id"], messages)
+        print(f"💬 [ScanChat] {scan['scan_id'][:8]}: answered ({len(grounding['findings'])} findings, "
+              f"{len(grounding['code'])} code excerpts, {grounding['rag']} past cases)")
+        return {"scan_id": scan["scan_id"], "reply": result["output"], "grounding": grounding, "messages": messages}
+
+    def history(self, scan: Dict[str, Any]) -> list:
+        """Last turns of the persisted conversation as LangChain messages."""
+        history = []
+        for message in (scan.get("chat") or [])[-MAX_HISTORY_MESSAGES:]:
+            cls = HumanMessage if message["role"] == "user" else AIMessage
+            history.append(cls(content=message["content"]))
+        return history
+
+    def build_context(self, scan: Dict[str, Any], question: str, root_dir: Optional[str] = None):
+        """Returns (Markdown context for the prompt, grounding summary {findings, code})."""
+        findings = sorted(scan.get("findings") or [], key=lambda f: -(f.get("cvss") or {}).get("base_score", 0))
+        referenced = self.referenced_findings(findings, question)
+        if not referenced and not self._line_numbers(question) and not self._mentions_file(question.lower()):
+            # Follow-ups ("give me a Go version of this fix") stay on the findings of the previous answer
+            previous = next((m.get("grounding") for m in reversed(scan.get("chat") or []) if m.get("grounding")), None)
+            fingerprints = (previous or {}).get("findings") or []
+            referenced = [f for f in findings if f.get("fingerprint") in fingerprints]
+
+        sections = [
+            f"Target: {scan['target']}",
+            f"Scan: {scan['scan_id']} ({scan.get('status')}), {len(findings)} finding(s)",
+            "",
+            "## Findings",
+            json.dumps([self._compact(f, full=f in referenced) for f in findings[:MAX_CONTEXT_FINDINGS]], indent=1, default=str),
+        ]
+
+        excerpts = self.code_excerpts(root_dir, referenced, question) if root_dir else []
+        if excerpts:
+            sections += ["", "## Source around the lines in question"]
+            sections += [f"{e['file']} (lines {e['start']}-{e['end']}):\n```\n{e['code']}\n```" for e in excerpts]
+
+        fixes = scan.get("fix_verifications") or []
+        if fixes:
+            sections += ["", "## Fix verifications"]
+            sections += [f"- {f['file']}: {f['verdict']} ({f.get('reason', '')})" for f in fixes]
+
+        if isinstance(scan.get("agent_response"), str) and scan["agent_response"]:
+            sections += ["", "## Scan report (excerpt)", scan["agent_response"][:3000]]
+
+        grounding = {
+            "findings": [f.get("fingerprint") for f in referenced],
+            "code": [f"{e['file']}:{e['start']}-{e['end']}" for e in excerpts],
+        }
+        return "\n".join(sections), grounding
+
+    def referenced_findings(self, findings: List[Dict[str, Any]], question: str) -> List[Dict[str, Any]]:
+        """Findings the question points at: by fingerprint, file name and/or line number."""
+        lines = self._line_numbers(question)
+        text = question.lower()
+        matches = []
+        for finding in findings:
+            file = (finding.get("file") or "").lower()
+            if finding.get("fingerprint") and finding["fingerprint"] in question:
+                matches.append(finding)
+                continue
+            file_hit = bool(file) and (file in text or os.path.basename(file) in text)
+            line_hit = finding.get("line") in lines
+            if (file_hit and (line_hit or not lines)) or (line_hit and not self._mentions_file(text)):
+                matches.append(finding)
+        return matches[:5]
+
+    def code_excerpts(self, root_dir: str, findings: List[Dict[str, Any]], question: str) -> List[Dict[str, Any]]:
+        """Source lines around referenced findings (or a file:line the question names directly)."""
+        targets = [(f["file"], f.get("line") or 1) for f in findings if f.get("file")]
+        if not targets:
+            for path in re.findall(r"[\w./-]+\.(?:go|py|js|ts|java|php|rb)\b", question):
+                line = next(iter(self._line_numbers(question)), 1)
+                targets.append((path.removeprefix("./"), line))
+
+        excerpts, seen = [], set()
+        for file, line in targets[:3]:
+            path = os.path.realpath(os.path.join(root_dir, file))
+            if (file, line) in seen or not path.startswith(os.path.realpath(root_dir) + os.sep) or not os.path.isfile(path):
+                continue
+            seen.add((file, line))
+            with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                lines = f.read().split("\n")
+            start = max(1, line - CODE_CONTEXT_LINES)
+            end = min(len(lines), line + CODE_CONTEXT_LINES)
+            code = "\n".join(f"{n:>5} {'>' if n == line else ' '} {lines[n - 1]}" for n in range(start, end + 1))
+            excerpts.append({"file": file, "start": start, "end": end, "code": code})
+        return excerpts
+
+    async def _past_solutions(self, question: str, fingerprints: List[str], scan: Dict[str, Any]) -> List[str]:
+        referenced = [f for f in scan.get("findings") or [] if f.get("fingerprint") in fingerprints]
+        query = " ".join(f"{f.get('alert')} {f.get('cwe') or ''}" for f in referenced) or question
+        try:
+            results = await rag_service.search_similar_alerts(query)
+            return [doc.page_content[:300] for doc in results]
+        except Exception as e:
+            print(f"⚠️ [ScanChat] RAG search failed: {e}")
+            return []
+
+    def _compact(self, finding: Dict[str, Any], full: bool = False) -> Dict[str, Any]:
+        cvss = finding.get("cvss") or {}
+        compact = {
+            "fingerprint": finding.get("fingerprint"),
+            "alert": finding.get("alert"),
+            "cwe": finding.get("cwe"),
+            "location": f"{finding['file']}:{finding.get('line')}" if finding.get("file") else finding.get("url"),
+            "cvss": f"{cvss.get('base_score')} ({cvss.get('vector')})" if cvss else None,
+        }
+        if "reachable" in finding:
+            compact["reachable"] = finding["reachable"]
+            compact["call_path"] = finding.get("call_path")
+        for kind in ("poc", "fuzz"):
+            if finding.get(kind):
+                compact[kind] = {"status": finding[kind].get("status"), "evidence": str(finding[kind].get("evidence", ""))[:300]}
+        # Full detail only for the findings the question is about, to keep the prompt small
+        if full:
+            compact["code"] = (finding.get("code") or "")[:1500]
+            compact["description"] = (finding.get("description") or "")[:500]
+            if cvss.get("rationale"):
+                compact["cvss_rationale"] = cvss["rationale"]
+        return compact
+
+    def _line_numbers(self, question: str) -> set:
+        numbers = set()
+        for pattern in (r"(?i)\blines?\s*#?(\d+)", r"\bL(\d+)\b", r"(?:라인|줄)\s*(\d+)", r"\.\w+:(\d+)\b",
+                        r"(\d+)\s*(?:번째 줄|번 줄|행)"):
+            numbers |= {int(n) for n in re.findall(pattern, question)}
+        return numbers
+
+    def _mentions_file(self, text: str) -> bool:
+        return bool(re.search(r"[\w-]+\.(?:go|py|js|ts|java|php|rb)\b", text))
+
+
+scan_chat_service = ScanChatService()
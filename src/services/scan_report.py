@@ -0,0 +1,514 @@
+import base64
+import html
+import re
+from datetime import datetime
+from typing import List, Dict, Any, Optional
+from src.services.pdf import PdfDocument, PdfFlow, can_render
+
+
+SEVERITY_ORDER = ["Critical", "High", "Medium", "Low", "None"]
+SEVERITY_COLORS = {"Critical": "#7b1fa2", "High": "#c62828", "Medium": "#ef6c00", "Low": "#1565c0", "None": "#607d8b"}
+RISK_TO_SEVERITY = {"High": "High", "Medium": "Medium", "Low": "Low", "Informational": "None"}
+
+DEFAULT_BRANDING = {
+    "company_name": "RedEye",
+    "primary_color": "#c62828",
+    "logo_data_uri": None,
+    "footer": "Generated by RedEye Security Scanner",
+    "classification": "CONFIDENTIAL",
+}
+
+def severity_of(finding: Dict[str, Any]) -> str:
+    """CVSS base severity, or the scanner risk label for findings not scored yet."""
+    cvss = finding.get("cvss") or {}
+    return cvss.get("base_severity") or RISK_TO_SEVERITY.get(finding.get("risk"), "Medium")
+
+
+METHODOLOGY = {
+    "surface": ("Attack surface inventory", "Routes, authentication, parameters, uploads, outbound calls, database access "
+                "and deserialization sites were extracted from the source before scanning."),
+    "sast": ("Static analysis (SAST)", "Source files were matched against line patterns and language rule packs "
+             "(Go concurrency/TOCTOU/unsafe rules), each mapped to a CWE."),
+    "dast": ("Dynamic analysis (DAST)", "The running target was spidered and actively scanned with OWASP ZAP."),
+    "reachability": ("Reachability analysis", "A call graph from entrypoints (routes, main, CLI, consumers) marks which "
+                     "findings sit on a live call path; unreachable findings are scored lower."),
+    "confirmation": ("Dynamic confirmation", "Selected findings were replayed with generated proof-of-concept requests "
+                     "or native Go fuzz tests against a sandboxed copy of the application."),
+    "fixes": ("Fix verification", "Suggested fixes were applied to a sandbox copy and the project's own test suite was "
+              "run before and after; fixes that broke the build or tests were withheld."),
+    "exceptions": ("Risk exceptions", "Findings shipped knowingly were covered by an exception with a justification, "
+                   "compensating controls and an expiry date, approved by the security team; expired exceptions reopen the finding."),
+    "cvss": ("Scoring", "Every finding carries a CVSS v3.1 vector (base, temporal and environmental) derived from its "
+             "CWE, route authentication, dynamic confirmation and reachability, plus an analyst override when triaged."),
+}
+
+
+class ScanReportBuilder:
+    """
+    ScanReportBuilder turns a completed scan into a stakeholder report
+    (executive summary, findings by severity with code, fixes as diffs,
+    trend against the previous scan, methodology) and renders it as a
+    self-contained HTML file or a locally generated PDF.
+    """
+
+    def build(
+        self,
+        scan: Dict[str, Any],
+        previous: Optional[Dict[str, Any]] = None,
+        branding: Optional[Dict[str, Any]] = None
+    ) -> Dict[str, Any]:
+        findings = [self._finding_view(f) for f in scan.get("findings") or []]
+        findings.sort(key=lambda f: (SEVERITY_ORDER.index(f["severity"]), -f["score"]))
+        by_severity = {s: [f for f in findings if f["severity"] == s] for s in SEVERITY_ORDER}
+        fixes = scan.get("fix_verifications") or []
+
+        return {
+            "branding": {**DEFAULT_BRANDING, **{k: v for k, v in (branding or {}).items() if v}},
+            "project": self._project_name(scan["target"]),
+            "target": scan["target"],
+            "scan_id": scan["scan_id"],
+            "scan_date": self._date(scan.get("created_at")),
+            "generated_at": datetime.utcnow().strftime("%Y-%m-%d %H:%M UTC"),
+            "summary": self._executive_summary(findings, by_severity, fixes),
+            "findings_by_severity": {s: items for s, items in by_severity.items() if items},
+            # Rejected fixes never leave the scan record (same rule as PR comments)
+            "fixes": [self._fix_view(f) for f in fixes if f.get("verdict") != "rejected" and f.get("diff")],
+            "withheld_fixes": sum(1 for f in fixes if f.get("verdict") == "rejected"),
+            "trend": self._trend(scan, previous, findings),
+            "exceptions": {
+                "accepted": [f for f in findings if f["exception"] and f["exception"]["status"] == "approved"],
+                "reopened": [f for f in findings if f["reopened"]],
+            },
+            "methodology": self._methodology(scan, findings, fixes),
+        }
+
+    # --- Sections ---
+    def _executive_summary(self, findings, by_severity, fixes) -> Dict[str, Any]:
+        counts = {s: len(items) for s, items in by_severity.items()}
+        overall = next((s for s in SEVERITY_ORDER if counts[s]), "None")
+        confirmed = sum(1 for f in findings if f["confirmed"])
+        reachable = sum(1 for f in findings if f["reachable"] is True)
+        accepted = sum(1 for f in fixes if f.get("verdict") == "accepted")
+
+        if not findings:
+            headline = "No security findings were identified in this scan."
+        else:
+            urgent = counts["Critical"] + counts["High"]
+            headline = (f"{len(findings)} finding(s) identified; overall risk is {overall}. "
+                        f"{urgent} require prompt remediation (Critical/High).")
+        highlights = []
+        if confirmed:
+            highlights.append(f"{confirmed} finding(s) were confirmed exploitable in a sandbox.")
+        if reachable:
+            highlights.append(f"{reachable} finding(s) are reachable from an application entrypoint.")
+        if accepted:
+            highlights.append(f"{accepted} verified fix(es) are ready to apply without test regressions.")
+        excepted = sum(1 for f in findings if f["exception"] and f["exception"]["status"] == "approved")
+        if excepted:
+            highlights.append(f"{excepted} finding(s) are accepted risks under an approved exception.")
+        overdue = sum(1 for f in findings if f["sla"] and f["sla"]["status"] in ("overdue", "escalated"))
+        if overdue:
+            highlights.append(f"{overdue} finding(s) are past their remediation SLA.")
+        reopened = sum(1 for f in findings if f["reopened"])
+        if reopened:
+            highlights.append(f"{reopened} finding(s) were reopened because their risk exception expired or was revoked.")
+
+        return {
+            "overall_risk": overall,
+            "total": len(findings),
+            "counts": counts,
+            "headline": headline,
+            "highlights": highlights,
+            "top_findings": findings[:5],
+        }
+
+    def _trend(self, scan, previous, findings) -> Optional[Dict[str, Any]]:
+        if not previous:
+            return None
+        before = {f.get("fingerprint"): f for f in previous.get("findings") or []}
+        now = {f["fingerprint"] for f in findings}
+        counts_before = {s: 0 for s in SEVERITY_ORDER}
+        for f in before.values():
+            counts_before[self._severity(f)] += 1
+        counts_now = {s: sum(1 for f in findings if f["severity"] == s) for s in SEVERITY_ORDER}
+        return {
+            "previous_scan_id": previous["scan_id"],
+            "previous_date": self._date(previous.get("created_at")),
+            "new": [f for f in findings if f["fingerprint"] not in before],
+            "resolved": [self._finding_view(f) for fp, f in before.items() if fp not in now],
+            "persisting": sum(1 for fp in now if fp in before),
+            "delta": {s: counts_now[s] - counts_before[s] for s in SEVERITY_ORDER},
+        }
+
+    def _methodology(self, scan, findings, fixes) -> List[Dict[str, str]]:
+        sources = {f["source"] for f in findings}
+        steps = []
+        if scan.get("surface"):
+            steps.append("surface")
+        if "github.com" in scan["target"] or "sast" in sources:
+            steps.append("sast")
+        if "github.com" not in scan["target"] or "dast" in sources:
+            steps.append("dast")
+        if any(f["reachable"] is not None for f in findings):
+            steps.append("reachability")
+        if any(f["confirmation"] for f in findings):
+            steps.append("confirmation")
+        if fixes:
+            steps.append("fixes")
+        if any(f["exception"] for f in findings):
+            steps.append("exceptions")
+        steps.append("cvss")
+        return [{"title": METHODOLOGY[s][0], "description": METHODOLOGY[s][1]} for s in steps]
+
+    # --- Views ---
+    def _finding_view(self, finding: Dict[str, Any]) -> Dict[str, Any]:
+        cvss = finding.get("cvss") or {}
+        confirmation = None
+        for kind in ("poc", "fuzz"):
+            if (finding.get(kind) or {}).get("status") == "confirmed":
+                confirmation = f"Confirmed by {'PoC replay' if kind == 'poc' else 'fuzzing'}: " + \
+                               str(finding[kind].get("evidence") or "")[:300]
+        return {
+            "fingerprint": finding.get("fingerprint", ""),
+            "title": finding.get("alert", "Finding"),
+            "cwe": finding.get("cwe"),
+            "severity": self._severity(finding),
+            "score": cvss.get("base_score", 0.0),
+            "vector": cvss.get("vector"),
+            "location": f"{finding['file']}:{finding.get('line')}" if finding.get("file") else finding.get("url", ""),
+            "code": finding.get("code") or finding.get("evidence") or "",
+            "description": finding.get("description") or "",
+            "solution": finding.get("solution") or "",
+            "source": finding.get("source", ""),
+            "reachable": finding.get("reachable"),
+            "confirmed": confirmation is not None,
+            "confirmation": confirmation,
+            "exception": self._exception_view(finding.get("exception")),
+            "reopened": bool(finding.get("reopened")),
+            "sla": self._sla_view(finding.get("sla")),
+        }
+
+    def _sla_view(self, sla: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        if not sla:
+            return None
+        days = sla["days_remaining"]
+        when = f"{-days} day(s) overdue" if days < 0 else f"due in {days} day(s)"
+        return {
+            "status": sla["status"],
+            "due": self._date(sla["due_at"])[:10],
+            "label": f"SLA {sla['sla_days']}d: {'paused (risk accepted)' if sla['status'] == 'exception' else when}",
+        }
+
+    def _exception_view(self, exception: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        if not exception or exception["status"] not in ("approved", "expired", "revoked"):
+            return None
+        return {
+            "status": exception["status"],
+            "expires": self._date(exception["expires_at"])[:10],
+            "approved_by": exception.get("approved_by") or "",
+            "justification": exception["justification"],
+            "compensating_controls": exception["compensating_controls"],
+        }
+
+    def _fix_view(self, fix: Dict[str, Any]) -> Dict[str, Any]:
+        regression = fix.get("regression_test") or {}
+        return {
+            "file": fix["file"],
+            "verdict": fix["verdict"],
+            "reason": fix.get("reason", ""),
+            "diff": fix["diff"],
+            "regression_test": regression.get("status"),
+        }
+
+    def _severity(self, finding: Dict[str, Any]) -> str:
+        return severity_of(finding)
+
+    def _project_name(self, target: str) -> str:
+        return target.rstrip("/").removesuffix(".git").split("/")[-1] or target
+
+    def _date(self, value) -> str:
+        if isinstance(value, datetime):
+            return value.strftime("%Y-%m-%d %H:%M UTC")
+        return str(value or "unknown")
+
+    # --- HTML ---
+    def to_html(self, report: Dict[str, Any]) -> str:
+        e = html.escape
+        brand = report["branding"]
+        color = brand["primary_color"]
+        summary = report["summary"]
+
+        logo = f'<img class="logo" src="{e(brand["logo_data_uri"])}" alt="">' if brand.get("logo_data_uri") else ""
+        counts = "".join(
+            f'<div class="count"><span class="sev" style="background:{SEVERITY_COLORS[s]}">{s}</span>'
+            f'<strong>{summary["counts"][s]}</strong></div>'
+            for s in SEVERITY_ORDER if s != "None" or summary["counts"][s]
+        )
+        highlights = "".join(f"<li>{e(h)}</li>" for h in summary["highlights"])
+
+        findings_html = []
+        for severity, items in report["findings_by_severity"].items():
+            findings_html.append(f'<h3><span class="sev" style="background:{SEVERITY_COLORS[severity]}">{severity}</span> '
+                                 f'{len(items)} finding(s)</h3>')
+            for f in items:
+                meta = [f"CVSS {f['score']}", e(f["cwe"] or "no CWE"), e(f["source"].upper())]
+                if f["reachable"] is not None:
+                    meta.append("reachable" if f["reachable"] else "not reachable")
+                if f["sla"]:
+                    sla_class = "confirmed" if f["sla"]["status"] in ("overdue", "escalated") else ""
+                    meta.append(f'<span class="{sla_class}">{e(f["sla"]["label"])} (due {e(f["sla"]["due"])})</span>')
+                findings_html.append(
+                    f'<div class="finding"><div class="title">{e(f["title"])}</div>'
+                    f'<div class="meta">{" &middot; ".join(meta)} &middot; <code>{e(f["location"])}</code></div>'
+                    + (f'<div class="vector">{e(f["vector"])}</div>' if f["vector"] else "")
+                    + (f'<p>{e(f["description"])}</p>' if f["description"] else "")
+                    + (f'<p class="confirmed">{e(f["confirmation"])}</p>' if f["confirmation"] else "")
+                    + (f'<p class="accepted">Risk accepted until {e(f["exception"]["expires"])} '
+                       f'(approved by {e(f["exception"]["approved_by"])})</p>'
+                       if f["exception"] and f["exception"]["status"] == "approved" else "")
+                    + (f'<p class="confirmed">Reopened: risk exception {e(f["exception"]["status"])}</p>' if f["reopened"] else "")
+                    + (f'<pre>{e(f["code"])}</pre>' if f["code"] else "")
+                    + (f'<p><em>Remediation:</em> {e(f["solution"])}</p>' if f["solution"] else "")
+                    + "</div>"
+                )
+
+        fixes_html = []
+        for fix in report["fixes"]:
+            diff_lines = "".join(
+                f'<span class="{"add" if l.startswith("+") and not l.startswith("+++") else "del" if l.startswith("-") and not l.startswith("---") else ""}">{e(l)}</span>\n'
+                for l in fix["diff"].rstrip("\n").split("\n")
+            )
+            test = f" &middot; regression test: {e(fix['regression_test'])}" if fix["regression_test"] else ""
+            fixes_html.append(f'<div class="fix"><div class="title">{e(fix["file"])}</div>'
+                              f'<div class="meta">{e(fix["verdict"])}: {e(fix["reason"])}{test}</div>'
+                              f'<pre class="diff">{diff_lines}</pre></div>')
+        if report["withheld_fixes"]:
+            fixes_html.append(f'<p class="meta">{report["withheld_fixes"]} suggested fix(es) were withheld because they failed verification.</p>')
+
+        exceptions_html = []
+        for f in report["exceptions"]["accepted"]:
+            exception = f["exception"]
+            controls = "".join(f"<li>{e(c)}</li>" for c in exception["compensating_controls"])
+            exceptions_html.append(
+                f'<div class="fix"><div class="title">{e(f["title"])} ({f["severity"]})</div>'
+                f'<div class="meta"><code>{e(f["location"])}</code> &middot; approved by {e(exception["approved_by"])} '
+                f'&middot; expires {e(exception["expires"])}</div>'
+                f'<p>{e(exception["justification"])}</p><p><em>Compensating controls:</em></p><ul>{controls}</ul></div>'
+            )
+        for f in report["exceptions"]["reopened"]:
+            exceptions_html.append(f'<p class="confirmed">Reopened ({e(f["exception"]["status"])} exception): '
+                                   f'{e(f["title"])} <code>{e(f["location"])}</code></p>')
+
+        trend = report["trend"]
+        if trend:
+            deltas = " ".join(f'{s}: {"+" if d > 0 else ""}{d}' for s, d in trend["delta"].items() if d)
+            trend_html = (
+                f'<p>Compared with scan <code>{e(trend["previous_scan_id"][:8])}</code> ({e(trend["previous_date"])}): '
+                f'<strong>{len(trend["new"])}</strong> new, <strong>{len(trend["resolved"])}</strong> resolved, '
+                f'<strong>{trend["persisting"]}</strong> persisting.</p>'
+                + (f'<p class="meta">Change by severity: {e(deltas)}</p>' if deltas else "")
+                + "".join(f'<li>New: {e(f["title"])} ({f["severity"]}) <code>{e(f["location"])}</code></li>' for f in trend["new"][:20])
+                + "".join(f'<li>Resolved: {e(f["title"])} <code>{e(f["location"])}</code></li>' for f in trend["resolved"][:20])
+            )
+        else:
+            trend_html = "<p>No previous completed scan of this project to compare with.</p>"
+
+        methodology = "".join(f"<dt>{e(m['title'])}</dt><dd>{e(m['description'])}</dd>" for m in report["methodology"])
+
+        return f"""<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Security Report - {e(report['project'])}</title>
+<style>
+body {{ font-family: -apple-system, "Segoe UI", Roboto, sans-serif; color: #1f2933; margin: 0; }}
+header {{ border-top: 8px solid {color}; padding: 2rem 3rem 1rem; display: flex; align-items: center; gap: 1.5rem; }}
+header .logo {{ max-height: 56px; }}
+header h1 {{ margin: 0; font-size: 1.8rem; }}
+header .classification {{ margin-left: auto; color: {color}; font-weight: 700; letter-spacing: 0.1em; }}
+main {{ padding: 0 3rem 2rem; max-width: 1000px; }}
+h2 {{ color: {color}; border-bottom: 1px solid #d9e2ec; padding-bottom: 0.3rem; margin-top: 2.5rem; }}
+.meta {{ color: #52606d; font-size: 0.85rem; }}
+.counts {{ display: flex; gap: 1rem; margin: 1rem 0; }}
+.count {{ background: #f0f4f8; border-radius: 6px; padding: 0.6rem 1rem; display: flex; gap: 0.6rem; align-items: center; }}
+.sev {{ color: #fff; border-radius: 4px; padding: 2px 8px; font-size: 0.8rem; font-weight: 600; }}
+.finding, .fix {{ border: 1px solid #d9e2ec; border-left: 4px solid {color}; border-radius: 4px; padding: 0.8rem 1rem; margin: 0.8rem 0; page-break-inside: avoid; }}
+.title {{ font-weight: 600; }}
+.vector {{ font-family: monospace; font-size: 0.8rem; color: #52606d; }}
+.confirmed {{ color: #c62828; font-weight: 600; }}
+.accepted {{ color: #ef6c00; font-weight: 600; }}
+pre {{ background: #f0f4f8; padding: 0.6rem; overflow-x: auto; font-size: 0.8rem; }}
+pre.diff .add {{ color: #2e7d32; }}
+pre.diff .del {{ color: #c62828; }}
+dt {{ font-weight: 600; margin-top: 0.6rem; }}
+footer {{ padding: 1rem 3rem; color: #7b8794; font-size: 0.8rem; border-top: 1px solid #d9e2ec; }}
+</style>
+</head>
+<body>
+<header>{logo}<div><h1>Security Assessment: {e(report['project'])}</h1>
+<div class="meta">{e(brand['company_name'])} &middot; {e(report['target'])} &middot; scan {e(report['scan_id'])} &middot; {e(report['scan_date'])}</div></div>
+<div class="classification">{e(brand.get('classification') or '')}</div></header>
+<main>
+<h2>Executive Summary</h2>
+<p><strong>{e(summary['headline'])}</strong></p>
+<div class="counts">{counts}</div>
+<ul>{highlights}</ul>
+<h2>Findings by Severity</h2>
+{chr(10).join(findings_html) or '<p>No findings.</p>'}
+<h2>Recommended Fixes</h2>
+{chr(10).join(fixes_html) or '<p>No verified fixes were recorded for this scan.</p>'}
+<h2>Risk Exceptions</h2>
+{chr(10).join(exceptions_html) or '<p>No findings are covered by a risk exception.</p>'}
+<h2>Trend</h2>
+{trend_html}
+<h2>Methodology</h2>
+<dl>{methodology}</dl>
+</main>
+<footer>{e(brand['footer'])} &middot; Report generated {e(report['generated_at'])}</footer>
+</body>
+</html>
+"""
+
+    # --- PDF ---
+    def pdf_unsupported_text(self, report: Dict[str, Any]) -> Optional[str]:
+        """
+        First report string the PDF writer has no glyphs for (e.g. Korean agent responses), or None.
+        Such reports are refused instead of being rendered with '?' in place of every character.
+        """
+        pending: List[Any] = [report]
+        while pending:
+            value = pending.pop()
+            if isinstance(value, dict):
+                pending.extend(v for k, v in value.items() if k != "logo_data_uri")
+            elif isinstance(value, (list, tuple)):
+                pending.extend(value)
+            elif isinstance(value, str) and not can_render(value):
+                return value
+        return None
+
+    def to_pdf(self, report: Dict[str, Any]) -> bytes:
+        brand = report["branding"]
+        color = brand["primary_color"]
+        summary = report["summary"]
+        document = PdfDocument(title=f"Security Assessment: {report['project']}", author=brand["company_name"])
+        flow = PdfFlow(
+            document,
+            header=f"{brand['company_name']}  |  {report['project']}  |  {brand.get('classification') or ''}",
+            footer=f"{brand['footer']}  |  {report['generated_at']}",
+            accent=color
+        )
+
+        logo = self._jpeg_logo(brand.get("logo_data_uri"))
+        if logo:
+            document.image(595 - 50 - 90, flow.y - 40, 90, 40, logo)
+        flow.heading(f"Security Assessment: {report['project']}", 20)
+        flow.paragraph(f"{report['target']}  -  scan {report['scan_id']}  -  {report['scan_date']}", 9, color="#52606d")
+        flow.space(10)
+
+        flow.heading("Executive Summary", 14, color)
+        flow.paragraph(summary["headline"], 11, "bold")
+        flow.space(4)
+        flow.ensure(24)
+        x = 50
+        for severity in SEVERITY_ORDER[:-1]:
+            label = f"{severity}: {summary['counts'][severity]}"
+            width = document.text_width(label, "bold", 9) + 12
+            document.rect(x, flow.y - 16, width, 16, SEVERITY_COLORS[severity])
+            document.text(x + 6, flow.y - 12, label, "bold", 9, "#ffffff")
+            x += width + 8
+        flow.space(26)
+        for highlight in summary["highlights"]:
+            flow.bullet(highlight)
+
+        flow.heading("Findings by Severity", 14, color)
+        for severity, items in report["findings_by_severity"].items():
+            for f in items:
+                flow.ensure(60)
+                offset = flow.badge(f"{severity} {f['score']}", SEVERITY_COLORS[severity])
+                document.text(50 + offset + 8, flow.y - 9, f["title"][:80], "bold", 10)
+                flow.space(18)
+                flow.paragraph(f"{f['cwe'] or 'no CWE'}  |  {f['source'].upper()}  |  {f['location']}", 8, color="#52606d")
+                if f["sla"]:
+                    late = f["sla"]["status"] in ("overdue", "escalated")
+                    flow.paragraph(f"{f['sla']['label']} (due {f['sla']['due']})", 8, "bold" if late else "regular",
+                                   "#c62828" if late else "#52606d")
+                if f["vector"]:
+                    flow.paragraph(f["vector"], 8, "mono", "#52606d")
+                if f["description"]:
+                    flow.paragraph(f["description"], 9)
+                if f["confirmation"]:
+                    flow.paragraph(f["confirmation"], 9, "bold", "#c62828")
+                if f["exception"] and f["exception"]["status"] == "approved":
+                    flow.paragraph(f"Risk accepted until {f['exception']['expires']} (approved by {f['exception']['approved_by']})",
+                                   9, "bold", "#ef6c00")
+                if f["reopened"]:
+                    flow.paragraph(f"Reopened: risk exception {f['exception']['status']}", 9, "bold", "#c62828")
+                if f["code"]:
+                    flow.space(2)
+                    flow.code(f["code"])
+                flow.space(8)
+
+        flow.heading("Recommended Fixes", 14, color)
+        if not report["fixes"]:
+            flow.paragraph("No verified fixes were recorded for this scan.")
+        for fix in report["fixes"]:
+            flow.ensure(50)
+            flow.paragraph(fix["file"], 10, "bold")
+            test = f"  |  regression test: {fix['regression_test']}" if fix["regression_test"] else ""
+            flow.paragraph(f"{fix['verdict']}: {fix['reason']}{test}", 8, color="#52606d")
+            flow.code(fix["diff"], line_colors={"+++": "#243b53", "---": "#243b53", "+": "#2e7d32", "-": "#c62828"})
+        if report["withheld_fixes"]:
+            flow.paragraph(f"{report['withheld_fixes']} suggested fix(es) were withheld because they failed verification.", 9,
+                           color="#52606d")
+
+        flow.heading("Risk Exceptions", 14, color)
+        if not report["exceptions"]["accepted"] and not report["exceptions"]["reopened"]:
+            flow.paragraph("No findings are covered by a risk exception.")
+        for f in report["exceptions"]["accepted"]:
+            exception = f["exception"]
+            flow.ensure(50)
+            flow.paragraph(f"{f['title']} ({f['severity']})  {f['location']}", 10, "bold")
+            flow.paragraph(f"Approved by {exception['approved_by']}  |  expires {exception['expires']}", 8, color="#52606d")
+            flow.paragraph(exception["justification"], 9)
+            for control in exception["compensating_controls"]:
+                flow.bullet(control)
+            flow.space(4)
+        for f in report["exceptions"]["reopened"]:
+            flow.bullet(f"Reopened ({f['exception']['status']} exception): {f['title']} {f['location']}")
+
+        flow.heading("Trend", 14, color)
+        trend = report["trend"]
+        if trend:
+            flow.paragraph(f"Compared with scan {trend['previous_scan_id'][:8]} ({trend['previous_date']}): "
+                           f"{len(trend['new'])} new, {len(trend['resolved'])} resolved, {trend['persisting']} persisting.")
+            deltas = ", ".join(f"{s} {'+' if d > 0 else ''}{d}" for s, d in trend["delta"].items() if d)
+            if deltas:
+                flow.paragraph(f"Change by severity: {deltas}", 9, color="#52606d")
+            for f in trend["new"][:20]:
+                flow.bullet(f"New: {f['title']} ({f['severity']}) {f['location']}")
+            for f in trend["resolved"][:20]:
+                flow.bullet(f"Resolved: {f['title']} {f['location']}")
+        else:
+            flow.paragraph("No previous completed scan of this project to compare with.")
+
+        flow.heading("Methodology", 14, color)
+        for step in report["methodology"]:
+            flow.ensure(40)
+            flow.paragraph(step["title"], 10, "bold")
+            flow.paragraph(step["description"], 9)
+            flow.space(4)
+
+        return document.render()
+
+    def _jpeg_logo(self, data_uri: Optional[str]) -> Optional[bytes]:
+        """PDF logos are embedded as-is, which works for JPEG only (PNG/SVG show in HTML)."""
+        match = re.match(r"data:image/jpe?g;base64,(.+)$", data_uri or "", re.DOTALL)
+        if not match:
+            return None
+        try:
+            return base64.b64decode(match.group(1))
+        except ValueError:
+            return None
+
+
+scan_report_builder = ScanReportBuilder()
@@ -0,0 +1,189 @@
+import math
+from datetime import datetime, timedelta
+from typing import List, Dict, Any, Optional
+from src.database import db
+from src.services.scan_report import SEVERITY_ORDER, severity_of
+from src.services.risk_exceptions import exception_manager
+from src.services.notifications import notifier
+from src.services.workspace import org_of
+
+
+DEFAULT_SLA_POLICY = {
+    "days": {"Critical": 7, "High": 30, "Medium": 90, "Low": 180},   # remediation window per severity
+    "due_soon_days": 3,          # warn this many days before the due date
+    "escalate_after_days": 7,    # overdue this long → escalation notification
+}
+
+# Worst first (dashboards, notification order)
+SLA_STATUSES = ["escalated", "overdue", "due_soon", "on_track", "exception"]
+
+
+class SlaTracker:
+    """
+    SlaTracker computes remediation SLAs for findings: the due date is the first-detected timestamp
+    (`first_seen`, carried from scan to scan) plus the organization's window for the finding's severity.
+
+    Status: on_track → due_soon → overdue → escalated (overdue for `escalate_after_days`).
+    Findings under an approved risk exception are `exception` (clock paused) until it expires.
+    Escalation notifications go out once per finding and level through the notification channels.
+    """
+    def policy(self, org_settings: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+        custom = (org_settings or {}).get("sla") or {}
+        return {**DEFAULT_SLA_POLICY, **custom, "days": {**DEFAULT_SLA_POLICY["days"], **(custom.get("days") or {})}}
+
+    def evaluate(
+        self,
+        finding: Dict[str, Any],
+        policy: Dict[str, Any],
+        detected_fallback: Optional[datetime] = None,
+        now: Optional[datetime] = None
+    ) -> Optional[Dict[str, Any]]:
+        """SLA of one finding, or None when its severity has no SLA or its detection time is unknown."""
+        severity = severity_of(finding)
+        days = policy["days"].get(severity)
+        first_seen = finding.get("first_seen") or detected_fallback
+        if not days or not isinstance(first_seen, datetime):
+            return None
+
+        now = now or datetime.utcnow()
+        due_at = first_seen + timedelta(days=days)
+        remaining = (due_at - now).total_seconds() / 86400
+        if finding.get("status") == "risk_accepted":
+            status = "exception"
+        elif remaining < -policy["escalate_after_days"]:
+            status = "escalated"
+        elif remaining < 0:
+            status = "overdue"
+        elif remaining <= policy["due_soon_days"]:
+            status = "due_soon"
+        else:
+            status = "on_track"
+        return {
+            "severity": severity,
+            "sla_days": days,
+            "first_seen": first_seen,
+            "due_at": due_at,
+            "days_remaining": math.floor(remaining),
+            "status": status,
+        }
+
+    def annotate(self, findings: List[Dict[str, Any]], policy: Dict[str, Any], detected_fallback: Optional[datetime] = None):
+        now = datetime.utcnow()
+        for finding in findings:
+            sla = self.evaluate(finding, policy, detected_fallback, now)
+            if sla:
+                finding["sla"] = sla
+        return findings
+
+    async def annotate_scan(self, scan: Dict[str, Any], org_settings: Optional[Dict[str, Any]] = None) -> List[Dict[str, Any]]:
+        """
+        SLA of every finding of a stored scan under its organization's policy.
+        Apply risk exceptions first so accepted findings pause instead of going overdue.
+        Findings stored before `first_seen` existed fall back to the scan's creation time.
+        """
+        findings = scan.get("findings") or []
+        if not findings:
+            return findings
+        if org_settings is None and db.db is not None:
+            org_settings = await db.get_org_settings(org_of(scan["target"]))
+        return self.annotate(findings, self.policy(org_settings), scan.get("created_at"))
+
+    async def latest_scans(self, org: str) -> List[Dict[str, Any]]:
+        """Latest completed scan of every target of the organization, with exceptions and SLAs applied."""
+        org_settings = await db.get_org_settings(org)
+        scans = await db.list_org_latest_scans(org)
+        for scan in scans:
+            await exception_manager.apply_to_scan(scan)
+            await self.annotate_scan(scan, org_settings)
+        return scans
+
+    def dashboard(self, scans: List[Dict[str, Any]], policy: Dict[str, Any]) -> Dict[str, Any]:
+        """Overdue dashboard: counts by status/severity, per-target breakdown and the late findings (most overdue first)."""
+        by_status = {status: 0 for status in SLA_STATUSES}
+        by_severity = {s: {status: 0 for status in SLA_STATUSES} for s in SEVERITY_ORDER if s in policy["days"]}
+        targets, late = [], []
+        for scan in scans:
+            counts = {status: 0 for status in SLA_STATUSES}
+            for finding in scan.get("findings") or []:
+                sla = finding.get("sla")
+                if not sla:
+                    continue
+                counts[sla["status"]] += 1
+                by_status[sla["status"]] += 1
+                by_severity.setdefault(sla["severity"], {status: 0 for status in SLA_STATUSES})[sla["status"]] += 1
+                if sla["status"] in ("escalated", "overdue", "due_soon"):
+                    late.append(self._late_view(scan, finding))
+            targets.append({"target": scan["target"], "scan_id": scan["scan_id"], "scanned_at": scan.get("created_at"), **counts})
+
+        late.sort(key=lambda f: f["days_remaining"])
+        targets.sort(key=lambda t: (-t["escalated"], -t["overdue"], -t["due_soon"], t["target"]))
+        tracked = sum(by_status.values()) - by_status["exception"]
+        return {
+            "policy": policy,
+            "totals": by_status,
+            "compliance_percent": round(100 * (tracked - by_status["overdue"] - by_status["escalated"]) / tracked) if tracked else 100,
+            "by_severity": by_severity,
+            "targets": targets,
+            "late_findings": late,
+        }
+
+    async def escalate(self) -> int:
+        """
+        Sends due-soon / overdue / escalated digests for every organization with notification channels.
+        Each finding is notified once per level. Returns the number of notifications recorded.
+        """
+        recorded = 0
+        for org_settings in await db.list_orgs_with_notifications():
+            org = org_settings["org"]
+            scans = await self.latest_scans(org)
+            already = {(n["target"], n["fingerprint"], n["level"]) for n in await db.list_sla_notifications(org)}
+
+            for level in ("escalated", "overdue", "due_soon"):
+                pending = [
+                    self._late_view(scan, f) for scan in scans for f in scan.get("findings") or []
+                    if (f.get("sla") or {}).get("status") == level and (scan["target"], f["fingerprint"], level) not in already
+                ]
+                if not pending:
+                    continue
+                pending.sort(key=lambda f: f["days_remaining"])
+                title, text = self._digest(org, level, pending)
+                results = await notifier.send(org_settings, f"sla.{level}", title, text, {"findings": pending})
+                if results and any(r["ok"] for r in results):
+                    now = datetime.utcnow()
+                    await db.save_sla_notifications([
+                        {"org": org, "target": f["target"], "fingerprint": f["fingerprint"], "level": level, "sent_at": now}
+                        for f in pending
+                    ])
+                    recorded += len(pending)
+        return recorded
+
+    def _late_view(self, scan: Dict[str, Any], finding: Dict[str, Any]) -> Dict[str, Any]:
+        sla = finding["sla"]
+        return {
+            "target": scan["target"],
+            "scan_id": scan["scan_id"],
+            "fingerprint": finding.get("fingerprint"),
+            "title": finding.get("alert", "Finding"),
+            "severity": sla["severity"],
+            "location": f"{finding['file']}:{finding.get('line')}" if finding.get("file") else finding.get("url", ""),
+            "status": sla["status"],
+            "due_at": sla["due_at"].isoformat() if isinstance(sla["due_at"], datetime) else sla["due_at"],
+            "days_remaining": sla["days_remaining"],
+            "owners": finding.get("owners") or [],
+        }
+
+    def _digest(self, org: str, level: str, findings: List[Dict[str, Any]]):
+        heading = {"escalated": "Escalation: findings far past their remediation SLA",
+                   "overdue": "Findings past their remediation SLA",
+                   "due_soon": "Findings due for remediation soon"}[level]
+        lines = []
+        for f in findings[:25]:
+            when = f"{-f['days_remaining']}d overdue" if f["days_remaining"] < 0 else f"due in {f['days_remaining']}d"
+            owners = f" → {', '.join(f['owners'])}" if f["owners"] else ""
+            lines.append(f"• [{f['severity']}] {f['title']} — {f['target']} `{f['location']}` ({when}){owners}")
+        if len(findings) > 25:
+            lines.append(f"… and {len(findings) - 25} more")
+        return f"{heading} ({org}, {len(findings)})", "\n".join(lines)
+
+
+sla_tracker = SlaTracker()
@@ -0,0 +1,510 @@
+import json
+import os
+import re
+import uuid
+import zlib
+from typing import List, Dict, Any, Optional
+import yaml
+
+
+# STRIDE-per-element (Microsoft SDL): which categories apply to which DFD element type
+STRIDE_PER_ELEMENT = {
+    "external_entity": ["Spoofing", "Repudiation"],
+    "process": ["Spoofing", "Tampering", "Repudiation", "Information Disclosure", "Denial of Service", "Elevation of Privilege"],
+    "data_store": ["Tampering", "Information Disclosure", "Denial of Service"],
+    "data_flow": ["Tampering", "Information Disclosure", "Denial of Service"],
+}
+
+# Finding CWE -> STRIDE categories it evidences
+CWE_TO_STRIDE = {
+    "CWE-22": ["Information Disclosure", "Tampering"],
+    "CWE-78": ["Elevation of Privilege", "Tampering"],
+    "CWE-79": ["Tampering", "Spoofing"],
+    "CWE-89": ["Tampering", "Information Disclosure", "Elevation of Privilege"],
+    "CWE-94": ["Elevation of Privilege", "Tampering"],
+    "CWE-242": ["Tampering", "Elevation of Privilege"],
+    "CWE-362": ["Denial of Service", "Tampering"],
+    "CWE-367": ["Tampering", "Elevation of Privilege"],
+    "CWE-401": ["Denial of Service"],
+    "CWE-489": ["Information Disclosure"],
+    "CWE-502": ["Elevation of Privilege", "Tampering"],
+    "CWE-798": ["Spoofing", "Information Disclosure"],
+    "CWE-843": ["Tampering", "Information Disclosure"],
+}
+
+# Generic threat text per (element type, category). {name} = element name.
+THREAT_TEMPLATES = {
+    ("external_entity", "Spoofing"): ("{name} may be impersonated", "Authenticate every request (OAuth/session/JWT) and bind sessions to the client."),
+    ("external_entity", "Repudiation"): ("{name} may deny having performed an action", "Log security-relevant actions with user identity and timestamp."),
+    ("process", "Spoofing"): ("Callers of {name} may be spoofed", "Require authentication on all non-public entrypoints."),
+    ("process", "Tampering"): ("Input to {name} may be tampered with to alter its behaviour", "Validate and sanitize all input; use parameterized queries and safe APIs."),
+    ("process", "Repudiation"): ("Actions in {name} may not be attributable", "Keep audit logs for state-changing operations."),
+    ("process", "Information Disclosure"): ("{name} may leak sensitive data (errors, debug output, files)", "Disable debug mode, return generic errors, restrict file access."),
+    ("process", "Denial of Service"): ("{name} may be exhausted by expensive or malformed requests", "Apply rate limits, timeouts and request size limits."),
+    ("process", "Elevation of Privilege"): ("An attacker may execute code or gain privileges in {name}", "Avoid eval/deserialization of untrusted data; run with least privilege."),
+    ("data_store", "Tampering"): ("Data in {name} may be modified by unauthorized parties", "Use least-privilege DB credentials and integrity checks."),
+    ("data_store", "Information Disclosure"): ("Data in {name} may be read by unauthorized parties", "Encrypt at rest, restrict network access, never use default credentials."),
+    ("data_store", "Denial of Service"): ("{name} may become unavailable or fill up", "Set quotas, retention policies and monitoring."),
+    ("data_flow", "Tampering"): ("Traffic on {name} may be modified in transit", "Use TLS for every hop, including internal ones."),
+    ("data_flow", "Information Disclosure"): ("Traffic on {name} may be sniffed", "Use TLS and avoid secrets in URLs/query strings."),
+    ("data_flow", "Denial of Service"): ("{name} may be flooded or interrupted", "Rate-limit and set timeouts on the flow."),
+}
+
+DATASTORE_IMAGES = re.compile(r"(?i)mongo|postgres|mysql|mariadb|redis|elasticsearch|opensearch|cassandra|sqlite|minio|rabbitmq|kafka")
+EXTERNAL_ENV_KEYS = re.compile(r"(?i)^(?P<name>[A-Z0-9]+?)_(?:API_KEY|TOKEN|URL|URI|ENDPOINT|CLIENT_ID)$")
+DEFAULT_PASSWORD = re.compile(r"(?i)(?:PASSWORD|PASS|SECRET)[A-Z_]*=\$\{[^:}]+:-(?P<value>[^}]+)\}|(?:PASSWORD|PASS)\s*[:=]\s*['\"]?(?P<plain>password|admin|changeme|secret|123456)['\"]?\s*$")
+
+
+class ThreatModelGenerator:
+    """
+    ThreatModelGenerator builds a STRIDE threat model for a project.
+
+    1. Data-flow diagram: external entities, processes (app / compose services),
+       data stores (DB access, compose images, connection strings) and flows
+       (HTTP routes, DB access, outbound calls), grouped by trust boundary.
+    2. STRIDE per element, with severity raised by concrete evidence
+       (unauthenticated routes, uploads, deserialization, default passwords).
+    3. Threats are linked to existing findings by CWE → STRIDE category and location.
+    4. Output: structured model + Markdown + Mermaid + OWASP Threat Dragon (v2) JSON.
+
+    The deterministic baseline is always produced; an optional LLM pass may add threats.
+    """
+
+    def generate(
+        self,
+        project_name: str,
+        surface: Optional[Dict[str, Any]] = None,
+        findings: Optional[List[Dict[str, Any]]] = None,
+        root_dir: Optional[str] = None
+    ) -> Dict[str, Any]:
+        surface = surface or {}
+        findings = findings or []
+        config = self._read_config(root_dir) if root_dir else {"services": [], "env_keys": [], "default_passwords": []}
+
+        elements, flows = self._build_dfd(project_name, surface, config)
+        threats = self._apply_stride(elements, flows, surface, config, findings)
+
+        model = {
+            "project": project_name,
+            "elements": elements,
+            "flows": flows,
+            "threats": threats,
+            "summary": {
+                "elements": len(elements),
+                "flows": len(flows),
+                "threats": len(threats),
+                "high": sum(1 for t in threats if t["severity"] == "High"),
+                "linked_findings": sum(len(t["findings"]) for t in threats)
+            }
+        }
+        model["markdown"] = self.to_markdown(model)
+        model["mermaid"] = self.to_mermaid(model)
+        model["threat_dragon"] = self.to_threat_dragon(model)
+        return model
+
+    async def enrich_with_llm(self, model: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Optional LLM pass: asks the model for additional, project-specific threats.
+        Failures leave the deterministic baseline untouched.
+        """
+        try:
+            from langchain_openai import ChatOpenAI
+            llm = ChatOpenAI(model="gpt-4o-mini", temperature=0)
+            element_list = "\n".join(f"- {e['id']} ({e['type']}): {e['name']}" for e in model["elements"])
+            prompt = (
+                "You are a threat modeling expert. Given this data-flow diagram, list up to 5 additional "
+                "STRIDE threats that are NOT generic. Respond ONLY with a JSON array of objects with keys "
+                "element_id, category, title, mitigation, severity (High/Medium/Low).\n\n" + element_list
+            )
+            response = await llm.ainvoke(prompt)
+            match = re.search(r"\[[\s\S]*\]", response.content)
+            extra = json.loads(match.group(0)) if match else []
+            known_ids = {e["id"] for e in model["elements"]}
+            for item in extra:
+                if item.get("element_id") not in known_ids:
+                    continue
+                model["threats"].append({
+                    "id": f"T{len(model['threats']) + 1:03d}",
+                    "element_id": item["element_id"],
+                    "category": item.get("category", "Tampering"),
+                    "title": item.get("title", ""),
+                    "mitigation": item.get("mitigation", ""),
+                    "severity": item.get("severity", "Medium"),
+                    "evidence": [],
+                    "findings": [],
+                    "source": "llm"
+                })
+            model["summary"]["threats"] = len(model["threats"])
+            model["markdown"] = self.to_markdown(model)
+            model["threat_dragon"] = self.to_threat_dragon(model)
+        except Exception as e:
+            print(f"⚠️ [ThreatModel] LLM enrichment skipped: {e}")
+        return model
+
+    # --- Inputs ---
+    def _read_config(self, root_dir: str) -> Dict[str, Any]:
+        config = {"services": [], "env_keys": [], "default_passwords": []}
+        for root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in {".git", "node_modules", "vendor"}]
+            for file in files:
+                path = os.path.join(root, file)
+                rel_path = os.path.relpath(path, root_dir)
+                if re.match(r"(?:docker-)?compose[\w.-]*\.ya?ml$", file):
+                    config["services"].extend(self._compose_services(path, rel_path))
+                if file.startswith(".env") or re.match(r"(?:docker-)?compose[\w.-]*\.ya?ml$", file):
+                    try:
+                        with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                            for i, line in enumerate(f):
+                                key = line.split("=", 1)[0].strip().lstrip("- ").strip()
+                                if EXTERNAL_ENV_KEYS.match(key):
+                                    config["env_keys"].append(key)
+                                if DEFAULT_PASSWORD.search(line.strip()):
+                                    config["default_passwords"].append({"file": rel_path, "line": i + 1, "code": line.strip()})
+                    except Exception:
+                        continue
+        config["env_keys"] = sorted(set(config["env_keys"]))
+        return config
+
+    def _compose_services(self, path: str, rel_path: str) -> List[Dict[str, Any]]:
+        try:
+            with open(path, "r", encoding="utf-8") as f:
+                data = yaml.safe_load(f) or {}
+        except Exception:
+            return []
+        services = []
+        for name, spec in (data.get("services") or {}).items():
+            spec = spec or {}
+            services.append({
+                "name": name,
+                "image": spec.get("image", ""),
+                "ports": [str(p) for p in spec.get("ports", [])],
+                "depends_on": list(spec.get("depends_on", []) if isinstance(spec.get("depends_on"), list) else (spec.get("depends_on") or {}).keys()),
+                "file": rel_path
+            })
+        return services
+
+    # --- Data-flow diagram ---
+    def _build_dfd(self, project_name: str, surface: Dict[str, Any], config: Dict[str, Any]):
+        elements: List[Dict[str, Any]] = []
+        flows: List[Dict[str, Any]] = []
+
+        def add(element_id, element_type, name, boundary, **extra):
+            if not any(e["id"] == element_id for e in elements):
+                elements.append({"id": element_id, "type": element_type, "name": name, "boundary": boundary, **extra})
+
+        add("user", "external_entity", "User / Client", "Internet")
+
+        app_id = "app"
+        add(app_id, "process", project_name, "Application")
+
+        for service in config["services"]:
+            service_id = self._service_id(service["name"])
+            is_store = bool(DATASTORE_IMAGES.search(service["image"] or service["name"]))
+            add(service_id, "data_store" if is_store else "process", service["name"],
+                "Data" if is_store else "Application", image=service["image"], ports=service["ports"],
+                compose_file=service["file"])
+            if service["ports"] and not is_store:
+                flows.append(self._flow("user", service_id, f"HTTP :{service['ports'][0]}", public=True))
+            for dependency in service["depends_on"]:
+                flows.append(self._flow(service_id, self._service_id(dependency), "depends_on"))
+
+        # Routes: one flow per route from the user into the app
+        for route in surface.get("routes", []):
+            flows.append(self._flow(
+                "user", app_id, f"{route['method']} {route['path']}",
+                public=True, auth=route["auth"], file_upload=route["file_upload"],
+                location=f"{route['file']}:{route['line']}", handler=route["handler"]
+            ))
+
+        # Data stores from DB access points
+        if surface.get("db_access"):
+            kinds = set()
+            for access in surface["db_access"]:
+                code = access["code"].lower()
+                if any(k in code for k in ("find_one", "insert_one", "update_one", "mongo", "aggregate")):
+                    kinds.add("MongoDB")
+                elif any(k in code for k in ("execute", "query", "sqlite", "sql.open", "gorm", "jdbc")):
+                    kinds.add("SQL Database")
+            for kind in sorted(kinds) or ["Database"]:
+                store_id = f"store_{kind.lower().replace(' ', '_')}"
+                add(store_id, "data_store", kind, "Data")
+                flows.append(self._flow(app_id, store_id, "DB queries"))
+
+        # External services from outbound calls and env keys (OPENAI_API_KEY -> OpenAI, GITHUB_TOKEN -> GitHub)
+        externals = {m.group("name").title() for m in map(EXTERNAL_ENV_KEYS.match, config["env_keys"]) if m}
+        externals -= {"Mongo", "Mongodb", "Database", "Db", "Redis", "Postgres", "App", "Frontend", "Webhook"}
+        if surface.get("outbound_calls") and not externals:
+            externals.add("External API")
+        for name in sorted(externals):
+            ext_id = f"ext_{name.lower().replace(' ', '_')}"
+            add(ext_id, "external_entity", name, "Internet")
+            flows.append(self._flow(app_id, ext_id, "Outbound HTTPS"))
+
+        return elements, flows
+
+    def _service_id(self, name: str) -> str:
+        return "svc_" + re.sub(r"\W", "_", name)
+
+    def _flow(self, source: str, target: str, name: str, **extra) -> Dict[str, Any]:
+        return {"id": f"flow_{source}_{target}_{zlib.crc32(name.encode('utf-8')) % 10000}", "type": "data_flow",
+                "source": source, "target": target, "name": name, **extra}
+
+    # --- STRIDE ---
+    def _apply_stride(self, elements, flows, surface, config, findings) -> List[Dict[str, Any]]:
+        threats = []
+
+        def add_threat(element, category, severity="Low", evidence=None):
+            title, mitigation = THREAT_TEMPLATES[(element["type"], category)]
+            threats.append({
+                "id": f"T{len(threats) + 1:03d}",
+                "element_id": element["id"],
+                "category": category,
+                "title": title.format(name=element["name"]),
+                "mitigation": mitigation,
+                "severity": severity,
+                "evidence": evidence or [],
+                "findings": [],
+                "source": "baseline"
+            })
+
+        unauthenticated = [r for r in surface.get("routes", []) if not r["auth"]]
+        uploads = surface.get("file_uploads", [])
+        deserialization = surface.get("deserialization", [])
+
+        for element in elements:
+            for category in STRIDE_PER_ELEMENT[element["type"]]:
+                severity, evidence = "Low", []
+                if element["id"] == "app":
+                    if category == "Spoofing" and unauthenticated:
+                        severity = "High" if len(unauthenticated) > 3 else "Medium"
+                        evidence = [f"{r['method']} {r['path']} has no authentication" for r in unauthenticated[:10]]
+                    elif category == "Elevation of Privilege" and deserialization:
+                        severity = "High"
+                        evidence = [f"Deserialization at {d['file']}:{d['line']}" for d in deserialization[:10]]
+                    elif category == "Denial of Service" and uploads:
+                        severity = "Medium"
+                        evidence = [f"File upload at {u['file']}:{u['line']}" for u in uploads[:10]]
+                    elif category == "Tampering" and surface.get("routes"):
+                        severity = "Medium"
+                credentials = self._default_credentials_for(element, config)
+                if credentials and category in ("Spoofing", "Information Disclosure"):
+                    severity = "High"
+                    evidence = [f"Default credential at {p['file']}:{p['line']}" for p in credentials]
+                add_threat(element, category, severity, evidence)
+
+        # Transport threats only for routes that are exposed without auth or accept uploads
+        for flow in flows:
+            if not flow.get("public") or (flow.get("auth", False) and not flow.get("file_upload")):
+                continue
+            for category in STRIDE_PER_ELEMENT["data_flow"]:
+                severity = "Medium" if category != "Denial of Service" or flow.get("file_upload") else "Low"
+                add_threat(flow, category, severity, [f"Handler {flow['handler']} at {flow['location']}"] if flow.get("handler") else [])
+
+        self._link_findings(threats, elements, flows, findings)
+        return threats
+
+    def _default_credentials_for(self, element, config) -> List[Dict[str, Any]]:
+        """Compose defaults belong to that file's services; .env defaults to the data stores."""
+        if element["type"] == "process" and element.get("compose_file"):
+            return [p for p in config["default_passwords"] if p["file"] == element["compose_file"]]
+        if element["type"] == "data_store":
+            return [p for p in config["default_passwords"] if not re.search(r"compose[\w.-]*\.ya?ml$", p["file"])]
+        return []
+
+    def _link_findings(self, threats, elements, flows, findings):
+        """
+        Attach findings to the application's threats with a matching STRIDE category.
+        If the finding sits in a route handler's file, the route is recorded on the link.
+        """
+        routes_by_file: Dict[str, List[str]] = {}
+        for flow in flows:
+            if flow.get("location"):
+                routes_by_file.setdefault(flow["location"].split(":")[0], []).append(flow["name"])
+
+        for finding in findings:
+            categories = CWE_TO_STRIDE.get(finding.get("cwe") or "", [])
+            if not categories:
+                continue
+            reference = {
+                "fingerprint": finding.get("fingerprint"),
+                "alert": finding.get("alert"),
+                "risk": finding.get("risk"),
+                "location": f"{finding.get('file') or finding.get('url', '')}:{finding.get('line', '')}".rstrip(":"),
+                "routes": routes_by_file.get(finding.get("file"), [])
+            }
+
+            for threat in threats:
+                if threat["element_id"] == "app" and threat["category"] in categories:
+                    threat["findings"].append(reference)
+                    if finding.get("risk") == "High":
+                        threat["severity"] = "High"
+                    elif finding.get("risk") == "Medium" and threat["severity"] == "Low":
+                        threat["severity"] = "Medium"
+
+    # --- Renderers ---
+    def to_markdown(self, model: Dict[str, Any]) -> str:
+        names = {e["id"]: e["name"] for e in model["elements"]}
+        names.update({f["id"]: f"{names.get(f['source'], f['source'])} → {names.get(f['target'], f['target'])}: {f['name']}" for f in model["flows"]})
+
+        lines = [
+            f"# Threat Model: {model['project']}",
+            "",
+            f"- Elements: {model['summary']['elements']}, Data flows: {model['summary']['flows']}",
+            f"- Threats: {model['summary']['threats']} (High: {model['summary']['high']}), linked findings: {model['summary']['linked_findings']}",
+            "",
+            "## Data-Flow Diagram",
+            "",
+            "```mermaid",
+            self.to_mermaid(model),
+            "```",
+            "",
+            "## Elements",
+            "",
+            "| ID | Type | Name | Trust Boundary |",
+            "|----|------|------|----------------|",
+        ]
+        for e in model["elements"]:
+            lines.append(f"| {e['id']} | {e['type']} | {e['name']} | {e['boundary']} |")
+
+        lines += ["", "## Threats (STRIDE)", "", "| ID | Element | Category | Severity | Threat | Mitigation | Findings |",
+                  "|----|---------|----------|----------|--------|------------|----------|"]
+        order = {"High": 0, "Medium": 1, "Low": 2}
+        for t in sorted(model["threats"], key=lambda t: (order.get(t["severity"], 3), t["id"])):
+            linked = "<br>".join(
+                f"{f['alert']} ({f['location']})" + (f" via {', '.join(f['routes'])}" if f.get("routes") else "")
+                for f in t["findings"]
+            ) or "-"
+            lines.append(
+                f"| {t['id']} | {names.get(t['element_id'], t['element_id'])} | {t['category']} | {t['severity']} | "
+                f"{t['title']} | {t['mitigation']} | {linked} |"
+            )
+
+        evidence = [t for t in model["threats"] if t["evidence"]]
+        if evidence:
+            lines += ["", "## Evidence", ""]
+            for t in evidence:
+                lines.append(f"- **{t['id']}** " + "; ".join(t["evidence"]))
+        return "\n".join(lines)
+
+    def to_mermaid(self, model: Dict[str, Any]) -> str:
+        lines = ["flowchart LR"]
+        boundaries: Dict[str, List[Dict[str, Any]]] = {}
+        for e in model["elements"]:
+            boundaries.setdefault(e["boundary"], []).append(e)
+
+        for boundary, members in boundaries.items():
+            lines.append(f"  subgraph {boundary.replace(' ', '_')}[\"{boundary} (trust boundary)\"]")
+            for e in members:
+                label = e["name"].replace('"', "'")
+                if e["type"] == "external_entity":
+                    lines.append(f"    {e['id']}[/\"{label}\"/]")
+                elif e["type"] == "data_store":
+                    lines.append(f"    {e['id']}[(\"{label}\")]")
+                else:
+                    lines.append(f"    {e['id']}((\"{label}\"))")
+            lines.append("  end")
+
+        seen = set()
+        for f in model["flows"]:
+            label = f["name"].replace('"', "'").replace("|", "/")
+            if not f.get("auth", True) and f.get("public"):
+                label += " (no auth)"
+            key = (f["source"], f["target"], label)
+            if key in seen:
+                continue
+            seen.add(key)
+            lines.append(f"  {f['source']} -->|\"{label}\"| {f['target']}")
+        return "\n".join(lines)
+
+    def to_threat_dragon(self, model: Dict[str, Any]) -> Dict[str, Any]:
+        """OWASP Threat Dragon v2 model JSON (importable via 'Open existing model')."""
+        shape = {"external_entity": ("actor", "tm.Actor"), "process": ("process", "tm.Process"), "data_store": ("store", "tm.Store")}
+        cell_ids = {e["id"]: str(uuid.uuid5(uuid.NAMESPACE_URL, f"{model['project']}/{e['id']}")) for e in model["elements"]}
+        columns = {"Internet": 50, "Application": 350, "Data": 650}
+        rows: Dict[str, int] = {}
+
+        def td_threats(element_id):
+            return [{
+                "id": str(uuid.uuid5(uuid.NAMESPACE_URL, f"{model['project']}/{t['id']}")),
+                "title": t["title"],
+                "status": "Open",
+                "severity": t["severity"],
+                "type": t["category"],
+                "description": t["title"] + ("\nFindings: " + ", ".join(f["alert"] for f in t["findings"]) if t["findings"] else ""),
+                "mitigation": t["mitigation"],
+                "modelType": "STRIDE",
+                "number": int(t["id"][1:]),
+                "score": ""
+            } for t in model["threats"] if t["element_id"] == element_id]
+
+        cells = []
+        for z, e in enumerate(model["elements"]):
+            shape_name, td_type = shape[e["type"]]
+            row = rows.get(e["boundary"], 0)
+            rows[e["boundary"]] = row + 1
+            threats = td_threats(e["id"])
+            cells.append({
+                "shape": shape_name,
+                "id": cell_ids[e["id"]],
+                "zIndex": z + 1,
+                "position": {"x": columns.get(e["boundary"], 350), "y": 50 + row * 150},
+                "size": {"width": 120, "height": 80 if shape_name != "process" else 120},
+                "attrs": {"text": {"text": e["name"]}},
+                "data": {
+                    "type": td_type,
+                    "name": e["name"],
+                    "description": "",
+                    "outOfScope": False,
+                    "reasonOutOfScope": "",
+                    "threats": threats,
+                    "hasOpenThreats": bool(threats)
+                }
+            })
+
+        for z, f in enumerate(model["flows"]):
+            if f["source"] not in cell_ids or f["target"] not in cell_ids:
+                continue
+            threats = td_threats(f["id"])
+            cells.append({
+                "shape": "flow",
+                "id": str(uuid.uuid5(uuid.NAMESPACE_URL, f"{model['project']}/{f['id']}/{z}")),
+                "zIndex": len(model["elements"]) + z + 1,
+                "source": {"cell": cell_ids[f["source"]]},
+                "target": {"cell": cell_ids[f["target"]]},
+                "labels": [f["name"]],
+                "data": {
+                    "type": "tm.Flow",
+                    "name": f["name"],
+                    "description": "",
+                    "outOfScope": False,
+                    "reasonOutOfScope": "",
+                    "protocol": "HTTP",
+                    "isEncrypted": False,
+                    "isPublicNetwork": bool(f.get("public")),
+                    "threats": threats,
+                    "hasOpenThreats": bool(threats)
+                }
+            })
+
+        return {
+            "version": "2.2.0",
+            "summary": {"title": f"{model['project']} (RedEye)", "owner": "RedEye", "description": "Generated by RedEye", "id": 0},
+            "detail": {
+                "contributors": [],
+                "diagrams": [{
+                    "id": 0,
+                    "title": "Data-Flow Diagram",
+                    "diagramType": "STRIDE",
+                    "placeholder": "Generated by RedEye",
+                    "thumbnail": "./public/content/images/thumbnail.stride.jpg",
+                    "version": "2.2.0",
+                    "cells": cells
+                }],
+                "diagramTop": 1,
+                "reviewer": "",
+                "threatTop": len(model["threats"])
+            }
+        }
+
+
+threat_model_generator = ThreatModelGenerator()
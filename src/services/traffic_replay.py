@@ -0,0 +1,314 @@
+import asyncio
+import json
+import re
+import time
+from typing import List, Dict, Any, Optional
+from urllib.parse import urlsplit, urlunsplit, parse_qsl, urlencode
+import httpx
+
+
+MAX_ENTRIES = 300            # recorded requests kept on the scan
+MAX_BODY_BYTES = 64 * 1024
+MAX_REQUESTS = 2000          # replayed (mutated) requests per scan
+CONCURRENCY = 5
+REQUEST_TIMEOUT = 10.0
+STATIC_EXTENSIONS = (".js", ".css", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".woff", ".woff2", ".ttf",
+                     ".map", ".webp", ".mp4", ".webm", ".mp3", ".pdf")
+# Hop-by-hop / recomputed headers are not replayed; HTTP/2 pseudo headers (:authority) neither
+SKIPPED_HEADERS = {"host", "content-length", "connection", "accept-encoding", "transfer-encoding", "keep-alive",
+                   "upgrade", "te", "proxy-connection", "proxy-authorization"}
+
+MARKER = "rdx7"
+SQL_ERRORS = re.compile(
+    r"SQL syntax|mysql_fetch|MySqlException|ORA-\d{5}|PostgreSQL.*ERROR|pq: syntax error|PSQLException|"
+    r"SQLite3?::|sqlite3\.OperationalError|SQLITE_ERROR|Unclosed quotation mark|Microsoft OLE DB|"
+    r"syntax error at or near|near \".*\": syntax error|SQLSTATE\[", re.IGNORECASE)
+STACK_TRACES = re.compile(
+    r"Traceback \(most recent call last\)|goroutine \d+ \[running\]|panic: |at [\w$.]+\([\w]+\.java:\d+\)|"
+    r"System\.\w+Exception|Fatal error: .* on line \d+|node_modules/.*\.js:\d+:\d+")
+
+# (check id, payload builder, detector) - detectors get (response, baseline response, payload)
+CHECKS = [
+    {
+        "id": "sqli",
+        "alert": "SQL Injection",
+        "risk": "High",
+        "cweid": "89",
+        "payloads": lambda value: [f"{value}'\"", f"{value}')"],
+        "detect": lambda r, b, p: _new_match(SQL_ERRORS, r, b),
+        "description": "A quote in the parameter produced a database error: the value is concatenated into a SQL query.",
+        "solution": "Use parameterized queries / prepared statements instead of building SQL from request input.",
+    },
+    {
+        "id": "xss",
+        "alert": "Cross Site Scripting (Reflected)",
+        "risk": "High",
+        "cweid": "79",
+        "payloads": lambda value: [f"{MARKER}<svg/onload=alert(1)>"],
+        "detect": lambda r, b, p: p if p in r.text and "html" in r.headers.get("content-type", "") else None,
+        "description": "The parameter is reflected unencoded into an HTML response, so injected markup runs in the victim's browser.",
+        "solution": "HTML-encode output for its context and set a restrictive Content-Security-Policy.",
+    },
+    {
+        "id": "path_traversal",
+        "alert": "Path Traversal",
+        "risk": "High",
+        "cweid": "22",
+        "payloads": lambda value: ["../../../../../../../../etc/passwd", "..%2f..%2f..%2f..%2f..%2f..%2fetc%2fpasswd",
+                                   "..\\..\\..\\..\\..\\..\\windows\\win.ini"],
+        "detect": lambda r, b, p: _new_match(re.compile(r"root:[x*]:0:0:|\[fonts\]|\[extensions\]"), r, b),
+        "description": "A path built from the parameter escapes the intended directory and returns system files.",
+        "solution": "Resolve the path and verify it stays under the allowed base directory; prefer IDs over file names.",
+    },
+    {
+        "id": "command_injection",
+        "alert": "Remote OS Command Injection",
+        "risk": "High",
+        "cweid": "78",
+        "payloads": lambda value: [f"{value};id", f"{value}|id", f"{value}$(id)"],
+        "detect": lambda r, b, p: _new_match(re.compile(r"uid=\d+\(\w+\) gid=\d+"), r, b),
+        "description": "The parameter reaches a shell: an injected `id` command was executed and its output returned.",
+        "solution": "Do not invoke a shell with request input; pass arguments as a list and validate them against an allow-list.",
+    },
+    {
+        "id": "ssti",
+        "alert": "Server Side Template Injection",
+        "risk": "High",
+        "cweid": "1336",
+        "payloads": lambda value: ["{{7*191}}", "${7*191}", "<%= 7*191 %>"],
+        "detect": lambda r, b, p: "1337" if "1337" in r.text and "1337" not in b.text and p not in r.text else None,
+        "description": "A template expression in the parameter was evaluated on the server (7*191 rendered as 1337).",
+        "solution": "Never compile templates from user input; pass input as template data only.",
+    },
+    {
+        "id": "open_redirect",
+        "alert": "External Redirect",
+        "risk": "Medium",
+        "cweid": "601",
+        "payloads": lambda value: ["https://redeye-redirect.invalid/"] if re.match(r"^(https?:|/)", value or "") else [],
+        "detect": lambda r, b, p: r.headers.get("location") if r.headers.get("location", "").startswith("https://redeye-redirect.invalid") else None,
+        "description": "The parameter controls the redirect target, so links to this site can forward users to any domain.",
+        "solution": "Redirect only to relative paths or to an allow-list of hosts.",
+    },
+    {
+        "id": "error_disclosure",
+        "alert": "Application Error Disclosure",
+        "risk": "Medium",
+        "cweid": "209",
+        "payloads": lambda value: [f"{value}%00{{[\"'"],
+        "detect": lambda r, b, p: _new_match(STACK_TRACES, r, b),
+        "description": "Malformed input makes the application return a stack trace that reveals code paths and versions.",
+        "solution": "Return generic error pages and log details server-side only.",
+    },
+]
+
+
+def _new_match(pattern: re.Pattern, response: httpx.Response, baseline: httpx.Response) -> Optional[str]:
+    """Evidence matched in the mutated response but not in the baseline (replayed original) response."""
+    match = pattern.search(response.text)
+    if match and not pattern.search(baseline.text):
+        return match.group(0)
+    return None
+
+
+class TrafficReplayer:
+    """
+    TrafficReplayer turns recorded browser traffic (HAR upload or `redeye proxy` recording) into
+    the DAST phase: every in-scope request is replayed with its recorded cookies / auth headers,
+    then each parameter (query, form and JSON body fields) is mutated with attack payloads.
+
+    Unlike spidering, this exercises authenticated flows exactly as QA recorded them, and every
+    alert links back to the HAR entry it was derived from (`har_entry`).
+    """
+
+    def parse_har(self, content: str, filename: str = "recording.har", target: Optional[str] = None) -> Dict[str, Any]:
+        """Recorded traffic stored on the scan: {file, target, entries: [{index, method, url, headers, body, ...}]}."""
+        try:
+            log = json.loads(content)["log"]
+        except (json.JSONDecodeError, KeyError, TypeError):
+            raise ValueError("Not a HAR file (expected a JSON object with a `log` section).")
+
+        entries = []
+        for index, entry in enumerate(log.get("entries") or []):
+            request = entry.get("request") or {}
+            url = request.get("url", "")
+            if not url.startswith(("http://", "https://")) or urlsplit(url).path.lower().endswith(STATIC_EXTENSIONS):
+                continue
+            post_data = request.get("postData") or {}
+            body = post_data.get("text") or ""
+            if not body and post_data.get("params"):
+                body = urlencode([(p.get("name", ""), p.get("value", "")) for p in post_data["params"]])
+            entries.append({
+                "index": index,
+                "method": request.get("method", "GET").upper(),
+                "url": url,
+                "headers": {h["name"]: h.get("value", "") for h in request.get("headers") or []
+                            if h.get("name") and not h["name"].startswith(":") and h["name"].lower() not in SKIPPED_HEADERS},
+                "mime": post_data.get("mimeType", ""),
+                "body": body[:MAX_BODY_BYTES],
+                "status": (entry.get("response") or {}).get("status"),
+                "started": entry.get("startedDateTime"),
+                "page": entry.get("pageref"),
+            })
+
+        # Scope: the target's host, or the most recorded host (third-party calls are not tested)
+        hosts = [urlsplit(e["url"]).netloc for e in entries]
+        host = urlsplit(target).netloc if target and "://" in target else max(set(hosts), key=hosts.count) if hosts else ""
+        in_scope = [e for e in entries if urlsplit(e["url"]).netloc == host]
+        if not in_scope:
+            raise ValueError("The HAR file has no requests to the target host.")
+
+        scheme = urlsplit(in_scope[0]["url"]).scheme
+        print(f"🎞️ [Traffic] {filename}: {len(in_scope)} in-scope request(s) to {host} "
+              f"({len(entries) - len(in_scope)} third-party skipped)")
+        return {
+            "file": filename,
+            "target": target or f"{scheme}://{host}",
+            "host": host,
+            "entries": in_scope[:MAX_ENTRIES],
+            "truncated": len(in_scope) > MAX_ENTRIES,
+        }
+
+    async def replay(self, traffic: Dict[str, Any], extra_headers: Optional[Dict[str, str]] = None) -> List[Dict[str, Any]]:
+        """Replays and mutates the recorded requests. Returns alerts in the ZAP alert shape (+ har_entry)."""
+        started = time.time()
+        semaphore = asyncio.Semaphore(CONCURRENCY)
+        budget = {"left": MAX_REQUESTS, "sent": 0, "expired": 0}
+        alerts: List[Dict[str, Any]] = []
+
+        seen = set()
+        unique = []
+        for entry in traffic["entries"]:
+            # One replay per endpoint + parameter set (QA sessions repeat the same calls)
+            key = (entry["method"], urlsplit(entry["url"]).path, tuple(sorted(p for p, _ in self.insertion_points(entry))))
+            if key not in seen:
+                seen.add(key)
+                unique.append(entry)
+
+        async with httpx.AsyncClient(verify=False, follow_redirects=False, timeout=REQUEST_TIMEOUT) as client:
+            async def run(entry):
+                async with semaphore:
+                    alerts.extend(await self._test_entry(client, entry, traffic, extra_headers or {}, budget))
+            await asyncio.gather(*(run(entry) for entry in unique))
+
+        if budget["expired"]:
+            print(f"⚠️ [Traffic] {budget['expired']} recorded request(s) were rejected on replay (401/403): session expired?")
+        print(f"🎞️ [Traffic] Replayed {len(unique)} endpoint(s) with {budget['sent']} request(s) "
+              f"in {time.time() - started:.1f}s: {len(alerts)} alert(s)")
+        return alerts
+
+    def insertion_points(self, entry: Dict[str, Any]) -> List[tuple]:
+        """[(location:name, value)] for query parameters, form fields and top-level JSON fields."""
+        points = [(f"query:{name}", value) for name, value in parse_qsl(urlsplit(entry["url"]).query, keep_blank_values=True)]
+        if "json" in entry["mime"]:
+            try:
+                body = json.loads(entry["body"])
+                if isinstance(body, dict):
+                    points += [(f"json:{k}", str(v)) for k, v in body.items() if isinstance(v, (str, int, float))]
+            except json.JSONDecodeError:
+                pass
+        elif "x-www-form-urlencoded" in entry["mime"] or (entry["body"] and not entry["mime"]):
+            points += [(f"form:{name}", value) for name, value in parse_qsl(entry["body"], keep_blank_values=True)]
+        return points
+
+    def mutate(self, entry: Dict[str, Any], point: str, payload: str) -> Dict[str, Any]:
+        """Copy of the recorded request with `payload` in the insertion point."""
+        location, name = point.split(":", 1)
+        url, body = entry["url"], entry["body"]
+        if location == "query":
+            parts = urlsplit(url)
+            query = [(k, payload if k == name else v) for k, v in parse_qsl(parts.query, keep_blank_values=True)]
+            url = urlunsplit(parts._replace(query=urlencode(query)))
+        elif location == "form":
+            body = urlencode([(k, payload if k == name else v) for k, v in parse_qsl(body, keep_blank_values=True)])
+        elif location == "json":
+            data = json.loads(body)
+            data[name] = payload
+            body = json.dumps(data)
+        return {**entry, "url": url, "body": body}
+
+    def link(self, alerts: List[Dict[str, Any]], traffic: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """Attaches `har_entry` to alerts of other engines (ZAP) by method + path."""
+        by_path = {}
+        for entry in traffic["entries"]:
+            by_path.setdefault(urlsplit(entry["url"]).path, entry)
+            by_path.setdefault((entry["method"], urlsplit(entry["url"]).path), entry)
+        for alert in alerts:
+            path = urlsplit(alert.get("url", "")).path
+            entry = by_path.get((alert.get("method", ""), path)) or by_path.get(path)
+            if entry and "har_entry" not in alert:
+                alert["har_entry"] = self._reference(entry, traffic)
+        return alerts
+
+    # --- Internals ---
+    async def _test_entry(self, client, entry, traffic, extra_headers, budget) -> List[Dict[str, Any]]:
+        baseline = await self._send(client, entry, extra_headers, budget)
+        if baseline is None:
+            return []
+        if baseline.status_code in (401, 403) and entry.get("status") not in (401, 403):
+            budget["expired"] += 1
+            return []
+
+        alerts = []
+        for point, value in self.insertion_points(entry):
+            for check in CHECKS:
+                for payload in check["payloads"](value):
+                    response = await self._send(client, self.mutate(entry, point, payload), extra_headers, budget)
+                    if response is None:
+                        if budget["left"] <= 0:
+                            return alerts
+                        continue
+                    evidence = check["detect"](response, baseline, payload)
+                    if evidence:
+                        alerts.append(self._alert(check, entry, traffic, point, payload, evidence, response))
+                        break
+        return alerts
+
+    async def _send(self, client, request: Dict[str, Any], extra_headers, budget) -> Optional[httpx.Response]:
+        if budget["left"] <= 0:
+            return None
+        budget["left"] -= 1
+        budget["sent"] += 1
+        try:
+            return await client.request(
+                request["method"], request["url"],
+                headers={**request["headers"], **extra_headers},
+                content=request["body"].encode("utf-8") if request["body"] else None
+            )
+        except httpx.HTTPError:
+            return None
+
+    def _alert(self, check, entry, traffic, point, payload, evidence, response) -> Dict[str, Any]:
+        location, name = point.split(":", 1)
+        reference = self._reference(entry, traffic)
+        return {
+            "alert": check["alert"],
+            "risk": check["risk"],
+            "confidence": "High",
+            "description": f"{check['description']} ({location} parameter `{name}`)",
+            "solution": check["solution"],
+            "url": entry["url"],
+            "method": entry["method"],
+            "param": name,
+            "attack": payload,
+            "evidence": str(evidence)[:200],
+            "cweid": check["cweid"],
+            "other": (f"Recorded request: HAR entry #{reference['index']} in {reference['file']}"
+                      f"{' (' + reference['started'] + ')' if reference.get('started') else ''}\n"
+                      f"Replayed with {location} `{name}` = {payload!r} -> HTTP {response.status_code}")[:500],
+            "har_entry": reference,
+            "tool": "redeye-replay",
+        }
+
+    def _reference(self, entry: Dict[str, Any], traffic: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            "file": traffic["file"],
+            "index": entry["index"],
+            "method": entry["method"],
+            "url": entry["url"],
+            "started": entry.get("started"),
+            "page": entry.get("page"),
+        }
+
+
+traffic_replayer = TrafficReplayer()
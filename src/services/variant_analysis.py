@@ -0,0 +1,215 @@
+import difflib
+import math
+import os
+import re
+from collections import Counter
+from typing import List, Dict, Any, Optional
+from src.services.explanations import explanation_generator, LANGUAGES
+
+
+SKIP_DIRS = {".git", "node_modules", "vendor", "venv", ".venv", "__pycache__", "dist", "build"}
+CONTEXT_LINES = 2
+MAX_FILE_BYTES = 512 * 1024
+MAX_CANDIDATES = 500
+
+# Tokens kept verbatim when abstracting code: they carry the bug's structure
+STRUCTURAL_KEYWORDS = {
+    "go": {"func", "return", "if", "defer", "go", "range", "for", "err", "nil", "fmt", "Sprintf"},
+    "python": {"def", "return", "if", "for", "with", "f", "format", "open", "eval", "exec"},
+    "javascript": {"function", "return", "if", "await", "async", "eval", "require", "new"},
+}
+
+
+class VariantAnalyzer:
+    """
+    VariantAnalyzer hunts for variants of a confirmed finding across every cached project workspace.
+
+    1. Generalize: the sink call of the finding is kept (e.g. `.Query(`, `exec.Command(`),
+       identifiers / strings / numbers are abstracted (ID / STR / NUM), and the shape of the
+       argument (string concatenation, format call, f-string) becomes a structural query.
+    2. Search: every workspace file of the same language is matched against the structural query.
+    3. Rank: structural similarity of the abstracted token windows, combined with embedding
+       similarity of the code (OpenAI embeddings, or a local token-vector cosine as fallback);
+       candidates whose input is traced to a request source get a boost.
+    """
+
+    def generalize(self, finding: Dict[str, Any], root_dir: Optional[str] = None) -> Dict[str, Any]:
+        """Structural query for the finding: {language, sink_calls, shape, pattern, tokens, window}."""
+        file = finding.get("file") or ""
+        language = LANGUAGES.get(os.path.splitext(file)[1].lower())
+        if not language:
+            raise ValueError("Variant analysis supports Go, Python and JavaScript/TypeScript findings.")
+
+        lines, sink_index, _ = explanation_generator.code_lines(finding, root_dir)
+        if not lines or sink_index is None:
+            raise ValueError("The finding has no code to generalize.")
+        sink_line = lines[sink_index]
+        window = "\n".join(lines[max(0, sink_index - CONTEXT_LINES):sink_index + CONTEXT_LINES + 1])
+
+        calls = re.findall(r"((?:\w+\.)*\w+)\s*\(", sink_line)
+        calls = [c for c in calls if c.split(".")[-1] not in ("if", "for", "func", "return", "len", "str", "string")]
+        if not calls:
+            raise ValueError("No call found on the flagged line to use as the sink.")
+        # Package-qualified calls (exec.Command, os.path.join) keep the package; method calls keep the method only
+        sink_calls = []
+        for call in calls:
+            parts = call.split(".")
+            qualified = len(parts) > 1 and parts[0] in ("exec", "os", "subprocess", "filepath", "ioutil", "template", "pickle", "yaml", "child_process", "fs")
+            sink_calls.append(".".join(parts[-2:]) if qualified else parts[-1])
+
+        shape = self._argument_shape(window, language)
+        sink_regex = "|".join(re.escape(c) for c in dict.fromkeys(sink_calls))
+        return {
+            "language": language,
+            "sink_calls": list(dict.fromkeys(sink_calls)),
+            "shape": shape,
+            # The sink takes the tainted argument, so argument-less calls (r.URL.Query()) are not variants
+            "pattern": rf"(?:\b|\.)(?:{sink_regex})\s*\(\s*[^\s)]",
+            "tokens": self.abstract(window, language),
+            "window": window,
+        }
+
+    def hunt(
+        self,
+        finding: Dict[str, Any],
+        workspaces: List[Dict[str, str]],
+        root_dir: Optional[str] = None,
+        embed=None,
+        limit: int = 50
+    ) -> Dict[str, Any]:
+        """
+        Args:
+            workspaces: [{"repo_url", "path"}] (WorkspaceManager.list_workspaces()).
+            root_dir: workspace of the finding's own project (used to generalize and to skip the original).
+            embed: optional callable(List[str]) -> List[List[float]] for embedding similarity.
+        """
+        query = self.generalize(finding, root_dir)
+        pattern = re.compile(query["pattern"])
+        extensions = {ext for ext, lang in LANGUAGES.items() if lang == query["language"]}
+
+        candidates = []
+        for workspace in workspaces:
+            for rel_path, lines in self._files(workspace["path"], extensions):
+                if len(candidates) >= MAX_CANDIDATES:
+                    break
+                for i, line in enumerate(lines):
+                    if not pattern.search(line):
+                        continue
+                    # The original finding is not its own variant
+                    if workspace["path"] == root_dir and rel_path == finding.get("file") \
+                            and abs(i + 1 - (finding.get("line") or 0)) <= CONTEXT_LINES:
+                        continue
+                    window = "\n".join(lines[max(0, i - CONTEXT_LINES):i + CONTEXT_LINES + 1])
+                    candidates.append({
+                        "repo_url": workspace["repo_url"],
+                        "workspace": workspace["path"],
+                        "file": rel_path,
+                        "line": i + 1,
+                        "code": window,
+                        "shape_match": query["shape"] is None or self._argument_shape(window, query["language"]) == query["shape"],
+                    })
+                    if len(candidates) >= MAX_CANDIDATES:
+                        break
+
+        self._score(query, candidates, embed)
+        candidates.sort(key=lambda c: -c["score"])
+        candidates = candidates[:limit]
+        for index, candidate in enumerate(candidates):
+            candidate["id"] = f"v{index + 1}"
+            candidate["status"] = "new"
+            candidate.pop("workspace", None)
+
+        print(f"🧬 [Variants] {finding.get('alert')} ({', '.join(query['sink_calls'])}): "
+              f"{len(candidates)} candidate(s) across {len(workspaces)} project(s)")
+        return {
+            "query": {k: query[k] for k in ("language", "sink_calls", "shape", "pattern", "tokens")},
+            "projects_searched": len(workspaces),
+            "semantic": "embeddings" if embed else "token-cosine",
+            "candidates": candidates,
+        }
+
+    # --- Ranking ---
+    def _score(self, query: Dict[str, Any], candidates: List[Dict[str, Any]], embed=None):
+        if not candidates:
+            return
+        semantic = None
+        if embed:
+            try:
+                vectors = embed([query["window"]] + [c["code"] for c in candidates])
+                semantic = [self._cosine_dense(vectors[0], v) for v in vectors[1:]]
+            except Exception as e:
+                print(f"⚠️ [Variants] Embedding similarity unavailable, using token cosine: {e}")
+        if semantic is None:
+            base = Counter(re.findall(r"\w+", query["window"].lower()))
+            semantic = [self._cosine_counts(base, Counter(re.findall(r"\w+", c["code"].lower()))) for c in candidates]
+
+        for candidate, semantic_score in zip(candidates, semantic):
+            tokens = self.abstract(candidate["code"], query["language"])
+            structural = difflib.SequenceMatcher(None, query["tokens"], tokens, autojunk=False).ratio()
+            tainted = any(s["kind"] == "source" for s in explanation_generator.taint_path(
+                {"file": candidate["file"], "line": candidate["line"]}, candidate["workspace"]))
+            score = 0.6 * structural + 0.4 * semantic_score
+            score += 0.1 if tainted else 0.0
+            score -= 0.0 if candidate["shape_match"] else 0.2
+            candidate.update({
+                "structural": round(structural, 3),
+                "semantic": round(semantic_score, 3),
+                "tainted": tainted,
+                "score": round(max(0.0, min(1.0, score)), 3),
+            })
+
+    def abstract(self, code: str, language: str) -> List[str]:
+        """Token sequence with identifiers, strings and numbers abstracted (calls keep their name)."""
+        keep = STRUCTURAL_KEYWORDS.get(language, set())
+        tokens = re.findall(r"\"(?:[^\"\\]|\\.)*\"|'(?:[^'\\]|\\.)*'|`[^`]*`|\w+|:=|[+%]=?|[^\s\w]", code)
+        abstracted = []
+        for i, token in enumerate(tokens):
+            if token[0] in "\"'`":
+                abstracted.append("STR")
+            elif token.isdigit():
+                abstracted.append("NUM")
+            elif re.match(r"\w+$", token):
+                is_call = i + 1 < len(tokens) and tokens[i + 1] == "("
+                abstracted.append(token if is_call or token in keep else "ID")
+            else:
+                abstracted.append(token)
+        return abstracted
+
+    def _argument_shape(self, code: str, language: str) -> Optional[str]:
+        """How input is built into the sink argument: concat / format / fstring / template, or None."""
+        if re.search(r"\bf[\"']", code) and language == "python":
+            return "fstring"
+        if re.search(r"`[^`]*\$\{", code) and language == "javascript":
+            return "template"
+        if re.search(r"Sprintf\(|\.format\(|%\s*\(|\"\s*%\s*\w", code):
+            return "format"
+        if re.search(r"[\"'`]\s*\+|\+\s*[\"'`]", code):
+            return "concat"
+        return None
+
+    def _cosine_counts(self, a: Counter, b: Counter) -> float:
+        dot = sum(a[k] * b[k] for k in a.keys() & b.keys())
+        norm = math.sqrt(sum(v * v for v in a.values())) * math.sqrt(sum(v * v for v in b.values()))
+        return dot / norm if norm else 0.0
+
+    def _cosine_dense(self, a: List[float], b: List[float]) -> float:
+        norm = math.sqrt(sum(x * x for x in a)) * math.sqrt(sum(y * y for y in b))
+        return max(0.0, sum(x * y for x, y in zip(a, b)) / norm) if norm else 0.0
+
+    def _files(self, root_dir: str, extensions: set):
+        for current_root, dirs, files in os.walk(root_dir):
+            dirs[:] = [d for d in dirs if d not in SKIP_DIRS]
+            for name in files:
+                if os.path.splitext(name)[1].lower() not in extensions:
+                    continue
+                path = os.path.join(current_root, name)
+                try:
+                    if os.path.getsize(path) > MAX_FILE_BYTES:
+                        continue
+                    with open(path, "r", encoding="utf-8", errors="ignore") as f:
+                        yield os.path.relpath(path, root_dir), f.read().split("\n")
+                except OSError:
+                    continue
+
+
+variant_analyzer = VariantAnalyzer()
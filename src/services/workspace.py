@@ -0,0 +1,239 @@
+import asyncio
+import hashlib
+import os
+import re
+import shutil
+import tempfile
+import threading
+import urllib.parse
+from contextlib import contextmanager, asynccontextmanager, ExitStack
+from typing import List, Dict, Optional
+from git import Repo
+from src.config import settings
+
+
+def org_of(target: str) -> str:
+    """Organization a scan target belongs to: the GitHub owner, or the host of a web target."""
+    parsed = urllib.parse.urlparse(target if "://" in target else f"https://{target}")
+    if parsed.netloc.endswith("github.com"):
+        parts = [p for p in parsed.path.split("/") if p]
+        if parts:
+            return parts[0].lower()
+    return parsed.netloc.lower() or target
+
+
+class ReadWriteLock:
+    """
+    Many readers or one writer. Writers wait for the readers to leave; new readers queue
+    behind a waiting writer so a busy workspace still gets refreshed. Not reentrant, and
+    not bound to a thread (an async request may acquire and release from different threads).
+    """
+    def __init__(self):
+        self._cond = threading.Condition()
+        self._readers = 0
+        self._writer = False
+        self._writers_waiting = 0
+
+    def acquire_read(self):
+        with self._cond:
+            while self._writer or self._writers_waiting:
+                self._cond.wait()
+            self._readers += 1
+
+    def release_read(self):
+        with self._cond:
+            self._readers -= 1
+            if not self._readers:
+                self._cond.notify_all()
+
+    def acquire_write(self):
+        with self._cond:
+            self._writers_waiting += 1
+            while self._writer or self._readers:
+                self._cond.wait()
+            self._writers_waiting -= 1
+            self._writer = True
+
+    def release_write(self):
+        with self._cond:
+            self._writer = False
+            self._cond.notify_all()
+
+    def downgrade(self):
+        """Writer → reader without letting another writer in between."""
+        with self._cond:
+            self._writer = False
+            self._readers += 1
+            self._cond.notify_all()
+
+
+class WorkspaceManager:
+    """
+    WorkspaceManager keeps a cached clone per repository under WORKSPACE_DIR so that
+    the phases of a scan (attack surface, SAST, reachability, fuzzing, fix verification)
+    share one checkout instead of cloning again each time.
+
+    Workspaces are read-only inputs: anything that executes project code copies the
+    workspace into a Sandbox first. Every user holds the workspace's read lock for as long
+    as it reads files (`checked_out` / `reading` / `use`); refreshing (fetch + reset + clean)
+    and removal take the write lock, so a clone is never rewritten under a running reader.
+    """
+    def __init__(self, root: Optional[str] = None):
+        self.root = root or settings.WORKSPACE_DIR or os.path.join(tempfile.gettempdir(), "redeye-workspaces")
+        self._locks: Dict[str, ReadWriteLock] = {}
+        self._locks_guard = threading.Lock()
+
+    def path_for(self, repo_url: str) -> str:
+        """Stable directory name: <owner>__<repo>-<short hash of the URL>."""
+        normalized = repo_url.rstrip("/").removesuffix(".git")
+        readable = re.sub(r"[^A-Za-z0-9_.-]+", "__", "/".join(normalized.split("/")[-2:]))
+        digest = hashlib.sha1(normalized.encode("utf-8")).hexdigest()[:8]
+        return os.path.join(self.root, f"{readable}-{digest}")
+
+    def _lock_for(self, path: str) -> ReadWriteLock:
+        with self._locks_guard:
+            return self._locks.setdefault(path, ReadWriteLock())
+
+    @contextmanager
+    def checked_out(self, repo_url: str, refresh: bool = True):
+        """
+        Yields the local path of an up-to-date shallow clone of repo_url and keeps it
+        read-locked until the block exits. Existing workspaces are fetched and hard-reset
+        to the remote HEAD when refresh=True.
+        """
+        path = self.path_for(repo_url)
+        lock = self._lock_for(path)
+        if not refresh:
+            lock.acquire_read()
+            if self._is_clone(path):
+                try:
+                    yield path
+                finally:
+                    lock.release_read()
+                return
+            lock.release_read()
+
+        lock.acquire_write()
+        try:
+            self._refresh_or_clone(repo_url, path, refresh)
+        except BaseException:
+            lock.release_write()
+            raise
+        lock.downgrade()
+        try:
+            yield path
+        finally:
+            lock.release_read()
+
+    @contextmanager
+    def reading(self, target: str):
+        """Read-locks the existing workspace of a GitHub target for the block. Yields None if there is none."""
+        if "github.com" not in target:
+            yield None
+            return
+        path = self.path_for(target)
+        lock = self._lock_for(path)
+        lock.acquire_read()
+        try:
+            yield path if self._is_clone(path) else None
+        finally:
+            lock.release_read()
+
+    @contextmanager
+    def reading_all(self):
+        """Read-locks every cached workspace for the block. Yields list_workspaces()."""
+        with ExitStack() as stack:
+            workspaces = []
+            for workspace in self.list_workspaces():
+                lock = self._lock_for(workspace["path"])
+                lock.acquire_read()
+                stack.callback(lock.release_read)
+                if self._is_clone(workspace["path"]):
+                    workspaces.append(workspace)
+            yield workspaces
+
+    @asynccontextmanager
+    async def use(self, target: str, checkout: bool = False, refresh: bool = True):
+        """
+        Async form of `reading` (or of `checked_out` with checkout=True) for request handlers:
+        waiting for the lock and cloning happen in a worker thread, off the event loop.
+        """
+        manager = self.checked_out(target, refresh) if checkout else self.reading(target)
+        path = await asyncio.to_thread(manager.__enter__)
+        try:
+            yield path
+        finally:
+            manager.__exit__(None, None, None)
+
+    @asynccontextmanager
+    async def use_all(self):
+        """Async form of `reading_all`."""
+        manager = self.reading_all()
+        workspaces = await asyncio.to_thread(manager.__enter__)
+        try:
+            yield workspaces
+        finally:
+            manager.__exit__(None, None, None)
+
+    def _is_clone(self, path: str) -> bool:
+        return os.path.isdir(os.path.join(path, ".git"))
+
+    def _refresh_or_clone(self, repo_url: str, path: str, refresh: bool):
+        """Caller holds the write lock."""
+        if self._is_clone(path):
+            if not refresh:
+                return
+            try:
+                repo = Repo(path)
+                repo.remotes.origin.fetch(depth=1)
+                repo.git.reset("--hard", "FETCH_HEAD")
+                repo.git.clean("-fdx")
+                print(f"🔄 [Workspace] Refreshed {repo_url}")
+                return
+            except Exception as e:
+                print(f"⚠️ [Workspace] Refresh failed, re-cloning {repo_url}: {e}")
+                shutil.rmtree(path, ignore_errors=True)
+        self._clone(repo_url, path)
+
+    def _clone(self, repo_url: str, path: str) -> str:
+        os.makedirs(self.root, exist_ok=True)
+        print(f"📦 [Workspace] Cloning {repo_url} -> {path}")
+        Repo.clone_from(repo_url, path, depth=1)
+        return path
+
+    def get(self, repo_url: str) -> Optional[str]:
+        """
+        Path of an existing workspace, or None if the repo was never cloned.
+        Unlocked: only for checking existence; read files inside `reading` / `use`.
+        """
+        path = self.path_for(repo_url)
+        return path if self._is_clone(path) else None
+
+    def list_workspaces(self) -> List[Dict[str, str]]:
+        """All cached workspaces with their origin URL."""
+        workspaces = []
+        if not os.path.isdir(self.root):
+            return workspaces
+        for name in sorted(os.listdir(self.root)):
+            path = os.path.join(self.root, name)
+            if not os.path.isdir(os.path.join(path, ".git")):
+                continue
+            try:
+                repo_url = Repo(path).remotes.origin.url
+            except Exception:
+                continue
+            workspaces.append({"repo_url": repo_url, "path": path})
+        return workspaces
+
+    def remove(self, repo_url: str):
+        path = self.path_for(repo_url)
+        lock = self._lock_for(path)
+        lock.acquire_write()
+        try:
+            shutil.rmtree(path, ignore_errors=True)
+            print(f"🧹 [Workspace] Removed {path}")
+        finally:
+            lock.release_write()
+
+
+workspace_manager = WorkspaceManager()